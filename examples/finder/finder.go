@@ -1,27 +1,67 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	sched "gopcpv2-web-spider/scheduler"
 	"gopcpv2-web-spider/examples/finder/internal"
 	"gopcpv2-web-spider/examples/finder/monitor"
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/remote"
+	"net/http"
+	"gopcpv2-web-spider/toolkit/publicsuffix"
 	"strings"
 	"time"
-	"net/http"
 )
 
 var firstURL string
 var domains string
 var depth uint
 var dirPath string
+var checkpointDir string
+var resumeDir string
+var obeyRobots bool
+var strategy string
+var seenSet string
+var seenDir string
+var pslFile string
+var excludes string
+var followOffsite bool
+var seedFile string
+var sitemaps string
+var balancer string
+var breakerFailures uint
+var remoteModules string
+var maxRetries uint
+var adaptive bool
+var redirectMode string
+var maxRedirects uint
 
 func init(){
-	flag.StringVar(&firstURL, "first", "http://zhihu.sogou.com/zhihu?query=golang+logo", "请输入入口URL：")
+	flag.StringVar(&firstURL, "first", "http://zhihu.sogou.com/zhihu?query=golang+logo", "请输入入口URL，多个URL以“,”分隔：")
+	flag.StringVar(&seedFile, "seed-file", "", "请输入种子文件（每行一个URL）：")
+	flag.StringVar(&sitemaps, "sitemap", "", "请输入站点地图的URL或文件路径，多个以“,”分隔：")
 	flag.StringVar(&domains, "domain", "zhihu.com", "请输入允许抓取的HOST列表：")
 	flag.UintVar(&depth, "depth", 3, "请输入抓取深度：")
 	flag.StringVar(&dirPath, "dir", "./pic", "请输入存放目录：")
+	flag.StringVar(&checkpointDir, "checkpoint", "", "请输入检查点目录（为空则不写入检查点）：")
+	flag.StringVar(&resumeDir, "resume", "", "请输入要恢复的检查点目录：")
+	flag.BoolVar(&obeyRobots, "robots", true, "是否遵守robots.txt：")
+	flag.StringVar(&strategy, "strategy", "bfs", "请输入爬取顺序的策略（bfs、dfs或host_round_robin）：")
+	flag.StringVar(&seenSet, "seen", "map", "请输入已见URL集合的类型（map、bloom或disk）：")
+	flag.StringVar(&seenDir, "seen-dir", "./seen", "请输入磁盘集合的存放目录：")
+	flag.StringVar(&excludes, "exclude", "", "请输入需要排除的URL的正则表达式，以“,”分隔：")
+	flag.BoolVar(&followOffsite, "offsite", false, "是否跟随站外链接一跳：")
+	flag.StringVar(&balancer, "balancer", "", "请输入下载器的负载均衡策略（least_score、round_robin、weighted_random、least_in_flight或power_of_two）：")
+	flag.UintVar(&breakerFailures, "breaker", 5, "请输入使组件熔断的连续失败次数（为0则不使用熔断器）：")
+	flag.UintVar(&maxRetries, "retries", 3, "请输入下载失败的请求的最大重试次数（为0则不重试）：")
+	flag.BoolVar(&adaptive, "adaptive", true, "是否按主机自适应地调整并发数量和请求间隔：")
+	flag.StringVar(&redirectMode, "redirect", "follow", "请输入处理重定向的方式（follow或emit）：")
+	flag.UintVar(&maxRedirects, "max-redirects", 10, "请输入每个请求最多经历的重定向次数：")
+	flag.StringVar(&remoteModules, "remote", "", "请输入组件服务器上的组件的MID，多个以“,”分隔（例如D1|127.0.0.1:8080）：")
+	flag.StringVar(&pslFile, "psl", "", "请输入公共后缀列表文件（为空则使用内置的列表）：")
 }
 
 func Usage(){
@@ -34,6 +74,12 @@ func Usage(){
 func main(){
 	flag.Usage = Usage
 	flag.Parse()
+	if pslFile != ""{
+		if err := publicsuffix.LoadFile(pslFile); err != nil{
+			fmt.Println("加载公共后缀列表失败", err.Error())
+			return
+		}
+	}
 	//创建调度器
 	scheduler := sched.NewScheduler()
 	domainParts := strings.Split(domains, ",")
@@ -44,6 +90,16 @@ func main(){
 			acceptedDomains = append(acceptedDomains, domain)
 		}
 	}
+	scopeArgs := sched.ScopeArgs{FollowOffsite: followOffsite}
+	for _, pattern := range strings.Split(excludes, ","){
+		pattern = strings.TrimSpace(pattern)
+		if pattern != ""{
+			scopeArgs.Rules = append(scopeArgs.Rules, sched.ScopeRule{
+				Action: sched.SCOPE_ACTION_EXCLUDE,
+				Regexp: pattern,
+			})
+		}
+	}
 	dataArgs := sched.DataArgs{
 		ReqBufferCap: 50,
 		ReqMaxBufferNumber: 1000,
@@ -53,10 +109,27 @@ func main(){
 		ItemMaxBufferNumber: 100,
 		ErrorBufferCap: 50,
 		ErrorMaxBufferNumber: 1,
+		CheckpointDir: checkpointDir,
+		CheckpointInterval: 30 * time.Second,
+		SeenSet: sched.SeenSetArgs{
+			Type: sched.SeenSetType(seenSet),
+			Dir: seenDir,
+		},
 	}
 	requestArgs := sched.RequestArgs{
 		AcceptedDomains: acceptedDomains,
 		MaxDepth: uint32(depth),
+		ObeyRobots: obeyRobots,
+		RobotsUserAgent: "finder",
+		FrontierStrategy: sched.FrontierStrategy(strategy),
+		Canonical: sched.CanonicalArgs{IgnoredParams: sched.DefaultIgnoredParams},
+		Scope: scopeArgs,
+		Retry: sched.RetryArgs{MaxRetries: uint32(maxRetries)},
+		Adaptive: sched.AdaptiveArgs{Enabled: adaptive},
+		Redirect: sched.RedirectArgs{
+			Mode: sched.RedirectMode(redirectMode),
+			MaxHops: uint32(maxRedirects),
+		},
 	}
 	downloader, err := internal.GetDownloaders(1)
 	if err != nil{
@@ -74,6 +147,12 @@ func main(){
 		Downloaders:downloader,
 		Analyzers:analyzers,
 		Pipelines:pipelines,
+		Balancers: sched.BalancerArgs{Downloader: module.BalanceStrategy(balancer)},
+		Breaker: module.BreakerArgs{FailureThreshold: uint32(breakerFailures)},
+	}
+	if err = addRemoteModules(&moduleArgs); err != nil{
+		fmt.Println("创建远程组件失败", err.Error())
+		return
 	}
 	err = scheduler.Init(requestArgs, dataArgs, moduleArgs)
 	if err != nil{
@@ -82,19 +161,78 @@ func main(){
 	//监控
 	checkInterval := time.Second
 	summarizeInterval := 100 * time.Microsecond
-	maxIdleCount := uint(5)
-	checkCountChan := monitor.Monitor(scheduler, checkInterval, summarizeInterval, maxIdleCount, true, internal.Record)
+	checkCountChan := monitor.Monitor(scheduler, checkInterval, summarizeInterval, true, internal.Record)
 	//启动调度器
-	firstHTTPReq, err := http.NewRequest("GET", firstURL, nil)
-	if err != nil{
-		fmt.Println(err)
-		return
+	if resumeDir != ""{
+		err = scheduler.ResumeFrom(resumeDir)
+	}else{
+		var seeds []sched.Seed
+		seeds, err = loadSeeds()
+		if err != nil{
+			fmt.Println("加载种子失败", err.Error())
+			return
+		}
+		err = scheduler.StartSeeds(seeds...)
 	}
-	err = scheduler.Start(firstHTTPReq)
 	if err != nil{
 		fmt.Println("启动调度器发生错误：", err.Error())
 		return
 	}
 	//等待监控结束
 	<-checkCountChan
-}
\ No newline at end of file
+}
+
+//加载命令行参数给出的所有种子
+func loadSeeds()([]sched.Seed, error){
+	var seeds []sched.Seed
+	for _, rawURL := range strings.Split(firstURL, ","){
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == ""{
+			continue
+		}
+		seed, err := sched.NewSeed(rawURL, 0)
+		if err != nil{
+			return nil, err
+		}
+		seeds = append(seeds, seed)
+	}
+	var providers []sched.SeedProvider
+	if seedFile != ""{
+		providers = append(providers, sched.NewFileSeedProvider(seedFile, 0))
+	}
+	for _, location := range strings.Split(sitemaps, ","){
+		location = strings.TrimSpace(location)
+		if location != ""{
+			providers = append(providers, sched.NewSitemapSeedProvider(location, 0, nil))
+		}
+	}
+	more, err := sched.LoadSeeds(context.Background(), providers...)
+	if err != nil{
+		return nil, err
+	}
+	return append(seeds, more...), nil
+}
+
+//为命令行参数给出的每个远程组件创建代理并加入组件参数
+func addRemoteModules(moduleArgs *sched.ModuleArgs)error{
+	client := &http.Client{Timeout: time.Minute}
+	for _, mid := range strings.Split(remoteModules, ","){
+		mid = strings.TrimSpace(mid)
+		if mid == ""{
+			continue
+		}
+		m, err := remote.New(module.MID(mid), "http", client, module.CalculateScoreByHealth)
+		if err != nil{
+			return err
+		}
+		switch m := m.(type){
+		case module.Downloader:
+			moduleArgs.Downloaders = append(moduleArgs.Downloaders, m)
+		case module.Analyzer:
+			moduleArgs.Analyzers = append(moduleArgs.Analyzers, m)
+		case module.Pipeline:
+			moduleArgs.Pipelines = append(moduleArgs.Pipelines, m)
+		}
+	}
+	return nil
+}
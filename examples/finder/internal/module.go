@@ -19,7 +19,7 @@ func GetDownloaders(number uint8)([]module.Downloader, error){
 		if err != nil{
 			return downloaders, nil
 		}
-		d, err := downloader.New(mid, genHttpClient(), module.CalculateScoreSimple)
+		d, err := downloader.New(mid, genHttpClient(), module.CalculateScoreByHealth)
 		if err != nil{
 			return downloaders, nil
 		}
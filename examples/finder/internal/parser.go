@@ -66,7 +66,9 @@ func genResponsePasers()[]module.ParseResponse{
 				if err != nil{
 					errList = append(errList, err)
 				}else{
-					req := module.NewRequest(httpReq, respDepth)
+					req := module.NewRequest(httpReq, respDepth).WithMeta(module.Meta{
+						AnchorText: strings.TrimSpace(sel.Text()),
+					})
 					dataList = append(dataList, req)
 				}
 			},
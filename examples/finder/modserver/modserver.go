@@ -0,0 +1,88 @@
+// modserver 是一个组件服务器，它通过HTTP+JSON向其他进程中的调度器提供finder的组件。
+// 调度器可以使用带有该服务器地址的MID创建组件代理，例如：
+//
+//	modserver -addr 127.0.0.1:8080 -downloaders 2
+//	finder -remote "D1|127.0.0.1:8080,D2|127.0.0.1:8080"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"gopcpv2-web-spider/examples/finder/internal"
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/remote"
+)
+
+var addr string
+var downloaderNumber uint
+var analyzerNumber uint
+var pipelineNumber uint
+var dirPath string
+
+func init() {
+	flag.StringVar(&addr, "addr", "127.0.0.1:8080", "请输入监听的地址（IP:端口）：")
+	flag.UintVar(&downloaderNumber, "downloaders", 1, "请输入下载器的数量：")
+	flag.UintVar(&analyzerNumber, "analyzers", 1, "请输入分析器的数量：")
+	flag.UintVar(&pipelineNumber, "pipelines", 0, "请输入条目处理管道的数量：")
+	flag.StringVar(&dirPath, "dir", "./pic", "请输入条目处理管道的存放目录：")
+}
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "%s的用法：\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\tmodserver [flags] \n")
+	fmt.Fprintf(os.Stderr, "Flags: \n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+	modules, err := createModules()
+	if err != nil {
+		fmt.Println("创建组件失败", err.Error())
+		return
+	}
+	server, err := remote.NewServer(modules...)
+	if err != nil {
+		fmt.Println("创建组件服务器失败", err.Error())
+		return
+	}
+	for _, key := range server.Modules() {
+		log.Printf("提供组件：%s|%s", key, addr)
+	}
+	log.Printf("组件服务器已启动：%s", addr)
+	if err := http.ListenAndServe(addr, server); err != nil {
+		fmt.Println("组件服务器发生错误：", err.Error())
+	}
+}
+
+// createModules 用于按照命令行参数创建所有的本地组件。
+func createModules() ([]module.Module, error) {
+	var modules []module.Module
+	downloaders, err := internal.GetDownloaders(uint8(downloaderNumber))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range downloaders {
+		modules = append(modules, d)
+	}
+	analyzers, err := internal.GetAnalyzer(uint8(analyzerNumber))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range analyzers {
+		modules = append(modules, a)
+	}
+	pipelines, err := internal.GetPipeline(uint8(pipelineNumber), dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pipelines {
+		modules = append(modules, p)
+	}
+	return modules, nil
+}
@@ -11,7 +11,7 @@ import (
 
 type Record func(level uint8, content string)
 
-func Monitor(scheduler sched.Scheduler, checkInterval time.Duration, summarizeInterval time.Duration, maxIdleCount uint, autoStop bool, record Record) <-chan uint64{
+func Monitor(scheduler sched.Scheduler, checkInterval time.Duration, summarizeInterval time.Duration, autoStop bool, record Record) <-chan uint64{
 	if scheduler == nil{
 		panic(errors.New("调度器不可用"))
 	}
@@ -21,9 +21,6 @@ func Monitor(scheduler sched.Scheduler, checkInterval time.Duration, summarizeIn
 	if summarizeInterval < time.Second{
 		summarizeInterval = time.Second
 	}
-	if maxIdleCount < 10 {
-		maxIdleCount = 10
-	}
 	stopNotifier, stopFunc := context.WithCancel(context.Background())
 	// 接收和报告错误。
 	reportError(scheduler, record, stopNotifier)
@@ -31,19 +28,19 @@ func Monitor(scheduler sched.Scheduler, checkInterval time.Duration, summarizeIn
 	recordSummary(scheduler, summarizeInterval, record, stopNotifier)
 	// 检查计数通道
 	checkCountChan := make(chan uint64, 2)
-	// 检查空闲状态
-	checkStatus(scheduler, checkInterval, maxIdleCount, autoStop,
+	// 检查完成状态
+	checkStatus(scheduler, checkInterval, autoStop,
 		checkCountChan, record, stopFunc)
 	return checkCountChan
 }
 
-var msgReachMaxIdleCount = "调度器已经长时间空闲 (about %s)." + " ，准备关闭"
+var msgSchedulerDone = "调度器已经没有需要处理的数据了 (about %s)." + " ，准备关闭"
 
 // msgStopScheduler 代表停止调度器的消息模板。
 var msgStopScheduler = "正在停止调度器...%s."
 
 func checkStatus(scheduler sched.Scheduler, checkInterval time.Duration,
-	maxIdleCount uint, autoStop bool, checkCountChan chan<- uint64,
+	autoStop bool, checkCountChan chan<- uint64,
 	record Record, stopFunc context.CancelFunc) {
 	go func() {
 		var checkCount uint64
@@ -54,42 +51,32 @@ func checkStatus(scheduler sched.Scheduler, checkInterval time.Duration,
 		// 等待调度器开启。
 		waitForSchedulerStart(scheduler)
 		// 准备。
-		var idleCount uint
-		var firstIdleTime time.Time
+		startTime := time.Now()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
 		for {
-			// 检查调度器的空闲状态。
-			if scheduler.Idle() {
-				idleCount++
-				if idleCount == 1 {
-					firstIdleTime = time.Now()
+			select {
+			case <-scheduler.Done():
+				// 调度器已被从外部停止时无需再做什么。
+				status := scheduler.Status()
+				if status != sched.SCHED_STATUS_STARTED &&
+					status != sched.SCHED_STATUS_PAUSED {
+					return
 				}
-				if idleCount >= maxIdleCount {
-					record(0, fmt.Sprintf(msgReachMaxIdleCount, time.Since(firstIdleTime).String()))
-					// 再次检查调度器的空闲状态，确保它已经可以被停止。
-					if scheduler.Idle() {
-						if autoStop {
-							var result string
-							if err := scheduler.Stop(); err == nil {
-								result = "成功"
-							} else {
-								result = fmt.Sprintf("失败(%s)", err)
-							}
-							record(0, fmt.Sprintf(msgStopScheduler, result))
-						}
-						break
+				record(0, fmt.Sprintf(msgSchedulerDone, time.Since(startTime).String()))
+				if autoStop {
+					var result string
+					if err := scheduler.Stop(); err == nil {
+						result = "成功"
 					} else {
-						if idleCount > 0 {
-							idleCount = 0
-						}
+						result = fmt.Sprintf("失败(%s)", err)
 					}
+					record(0, fmt.Sprintf(msgStopScheduler, result))
 				}
-			} else {
-				if idleCount > 0 {
-					idleCount = 0
-				}
+				return
+			case <-ticker.C:
+				checkCount++
 			}
-			checkCount++
-			time.Sleep(checkInterval)
 		}
 	}()
 }
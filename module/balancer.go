@@ -0,0 +1,228 @@
+package module
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/errors"
+)
+
+// BalanceStrategy 代表负载均衡策略的类型。
+type BalanceStrategy string
+
+const (
+	// BALANCE_LEAST_SCORE 代表选择评分最低的组件，评分相同时按MID的顺序选择。
+	BALANCE_LEAST_SCORE BalanceStrategy = "least_score"
+	// BALANCE_ROUND_ROBIN 代表按权重依次轮流选择组件（平滑加权轮询）。
+	BALANCE_ROUND_ROBIN BalanceStrategy = "round_robin"
+	// BALANCE_WEIGHTED_RANDOM 代表按权重随机选择组件。
+	BALANCE_WEIGHTED_RANDOM BalanceStrategy = "weighted_random"
+	// BALANCE_LEAST_IN_FLIGHT 代表选择正在处理的数量与权重之比最小的组件。
+	BALANCE_LEAST_IN_FLIGHT BalanceStrategy = "least_in_flight"
+	// BALANCE_POWER_OF_TWO 代表随机选出两个组件，再选择其中正在处理的数量与权重之比较小的一个。
+	BALANCE_POWER_OF_TWO BalanceStrategy = "power_of_two"
+)
+
+// DefaultBalanceStrategy 代表默认的负载均衡策略。
+const DefaultBalanceStrategy = BALANCE_LEAST_SCORE
+
+// DefaultWeight 代表组件的默认权重。
+const DefaultWeight uint32 = 1
+
+// Candidate 代表负载均衡时的候选组件。
+type Candidate struct {
+	// Module 代表组件实例。
+	Module Module
+	// Weight 代表组件注册时的权重，不会为0。
+	Weight uint32
+}
+
+// Balancer 代表负载均衡器的接口类型。它的实现类型需要是并发安全的。
+type Balancer interface {
+	// Strategy 用于获取负载均衡策略。
+	Strategy() BalanceStrategy
+	// Select 用于从给定的候选组件中选出一个。
+	// 参数candidates不会为空，并且已按照MID排序。
+	Select(candidates []Candidate) Module
+}
+
+// NewBalancer 用于根据给定的策略创建一个负载均衡器。参数strategy为空时使用默认策略。
+func NewBalancer(strategy BalanceStrategy) (Balancer, error) {
+	switch strategy {
+	case "", BALANCE_LEAST_SCORE:
+		return leastScoreBalancer{}, nil
+	case BALANCE_ROUND_ROBIN:
+		return &roundRobinBalancer{current: map[MID]int64{}}, nil
+	case BALANCE_WEIGHTED_RANDOM:
+		return &weightedRandomBalancer{random: newLockedRand()}, nil
+	case BALANCE_LEAST_IN_FLIGHT:
+		return &leastInFlightBalancer{random: newLockedRand()}, nil
+	case BALANCE_POWER_OF_TWO:
+		return &powerOfTwoBalancer{random: newLockedRand()}, nil
+	}
+	return nil, errors.NewIllegalParameterError(
+		fmt.Sprintf("unknown balance strategy: %q", strategy))
+}
+
+// lockedRand 代表并发安全的随机数生成器。
+type lockedRand struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	return &lockedRand{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Int63n 用于获取[0, n)之间的随机数。
+func (lr *lockedRand) Int63n(n int64) int64 {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	return lr.rand.Int63n(n)
+}
+
+// leastScoreBalancer 代表选择评分最低的组件的负载均衡器。
+type leastScoreBalancer struct{}
+
+func (leastScoreBalancer) Strategy() BalanceStrategy {
+	return BALANCE_LEAST_SCORE
+}
+
+func (leastScoreBalancer) Select(candidates []Candidate) Module {
+	var selected Module
+	var minScore uint64
+	for _, c := range candidates {
+		SetScore(c.Module)
+		score := c.Module.Score()
+		if selected == nil || score < minScore {
+			selected = c.Module
+			minScore = score
+		}
+	}
+	return selected
+}
+
+// roundRobinBalancer 代表平滑加权轮询的负载均衡器。
+// 每次选择时各组件的当前值都会增加其权重，当前值最大的组件被选中，
+// 然后它的当前值减去总权重。这样权重较大的组件被选中的次数较多，而且不会连续地被选中。
+type roundRobinBalancer struct {
+	lock    sync.Mutex
+	current map[MID]int64
+}
+
+func (b *roundRobinBalancer) Strategy() BalanceStrategy {
+	return BALANCE_ROUND_ROBIN
+}
+
+func (b *roundRobinBalancer) Select(candidates []Candidate) Module {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var total int64
+	var selected *Candidate
+	present := make(map[MID]bool, len(candidates))
+	for i, c := range candidates {
+		mid := c.Module.ID()
+		present[mid] = true
+		b.current[mid] += int64(c.Weight)
+		total += int64(c.Weight)
+		if selected == nil || b.current[mid] > b.current[selected.Module.ID()] {
+			selected = &candidates[i]
+		}
+	}
+	// 清除已注销的组件的状态。
+	for mid := range b.current {
+		if !present[mid] {
+			delete(b.current, mid)
+		}
+	}
+	b.current[selected.Module.ID()] -= total
+	return selected.Module
+}
+
+// weightedRandomBalancer 代表按权重随机选择组件的负载均衡器。
+type weightedRandomBalancer struct {
+	random *lockedRand
+}
+
+func (b *weightedRandomBalancer) Strategy() BalanceStrategy {
+	return BALANCE_WEIGHTED_RANDOM
+}
+
+func (b *weightedRandomBalancer) Select(candidates []Candidate) Module {
+	var total int64
+	for _, c := range candidates {
+		total += int64(c.Weight)
+	}
+	n := b.random.Int63n(total)
+	for _, c := range candidates {
+		n -= int64(c.Weight)
+		if n < 0 {
+			return c.Module
+		}
+	}
+	return candidates[len(candidates)-1].Module
+}
+
+// lessLoaded 用于判断组件a的负载是否低于组件b，即正在处理的数量与权重之比是否更小。
+// 结果值equal代表两者的负载是否相同。
+func lessLoaded(a, b Candidate) (less bool, equal bool) {
+	// 交叉相乘以避免除法的精度问题。
+	la := a.Module.HandlingNumber() * uint64(b.Weight)
+	lb := b.Module.HandlingNumber() * uint64(a.Weight)
+	return la < lb, la == lb
+}
+
+// leastInFlightBalancer 代表选择负载最低的组件的负载均衡器，负载相同时随机选择。
+type leastInFlightBalancer struct {
+	random *lockedRand
+}
+
+func (b *leastInFlightBalancer) Strategy() BalanceStrategy {
+	return BALANCE_LEAST_IN_FLIGHT
+}
+
+func (b *leastInFlightBalancer) Select(candidates []Candidate) Module {
+	var best []Candidate
+	for _, c := range candidates {
+		if len(best) == 0 {
+			best = append(best, c)
+			continue
+		}
+		less, equal := lessLoaded(c, best[0])
+		switch {
+		case less:
+			best = append(best[:0], c)
+		case equal:
+			best = append(best, c)
+		}
+	}
+	return best[b.random.Int63n(int64(len(best)))].Module
+}
+
+// powerOfTwoBalancer 代表“两次随机选择”的负载均衡器。
+// 它只需比较两个组件，在组件较多时开销较小，同时能避免所有请求都涌向同一个组件。
+type powerOfTwoBalancer struct {
+	random *lockedRand
+}
+
+func (b *powerOfTwoBalancer) Strategy() BalanceStrategy {
+	return BALANCE_POWER_OF_TWO
+}
+
+func (b *powerOfTwoBalancer) Select(candidates []Candidate) Module {
+	n := int64(len(candidates))
+	if n == 1 {
+		return candidates[0].Module
+	}
+	i := b.random.Int63n(n)
+	j := b.random.Int63n(n - 1)
+	if j >= i {
+		j++
+	}
+	if less, _ := lessLoaded(candidates[j], candidates[i]); less {
+		return candidates[j].Module
+	}
+	return candidates[i].Module
+}
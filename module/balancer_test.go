@@ -0,0 +1,194 @@
+package module
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// loadModule 代表可以设定正在处理的数量的仿造组件。
+type loadModule struct {
+	Module
+	handling uint64
+}
+
+func (lm *loadModule) HandlingNumber() uint64 {
+	return lm.handling
+}
+
+// genCandidates 用于生成一组候选组件，其权重和正在处理的数量由参数给定。
+func genCandidates(weights []uint32, handlings []uint64) []Candidate {
+	candidates := make([]Candidate, len(weights))
+	for i, weight := range weights {
+		mid := MID(fmt.Sprintf("D%d", i+1))
+		candidates[i] = Candidate{
+			Module: &loadModule{
+				Module:   NewFakeDownloader(mid, CalculateScoreSimple),
+				handling: handlings[i],
+			},
+			Weight: weight,
+		}
+	}
+	return candidates
+}
+
+// countSelections 用于统计多次选择中各组件被选中的次数。
+func countSelections(balancer Balancer, candidates []Candidate, times int) map[MID]int {
+	counts := map[MID]int{}
+	for i := 0; i < times; i++ {
+		counts[balancer.Select(candidates).ID()]++
+	}
+	return counts
+}
+
+func TestNewBalancer(t *testing.T) {
+	strategies := []BalanceStrategy{
+		BALANCE_LEAST_SCORE,
+		BALANCE_ROUND_ROBIN,
+		BALANCE_WEIGHTED_RANDOM,
+		BALANCE_LEAST_IN_FLIGHT,
+		BALANCE_POWER_OF_TWO,
+	}
+	for _, strategy := range strategies {
+		balancer, err := NewBalancer(strategy)
+		if err != nil {
+			t.Fatalf("An error occurs when creating balancer: %s (strategy: %s)",
+				err, strategy)
+		}
+		if balancer.Strategy() != strategy {
+			t.Fatalf("Inconsistent balance strategy: expected: %s, actual: %s",
+				strategy, balancer.Strategy())
+		}
+		// 只有一个候选组件时总是选择它。
+		candidates := genCandidates([]uint32{1}, []uint64{5})
+		if m := balancer.Select(candidates); m != candidates[0].Module {
+			t.Fatalf("Inconsistent selected module: %v (strategy: %s)", m, strategy)
+		}
+	}
+	if balancer, err := NewBalancer(""); err != nil ||
+		balancer.Strategy() != DefaultBalanceStrategy {
+		t.Fatalf("Inconsistent default balancer: %v (error: %v)", balancer, err)
+	}
+	if _, err := NewBalancer("random"); err == nil {
+		t.Fatal("No error when creating balancer with unknown strategy!")
+	}
+}
+
+func TestLeastScoreBalancer(t *testing.T) {
+	balancer, _ := NewBalancer(BALANCE_LEAST_SCORE)
+	candidates := genCandidates([]uint32{1, 1, 1}, []uint64{0, 0, 0})
+	// 评分都相同（包括为0）时，总是选择第一个。
+	counts := countSelections(balancer, candidates, 10)
+	if counts["D1"] != 10 {
+		t.Fatalf("Inconsistent selections: %v", counts)
+	}
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	balancer, _ := NewBalancer(BALANCE_ROUND_ROBIN)
+	candidates := genCandidates([]uint32{5, 1, 1}, []uint64{0, 0, 0})
+	var sequence []MID
+	for i := 0; i < 7; i++ {
+		sequence = append(sequence, balancer.Select(candidates).ID())
+	}
+	// 平滑加权轮询不会连续地选择权重较大的组件。
+	expected := []MID{"D1", "D1", "D2", "D1", "D3", "D1", "D1"}
+	if fmt.Sprint(sequence) != fmt.Sprint(expected) {
+		t.Fatalf("Inconsistent round robin sequence: expected: %v, actual: %v",
+			expected, sequence)
+	}
+	// 组件被注销后，轮询仍在剩余的组件中进行。
+	counts := countSelections(balancer, candidates[1:], 10)
+	if counts["D2"] != 5 || counts["D3"] != 5 {
+		t.Fatalf("Inconsistent selections: %v", counts)
+	}
+}
+
+func TestWeightedRandomBalancer(t *testing.T) {
+	balancer, _ := NewBalancer(BALANCE_WEIGHTED_RANDOM)
+	candidates := genCandidates([]uint32{9, 1}, []uint64{0, 0})
+	counts := countSelections(balancer, candidates, 10000)
+	if counts["D1"] < 8500 || counts["D1"] > 9500 {
+		t.Fatalf("Inconsistent weighted random selections: %v", counts)
+	}
+}
+
+func TestLeastInFlightBalancer(t *testing.T) {
+	balancer, _ := NewBalancer(BALANCE_LEAST_IN_FLIGHT)
+	// D2的负载为3/1，D1的负载为4/2，D3的负载为2/1。
+	candidates := genCandidates([]uint32{2, 1, 1}, []uint64{4, 3, 2})
+	counts := countSelections(balancer, candidates, 100)
+	if counts["D1"]+counts["D3"] != 100 || counts["D1"] == 0 || counts["D3"] == 0 {
+		t.Fatalf("Inconsistent least in-flight selections: %v", counts)
+	}
+}
+
+func TestPowerOfTwoBalancer(t *testing.T) {
+	balancer, _ := NewBalancer(BALANCE_POWER_OF_TWO)
+	candidates := genCandidates([]uint32{1, 1, 1}, []uint64{0, 10, 20})
+	counts := countSelections(balancer, candidates, 3000)
+	// 负载最高的组件永远不会被选中，负载最低的组件在它被抽中的两种组合中都会胜出。
+	if counts["D3"] != 0 || counts["D1"] < 1700 || counts["D1"] > 2300 {
+		t.Fatalf("Inconsistent power-of-two selections: %v", counts)
+	}
+}
+
+func TestRegistrarBalancer(t *testing.T) {
+	registrar := NewRegistrar()
+	if registrar.GetBalancer(TYPE_DOWNLOADER).Strategy() != DefaultBalanceStrategy {
+		t.Fatal("Inconsistent default balancer of registrar!")
+	}
+	if err := registrar.SetBalancer(TYPE_DOWNLOADER, nil); err == nil {
+		t.Fatal("No error when setting nil balancer!")
+	}
+	balancer, _ := NewBalancer(BALANCE_ROUND_ROBIN)
+	if err := registrar.SetBalancer(Type("unknown"), balancer); err == nil {
+		t.Fatal("No error when setting balancer for illegal type!")
+	}
+	if err := registrar.SetBalancer(TYPE_DOWNLOADER, balancer); err != nil {
+		t.Fatalf("An error occurs when setting balancer: %s", err)
+	}
+	if registrar.GetBalancer(TYPE_DOWNLOADER) != balancer {
+		t.Fatal("Inconsistent balancer of registrar!")
+	}
+	d1 := NewFakeDownloader("D1", CalculateScoreSimple)
+	d2 := NewFakeDownloader("D2", CalculateScoreSimple)
+	if ok, err := registrar.RegisterWithWeight(d1, 0); ok || err == nil {
+		t.Fatal("No error when registering module with zero weight!")
+	}
+	registrar.RegisterWithWeight(d1, 3)
+	registrar.Register(d2)
+	if weight := registrar.GetWeight("D1"); weight != 3 {
+		t.Fatalf("Inconsistent weight: expected: %d, actual: %d", 3, weight)
+	}
+	if weight := registrar.GetWeight("D2"); weight != DefaultWeight {
+		t.Fatalf("Inconsistent weight: expected: %d, actual: %d", DefaultWeight, weight)
+	}
+	counts := map[MID]int{}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m, err := registrar.Get(TYPE_DOWNLOADER)
+				if err != nil {
+					t.Errorf("An error occurs when getting module: %s", err)
+					return
+				}
+				lock.Lock()
+				counts[m.ID()]++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if counts["D1"] != 300 || counts["D2"] != 100 {
+		t.Fatalf("Inconsistent weighted round robin selections: %v", counts)
+	}
+	registrar.UnRegister("D1")
+	if weight := registrar.GetWeight("D1"); weight != 0 {
+		t.Fatalf("Inconsistent weight of unregistered module: %d", weight)
+	}
+}
@@ -1,6 +1,9 @@
 package module
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type Module interface {
 	//当前组件的mid
@@ -35,14 +38,25 @@ type Counts struct {
 	CompletedCount uint64
 	// HandlingNumber 代表实时处理数。
 	HandlingNumber uint64
+	// FailedCount 代表已接受但未能成功完成的调用的计数。
+	FailedCount uint64
+	// LatencyEWMA 代表已接受的调用的延迟的指数加权移动平均值。
+	LatencyEWMA time.Duration
+	// Latency 代表已接受的调用的延迟直方图。
+	Latency LatencyHistogram
 }
 
 type SummaryStruct struct{
 	ID MID `json:"id"`
-	Called uint64 `json:"id"`
-	Accepted uint64 `json:"id"`
-	Completed uint64 `json:"id"`
-	Handling uint64 `json:"id"`
+	Called uint64 `json:"called"`
+	Accepted uint64 `json:"accepted"`
+	Completed uint64 `json:"completed"`
+	Handling uint64 `json:"handling"`
+	Failed uint64 `json:"failed"`
+	LatencyEWMA time.Duration `json:"latency_ewma"`
+	Latency LatencyHistogram `json:"latency_histogram"`
+	//组件的熔断器的状态，由调度器根据注册器填写，未使用熔断器时为空
+	Breaker BreakerState `json:"breaker,omitempty"`
 	//如果extra字段不为空，则解析该字段
 	Extra interface{} `json:"extra,omitempty"`
 }
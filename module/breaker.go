@@ -0,0 +1,218 @@
+package module
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/errors"
+)
+
+// BreakerState 代表熔断器的状态。
+type BreakerState string
+
+const (
+	// BREAKER_CLOSED 代表熔断器闭合，组件可以被正常选择。
+	BREAKER_CLOSED BreakerState = "closed"
+	// BREAKER_OPEN 代表熔断器断开，组件在选择时会被跳过。
+	BREAKER_OPEN BreakerState = "open"
+	// BREAKER_HALF_OPEN 代表熔断器半开，组件只会被分配少量的试探调用。
+	BREAKER_HALF_OPEN BreakerState = "half_open"
+)
+
+// DefaultBreakerOpenTimeout 代表熔断器从断开到半开的默认时长。
+const DefaultBreakerOpenTimeout = 30 * time.Second
+
+// BreakerArgs 代表熔断器的参数。
+type BreakerArgs struct {
+	// FailureThreshold 代表使熔断器断开的连续失败的次数，为0时不使用熔断器。
+	FailureThreshold uint32 `json:"failure_threshold"`
+	// OpenTimeout 代表熔断器从断开到半开的时长，为0时使用默认时长。
+	OpenTimeout time.Duration `json:"open_timeout"`
+	// HalfOpenProbes 代表半开时允许的试探调用的次数，
+	// 也是使熔断器重新闭合所需的连续成功的次数，为0时为1。
+	HalfOpenProbes uint32 `json:"half_open_probes"`
+}
+
+// Check 用于检查熔断器的参数。
+func (args BreakerArgs) Check() error {
+	if args.OpenTimeout < 0 {
+		return errors.NewIllegalParameterError(
+			fmt.Sprintf("negative breaker open timeout: %s", args.OpenTimeout))
+	}
+	return nil
+}
+
+// Enabled 用于判断是否使用熔断器。
+func (args BreakerArgs) Enabled() bool {
+	return args.FailureThreshold > 0
+}
+
+// openTimeout 用于获取实际使用的从断开到半开的时长。
+func (args BreakerArgs) openTimeout() time.Duration {
+	if args.OpenTimeout == 0 {
+		return DefaultBreakerOpenTimeout
+	}
+	return args.OpenTimeout
+}
+
+// halfOpenProbes 用于获取实际使用的半开时的试探调用的次数。
+func (args BreakerArgs) halfOpenProbes() uint32 {
+	if args.HalfOpenProbes == 0 {
+		return 1
+	}
+	return args.HalfOpenProbes
+}
+
+// BreakerStateChange 代表熔断器的状态变化。
+// 它实现了error接口，以便调度器通过错误缓冲池和观察者报告该变化。
+type BreakerStateChange struct {
+	// MID 代表熔断器所属的组件的ID。
+	MID MID
+	// From 代表原先的状态。
+	From BreakerState
+	// To 代表新的状态。
+	To BreakerState
+	// Failures 代表状态变化时的连续失败的次数。
+	Failures uint32
+	// Time 代表状态变化的时间。
+	Time time.Time
+}
+
+func (change BreakerStateChange) Error() string {
+	return fmt.Sprintf("circuit breaker of module %q changed from %s to %s (consecutive failures: %d)",
+		change.MID, change.From, change.To, change.Failures)
+}
+
+// BreakerListener 代表接收熔断器状态变化的函数类型。
+// 它会在注册器的调用者的goroutine中被同步调用，所以不应阻塞。
+type BreakerListener func(change BreakerStateChange)
+
+// CircuitBreaker 代表组件的熔断器。
+// 闭合时连续失败的次数达到阈值后，熔断器会断开；断开一段时间后会变为半开，
+// 此时只允许少量的试探调用，它们都成功后熔断器重新闭合，其中任何一个失败则再次断开。
+type CircuitBreaker struct {
+	mid  MID
+	args BreakerArgs
+	lock sync.Mutex
+	// state 代表当前的状态。
+	state BreakerState
+	// failures 代表连续失败的次数。
+	failures uint32
+	// probes 代表本次半开以来已允许的试探调用的次数。
+	probes uint32
+	// successes 代表本次半开以来成功的试探调用的次数。
+	successes uint32
+	// changedAt 代表最近一次断开或半开的时间。
+	changedAt time.Time
+	// now 用于获取当前时间，测试时可以替换。
+	now func() time.Time
+}
+
+// NewCircuitBreaker 用于创建一个闭合的熔断器。
+func NewCircuitBreaker(mid MID, args BreakerArgs) *CircuitBreaker {
+	return &CircuitBreaker{
+		mid:   mid,
+		args:  args,
+		state: BREAKER_CLOSED,
+		now:   time.Now,
+	}
+}
+
+// State 用于获取熔断器的当前状态。
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	return cb.state
+}
+
+// Available 用于判断组件当前是否可以被选择，它不会改变熔断器的状态。
+func (cb *CircuitBreaker) Available() bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	switch cb.state {
+	case BREAKER_OPEN:
+		return cb.now().Sub(cb.changedAt) >= cb.args.openTimeout()
+	case BREAKER_HALF_OPEN:
+		return cb.probes < cb.args.halfOpenProbes() || cb.probesExpired()
+	}
+	return true
+}
+
+// probesExpired 用于判断半开时的试探调用是否已久未报告结果。
+// 此时会允许新一轮的试探，以免熔断器因结果丢失而一直停留在半开状态。
+func (cb *CircuitBreaker) probesExpired() bool {
+	return cb.now().Sub(cb.changedAt) >= cb.args.openTimeout()
+}
+
+// Allow 用于在组件被选中时获取调用许可。
+// 断开的时长已足够时，熔断器会变为半开，此时结果值change不为nil。
+func (cb *CircuitBreaker) Allow() (allowed bool, change *BreakerStateChange) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	switch cb.state {
+	case BREAKER_OPEN:
+		if cb.now().Sub(cb.changedAt) < cb.args.openTimeout() {
+			return false, nil
+		}
+		change = cb.setState(BREAKER_HALF_OPEN)
+		fallthrough
+	case BREAKER_HALF_OPEN:
+		if cb.probes >= cb.args.halfOpenProbes() {
+			if !cb.probesExpired() {
+				return false, change
+			}
+			cb.probes = 0
+			cb.successes = 0
+			cb.changedAt = cb.now()
+		}
+		cb.probes++
+	}
+	return true, change
+}
+
+// Record 用于记录一次调用的结果。
+// 熔断器的状态因此变化时，结果值不为nil。
+func (cb *CircuitBreaker) Record(success bool) *BreakerStateChange {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if success {
+		cb.failures = 0
+		if cb.state == BREAKER_HALF_OPEN {
+			cb.successes++
+			if cb.successes >= cb.args.halfOpenProbes() {
+				return cb.setState(BREAKER_CLOSED)
+			}
+		}
+		return nil
+	}
+	cb.failures++
+	switch cb.state {
+	case BREAKER_CLOSED:
+		if cb.failures >= cb.args.FailureThreshold {
+			return cb.setState(BREAKER_OPEN)
+		}
+	case BREAKER_HALF_OPEN:
+		return cb.setState(BREAKER_OPEN)
+	}
+	return nil
+}
+
+// setState 用于改变熔断器的状态，调用方需持有锁。
+func (cb *CircuitBreaker) setState(state BreakerState) *BreakerStateChange {
+	change := &BreakerStateChange{
+		MID:      cb.mid,
+		From:     cb.state,
+		To:       state,
+		Failures: cb.failures,
+		Time:     cb.now(),
+	}
+	cb.state = state
+	cb.probes = 0
+	cb.successes = 0
+	cb.changedAt = change.Time
+	if state == BREAKER_CLOSED {
+		cb.failures = 0
+	}
+	return change
+}
@@ -0,0 +1,170 @@
+package module
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock 代表可以手动推进的时钟。
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+// newTestBreaker 用于创建一个使用仿造时钟的熔断器。
+func newTestBreaker(args BreakerArgs) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cb := NewCircuitBreaker("D1", args)
+	cb.now = clock.Now
+	return cb, clock
+}
+
+func TestBreakerArgs(t *testing.T) {
+	if (BreakerArgs{}).Enabled() {
+		t.Fatal("The breaker with zero failure threshold is enabled!")
+	}
+	if err := (BreakerArgs{OpenTimeout: -time.Second}).Check(); err == nil {
+		t.Fatal("No error when checking breaker args with negative open timeout!")
+	}
+	args := BreakerArgs{FailureThreshold: 1}
+	if err := args.Check(); err != nil {
+		t.Fatalf("An error occurs when checking breaker args: %s", err)
+	}
+	if args.openTimeout() != DefaultBreakerOpenTimeout || args.halfOpenProbes() != 1 {
+		t.Fatalf("Inconsistent default breaker args: %s, %d",
+			args.openTimeout(), args.halfOpenProbes())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cb, clock := newTestBreaker(BreakerArgs{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Second,
+		HalfOpenProbes:   2,
+	})
+	// 成功会清除连续失败的次数。
+	cb.Record(false)
+	cb.Record(false)
+	cb.Record(true)
+	cb.Record(false)
+	if change := cb.Record(false); change != nil || cb.State() != BREAKER_CLOSED {
+		t.Fatalf("Inconsistent breaker state: %s (change: %v)", cb.State(), change)
+	}
+	change := cb.Record(false)
+	if change == nil || change.From != BREAKER_CLOSED || change.To != BREAKER_OPEN ||
+		change.MID != "D1" || change.Failures != 3 {
+		t.Fatalf("Inconsistent breaker state change: %#v", change)
+	}
+	if cb.Available() {
+		t.Fatal("The open breaker is available!")
+	}
+	if allowed, _ := cb.Allow(); allowed {
+		t.Fatal("The open breaker allows a call!")
+	}
+	clock.now = clock.now.Add(time.Second)
+	if !cb.Available() || cb.State() != BREAKER_OPEN {
+		t.Fatalf("Inconsistent breaker after open timeout: %s", cb.State())
+	}
+	allowed, change := cb.Allow()
+	if !allowed || change == nil || change.To != BREAKER_HALF_OPEN {
+		t.Fatalf("Inconsistent breaker state change: %#v (allowed: %v)", change, allowed)
+	}
+	if allowed, _ := cb.Allow(); !allowed {
+		t.Fatal("The half-open breaker doesn't allow the second probe!")
+	}
+	if cb.Available() {
+		t.Fatal("The half-open breaker is available after all probes are allowed!")
+	}
+	// 试探调用失败后再次断开。
+	cb.Record(true)
+	if change := cb.Record(false); change == nil || change.To != BREAKER_OPEN {
+		t.Fatalf("Inconsistent breaker state change: %#v", change)
+	}
+	clock.now = clock.now.Add(time.Second)
+	cb.Allow()
+	cb.Allow()
+	cb.Record(true)
+	if change := cb.Record(true); change == nil || change.To != BREAKER_CLOSED {
+		t.Fatalf("Inconsistent breaker state change: %#v", change)
+	}
+	if allowed, _ := cb.Allow(); !allowed {
+		t.Fatal("The closed breaker doesn't allow a call!")
+	}
+}
+
+func TestCircuitBreakerLostProbes(t *testing.T) {
+	cb, clock := newTestBreaker(BreakerArgs{FailureThreshold: 1, OpenTimeout: time.Second})
+	cb.Record(false)
+	clock.now = clock.now.Add(time.Second)
+	if allowed, _ := cb.Allow(); !allowed {
+		t.Fatal("The breaker doesn't allow a probe!")
+	}
+	if allowed, _ := cb.Allow(); allowed {
+		t.Fatal("The half-open breaker allows too many probes!")
+	}
+	// 试探调用的结果一直没有被报告时，允许新一轮的试探。
+	clock.now = clock.now.Add(time.Second)
+	if allowed, _ := cb.Allow(); !allowed || cb.State() != BREAKER_HALF_OPEN {
+		t.Fatalf("The breaker doesn't allow a new probe: %s", cb.State())
+	}
+}
+
+func TestRegistrarBreaker(t *testing.T) {
+	registrar := NewRegistrar()
+	if err := registrar.SetBreakerArgs(BreakerArgs{OpenTimeout: -1}); err == nil {
+		t.Fatal("No error when setting illegal breaker args!")
+	}
+	d1 := NewFakeDownloader("D1", CalculateScoreSimple)
+	registrar.Register(d1)
+	if state := registrar.GetBreakerState("D1"); state != "" {
+		t.Fatalf("Inconsistent breaker state without breaker: %s", state)
+	}
+	registrar.Report("D1", errors.New("failed"))
+	if err := registrar.SetBreakerArgs(BreakerArgs{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+	}); err != nil {
+		t.Fatalf("An error occurs when setting breaker args: %s", err)
+	}
+	var changes []BreakerStateChange
+	registrar.SetBreakerListener(func(change BreakerStateChange) {
+		changes = append(changes, change)
+	})
+	d2 := NewFakeDownloader("D2", CalculateScoreSimple)
+	registrar.Register(d2)
+	if state := registrar.GetBreakerState("D2"); state != BREAKER_CLOSED {
+		t.Fatalf("Inconsistent breaker state: %s", state)
+	}
+	registrar.Report("D1", errors.New("failed"))
+	registrar.Report("D1", errors.New("failed"))
+	if state := registrar.GetBreakerState("D1"); state != BREAKER_OPEN {
+		t.Fatalf("Inconsistent breaker state: %s", state)
+	}
+	if len(changes) != 1 || changes[0].MID != "D1" || changes[0].To != BREAKER_OPEN {
+		t.Fatalf("Inconsistent breaker state changes: %v", changes)
+	}
+	// 熔断器断开的组件会被跳过。
+	for i := 0; i < 10; i++ {
+		m, err := registrar.Get(TYPE_DOWNLOADER)
+		if err != nil || m.ID() != "D2" {
+			t.Fatalf("Inconsistent module: %v (error: %v)", m, err)
+		}
+	}
+	registrar.Report("D2", errors.New("failed"))
+	registrar.Report("D2", errors.New("failed"))
+	if _, err := registrar.Get(TYPE_DOWNLOADER); err != ErrNoAvailableModuleInstance {
+		t.Fatalf("Inconsistent error when all breakers are open: %v", err)
+	}
+	registrar.UnRegister("D2")
+	if state := registrar.GetBreakerState("D2"); state != "" {
+		t.Fatalf("Inconsistent breaker state of unregistered module: %s", state)
+	}
+	registrar.Clear()
+	if _, err := registrar.Get(TYPE_DOWNLOADER); err != ErrNotFoundModuleInstance {
+		t.Fatalf("Inconsistent error when no module is registered: %v", err)
+	}
+}
@@ -16,6 +16,8 @@ func (item Item)Valid()bool{
 type Request struct{
 	httpReq *http.Request
 	depth uint32
+	//请求的元数据
+	meta Meta
 }
 
 func NewRequest(httpRequest *http.Request, depth uint32)*Request{
@@ -30,6 +32,30 @@ func (req *Request)Depth()uint32{
 	return req.depth
 }
 
+// Attempt 用于获取请求已经尝试下载的次数，初次下载之前为0。
+// 它与元数据中的重试次数相同。
+func (req *Request)Attempt()uint32{
+	return req.meta.RetryCount
+}
+
+// WithAttempt 用于生成一个除了尝试次数之外都与当前请求相同的新请求，
+// 例如重试时使用的请求。
+func (req *Request)WithAttempt(attempt uint32)*Request{
+	meta := req.meta
+	meta.RetryCount = attempt
+	return &Request{httpReq: req.httpReq, depth: req.depth, meta: meta}
+}
+
+// Meta 用于获取请求的元数据的副本。
+func (req *Request)Meta()Meta{
+	return req.meta.clone()
+}
+
+// WithMeta 用于生成一个除了元数据之外都与当前请求相同的新请求。
+func (req *Request)WithMeta(meta Meta)*Request{
+	return &Request{httpReq: req.httpReq, depth: req.depth, meta: meta.clone()}
+}
+
 func (req *Request)Valid()bool{
 	return req.httpReq != nil && req.httpReq.URL != nil
 }
@@ -37,6 +63,12 @@ func (req *Request)Valid()bool{
 type Response struct{
 	httpResp *http.Response
 	depth uint32
+	//得到该响应的请求的元数据
+	meta Meta
+	//下载的各阶段耗时及传输情况
+	trace Trace
+	//得到该响应所经过的重定向链
+	redirects []string
 }
 
 func NewResponse(httpResp *http.Response, depth uint32)*Response{
@@ -51,6 +83,52 @@ func (resp *Response)Depth()uint32{
 	return resp.depth
 }
 
+// Meta 用于获取得到该响应的请求的元数据的副本。
+func (resp *Response)Meta()Meta{
+	return resp.meta.clone()
+}
+
+// WithMeta 用于生成一个除了元数据之外都与当前响应相同的新响应。
+// 下载器应该用它把请求的元数据转入响应。
+func (resp *Response)WithMeta(meta Meta)*Response{
+	newResp := *resp
+	newResp.meta = meta.clone()
+	return &newResp
+}
+
+// Trace 用于获取下载该响应时的各阶段耗时及传输情况。
+func (resp *Response)Trace()Trace{
+	return resp.trace
+}
+
+// WithTrace 用于生成一个除了下载耗时记录之外都与当前响应相同的新响应。
+// 下载器应该用它记录下载的各阶段耗时及传输情况。
+func (resp *Response)WithTrace(trace Trace)*Response{
+	newResp := *resp
+	newResp.trace = trace
+	return &newResp
+}
+
+// RedirectChain 用于获取得到该响应所经过的重定向链的副本。
+// 其中依次是原始请求的URL、各次重定向的目标URL和最终的URL，没有发生重定向时为nil。
+func (resp *Response)RedirectChain()[]string{
+	if resp.redirects == nil{
+		return nil
+	}
+	return append([]string(nil), resp.redirects...)
+}
+
+// WithRedirectChain 用于生成一个除了重定向链之外都与当前响应相同的新响应。
+// 下载器应该用它记录跟随过的重定向。
+func (resp *Response)WithRedirectChain(chain []string)*Response{
+	newResp := *resp
+	newResp.redirects = nil
+	if len(chain) > 0{
+		newResp.redirects = append([]string(nil), chain...)
+	}
+	return &newResp
+}
+
 func (resp *Response)Valid()bool{
 	return resp.httpResp != nil && resp.httpResp.Body != nil
 }
\ No newline at end of file
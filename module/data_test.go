@@ -62,6 +62,29 @@ func TestRequest(t *testing.T) {
 	}
 }
 
+func TestRequestAttempt(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	req := NewRequest(httpReq, 2)
+	if req.Attempt() != 0 {
+		t.Fatalf("Inconsistent attempt for new request: expected: %d, actual: %d",
+			0, req.Attempt())
+	}
+	retry := req.WithAttempt(req.Attempt() + 1)
+	if retry == req {
+		t.Fatal("The retry request is the same instance as the original one!")
+	}
+	if retry.Attempt() != 1 {
+		t.Fatalf("Inconsistent attempt for retry request: expected: %d, actual: %d",
+			1, retry.Attempt())
+	}
+	if retry.HTTPReq() != httpReq || retry.Depth() != 2 {
+		t.Fatalf("Inconsistent retry request: %#v", retry)
+	}
+	if req.Attempt() != 0 {
+		t.Fatalf("The attempt of the original request was changed to %d!", req.Attempt())
+	}
+}
+
 func TestResponse(t *testing.T) {
 	method := "GET"
 	expectedURLStr := "https://github.com/gopcp"
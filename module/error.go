@@ -3,4 +3,6 @@ package module
 import "errors"
 
 //代表未找到组件实例的错误类型。
-var ErrNotFoundModuleInstance = errors.New("not found module instance")
\ No newline at end of file
+var ErrNotFoundModuleInstance = errors.New("not found module instance")
+//代表同一类型的组件的熔断器都已断开，暂时没有可用的组件实例的错误类型。
+var ErrNoAvailableModuleInstance = errors.New("no available module instance")
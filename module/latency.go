@@ -0,0 +1,72 @@
+package module
+
+import "time"
+
+// LatencyBucketBounds 代表延迟直方图中各个桶的上界（包含），
+// 超出最后一个上界的延迟会被计入最后一个额外的桶。
+var LatencyBucketBounds = [LatencyBucketNumber - 1]time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyBucketNumber 代表延迟直方图中桶的数量。
+const LatencyBucketNumber = 10
+
+// LatencyHistogram 代表延迟直方图，其中的元素是落入相应的桶的调用次数。
+type LatencyHistogram [LatencyBucketNumber]uint64
+
+// LatencyBucketIndex 用于获取给定的延迟所属的桶的索引。
+func LatencyBucketIndex(latency time.Duration) int {
+	for i, bound := range LatencyBucketBounds {
+		if latency <= bound {
+			return i
+		}
+	}
+	return LatencyBucketNumber - 1
+}
+
+// Total 用于获取直方图中所有调用的次数。
+func (h LatencyHistogram) Total() uint64 {
+	var total uint64
+	for _, n := range h {
+		total += n
+	}
+	return total
+}
+
+// Quantile 用于估算给定分位（0到1之间）的延迟，结果值为相应的桶的上界。
+// 落在最后一个桶中时，结果值为最后一个上界。没有任何调用时结果值为0。
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	total := h.Total()
+	if total == 0 {
+		return 0
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+	rank := uint64(q * float64(total))
+	if rank == 0 {
+		rank = 1
+	}
+	var count uint64
+	for i, n := range h {
+		count += n
+		if count >= rank {
+			if i < len(LatencyBucketBounds) {
+				return LatencyBucketBounds[i]
+			}
+			break
+		}
+	}
+	return LatencyBucketBounds[len(LatencyBucketBounds)-1]
+}
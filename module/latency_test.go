@@ -0,0 +1,48 @@
+package module
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyBucketIndex(t *testing.T) {
+	cases := map[time.Duration]int{
+		0:                      0,
+		10 * time.Millisecond:  0,
+		11 * time.Millisecond:  1,
+		300 * time.Millisecond: 4,
+		10 * time.Second:       LatencyBucketNumber - 2,
+		time.Minute:            LatencyBucketNumber - 1,
+	}
+	for latency, expected := range cases {
+		if index := LatencyBucketIndex(latency); index != expected {
+			t.Fatalf("Inconsistent bucket index of %s: expected: %d, actual: %d",
+				latency, expected, index)
+		}
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var histogram LatencyHistogram
+	if histogram.Quantile(0.5) != 0 {
+		t.Fatal("Inconsistent quantile of empty histogram!")
+	}
+	histogram[0] = 90
+	histogram[3] = 9
+	histogram[LatencyBucketNumber-1] = 1
+	if total := histogram.Total(); total != 100 {
+		t.Fatalf("Inconsistent total: expected: %d, actual: %d", 100, total)
+	}
+	cases := map[float64]time.Duration{
+		0:    10 * time.Millisecond,
+		0.5:  10 * time.Millisecond,
+		0.95: 250 * time.Millisecond,
+		1:    10 * time.Second,
+	}
+	for q, expected := range cases {
+		if latency := histogram.Quantile(q); latency != expected {
+			t.Fatalf("Inconsistent %v quantile: expected: %s, actual: %s",
+				q, expected, latency)
+		}
+	}
+}
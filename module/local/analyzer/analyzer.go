@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"gopcpv2-web-spider/toolkit/reader"
+	"time"
+	"net/http"
 )
 
 type myAnalyzer struct {
@@ -68,6 +70,12 @@ func(analyzer *myAnalyzer)Analyze(resp *module.Response)(dataList []module.Data,
 		return
 	}
 	analyzer.ModuleInternal.IncrAcceptedCount()
+	defer func(start time.Time){
+		analyzer.ModuleInternal.RecordLatency(time.Since(start))
+		if len(errorList) > 0{
+			analyzer.ModuleInternal.IncrFailedCount()
+		}
+	}(time.Now())
 	log.Printf("解析响应, URL: %s, depth: %d...", reqUrl, resp.Depth())
 	if httpResp.Body != nil{
 		defer httpResp.Body.Close()
@@ -77,6 +85,9 @@ func(analyzer *myAnalyzer)Analyze(resp *module.Response)(dataList []module.Data,
 		errorList = append(errorList, genParameterError(err.Error()))
 		return
 	}
+	// 把请求的元数据放入HTTP请求的上下文，以便解析函数通过module.ResponseMeta读取。
+	meta := resp.Meta()
+	httpResp.Request = httpReq.WithContext(module.ContextWithMeta(httpReq.Context(), meta))
 	dataList = []module.Data{}
 	for _, respParser := range analyzer.respParsers{
 		httpResp.Body = multipleReader.Reader()
@@ -86,7 +97,7 @@ func(analyzer *myAnalyzer)Analyze(resp *module.Response)(dataList []module.Data,
 				if pData == nil{
 					continue
 				}
-				dataList = appendDataList(dataList, pData, resp.Depth())
+				dataList = appendDataList(dataList, pData, resp.Depth(), httpResp, meta)
 			}
 		}
 		if pErrorList != nil{
@@ -104,7 +115,11 @@ func(analyzer *myAnalyzer)Analyze(resp *module.Response)(dataList []module.Data,
 	return
 }
 
-func appendDataList(dataList []module.Data, data module.Data, respDepth uint32) []module.Data {
+// appendDataList 用于把解析出的数据追加到数据列表中。
+// 对于请求，它会设置深度并补全元数据：生成ID，设置链接所在网页的URL和来源，
+// 继承链接所在网页的请求的属性，并在请求没有Referer头时设置它。
+func appendDataList(dataList []module.Data, data module.Data, respDepth uint32,
+	parent *http.Response, parentMeta module.Meta) []module.Data {
 	if data == nil {
 		return dataList
 	}
@@ -112,9 +127,40 @@ func appendDataList(dataList []module.Data, data module.Data, respDepth uint32)
 	if !ok {
 		return append(dataList, data)
 	}
-	newDepth := respDepth + 1
-	if req.Depth() != newDepth {
-		req = module.NewRequest(req.HTTPReq(), newDepth)
+	meta := req.Meta()
+	if meta.ID == "" {
+		meta.ID = module.NewRequestID()
+	}
+	if meta.ParentURL == "" {
+		meta.ParentURL = originalURL(parent)
+	}
+	if meta.Referrer == "" {
+		meta.Referrer = parent.Request.URL.String()
+	}
+	if len(parentMeta.Attrs) > 0 {
+		attrs := make(map[string]string, len(parentMeta.Attrs)+len(meta.Attrs))
+		for key, value := range parentMeta.Attrs {
+			attrs[key] = value
+		}
+		for key, value := range meta.Attrs {
+			attrs[key] = value
+		}
+		meta.Attrs = attrs
+	}
+	if httpReq := req.HTTPReq(); httpReq != nil && httpReq.URL != nil &&
+		httpReq.Header != nil && httpReq.Header.Get("Referer") == "" &&
+		// 与浏览器一样，从HTTPS网页到HTTP网页的请求不带Referer头。
+		!(parent.Request.URL.Scheme == "https" && httpReq.URL.Scheme == "http") {
+		httpReq.Header.Set("Referer", meta.Referrer)
+	}
+	return append(dataList, module.NewRequest(req.HTTPReq(), respDepth+1).WithMeta(meta))
+}
+
+// originalURL 用于获取响应对应的重定向之前的请求的URL。
+func originalURL(httpResp *http.Response) string {
+	httpReq := httpResp.Request
+	for httpReq.Response != nil && httpReq.Response.Request != nil {
+		httpReq = httpReq.Response.Request
 	}
-	return append(dataList, req)
+	return httpReq.URL.String()
 }
\ No newline at end of file
@@ -329,4 +329,86 @@ t *testing.T) []*module.Response {
 		resps = append(resps, resp)
 	}
 	return resps
-}
\ No newline at end of file
+}
+
+func TestAnalyzeMeta(t *testing.T) {
+	originalURL := "http://github.com/gopcp"
+	finalURL := "https://github.com/gopcp/"
+	originalReq, _ := http.NewRequest("GET", originalURL, nil)
+	finalReq, _ := http.NewRequest("GET", finalURL, nil)
+	// 重定向之后的请求通过Response字段指向引起重定向的响应。
+	finalReq.Response = &http.Response{StatusCode: http.StatusFound, Request: originalReq}
+	httpResp := &http.Response{
+		StatusCode: 200,
+		Request:    finalReq,
+		Body:       testingReader{strings.NewReader("")},
+	}
+	parentMeta := module.Meta{
+		ID:    "parent",
+		Attrs: map[string]string{"source": "seed", "topic": "go"},
+	}
+	resp := module.NewResponse(httpResp, 1).WithMeta(parentMeta)
+	var parsedMeta module.Meta
+	parser := func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+		parsedMeta, _ = module.ResponseMeta(httpResp)
+		var dataList []module.Data
+		for _, link := range []string{"https://github.com/a", "http://github.com/b"} {
+			httpReq, _ := http.NewRequest("GET", link, nil)
+			req := module.NewRequest(httpReq, 0).WithMeta(module.Meta{
+				AnchorText: "link " + link,
+				Priority:   2,
+				Attrs:      map[string]string{"topic": "golang"},
+			})
+			dataList = append(dataList, req)
+		}
+		return dataList, nil
+	}
+	a, err := New("A1", []module.ParseResponse{parser}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	dataList, errs := a.Analyze(resp)
+	if len(errs) != 0 {
+		t.Fatalf("An error occurs when analyzing: %v", errs)
+	}
+	if parsedMeta.ID != "parent" || parsedMeta.Attr("source") != "seed" {
+		t.Fatalf("Inconsistent meta in parser: %#v", parsedMeta)
+	}
+	if len(dataList) != 2 {
+		t.Fatalf("Inconsistent data number: expected: %d, actual: %d", 2, len(dataList))
+	}
+	ids := map[string]bool{}
+	for i, data := range dataList {
+		req, ok := data.(*module.Request)
+		if !ok {
+			t.Fatalf("Inconsistent datum type: expected: %T, actual: %T", req, data)
+		}
+		meta := req.Meta()
+		if meta.ID == "" || ids[meta.ID] {
+			t.Fatalf("Inconsistent request ID: %q (index: %d)", meta.ID, i)
+		}
+		ids[meta.ID] = true
+		if req.Depth() != 2 {
+			t.Fatalf("Inconsistent depth: expected: %d, actual: %d", 2, req.Depth())
+		}
+		if meta.ParentURL != originalURL || meta.Referrer != finalURL {
+			t.Fatalf("Inconsistent parent URL or referrer: %#v", meta)
+		}
+		if meta.AnchorText != "link "+req.HTTPReq().URL.String() || meta.Priority != 2 {
+			t.Fatalf("Inconsistent anchor text or priority: %#v", meta)
+		}
+		if meta.Attr("source") != "seed" || meta.Attr("topic") != "golang" {
+			t.Fatalf("Inconsistent attributes: %#v", meta.Attrs)
+		}
+	}
+	// 从HTTPS网页到HTTP网页的请求不带Referer头。
+	if referer := dataList[0].(*module.Request).HTTPReq().Header.Get("Referer"); referer != finalURL {
+		t.Fatalf("Inconsistent Referer header: expected: %s, actual: %s", finalURL, referer)
+	}
+	if referer := dataList[1].(*module.Request).HTTPReq().Header.Get("Referer"); referer != "" {
+		t.Fatalf("Unexpected Referer header from HTTPS to HTTP: %s", referer)
+	}
+	if parentMeta.Attr("topic") != "go" {
+		t.Fatalf("The attributes of parent meta were changed: %#v", parentMeta.Attrs)
+	}
+}
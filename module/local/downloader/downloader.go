@@ -1,10 +1,13 @@
 package downloader
 
 import (
+	"bytes"
 	"gopcpv2-web-spider/module"
+	"io/ioutil"
 	"net/http"
 	"gopcpv2-web-spider/module/stub"
 	"log"
+	"time"
 )
 
 type myDownloader struct{
@@ -39,10 +42,30 @@ func (downloader *myDownloader)Download(req *module.Request)(*module.Response, e
 	}
 	downloader.ModuleInternal.IncrAcceptedCount()
 	log.Printf("执行请求：url: %s, depth: %d...\n", httpReq.URL, req.Depth())
-	httpResp, err := downloader.httpClient.Do(httpReq)
+	start := time.Now()
+	tracer := module.NewTracer()
+	client := downloader.httpClient
+	//调度器可以通过请求的上下文接管重定向的检查。
+	if checker := module.RedirectCheckerFromContext(httpReq.Context()); checker != nil{
+		client.CheckRedirect = checker
+	}
+	httpResp, err := client.Do(tracer.Trace(httpReq))
+	if err != nil{
+		downloader.ModuleInternal.RecordLatency(time.Since(start))
+		downloader.ModuleInternal.IncrFailedCount()
+		return nil, err
+	}
+	//读完响应主体才能得到完整的耗时和传输的字节数。
+	body, err := ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	downloader.ModuleInternal.RecordLatency(time.Since(start))
 	if err != nil{
+		downloader.ModuleInternal.IncrFailedCount()
 		return nil, err
 	}
+	httpResp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	trace := tracer.Finish(httpResp, int64(len(body)))
 	downloader.ModuleInternal.IncrCompletedCount()
-	return module.NewResponse(httpResp, req.Depth()), nil
+	return module.NewResponse(httpResp, req.Depth()).WithMeta(req.Meta()).WithTrace(trace).
+		WithRedirectChain(module.RedirectChain(httpResp)), nil
 }
\ No newline at end of file
@@ -5,7 +5,10 @@ import (
 	"gopcpv2-web-spider/module"
 	"gopcpv2-web-spider/module/stub"
 	"bufio"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 )
 
 func TestNew(t *testing.T) {
@@ -203,4 +206,77 @@ func TestCount(t *testing.T) {
 		t.Fatalf("Inconsistent handling number for internal module: expected: %d, actual: %d",
 			0, di.HandlingNumber())
 	}
-}
\ No newline at end of file
+}
+
+func TestDownloadTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "hello")
+		}))
+	defer server.Close()
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	httpReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	resp, err := d.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	trace := resp.Trace()
+	if trace.Total <= 0 || trace.TTFB > trace.Total || trace.Bytes != 5 ||
+		trace.FinalURL != server.URL+"/" || trace.RemoteIP != "127.0.0.1" {
+		t.Fatalf("Inconsistent trace: %#v", trace)
+	}
+	// 响应主体已被读出，但仍然可以被再次读取。
+	body, err := ioutil.ReadAll(resp.HTTPResp().Body)
+	if err != nil || string(body) != "hello" {
+		t.Fatalf("Inconsistent body: %q (error: %v)", body, err)
+	}
+}
+
+func TestDownloadRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/a":
+				http.Redirect(w, r, "/b", http.StatusFound)
+			case "/b":
+				http.Redirect(w, r, "/c", http.StatusFound)
+			default:
+				fmt.Fprint(w, "hello")
+			}
+		}))
+	defer server.Close()
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	httpReq, _ := http.NewRequest("GET", server.URL+"/a", nil)
+	resp, err := d.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	if chain := resp.RedirectChain(); len(chain) != 3 || chain[2] != server.URL+"/c" {
+		t.Fatalf("Inconsistent redirect chain: %v", chain)
+	}
+	// 上下文中的重定向检查函数代替HTTP客户端自身的重定向策略。
+	var checked []string
+	ctx := module.ContextWithRedirectChecker(httpReq.Context(),
+		func(target *http.Request, via []*http.Request) error {
+			checked = append(checked, target.URL.Path)
+			if len(via) > 1 {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		})
+	resp, err = d.Download(module.NewRequest(httpReq.WithContext(ctx), 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	if resp.HTTPResp().StatusCode != http.StatusFound ||
+		resp.HTTPResp().Header.Get("Location") != "/c" {
+		t.Fatalf("Inconsistent response: %d (location: %s)",
+			resp.HTTPResp().StatusCode, resp.HTTPResp().Header.Get("Location"))
+	}
+	if len(checked) != 2 || checked[0] != "/b" || checked[1] != "/c" {
+		t.Fatalf("Inconsistent checked targets: %v", checked)
+	}
+	if chain := resp.RedirectChain(); len(chain) != 2 || chain[1] != server.URL+"/b" {
+		t.Fatalf("Inconsistent redirect chain: %v", chain)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"gopcpv2-web-spider/module/stub"
 	"fmt"
 	"log"
+	"time"
 )
 
 type myPipeline struct{
@@ -54,6 +55,7 @@ func (pipeline *myPipeline)Send(item module.Item)[]error{
 	}
 	pipeline.ModuleInternal.IncrAcceptedCount()
 	log.Printf("条目处理开始，%+v...\n", item)
+	start := time.Now()
 	var currentItem = item
 	for _, processor := range pipeline.itemProcessors{
 		processedItem, err := processor(currentItem)
@@ -67,8 +69,11 @@ func (pipeline *myPipeline)Send(item module.Item)[]error{
 			currentItem = processedItem
 		}
 	}
+	pipeline.ModuleInternal.RecordLatency(time.Since(start))
 	if len(errList) == 0{
 		pipeline.IncrCompletedCount()
+	}else{
+		pipeline.ModuleInternal.IncrFailedCount()
 	}
 	return errList
 }
@@ -0,0 +1,94 @@
+package module
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Meta 代表请求的元数据。
+// 它会随请求被下载并转入响应，解析函数可以通过ResponseMeta函数读取它。
+type Meta struct {
+	// ID 代表请求的ID，为空时会由调度器或分析器自动生成。
+	ID string `json:"id,omitempty"`
+	// ParentURL 代表链接所在网页的请求的URL，即重定向之前的URL。
+	ParentURL string `json:"parent_url,omitempty"`
+	// Referrer 代表链接所在网页的最终URL，即重定向之后的URL，它也会被用作请求的Referer头。
+	Referrer string `json:"referrer,omitempty"`
+	// AnchorText 代表链接的锚文本，它需要由解析函数设置。
+	AnchorText string `json:"anchor_text,omitempty"`
+	// Priority 代表请求的优先级，值越大越优先。
+	Priority int `json:"priority,omitempty"`
+	// RetryCount 代表请求已被重试的次数。
+	RetryCount uint32 `json:"retry_count,omitempty"`
+	// RedirectCount 代表请求由重定向产生时已经历的重定向次数。
+	RedirectCount uint32 `json:"redirect_count,omitempty"`
+	// Attrs 代表任意的属性，子请求会继承链接所在网页的请求的属性。
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Attr 用于获取给定名称的属性，不存在时结果值为空字符串。
+func (meta Meta) Attr(key string) string {
+	return meta.Attrs[key]
+}
+
+// clone 用于复制元数据，其中的属性也会被复制。
+func (meta Meta) clone() Meta {
+	if meta.Attrs != nil {
+		attrs := make(map[string]string, len(meta.Attrs))
+		for key, value := range meta.Attrs {
+			attrs[key] = value
+		}
+		meta.Attrs = attrs
+	}
+	return meta
+}
+
+// requestIDPrefix 代表本进程生成的请求ID的前缀。
+var requestIDPrefix = genRequestIDPrefix()
+
+// requestIDSN 代表本进程生成的请求ID的序列号。
+var requestIDSN uint64
+
+// genRequestIDPrefix 用于生成随机的请求ID的前缀，以免不同的进程生成相同的ID。
+func genRequestIDPrefix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewRequestID 用于生成一个新的请求ID。
+func NewRequestID() string {
+	return fmt.Sprintf("%s-%d", requestIDPrefix, atomic.AddUint64(&requestIDSN, 1))
+}
+
+// metaContextKey 代表上下文中的元数据的键。
+type metaContextKey struct{}
+
+// ContextWithMeta 用于生成一个带有给定元数据的上下文。
+func ContextWithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, metaContextKey{}, meta)
+}
+
+// MetaFromContext 用于获取上下文中的元数据，第二个结果值代表元数据是否存在。
+func MetaFromContext(ctx context.Context) (Meta, bool) {
+	if ctx == nil {
+		return Meta{}, false
+	}
+	meta, ok := ctx.Value(metaContextKey{}).(Meta)
+	return meta, ok
+}
+
+// ResponseMeta 用于在解析函数中获取响应对应的请求的元数据，第二个结果值代表元数据是否存在。
+func ResponseMeta(httpResp *http.Response) (Meta, bool) {
+	if httpResp == nil || httpResp.Request == nil {
+		return Meta{}, false
+	}
+	return MetaFromContext(httpResp.Request.Context())
+}
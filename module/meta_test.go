@@ -0,0 +1,76 @@
+package module
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRequestMeta(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	req := NewRequest(httpReq, 1)
+	if meta := req.Meta(); meta.ID != "" || meta.Attrs != nil {
+		t.Fatalf("Inconsistent meta for new request: %#v", meta)
+	}
+	attrs := map[string]string{"topic": "go"}
+	meta := Meta{ID: "1", ParentURL: "https://github.com/", AnchorText: "gopcp",
+		Priority: 3, Attrs: attrs}
+	withMeta := req.WithMeta(meta)
+	if withMeta.HTTPReq() != httpReq || withMeta.Depth() != 1 {
+		t.Fatalf("Inconsistent request with meta: %#v", withMeta)
+	}
+	// 元数据中的属性会被复制，修改原属性或副本都不会影响请求。
+	attrs["topic"] = "golang"
+	actual := withMeta.Meta()
+	actual.Attrs["extra"] = "x"
+	if withMeta.Meta().Attr("topic") != "go" || withMeta.Meta().Attr("extra") != "" {
+		t.Fatalf("The attributes of request were changed: %#v", withMeta.Meta().Attrs)
+	}
+	retry := withMeta.WithAttempt(2)
+	if retry.Attempt() != 2 || retry.Meta().RetryCount != 2 || retry.Meta().ID != "1" {
+		t.Fatalf("Inconsistent meta for retry request: %#v", retry.Meta())
+	}
+	httpResp := &http.Response{Request: httpReq}
+	resp := NewResponse(httpResp, 1).WithMeta(retry.Meta())
+	if resp.HTTPResp() != httpResp || resp.Depth() != 1 {
+		t.Fatalf("Inconsistent response with meta: %#v", resp)
+	}
+	if respMeta := resp.Meta(); respMeta.ID != "1" || respMeta.AnchorText != "gopcp" ||
+		respMeta.Priority != 3 || respMeta.RetryCount != 2 {
+		t.Fatalf("Inconsistent meta for response: %#v", respMeta)
+	}
+}
+
+func TestMetaContext(t *testing.T) {
+	if _, ok := MetaFromContext(context.Background()); ok {
+		t.Fatal("Found meta in empty context!")
+	}
+	meta := Meta{ID: "1", Referrer: "https://github.com/"}
+	ctx := ContextWithMeta(context.Background(), meta)
+	if actual, ok := MetaFromContext(ctx); !ok || actual.ID != meta.ID ||
+		actual.Referrer != meta.Referrer {
+		t.Fatalf("Inconsistent meta from context: expected: %#v, actual: %#v", meta, actual)
+	}
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	if _, ok := ResponseMeta(&http.Response{Request: httpReq}); ok {
+		t.Fatal("Found meta in response without meta!")
+	}
+	if _, ok := ResponseMeta(nil); ok {
+		t.Fatal("Found meta in nil response!")
+	}
+	httpResp := &http.Response{Request: httpReq.WithContext(ctx)}
+	if actual, ok := ResponseMeta(httpResp); !ok || actual.ID != meta.ID {
+		t.Fatalf("Inconsistent meta from response: expected: %#v, actual: %#v", meta, actual)
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	ids := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := NewRequestID()
+		if id == "" || ids[id] {
+			t.Fatalf("Inconsistent request ID: %q", id)
+		}
+		ids[id] = true
+	}
+}
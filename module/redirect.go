@@ -0,0 +1,53 @@
+package module
+
+import (
+	"context"
+	"net/http"
+)
+
+// RedirectChecker 代表检查重定向的函数类型，其含义与http.Client的CheckRedirect字段相同。
+// 参数target代表重定向的目标，via代表此前的请求，其中第一个是原始请求。
+// 它返回http.ErrUseLastResponse时，下载器会停止跟随并返回重定向的响应本身。
+type RedirectChecker func(target *http.Request, via []*http.Request) error
+
+// redirectCheckerContextKey 代表上下文中的重定向检查函数的键。
+type redirectCheckerContextKey struct{}
+
+// ContextWithRedirectChecker 用于生成一个带有给定重定向检查函数的上下文。
+// 下载器应该用上下文中的函数代替HTTP客户端自身的重定向策略。
+func ContextWithRedirectChecker(ctx context.Context, checker RedirectChecker) context.Context {
+	return context.WithValue(ctx, redirectCheckerContextKey{}, checker)
+}
+
+// RedirectCheckerFromContext 用于获取上下文中的重定向检查函数，不存在时结果值为nil。
+func RedirectCheckerFromContext(ctx context.Context) RedirectChecker {
+	if ctx == nil {
+		return nil
+	}
+	checker, _ := ctx.Value(redirectCheckerContextKey{}).(RedirectChecker)
+	return checker
+}
+
+// RedirectChain 用于获取得到给定HTTP响应所经过的重定向链。
+// 其中依次是原始请求的URL、各次重定向的目标URL，最后一个是最终的URL。
+// 没有发生重定向时结果值为nil。
+func RedirectChain(httpResp *http.Response) []string {
+	if httpResp == nil {
+		return nil
+	}
+	var chain []string
+	for httpReq := httpResp.Request; httpReq != nil && httpReq.URL != nil; {
+		chain = append(chain, httpReq.URL.String())
+		if httpReq.Response == nil {
+			break
+		}
+		httpReq = httpReq.Response.Request
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
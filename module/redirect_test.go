@@ -0,0 +1,65 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectCheckerContext(t *testing.T) {
+	if checker := RedirectCheckerFromContext(context.Background()); checker != nil {
+		t.Fatal("Found redirect checker in empty context!")
+	}
+	expectedErr := errors.New("stop")
+	ctx := ContextWithRedirectChecker(context.Background(),
+		func(*http.Request, []*http.Request) error { return expectedErr })
+	checker := RedirectCheckerFromContext(ctx)
+	if checker == nil || checker(nil, nil) != expectedErr {
+		t.Fatal("Inconsistent redirect checker from context!")
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/a":
+				http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+			case "/b":
+				http.Redirect(w, r, "/c", http.StatusFound)
+			}
+		}))
+	defer server.Close()
+	httpResp, err := http.Get(server.URL + "/a")
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	httpResp.Body.Close()
+	expected := []string{server.URL + "/a", server.URL + "/b", server.URL + "/c"}
+	chain := RedirectChain(httpResp)
+	if len(chain) != len(expected) {
+		t.Fatalf("Inconsistent redirect chain: expected: %v, actual: %v", expected, chain)
+	}
+	for i, u := range chain {
+		if u != expected[i] {
+			t.Fatalf("Inconsistent redirect chain: expected: %v, actual: %v", expected, chain)
+		}
+	}
+	httpResp, _ = http.Get(server.URL + "/c")
+	httpResp.Body.Close()
+	if chain := RedirectChain(httpResp); chain != nil {
+		t.Fatalf("Found redirect chain without redirect: %v", chain)
+	}
+	// 响应中的重定向链会被复制，修改副本不会影响响应。
+	resp := NewResponse(httpResp, 0).WithRedirectChain(expected).WithMeta(Meta{ID: "1"})
+	actual := resp.RedirectChain()
+	actual[0] = ""
+	if resp.RedirectChain()[0] != expected[0] || len(resp.RedirectChain()) != len(expected) {
+		t.Fatalf("The redirect chain of response was changed: %v", resp.RedirectChain())
+	}
+	if chain := NewResponse(httpResp, 0).RedirectChain(); chain != nil {
+		t.Fatalf("Found redirect chain in new response: %v", chain)
+	}
+}
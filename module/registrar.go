@@ -4,14 +4,31 @@ import (
 	"sync"
 	"gopcpv2-web-spider/errors"
 	"fmt"
+	"sort"
 )
 
 type Registrar interface {
-	//注册
+	//注册，组件的权重为默认权重
 	Register(module Module)(bool, error)
+	//以给定的权重注册，权重不能为0，它会被按权重分配的负载均衡策略使用
+	RegisterWithWeight(module Module, weight uint32)(bool, error)
 	UnRegister(mid MID)(bool, error)
 	//获取一个指定类型的组件的实例，该函数基于负载均衡策略
 	Get(moduleType Type)(Module, error)
+	//设置指定类型的组件所用的负载均衡器
+	SetBalancer(moduleType Type, balancer Balancer)error
+	//获取指定类型的组件所用的负载均衡器，未设置时为默认策略的负载均衡器
+	GetBalancer(moduleType Type)Balancer
+	//获取已注册的组件的权重，组件未注册时为0
+	GetWeight(mid MID)uint32
+	//设置熔断器的参数，已注册的组件的熔断器会被重置
+	SetBreakerArgs(args BreakerArgs)error
+	//设置接收熔断器状态变化的函数，可以为nil
+	SetBreakerListener(listener BreakerListener)
+	//报告组件的一次调用的结果，参数err为nil代表成功，它会被组件的熔断器记录
+	Report(mid MID, err error)
+	//获取组件的熔断器的状态，未使用熔断器或组件未注册时为空
+	GetBreakerState(mid MID)BreakerState
 	GetAllByType(moduleType Type)(map[MID]Module, error)
 	GetAll()map[MID]Module
 	Clear()
@@ -19,19 +36,37 @@ type Registrar interface {
 
 type myRegistrar struct {
 	moduleTypeMap map[Type]map[MID]Module
+	//各组件的权重
+	weightMap map[MID]uint32
+	//各类型的组件所用的负载均衡器
+	balancerMap map[Type]Balancer
+	//各组件的熔断器，未使用熔断器时为空
+	breakerMap map[MID]*CircuitBreaker
+	breakerArgs BreakerArgs
+	breakerListener BreakerListener
 	rwlock sync.RWMutex
 }
 
 func NewRegistrar()Registrar{
 	return &myRegistrar{
 		moduleTypeMap: map[Type]map[MID]Module{},
+		weightMap: map[MID]uint32{},
+		balancerMap: map[Type]Balancer{},
+		breakerMap: map[MID]*CircuitBreaker{},
 	}
 }
 
 func(registrar *myRegistrar)Register(module Module)(bool, error){
+	return registrar.RegisterWithWeight(module, DefaultWeight)
+}
+
+func(registrar *myRegistrar)RegisterWithWeight(module Module, weight uint32)(bool, error){
 	if module == nil{
 		return false, errors.NewIllegalParameterError("组件实例是nil")
 	}
+	if weight == 0{
+		return false, errors.NewIllegalParameterError("组件的权重是0")
+	}
 	mid := module.ID()
 	parts, err := SplitMID(mid)
 	if err != nil{
@@ -52,6 +87,10 @@ func(registrar *myRegistrar)Register(module Module)(bool, error){
 	}
 	modules[mid] = module
 	registrar.moduleTypeMap[moduleType] = modules
+	registrar.weightMap[mid] = weight
+	if registrar.breakerArgs.Enabled(){
+		registrar.breakerMap[mid] = NewCircuitBreaker(mid, registrar.breakerArgs)
+	}
 	return true, nil
 
 }
@@ -68,6 +107,8 @@ func (registrar *myRegistrar)UnRegister(mid MID)(bool, error){
 	if modules, ok := registrar.moduleTypeMap[moduleType]; ok{
 		if _, ok := modules[mid]; ok{
 			delete(modules, mid)
+			delete(registrar.weightMap, mid)
+			delete(registrar.breakerMap, mid)
 			deleted = true
 		}
 	}
@@ -75,27 +116,137 @@ func (registrar *myRegistrar)UnRegister(mid MID)(bool, error){
 }
 
 //用于获取一个指定类型的组件的实例。
-//本函数会基于负载均衡策略返回实例。
+//本函数会基于该类型的负载均衡器返回实例，熔断器断开的组件会被跳过。
 func (registrar *myRegistrar)Get(moduleType Type)(Module, error){
-	modules, err := registrar.GetAllByType(moduleType)
-	if err != nil{
-		return nil, err
-	}
-	minScore := uint64(0)
-	var selectedModule Module
-	for _, module := range modules{
-		SetScore(module)
-		if err != nil{
-			return nil, err
+	if !LegalType(moduleType){
+		return nil, errors.NewIllegalParameterError(fmt.Sprintf("无效的模块类型：%s", moduleType))
+	}
+	registrar.rwlock.RLock()
+	modules := registrar.moduleTypeMap[moduleType]
+	candidates := make([]Candidate, 0, len(modules))
+	breakers := map[MID]*CircuitBreaker{}
+	for mid, module := range modules{
+		if breaker := registrar.breakerMap[mid]; breaker != nil{
+			if !breaker.Available(){
+				continue
+			}
+			breakers[mid] = breaker
 		}
-		score := module.Score()
-		if minScore == 0 || score < minScore{
-			selectedModule = module
-			minScore = score
+		candidates = append(candidates, Candidate{Module: module, Weight: registrar.weightMap[mid]})
+	}
+	balancer := registrar.balancerMap[moduleType]
+	listener := registrar.breakerListener
+	registrar.rwlock.RUnlock()
+	if len(modules) == 0{
+		return nil, ErrNotFoundModuleInstance
+	}
+	if balancer == nil{
+		balancer = defaultBalancer
+	}
+	sort.Slice(candidates, func(i, j int)bool{
+		return candidates[i].Module.ID() < candidates[j].Module.ID()
+	})
+	for len(candidates) > 0{
+		selected := balancer.Select(candidates)
+		breaker := breakers[selected.ID()]
+		if breaker == nil{
+			return selected, nil
+		}
+		allowed, change := breaker.Allow()
+		if change != nil && listener != nil{
+			listener(*change)
+		}
+		if allowed{
+			return selected, nil
+		}
+		// 半开的组件的试探调用可能已被其他调用者用完，换一个组件。
+		for i, c := range candidates{
+			if c.Module == selected{
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
 		}
 	}
-	return selectedModule, nil
+	return nil, ErrNoAvailableModuleInstance
+}
+
+// defaultBalancer 代表未设置负载均衡器时使用的负载均衡器。
+var defaultBalancer, _ = NewBalancer(DefaultBalanceStrategy)
+
+func (registrar *myRegistrar)SetBalancer(moduleType Type, balancer Balancer)error{
+	if !LegalType(moduleType){
+		return errors.NewIllegalParameterError(fmt.Sprintf("无效的模块类型：%s", moduleType))
+	}
+	if balancer == nil{
+		return errors.NewIllegalParameterError("负载均衡器是nil")
+	}
+	registrar.rwlock.Lock()
+	defer registrar.rwlock.Unlock()
+	registrar.balancerMap[moduleType] = balancer
+	return nil
+}
 
+func (registrar *myRegistrar)GetBalancer(moduleType Type)Balancer{
+	registrar.rwlock.RLock()
+	defer registrar.rwlock.RUnlock()
+	if balancer := registrar.balancerMap[moduleType]; balancer != nil{
+		return balancer
+	}
+	return defaultBalancer
+}
+
+func (registrar *myRegistrar)GetWeight(mid MID)uint32{
+	registrar.rwlock.RLock()
+	defer registrar.rwlock.RUnlock()
+	return registrar.weightMap[mid]
+}
+
+func (registrar *myRegistrar)SetBreakerArgs(args BreakerArgs)error{
+	if err := args.Check(); err != nil{
+		return err
+	}
+	registrar.rwlock.Lock()
+	defer registrar.rwlock.Unlock()
+	registrar.breakerArgs = args
+	registrar.breakerMap = map[MID]*CircuitBreaker{}
+	if !args.Enabled(){
+		return nil
+	}
+	for _, modules := range registrar.moduleTypeMap{
+		for mid := range modules{
+			registrar.breakerMap[mid] = NewCircuitBreaker(mid, args)
+		}
+	}
+	return nil
+}
+
+func (registrar *myRegistrar)SetBreakerListener(listener BreakerListener){
+	registrar.rwlock.Lock()
+	defer registrar.rwlock.Unlock()
+	registrar.breakerListener = listener
+}
+
+func (registrar *myRegistrar)Report(mid MID, err error){
+	registrar.rwlock.RLock()
+	breaker := registrar.breakerMap[mid]
+	listener := registrar.breakerListener
+	registrar.rwlock.RUnlock()
+	if breaker == nil{
+		return
+	}
+	if change := breaker.Record(err == nil); change != nil && listener != nil{
+		listener(*change)
+	}
+}
+
+func (registrar *myRegistrar)GetBreakerState(mid MID)BreakerState{
+	registrar.rwlock.RLock()
+	breaker := registrar.breakerMap[mid]
+	registrar.rwlock.RUnlock()
+	if breaker == nil{
+		return ""
+	}
+	return breaker.State()
 }
 
 //用于获取指定类型的所有组件实例。
@@ -134,4 +285,6 @@ func (registrar *myRegistrar)Clear(){
 	registrar.rwlock.Lock()
 	defer registrar.rwlock.Unlock()
 	registrar.moduleTypeMap = map[Type]map[MID]Module{}
+	registrar.weightMap = map[MID]uint32{}
+	registrar.breakerMap = map[MID]*CircuitBreaker{}
 }
\ No newline at end of file
@@ -0,0 +1,354 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopcpv2-web-spider/errors"
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/stub"
+)
+
+// client 代表访问组件服务器上的某个组件的客户端。
+type client struct {
+	httpClient *http.Client
+	network    string
+	addr       string
+	// moduleURL 代表组件在服务器上的URL。
+	moduleURL string
+}
+
+// newClient 用于根据给定的MID创建一个客户端，MID中必须包含组件服务器的网络地址。
+// 参数network代表访问服务器所用的协议，可以是http或https，为空时为http。
+func newClient(mid module.MID, network string, httpClient *http.Client) (*client, error) {
+	if httpClient == nil {
+		return nil, errors.NewIllegalParameterError("nil HTTP client")
+	}
+	parts, err := module.SplitMID(mid)
+	if err != nil {
+		return nil, err
+	}
+	if parts[2] == "" {
+		return nil, errors.NewIllegalParameterError(
+			fmt.Sprintf("no network address in MID %q", mid))
+	}
+	if network == "" {
+		network = "http"
+	}
+	index := strings.LastIndex(parts[2], ":")
+	port, _ := strconv.ParseUint(parts[2][index+1:], 10, 64)
+	addr, err := module.NewAddr(network, parts[2][:index], port)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		httpClient: httpClient,
+		network:    addr.Network(),
+		addr:       addr.String(),
+		moduleURL:  fmt.Sprintf("%s://%s%s%s%s", addr.Network(), addr, modulesPath, parts[0], parts[1]),
+	}, nil
+}
+
+// call 用于调用组件服务器上的组件的给定操作。
+func (c *client) call(ctx context.Context, action string, args interface{}, reply interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, c.moduleURL+"/"+action, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		content, _ := readBody(httpResp.Body)
+		return fmt.Errorf("module server error: %s: %s",
+			httpResp.Status, strings.TrimSpace(string(content)))
+	}
+	return decodeJSON(httpResp.Body, reply)
+}
+
+// remoteSummaryStruct 代表组件代理的额外信息的摘要类型。
+type remoteSummaryStruct struct {
+	Network string `json:"network"`
+	Addr    string `json:"addr"`
+}
+
+// summary 用于获取给定组件代理的摘要。
+func (c *client) summary(mi stub.ModuleInternal) module.SummaryStruct {
+	summary := mi.Summary()
+	summary.Extra = remoteSummaryStruct{Network: c.network, Addr: c.addr}
+	return summary
+}
+
+// genError 用于生成给定类型的组件的爬虫错误值。
+func genError(mType module.Type, errMsg string) error {
+	var errType errors.ErrorType
+	switch mType {
+	case module.TYPE_DOWNLOADER:
+		errType = errors.ERROR_TYPE_DOWNLOADER
+	case module.TYPE_ANALYZER:
+		errType = errors.ERROR_TYPE_ANALYZER
+	case module.TYPE_PIPELINE:
+		errType = errors.ERROR_TYPE_PIPELINE
+	}
+	return errors.NewCrawlerError(errType, errMsg)
+}
+
+// genParameterError 用于生成给定类型的组件的爬虫参数错误值。
+func genParameterError(mType module.Type, errMsg string) error {
+	return genError(mType, errors.NewIllegalParameterError(errMsg).Error())
+}
+
+// New 用于根据MID中的类型字母创建相应的组件代理。
+func New(mid module.MID, network string, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Module, error) {
+	ok, mType := module.GetType(mid)
+	if !ok {
+		return nil, errors.NewIllegalParameterError(fmt.Sprintf("invalid MID %q", mid))
+	}
+	switch mType {
+	case module.TYPE_DOWNLOADER:
+		return NewDownloader(mid, network, httpClient, scoreCalculator)
+	case module.TYPE_ANALYZER:
+		return NewAnalyzer(mid, network, httpClient, scoreCalculator)
+	default:
+		return NewPipeline(mid, network, httpClient, scoreCalculator)
+	}
+}
+
+// remoteDownloader 代表下载器的代理，它通过组件服务器上的下载器下载请求。
+type remoteDownloader struct {
+	stub.ModuleInternal
+	client *client
+}
+
+// NewDownloader 用于创建一个下载器的代理。
+// 参数mid中的网络地址是组件服务器的地址，network代表访问服务器所用的协议。
+func NewDownloader(mid module.MID, network string, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Downloader, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(mid, network, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteDownloader{ModuleInternal: moduleBase, client: c}, nil
+}
+
+func (downloader *remoteDownloader) Download(req *module.Request) (*module.Response, error) {
+	downloader.ModuleInternal.IncrHandlingNumber()
+	defer downloader.ModuleInternal.DecrHandlingNumber()
+	downloader.ModuleInternal.IncrCalledCount()
+	if req == nil || !req.Valid() {
+		return nil, genParameterError(module.TYPE_DOWNLOADER, "无效的请求")
+	}
+	downloader.ModuleInternal.IncrAcceptedCount()
+	start := time.Now()
+	resp, err := downloader.download(req)
+	downloader.ModuleInternal.RecordLatency(time.Since(start))
+	if err != nil {
+		downloader.ModuleInternal.IncrFailedCount()
+		return nil, err
+	}
+	downloader.ModuleInternal.IncrCompletedCount()
+	return resp, nil
+}
+
+// download 会请求组件服务器下载给定的请求。
+func (downloader *remoteDownloader) download(req *module.Request) (*module.Response, error) {
+	httpReq := req.HTTPReq()
+	wr, err := toWireRequest(req)
+	if err != nil {
+		return nil, genError(module.TYPE_DOWNLOADER, err.Error())
+	}
+	var reply downloadReply
+	if err := downloader.client.call(httpReq.Context(), actionDownload, wr, &reply); err != nil {
+		return nil, genError(module.TYPE_DOWNLOADER, err.Error())
+	}
+	if reply.Error != "" {
+		return nil, genError(module.TYPE_DOWNLOADER, reply.Error)
+	}
+	if reply.Response == nil {
+		return nil, genError(module.TYPE_DOWNLOADER, "nil response")
+	}
+	httpResp, err := reply.Response.httpResponse()
+	if err != nil {
+		return nil, genError(module.TYPE_DOWNLOADER, err.Error())
+	}
+	return module.NewResponse(httpResp, reply.Response.Depth).
+		WithMeta(reply.Response.Request.Meta).WithTrace(reply.Response.Trace).
+		WithRedirectChain(reply.Response.Redirects), nil
+}
+
+func (downloader *remoteDownloader) Summary() module.SummaryStruct {
+	return downloader.client.summary(downloader.ModuleInternal)
+}
+
+// remoteAnalyzer 代表分析器的代理，它通过组件服务器上的分析器解析响应。
+type remoteAnalyzer struct {
+	stub.ModuleInternal
+	client *client
+}
+
+// NewAnalyzer 用于创建一个分析器的代理。
+// 参数mid中的网络地址是组件服务器的地址，network代表访问服务器所用的协议。
+func NewAnalyzer(mid module.MID, network string, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Analyzer, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(mid, network, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteAnalyzer{ModuleInternal: moduleBase, client: c}, nil
+}
+
+// RespParsers 总是返回nil，因为响应解析函数在组件服务器上。
+func (analyzer *remoteAnalyzer) RespParsers() []module.ParseResponse {
+	return nil
+}
+
+// Analyze 会请求组件服务器解析给定的响应，响应的主体会被读出并关闭。
+// 条目经过JSON编码，所以其中的数字都会变为float64类型。
+func (analyzer *remoteAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, errorList []error) {
+	analyzer.ModuleInternal.IncrHandlingNumber()
+	defer analyzer.ModuleInternal.DecrHandlingNumber()
+	analyzer.ModuleInternal.IncrCalledCount()
+	if resp == nil || resp.HTTPResp() == nil {
+		errorList = append(errorList, genParameterError(module.TYPE_ANALYZER, "无效的响应"))
+		return
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.Request == nil || httpResp.Request.URL == nil {
+		errorList = append(errorList, genParameterError(module.TYPE_ANALYZER, "HTTP请求是nil"))
+		return
+	}
+	analyzer.ModuleInternal.IncrAcceptedCount()
+	defer func(start time.Time) {
+		analyzer.ModuleInternal.RecordLatency(time.Since(start))
+		if len(errorList) > 0 {
+			analyzer.ModuleInternal.IncrFailedCount()
+		} else {
+			analyzer.ModuleInternal.IncrCompletedCount()
+		}
+	}(time.Now())
+	wr, err := toWireResponse(httpResp, resp.Depth(), resp.Meta())
+	if err != nil {
+		errorList = append(errorList, genError(module.TYPE_ANALYZER, err.Error()))
+		return
+	}
+	var reply analyzeReply
+	if err := analyzer.client.call(httpResp.Request.Context(), actionAnalyze, wr, &reply); err != nil {
+		errorList = append(errorList, genError(module.TYPE_ANALYZER, err.Error()))
+		return
+	}
+	for _, wd := range reply.Data {
+		data, err := wd.data()
+		if err != nil {
+			errorList = append(errorList, genError(module.TYPE_ANALYZER, err.Error()))
+			continue
+		}
+		dataList = append(dataList, data)
+	}
+	for _, errMsg := range reply.Errors {
+		errorList = append(errorList, genError(module.TYPE_ANALYZER, errMsg))
+	}
+	return
+}
+
+func (analyzer *remoteAnalyzer) Summary() module.SummaryStruct {
+	return analyzer.client.summary(analyzer.ModuleInternal)
+}
+
+// remotePipeline 代表条目处理管道的代理，它通过组件服务器上的条目处理管道处理条目。
+type remotePipeline struct {
+	stub.ModuleInternal
+	client   *client
+	failFast uint32
+}
+
+// NewPipeline 用于创建一个条目处理管道的代理。
+// 参数mid中的网络地址是组件服务器的地址，network代表访问服务器所用的协议。
+func NewPipeline(mid module.MID, network string, httpClient *http.Client,
+	scoreCalculator module.CalculateScore) (module.Pipeline, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(mid, network, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &remotePipeline{ModuleInternal: moduleBase, client: c}, nil
+}
+
+// ItemProcessors 总是返回nil，因为条目处理函数在组件服务器上。
+func (pipeline *remotePipeline) ItemProcessors() []module.ProcessItem {
+	return nil
+}
+
+// Send 会请求组件服务器处理给定的条目。
+func (pipeline *remotePipeline) Send(item module.Item) (errList []error) {
+	pipeline.ModuleInternal.IncrHandlingNumber()
+	defer pipeline.ModuleInternal.DecrHandlingNumber()
+	pipeline.ModuleInternal.IncrCalledCount()
+	if item == nil {
+		return []error{genParameterError(module.TYPE_PIPELINE, "条目为空")}
+	}
+	pipeline.ModuleInternal.IncrAcceptedCount()
+	start := time.Now()
+	var reply sendReply
+	if err := pipeline.client.call(context.Background(), actionSend, item, &reply); err != nil {
+		errList = append(errList, genError(module.TYPE_PIPELINE, err.Error()))
+	}
+	for _, errMsg := range reply.Errors {
+		errList = append(errList, genError(module.TYPE_PIPELINE, errMsg))
+	}
+	pipeline.ModuleInternal.RecordLatency(time.Since(start))
+	if len(errList) == 0 {
+		pipeline.ModuleInternal.IncrCompletedCount()
+	} else {
+		pipeline.ModuleInternal.IncrFailedCount()
+	}
+	return errList
+}
+
+func (pipeline *remotePipeline) FailFast() bool {
+	return atomic.LoadUint32(&pipeline.failFast) == 1
+}
+
+// SetFailFast 会设置组件服务器上的条目处理管道是否快速失败，设置失败时只会记入日志。
+func (pipeline *remotePipeline) SetFailFast(failFast bool) {
+	var value uint32
+	if failFast {
+		value = 1
+	}
+	atomic.StoreUint32(&pipeline.failFast, value)
+	var reply failFastArgs
+	if err := pipeline.client.call(context.Background(), actionFailFast, failFastArgs{FailFast: failFast}, &reply); err != nil {
+		log.Printf("设置条目处理管道%s是否快速失败时出错：%s", pipeline.ID(), err)
+	}
+}
+
+func (pipeline *remotePipeline) Summary() module.SummaryStruct {
+	return pipeline.client.summary(pipeline.ModuleInternal)
+}
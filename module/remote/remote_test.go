@@ -0,0 +1,318 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+	"gopcpv2-web-spider/module/local/downloader"
+	"gopcpv2-web-spider/module/local/pipeline"
+	"gopcpv2-web-spider/scheduler"
+)
+
+// newSite 用于创建一个测试用的网站。
+// 首页中每行是一个链接，“/echo”会回显请求的主体。
+func newSite() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				fmt.Fprint(w, "/a\n/b\n")
+			case "/echo":
+				w.Header().Set("X-Method", r.Method)
+				body, _ := ioutil.ReadAll(r.Body)
+				w.Write(body)
+			}
+		}))
+}
+
+// parseLines 会把响应中的每个非空行解析为一个请求，并为响应生成一个条目。
+func parseLines(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+	dataList := []module.Data{module.Item{"url": httpResp.Request.URL.String()}}
+	scanner := bufio.NewScanner(httpResp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		u, err := httpResp.Request.URL.Parse(line)
+		if err != nil {
+			return dataList, []error{err}
+		}
+		httpReq, _ := http.NewRequest("GET", u.String(), nil)
+		dataList = append(dataList, module.NewRequest(httpReq, respDepth))
+	}
+	return dataList, nil
+}
+
+// itemRecorder 代表记录条目的条目处理函数，URL为“fail”的条目会导致错误。
+type itemRecorder struct {
+	lock  sync.Mutex
+	items []module.Item
+}
+
+func (ir *itemRecorder) process(item module.Item) (module.Item, error) {
+	if item["url"] == "fail" {
+		return nil, errors.New("illegal item")
+	}
+	ir.lock.Lock()
+	defer ir.lock.Unlock()
+	ir.items = append(ir.items, item)
+	return nil, nil
+}
+
+func (ir *itemRecorder) number() int {
+	ir.lock.Lock()
+	defer ir.lock.Unlock()
+	return len(ir.items)
+}
+
+// localModules 代表组件服务器上的本地组件。
+type localModules struct {
+	downloader module.Downloader
+	analyzer   module.Analyzer
+	pipeline   module.Pipeline
+	recorder   *itemRecorder
+}
+
+// newModuleServer 用于创建一个提供本地组件的服务的组件服务器，结果值addr是它的网络地址。
+func newModuleServer(t *testing.T) (server *httptest.Server, addr string, locals localModules) {
+	var err error
+	locals.recorder = &itemRecorder{}
+	if locals.downloader, err = downloader.New("D1", &http.Client{}, nil); err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	if locals.analyzer, err = analyzer.New("A2", []module.ParseResponse{parseLines}, nil); err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	locals.pipeline, err = pipeline.New("P3", []module.ProcessItem{locals.recorder.process}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	modServer, err := NewServer(locals.downloader, locals.analyzer, locals.pipeline)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a module server: %s", err)
+	}
+	server = httptest.NewServer(modServer)
+	return server, strings.TrimPrefix(server.URL, "http://"), locals
+}
+
+func TestNewServer(t *testing.T) {
+	if _, err := NewServer(); err == nil {
+		t.Fatal("No error when creating module server without modules!")
+	}
+	d1, _ := downloader.New("D1", &http.Client{}, nil)
+	d2, _ := downloader.New("D1|127.0.0.1:8080", &http.Client{}, nil)
+	if _, err := NewServer(d1, nil); err == nil {
+		t.Fatal("No error when creating module server with nil module!")
+	}
+	if _, err := NewServer(d1, d2); err == nil {
+		t.Fatal("No error when creating module server with duplicate modules!")
+	}
+	server, _, _ := newModuleServer(t)
+	defer server.Close()
+	resp, err := http.Get(server.URL + modulesPath)
+	if err != nil {
+		t.Fatalf("An error occurs when listing modules: %s", err)
+	}
+	var mids []module.MID
+	json.NewDecoder(resp.Body).Decode(&mids)
+	resp.Body.Close()
+	if fmt.Sprint(mids) != "[A2 D1 P3]" {
+		t.Fatalf("Inconsistent modules: %v", mids)
+	}
+	cases := map[string]int{
+		"/modules/D9/download": http.StatusNotFound,
+		"/modules/D1/send":     http.StatusNotFound,
+		"/modules/D1/download": http.StatusBadRequest,
+		"/other":               http.StatusNotFound,
+	}
+	for path, expected := range cases {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader("{"))
+		if err != nil {
+			t.Fatalf("An error occurs when posting to %s: %s", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Fatalf("Inconsistent status code of %s: expected: %d, actual: %d",
+				path, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	client := &http.Client{}
+	if _, err := NewDownloader("D1", "", client, nil); err == nil {
+		t.Fatal("No error when creating proxy with MID without address!")
+	}
+	if _, err := NewDownloader("D1|127.0.0.1:8080", "ftp", client, nil); err == nil {
+		t.Fatal("No error when creating proxy with illegal network!")
+	}
+	if _, err := NewDownloader("D1|127.0.0.1:8080", "", nil, nil); err == nil {
+		t.Fatal("No error when creating proxy with nil HTTP client!")
+	}
+	mids := []module.MID{"D1|127.0.0.1:8080", "A2|127.0.0.1:8080", "P3|127.0.0.1:8080"}
+	for _, mid := range mids {
+		m, err := New(mid, "https", client, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating proxy: %s (mid: %s)", err, mid)
+		}
+		if ok, mType := module.GetType(mid); !ok || !module.CheckType(mType, m) {
+			t.Fatalf("Inconsistent proxy type: %T (mid: %s)", m, mid)
+		}
+		extra := m.Summary().Extra.(remoteSummaryStruct)
+		if extra.Network != "https" || extra.Addr != "127.0.0.1:8080" {
+			t.Fatalf("Inconsistent proxy summary: %#v", extra)
+		}
+	}
+}
+
+func TestRemoteModules(t *testing.T) {
+	site := newSite()
+	defer site.Close()
+	server, addr, locals := newModuleServer(t)
+	defer server.Close()
+	client := &http.Client{}
+	d, err := NewDownloader(module.MID("D1|"+addr), "", client, module.CalculateScoreByHealth)
+	if err != nil {
+		t.Fatalf("An error occurs when creating downloader proxy: %s", err)
+	}
+	a, _ := NewAnalyzer(module.MID("A2|"+addr), "", client, nil)
+	p, _ := NewPipeline(module.MID("P3|"+addr), "", client, nil)
+	// 请求的方法、主体和深度都会被传递。
+	httpReq, _ := http.NewRequest("POST", site.URL+"/echo", strings.NewReader("hello"))
+	resp, err := d.Download(module.NewRequest(httpReq, 2))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.HTTPResp().Body)
+	if string(body) != "hello" || resp.Depth() != 2 ||
+		resp.HTTPResp().Header.Get("X-Method") != "POST" ||
+		resp.HTTPResp().Request.URL.String() != site.URL+"/echo" {
+		t.Fatalf("Inconsistent response: %q (depth: %d, header: %v)",
+			body, resp.Depth(), resp.HTTPResp().Header)
+	}
+	if locals.downloader.CompletedCount() != 1 || d.CompletedCount() != 1 {
+		t.Fatalf("Inconsistent completed count: remote: %d, proxy: %d",
+			locals.downloader.CompletedCount(), d.CompletedCount())
+	}
+	// 请求的元数据会随请求传递并转入响应。
+	meta := module.Meta{ID: "seed", Priority: 1, RetryCount: 1,
+		Attrs: map[string]string{"topic": "go"}}
+	httpReq, _ = http.NewRequest("GET", site.URL+"/", nil)
+	resp, err = d.Download(module.NewRequest(httpReq, 0).WithMeta(meta))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	if respMeta := resp.Meta(); respMeta.ID != meta.ID || respMeta.Priority != meta.Priority ||
+		respMeta.RetryCount != meta.RetryCount || respMeta.Attr("topic") != "go" {
+		t.Fatalf("Inconsistent meta of response: expected: %#v, actual: %#v", meta, respMeta)
+	}
+	// 组件服务器上记录的下载耗时会随响应传递。
+	if trace := resp.Trace(); trace.Total <= 0 || trace.Bytes <= 0 ||
+		trace.FinalURL != site.URL+"/" || trace.RemoteIP != "127.0.0.1" {
+		t.Fatalf("Inconsistent trace of response: %#v", trace)
+	}
+	dataList, errs := a.Analyze(resp)
+	if len(errs) != 0 || len(dataList) != 3 {
+		t.Fatalf("Inconsistent analyze result: %v (errors: %v)", dataList, errs)
+	}
+	if item, ok := dataList[0].(module.Item); !ok || item["url"] != site.URL+"/" {
+		t.Fatalf("Inconsistent item: %#v", dataList[0])
+	}
+	for i, path := range []string{"/a", "/b"} {
+		req, ok := dataList[i+1].(*module.Request)
+		if !ok || req.HTTPReq().URL.String() != site.URL+path || req.Depth() != 1 {
+			t.Fatalf("Inconsistent request: %#v", dataList[i+1])
+		}
+		if reqMeta := req.Meta(); reqMeta.ID == "" || reqMeta.ParentURL != site.URL+"/" ||
+			reqMeta.Referrer != site.URL+"/" || reqMeta.Attr("topic") != "go" {
+			t.Fatalf("Inconsistent meta of request: %#v", reqMeta)
+		}
+	}
+	if errs := p.Send(dataList[0].(module.Item)); len(errs) != 0 {
+		t.Fatalf("An error occurs when sending item: %v", errs)
+	}
+	if errs := p.Send(module.Item{"url": "fail"}); len(errs) != 1 ||
+		!strings.Contains(errs[0].Error(), "illegal item") {
+		t.Fatalf("Inconsistent errors when sending illegal item: %v", errs)
+	}
+	if locals.recorder.number() != 1 {
+		t.Fatalf("Inconsistent item number: expected: %d, actual: %d",
+			1, locals.recorder.number())
+	}
+	p.SetFailFast(true)
+	if !p.FailFast() || !locals.pipeline.FailFast() {
+		t.Fatal("Inconsistent fail fast setting!")
+	}
+	// 组件服务器上的下载失败会作为错误返回。
+	httpReq, _ = http.NewRequest("GET", "http://127.0.0.1:1/", nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when downloading from unreachable site!")
+	}
+	if counts := d.Counts(); counts.FailedCount != 1 || counts.Latency.Total() != 3 {
+		t.Fatalf("Inconsistent counts of downloader proxy: %#v", counts)
+	}
+	// 组件服务器不可用时，调用会失败。
+	server.Close()
+	if errs := p.Send(module.Item{"url": "x"}); len(errs) != 1 {
+		t.Fatalf("Inconsistent errors when module server is down: %v", errs)
+	}
+}
+
+func TestRemoteSched(t *testing.T) {
+	site := newSite()
+	defer site.Close()
+	server, addr, locals := newModuleServer(t)
+	defer server.Close()
+	client := &http.Client{}
+	d, _ := NewDownloader(module.MID("D1|"+addr), "", client, nil)
+	a, _ := NewAnalyzer(module.MID("A2|"+addr), "", client, nil)
+	p, _ := NewPipeline(module.MID("P3|"+addr), "", client, nil)
+	sched := scheduler.NewScheduler()
+	err := sched.Init(
+		scheduler.RequestArgs{AcceptedDomains: []string{"127.0.0.1"}, MaxDepth: 1},
+		scheduler.DataArgs{
+			ReqBufferCap: 10, ReqMaxBufferNumber: 2,
+			RespBufferCap: 10, RespMaxBufferNumber: 2,
+			ItemBufferCap: 10, ItemMaxBufferNumber: 2,
+			ErrorBufferCap: 10, ErrorMaxBufferNumber: 2,
+		},
+		scheduler.ModuleArgs{
+			Downloaders: []module.Downloader{d},
+			Analyzers:   []module.Analyzer{a},
+			Pipelines:   []module.Pipeline{p},
+		})
+	if err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", site.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 首页及其中的两个链接都被组件服务器下载、解析和处理了。
+	if completed := locals.downloader.CompletedCount(); completed != 3 {
+		t.Fatalf("Inconsistent completed count of remote downloader: expected: %d, actual: %d",
+			3, completed)
+	}
+	if number := locals.recorder.number(); number != 3 {
+		t.Fatalf("Inconsistent item number: expected: %d, actual: %d", 3, number)
+	}
+}
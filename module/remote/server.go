@@ -0,0 +1,221 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopcpv2-web-spider/errors"
+	"gopcpv2-web-spider/module"
+)
+
+// modulesPath 代表组件服务器上各组件的路径的前缀。
+// 组件的路径为该前缀加上组件的类型字母和序列号，例如“/modules/D1”。
+const modulesPath = "/modules/"
+
+// 组件服务器支持的操作，它们是组件的路径之下的子路径。
+const (
+	actionDownload = "download"
+	actionAnalyze  = "analyze"
+	actionSend     = "send"
+	actionFailFast = "fail_fast"
+)
+
+// Server 代表组件服务器，它通过HTTP+JSON向其他进程提供本地组件的服务。
+// 组件以类型字母和序列号（例如“D1”）标识，MID中的网络地址会被忽略，
+// 所以代理可以使用带有服务器地址的MID（例如“D1|127.0.0.1:8080”）访问组件。
+type Server struct {
+	modules map[module.MID]module.Module
+}
+
+// NewServer 用于创建一个提供给定组件的服务的组件服务器。
+func NewServer(modules ...module.Module) (*Server, error) {
+	if len(modules) == 0 {
+		return nil, errors.NewIllegalParameterError("empty module list")
+	}
+	server := &Server{modules: map[module.MID]module.Module{}}
+	for i, m := range modules {
+		if m == nil {
+			return nil, errors.NewIllegalParameterError(fmt.Sprintf("nil module [%d]", i))
+		}
+		key, err := moduleKey(m.ID())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := server.modules[key]; ok {
+			return nil, errors.NewIllegalParameterError(
+				fmt.Sprintf("duplicate module %q", key))
+		}
+		server.modules[key] = m
+	}
+	return server, nil
+}
+
+// moduleKey 用于获取组件在服务器上的标识，即MID中的类型字母和序列号。
+func moduleKey(mid module.MID) (module.MID, error) {
+	parts, err := module.SplitMID(mid)
+	if err != nil {
+		return "", err
+	}
+	return module.MID(parts[0] + parts[1]), nil
+}
+
+// Modules 用于获取服务器提供的所有组件的标识，已排序。
+func (server *Server) Modules() []module.MID {
+	keys := make([]module.MID, 0, len(server.modules))
+	for key := range server.modules {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == modulesPath || r.URL.Path == strings.TrimSuffix(modulesPath, "/") {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, server.Modules())
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, modulesPath) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, modulesPath), "/", 2)
+	m, ok := server.modules[module.MID(parts[0])]
+	if !ok {
+		http.Error(w, fmt.Sprintf("module %q not found", parts[0]), http.StatusNotFound)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, m.Summary())
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var handle func(w http.ResponseWriter, r *http.Request)
+	switch parts[1] {
+	case actionDownload:
+		if d, ok := m.(module.Downloader); ok {
+			handle = func(w http.ResponseWriter, r *http.Request) { server.download(d, w, r) }
+		}
+	case actionAnalyze:
+		if a, ok := m.(module.Analyzer); ok {
+			handle = func(w http.ResponseWriter, r *http.Request) { server.analyze(a, w, r) }
+		}
+	case actionSend:
+		if p, ok := m.(module.Pipeline); ok {
+			handle = func(w http.ResponseWriter, r *http.Request) { server.send(p, w, r) }
+		}
+	case actionFailFast:
+		if p, ok := m.(module.Pipeline); ok {
+			handle = func(w http.ResponseWriter, r *http.Request) { server.setFailFast(p, w, r) }
+		}
+	}
+	if handle == nil {
+		http.Error(w, fmt.Sprintf("unsupported action %q of module %q", parts[1], parts[0]),
+			http.StatusNotFound)
+		return
+	}
+	handle(w, r)
+}
+
+// download 会使用给定的下载器下载请求。
+// 请求会使用调用方的上下文，所以调用方取消调用时下载也会被取消。
+func (server *Server) download(d module.Downloader, w http.ResponseWriter, r *http.Request) {
+	var wr wireRequest
+	if err := decodeJSON(r.Body, &wr); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	req, err := wr.request()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	req = module.NewRequest(req.HTTPReq().WithContext(r.Context()), req.Depth()).
+		WithMeta(req.Meta())
+	var reply downloadReply
+	resp, err := d.Download(req)
+	if err != nil {
+		reply.Error = err.Error()
+	} else if resp == nil || resp.HTTPResp() == nil {
+		reply.Error = "nil response"
+	} else if reply.Response, err = toWireResponse(resp.HTTPResp(), resp.Depth(), resp.Meta()); err != nil {
+		reply.Error = err.Error()
+	} else {
+		reply.Response.Trace = resp.Trace()
+		reply.Response.Redirects = resp.RedirectChain()
+	}
+	writeJSON(w, reply)
+}
+
+// analyze 会使用给定的分析器解析响应。
+func (server *Server) analyze(a module.Analyzer, w http.ResponseWriter, r *http.Request) {
+	var wr wireResponse
+	if err := decodeJSON(r.Body, &wr); err != nil {
+		http.Error(w, fmt.Sprintf("invalid response: %s", err), http.StatusBadRequest)
+		return
+	}
+	httpResp, err := wr.httpResponse()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid response: %s", err), http.StatusBadRequest)
+		return
+	}
+	dataList, errs := a.Analyze(module.NewResponse(httpResp, wr.Depth).WithMeta(wr.Request.Meta))
+	reply := analyzeReply{Errors: errorStrings(errs)}
+	for _, data := range dataList {
+		if data == nil {
+			continue
+		}
+		wd, err := toWireData(data)
+		if err != nil {
+			reply.Errors = append(reply.Errors, err.Error())
+			continue
+		}
+		reply.Data = append(reply.Data, wd)
+	}
+	writeJSON(w, reply)
+}
+
+// send 会使用给定的条目处理管道处理条目。
+func (server *Server) send(p module.Pipeline, w http.ResponseWriter, r *http.Request) {
+	var item module.Item
+	if err := decodeJSON(r.Body, &item); err != nil {
+		http.Error(w, fmt.Sprintf("invalid item: %s", err), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, sendReply{Errors: errorStrings(p.Send(item))})
+}
+
+// setFailFast 会设置给定的条目处理管道是否快速失败。
+func (server *Server) setFailFast(p module.Pipeline, w http.ResponseWriter, r *http.Request) {
+	var args failFastArgs
+	if err := decodeJSON(r.Body, &args); err != nil {
+		http.Error(w, fmt.Sprintf("invalid arguments: %s", err), http.StatusBadRequest)
+		return
+	}
+	p.SetFailFast(args.FailFast)
+	writeJSON(w, args)
+}
+
+// writeJSON 用于把给定的值编码为JSON并写入响应。
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("写入组件服务器的响应失败：%s", err)
+	}
+}
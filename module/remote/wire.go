@@ -0,0 +1,226 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"gopcpv2-web-spider/module"
+)
+
+// maxMessageSize 代表一条消息的最大字节数，它限制了随消息传递的HTTP主体的大小。
+const maxMessageSize = 64 << 20
+
+// wireRequest 代表在网络上传递的请求。
+type wireRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Depth  uint32      `json:"depth"`
+	Meta   module.Meta `json:"meta"`
+}
+
+// wireResponse 代表在网络上传递的响应。
+type wireResponse struct {
+	// Request 代表得到该响应的（重定向之后的）请求。
+	Request    wireRequest `json:"request"`
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Depth      uint32      `json:"depth"`
+	// Trace 代表组件服务器上的下载器记录的下载耗时及传输情况。
+	Trace module.Trace `json:"trace"`
+	// Redirects 代表得到该响应所经过的重定向链。
+	Redirects []string `json:"redirects,omitempty"`
+}
+
+// wireData 代表在网络上传递的数据，Request和Item中有且仅有一个不为nil。
+type wireData struct {
+	Request *wireRequest `json:"request,omitempty"`
+	Item    module.Item  `json:"item,omitempty"`
+}
+
+// downloadReply 代表下载的结果。
+type downloadReply struct {
+	Response *wireResponse `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// analyzeReply 代表解析的结果。
+type analyzeReply struct {
+	Data   []wireData `json:"data,omitempty"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+// sendReply 代表条目处理的结果。
+type sendReply struct {
+	Errors []string `json:"errors,omitempty"`
+}
+
+// failFastArgs 代表设置条目处理管道是否快速失败的参数。
+type failFastArgs struct {
+	FailFast bool `json:"fail_fast"`
+}
+
+// readBody 用于读取并关闭HTTP主体，读取的字节数不会超过maxMessageSize。
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(body, maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxMessageSize {
+		return nil, fmt.Errorf("body is larger than %d bytes", maxMessageSize)
+	}
+	return content, nil
+}
+
+// toWireRequest 用于把请求转换为可在网络上传递的形式，请求的主体会被读出并重置。
+func toWireRequest(req *module.Request) (*wireRequest, error) {
+	httpReq := req.HTTPReq()
+	wr := &wireRequest{
+		Method: httpReq.Method,
+		URL:    httpReq.URL.String(),
+		Header: httpReq.Header,
+		Depth:  req.Depth(),
+		Meta:   req.Meta(),
+	}
+	if httpReq.Body != nil && httpReq.Body != http.NoBody {
+		body, err := readBody(httpReq.Body)
+		if err != nil {
+			return nil, err
+		}
+		wr.Body = body
+		httpReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return wr, nil
+}
+
+// httpRequest 用于把请求还原为HTTP请求。
+func (wr *wireRequest) httpRequest() (*http.Request, error) {
+	var body io.Reader
+	if len(wr.Body) > 0 {
+		body = bytes.NewReader(wr.Body)
+	}
+	httpReq, err := http.NewRequest(wr.Method, wr.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range wr.Header {
+		httpReq.Header[key] = values
+	}
+	return httpReq, nil
+}
+
+// request 用于把请求还原为爬虫请求，其元数据也会被还原。
+func (wr *wireRequest) request() (*module.Request, error) {
+	httpReq, err := wr.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+	return module.NewRequest(httpReq, wr.Depth).WithMeta(wr.Meta), nil
+}
+
+// toWireResponse 用于把响应转换为可在网络上传递的形式，响应的主体会被读出并关闭。
+// 得到该响应的请求的元数据会随Request字段传递。
+func toWireResponse(httpResp *http.Response, depth uint32, meta module.Meta) (*wireResponse, error) {
+	body, err := readBody(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	wr := &wireResponse{
+		StatusCode: httpResp.StatusCode,
+		Status:     httpResp.Status,
+		Header:     httpResp.Header,
+		Body:       body,
+		Depth:      depth,
+		Request:    wireRequest{Depth: depth, Meta: meta},
+	}
+	if httpReq := httpResp.Request; httpReq != nil && httpReq.URL != nil {
+		wr.Request = wireRequest{
+			Method: httpReq.Method,
+			URL:    httpReq.URL.String(),
+			Header: httpReq.Header,
+			Depth:  depth,
+			Meta:   meta,
+		}
+	}
+	return wr, nil
+}
+
+// httpResponse 用于把响应还原为HTTP响应，其中的Request字段也会被还原。
+func (wr *wireResponse) httpResponse() (*http.Response, error) {
+	httpReq, err := wr.Request.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+	header := wr.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        wr.Status,
+		StatusCode:    wr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(wr.Body)),
+		ContentLength: int64(len(wr.Body)),
+		Request:       httpReq,
+	}, nil
+}
+
+// toWireData 用于把解析出的数据转换为可在网络上传递的形式。
+func toWireData(data module.Data) (wireData, error) {
+	switch d := data.(type) {
+	case *module.Request:
+		if !d.Valid() {
+			return wireData{}, fmt.Errorf("invalid request: %v", d)
+		}
+		wr, err := toWireRequest(d)
+		if err != nil {
+			return wireData{}, err
+		}
+		return wireData{Request: wr}, nil
+	case module.Item:
+		return wireData{Item: d}, nil
+	}
+	return wireData{}, fmt.Errorf("unsupported data type: %T", data)
+}
+
+// data 用于把数据还原为请求或条目。
+func (wd wireData) data() (module.Data, error) {
+	if wd.Request != nil {
+		return wd.Request.request()
+	}
+	if wd.Item != nil {
+		return wd.Item, nil
+	}
+	return nil, fmt.Errorf("empty data")
+}
+
+// errorStrings 用于把错误值的列表转换为字符串的列表。
+func errorStrings(errs []error) []string {
+	var result []string
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err.Error())
+		}
+	}
+	return result
+}
+
+// decodeJSON 用于从给定的HTTP主体中解码一条消息。
+func decodeJSON(body io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(body, maxMessageSize))
+	return decoder.Decode(v)
+}
@@ -1,5 +1,10 @@
 package module
 
+import (
+	"math"
+	"time"
+)
+
 type CalculateScore func(counts Counts)uint64
 
 //代表简易的组件评分计算函数。
@@ -7,6 +12,37 @@ func CalculateScoreSimple(counts Counts)uint64{
 	return counts.CalledCount + counts.AcceptedCount<<1 + counts.CompletedCount<<2 + counts.HandlingNumber<<4
 }
 
+// healthErrorPenalty 代表错误率对评分的惩罚系数，错误率为100%时评分变为原来的(1+该系数)倍。
+const healthErrorPenalty = 4.0
+
+// healthLatencyReference 代表评分时的参考延迟，平均延迟每达到一次该值，评分就再增加一倍。
+const healthLatencyReference = 500 * time.Millisecond
+
+//代表考虑错误率和延迟的组件评分计算函数。
+//它在简易评分的基础上，按已接受的调用中失败的比例和延迟的移动平均值放大评分，
+//所以正在失败或响应缓慢的组件会较少地被选中。
+func CalculateScoreByHealth(counts Counts)uint64{
+	base := float64(CalculateScoreSimple(counts))
+	// 还没有被使用过的组件也应该有机会被选中，所以以1为底。
+	if base == 0{
+		base = 1
+	}
+	errorFactor := 1.0
+	if counts.AcceptedCount > 0{
+		errorRate := float64(counts.FailedCount) / float64(counts.AcceptedCount)
+		if errorRate > 1{
+			errorRate = 1
+		}
+		errorFactor += healthErrorPenalty * errorRate
+	}
+	latencyFactor := 1 + float64(counts.LatencyEWMA) / float64(healthLatencyReference)
+	score := base * errorFactor * latencyFactor
+	if score >= math.MaxUint64{
+		return math.MaxUint64
+	}
+	return uint64(score)
+}
+
 // SetScore 用于设置给定组件的评分。
 // 结果值代表是否更新了评分。
 func SetScore(module Module)bool{
@@ -1,6 +1,9 @@
 package module
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestCalculateScoreSimple(t *testing.T) {
 	counts := Counts{
@@ -21,6 +24,48 @@ func TestCalculateScoreSimple(t *testing.T) {
 	t.Logf("The score is %d.", score)
 }
 
+func TestCalculateScoreByHealth(t *testing.T) {
+	healthy := Counts{
+		CalledCount:    100,
+		AcceptedCount:  100,
+		CompletedCount: 100,
+	}
+	baseScore := CalculateScoreSimple(healthy)
+	if score := CalculateScoreByHealth(healthy); score != baseScore {
+		t.Fatalf("Inconsistent score: expected: %d, actual: %d", baseScore, score)
+	}
+	if score := CalculateScoreByHealth(Counts{}); score != 1 {
+		t.Fatalf("Inconsistent score of unused module: expected: %d, actual: %d", 1, score)
+	}
+	failing := healthy
+	failing.FailedCount = 50
+	if score, expected := CalculateScoreByHealth(failing), baseScore*3; score != expected {
+		t.Fatalf("Inconsistent score of failing module: expected: %d, actual: %d",
+			expected, score)
+	}
+	slow := healthy
+	slow.LatencyEWMA = 2 * healthLatencyReference
+	if score, expected := CalculateScoreByHealth(slow), baseScore*3; score != expected {
+		t.Fatalf("Inconsistent score of slow module: expected: %d, actual: %d",
+			expected, score)
+	}
+	// 相同负载下，失败较多或较慢的组件的评分更高，因而较少被选中。
+	if CalculateScoreByHealth(failing) <= CalculateScoreByHealth(healthy) ||
+		CalculateScoreByHealth(slow) <= CalculateScoreByHealth(healthy) {
+		t.Fatal("Unhealthy module doesn't have a higher score!")
+	}
+	huge := Counts{
+		HandlingNumber: 1 << 59,
+		AcceptedCount:  1,
+		FailedCount:    1,
+		LatencyEWMA:    time.Hour,
+	}
+	if score := CalculateScoreByHealth(huge); score != ^uint64(0) {
+		t.Fatalf("Inconsistent score of overloaded module: expected: %d, actual: %d",
+			^uint64(0), score)
+	}
+}
+
 func TestSetScore(t *testing.T) {
 	fakeModule := NewFakeDownloader(MID("D0"), nil)
 	ok := SetScore(fakeModule)
@@ -2,6 +2,7 @@ package stub
 
 import (
 	"gopcpv2-web-spider/module"
+	"time"
 )
 
 type ModuleInternal interface {
@@ -11,5 +12,9 @@ type ModuleInternal interface {
 	IncrCompletedCount()
 	IncrHandlingNumber()
 	DecrHandlingNumber()
+	// IncrFailedCount 用于增加已接受但未能成功完成的调用的计数。
+	IncrFailedCount()
+	// RecordLatency 用于记录一次已接受的调用的延迟。
+	RecordLatency(latency time.Duration)
 	Clear()
 }
\ No newline at end of file
@@ -1,31 +1,39 @@
 package stub
 
 import (
-	"gopcpv2-web-spider/module"
 	"fmt"
-	"sync/atomic"
 	"gopcpv2-web-spider/errors"
+	"gopcpv2-web-spider/module"
+	"sync/atomic"
+	"time"
 )
 
+// latencyEWMAAlpha 代表计算延迟的指数加权移动平均值时最新一次延迟所占的比重。
+const latencyEWMAAlpha = 0.2
+
 type myModule struct {
-	mid module.MID
-	addr string
-	score uint64
+	mid             module.MID
+	addr            string
+	score           uint64
 	scoreCalculator module.CalculateScore
-	calledCount uint64
-	acceptedCount uint64
-	completedCount uint64
-	handlingNumber uint64
+	calledCount     uint64
+	acceptedCount   uint64
+	completedCount  uint64
+	handlingNumber  uint64
+	failedCount     uint64
+	// latencyEWMA 代表延迟的指数加权移动平均值，单位为纳秒。
+	latencyEWMA    int64
+	latencyBuckets [module.LatencyBucketNumber]uint64
 }
 
-func NewModuleInternal(mid module.MID, scoreCalculator module.CalculateScore)(ModuleInternal, error){
+func NewModuleInternal(mid module.MID, scoreCalculator module.CalculateScore) (ModuleInternal, error) {
 	parts, err := module.SplitMID(mid)
-	if err != nil{
+	if err != nil {
 		return nil, errors.NewIllegalParameterError(fmt.Sprintf("无效的ID%q: %s", mid, err))
 	}
 	return &myModule{
-		mid: mid,
-		addr: parts[2],
+		mid:             mid,
+		addr:            parts[2],
 		scoreCalculator: scoreCalculator,
 	}, nil
 }
@@ -72,45 +80,89 @@ func (m *myModule) Counts() module.Counts {
 		AcceptedCount:  atomic.LoadUint64(&m.acceptedCount),
 		CompletedCount: atomic.LoadUint64(&m.completedCount),
 		HandlingNumber: atomic.LoadUint64(&m.handlingNumber),
+		FailedCount:    atomic.LoadUint64(&m.failedCount),
+		LatencyEWMA:    time.Duration(atomic.LoadInt64(&m.latencyEWMA)),
+		Latency:        m.latency(),
 	}
 }
 
+// latency 用于获取延迟直方图的快照。
+func (m *myModule) latency() module.LatencyHistogram {
+	var histogram module.LatencyHistogram
+	for i := range m.latencyBuckets {
+		histogram[i] = atomic.LoadUint64(&m.latencyBuckets[i])
+	}
+	return histogram
+}
+
 func (m *myModule) Summary() module.SummaryStruct {
 	counts := m.Counts()
 	return module.SummaryStruct{
-		ID:        m.ID(),
-		Called:    counts.CalledCount,
-		Accepted:  counts.AcceptedCount,
-		Completed: counts.CompletedCount,
-		Handling:  counts.HandlingNumber,
-		Extra:     nil,
+		ID:          m.ID(),
+		Called:      counts.CalledCount,
+		Accepted:    counts.AcceptedCount,
+		Completed:   counts.CompletedCount,
+		Handling:    counts.HandlingNumber,
+		Failed:      counts.FailedCount,
+		LatencyEWMA: counts.LatencyEWMA,
+		Latency:     counts.Latency,
+		Extra:       nil,
 	}
 }
 
-
-func (m *myModule)IncrCalledCount(){
+func (m *myModule) IncrCalledCount() {
 	atomic.AddUint64(&m.calledCount, 1)
 }
 
-func (m *myModule)IncrAcceptedCount(){
+func (m *myModule) IncrAcceptedCount() {
 	atomic.AddUint64(&m.acceptedCount, 1)
 }
 
-func (m *myModule)IncrCompletedCount(){
+func (m *myModule) IncrCompletedCount() {
 	atomic.AddUint64(&m.completedCount, 1)
 }
 
-func (m *myModule)IncrHandlingNumber(){
+func (m *myModule) IncrHandlingNumber() {
 	atomic.AddUint64(&m.handlingNumber, 1)
 }
 
-func (m *myModule)DecrHandlingNumber(){
+func (m *myModule) DecrHandlingNumber() {
 	atomic.AddUint64(&m.handlingNumber, ^uint64(0))
 }
 
-func (m *myModule)Clear(){
+func (m *myModule) IncrFailedCount() {
+	atomic.AddUint64(&m.failedCount, 1)
+}
+
+func (m *myModule) RecordLatency(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	atomic.AddUint64(&m.latencyBuckets[module.LatencyBucketIndex(latency)], 1)
+	for {
+		old := atomic.LoadInt64(&m.latencyEWMA)
+		// 第一次记录时直接使用该延迟，以免平均值从0开始缓慢上升。
+		newValue := int64(latency)
+		if old != 0 {
+			newValue = old + int64(latencyEWMAAlpha*float64(int64(latency)-old))
+			if newValue == 0 {
+				newValue = 1
+			}
+		}
+		if atomic.CompareAndSwapInt64(&m.latencyEWMA, old, newValue) {
+			return
+		}
+	}
+}
+
+func (m *myModule) Clear() {
 	atomic.StoreUint64(&m.calledCount, 0)
 	atomic.StoreUint64(&m.acceptedCount, 0)
 	atomic.StoreUint64(&m.completedCount, 0)
 	atomic.StoreUint64(&m.handlingNumber, 0)
-}
\ No newline at end of file
+	atomic.StoreUint64(&m.failedCount, 0)
+	atomic.StoreInt64(&m.latencyEWMA, 0)
+	for i := range m.latencyBuckets {
+		atomic.StoreUint64(&m.latencyBuckets[i], 0)
+	}
+}
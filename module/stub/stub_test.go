@@ -2,6 +2,7 @@ package stub
 
 import (
 	"testing"
+	"time"
 	"gopcpv2-web-spider/module"
 )
 
@@ -133,6 +134,55 @@ func TestHandlingNumber(t *testing.T) {
 	}
 }
 
+func TestFailedCountAndLatency(t *testing.T) {
+	mi, _ := NewModuleInternal(mid, nil)
+	counts := mi.Counts()
+	if counts.FailedCount != 0 || counts.LatencyEWMA != 0 || counts.Latency.Total() != 0 {
+		t.Fatalf("Inconsistent initial counts for internal module: %#v", counts)
+	}
+	mi.IncrFailedCount()
+	mi.IncrFailedCount()
+	// 第一次记录的延迟会直接作为平均值。
+	mi.RecordLatency(100 * time.Millisecond)
+	if ewma := mi.Counts().LatencyEWMA; ewma != 100*time.Millisecond {
+		t.Fatalf("Inconsistent latency EWMA for internal module: expected: %s, actual: %s",
+			100*time.Millisecond, ewma)
+	}
+	mi.RecordLatency(600 * time.Millisecond)
+	mi.RecordLatency(20 * time.Second)
+	counts = mi.Counts()
+	if counts.FailedCount != 2 {
+		t.Fatalf("Inconsistent failed count for internal module: expected: %d, actual: %d",
+			2, counts.FailedCount)
+	}
+	// 100ms*0.8+600ms*0.2=200ms，200ms*0.8+20s*0.2=4.16s。
+	expectedEWMA := 4160 * time.Millisecond
+	if counts.LatencyEWMA != expectedEWMA {
+		t.Fatalf("Inconsistent latency EWMA for internal module: expected: %s, actual: %s",
+			expectedEWMA, counts.LatencyEWMA)
+	}
+	expectedLatency := module.LatencyHistogram{}
+	expectedLatency[2] = 1
+	expectedLatency[5] = 1
+	expectedLatency[module.LatencyBucketNumber-1] = 1
+	if counts.Latency != expectedLatency {
+		t.Fatalf("Inconsistent latency histogram for internal module: expected: %v, actual: %v",
+			expectedLatency, counts.Latency)
+	}
+	summary := mi.Summary()
+	if summary.Failed != counts.FailedCount ||
+		summary.LatencyEWMA != counts.LatencyEWMA ||
+		summary.Latency != counts.Latency {
+		t.Fatalf("Inconsistent summary for internal module: %#v (counts: %#v)",
+			summary, counts)
+	}
+	mi.Clear()
+	counts = mi.Counts()
+	if counts.FailedCount != 0 || counts.LatencyEWMA != 0 || counts.Latency.Total() != 0 {
+		t.Fatalf("Inconsistent counts for internal module after clearing: %#v", counts)
+	}
+}
+
 func TestClearAndCounts(t *testing.T) {
 	number := uint64(10000)
 	mi, _ := NewModuleInternal(mid, nil)
@@ -0,0 +1,127 @@
+package module
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Trace 代表一次下载的各阶段耗时及传输情况。
+// 发生重定向时，DNS查询、建立连接和TLS握手的耗时是各次请求的总和。
+type Trace struct {
+	// DNS 代表DNS查询的耗时。
+	DNS time.Duration `json:"dns"`
+	// Connect 代表建立TCP连接的耗时。
+	Connect time.Duration `json:"connect"`
+	// TLS 代表TLS握手的耗时。
+	TLS time.Duration `json:"tls"`
+	// TTFB 代表从开始下载到收到最终响应的第一个字节的耗时。
+	TTFB time.Duration `json:"ttfb"`
+	// Total 代表从开始下载到读完响应主体的耗时。
+	Total time.Duration `json:"total"`
+	// Bytes 代表响应主体的字节数。
+	Bytes int64 `json:"bytes"`
+	// FinalURL 代表重定向之后的最终URL。
+	FinalURL string `json:"final_url,omitempty"`
+	// RemoteIP 代表最终响应所用的连接的远端IP。
+	RemoteIP string `json:"remote_ip,omitempty"`
+}
+
+// Tracer 代表通过httptrace记录一次下载的各阶段耗时的记录器。
+// 它的回调函数可能在不同的协程中被调用，所以其中的字段受锁保护。
+type Tracer struct {
+	lock         sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+	trace        Trace
+}
+
+// NewTracer 用于创建一个下载耗时的记录器。
+func NewTracer() *Tracer {
+	return &Tracer{connectStart: map[string]time.Time{}}
+}
+
+// Trace 用于生成一个带有记录器的HTTP请求，并开始计时。
+func (tracer *Tracer) Trace(httpReq *http.Request) *http.Request {
+	tracer.lock.Lock()
+	tracer.start = time.Now()
+	tracer.lock.Unlock()
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			tracer.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			if !tracer.dnsStart.IsZero() {
+				tracer.trace.DNS += time.Since(tracer.dnsStart)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			tracer.connectStart[network+" "+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			key := network + " " + addr
+			// 同时尝试多个地址时，只计入成功的连接。
+			if start, ok := tracer.connectStart[key]; ok && err == nil {
+				tracer.trace.Connect += time.Since(start)
+			}
+			delete(tracer.connectStart, key)
+		},
+		TLSHandshakeStart: func() {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			tracer.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			if !tracer.tlsStart.IsZero() {
+				tracer.trace.TLS += time.Since(tracer.tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			if info.Conn == nil {
+				return
+			}
+			if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+				tracer.trace.RemoteIP = addr.IP.String()
+			} else if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				tracer.trace.RemoteIP = host
+			}
+		},
+		GotFirstResponseByte: func() {
+			tracer.lock.Lock()
+			defer tracer.lock.Unlock()
+			tracer.trace.TTFB = time.Since(tracer.start)
+		},
+	}
+	return httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), clientTrace))
+}
+
+// Finish 用于在读完响应主体之后结束计时并获取记录的结果。
+// 参数bytes代表响应主体的字节数。
+func (tracer *Tracer) Finish(httpResp *http.Response, bytes int64) Trace {
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	trace := tracer.trace
+	trace.Total = time.Since(tracer.start)
+	trace.Bytes = bytes
+	if httpResp != nil && httpResp.Request != nil && httpResp.Request.URL != nil {
+		trace.FinalURL = httpResp.Request.URL.String()
+	}
+	return trace
+}
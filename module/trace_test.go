@@ -0,0 +1,44 @@
+package module
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/old" {
+				http.Redirect(w, r, "/new", http.StatusFound)
+				return
+			}
+			fmt.Fprint(w, "hello")
+		}))
+	defer server.Close()
+	httpReq, _ := http.NewRequest("GET", server.URL+"/old", nil)
+	tracer := NewTracer()
+	httpResp, err := server.Client().Do(tracer.Trace(httpReq))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading: %s", err)
+	}
+	body, _ := ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	trace := tracer.Finish(httpResp, int64(len(body)))
+	if trace.Connect <= 0 || trace.TLS <= 0 {
+		t.Fatalf("Inconsistent connect or TLS duration: %#v", trace)
+	}
+	if trace.TTFB <= 0 || trace.TTFB > trace.Total {
+		t.Fatalf("Inconsistent TTFB: %s (total: %s)", trace.TTFB, trace.Total)
+	}
+	if trace.Bytes != 5 || trace.FinalURL != server.URL+"/new" || trace.RemoteIP != "127.0.0.1" {
+		t.Fatalf("Inconsistent transfer info: %#v", trace)
+	}
+	// 下载耗时记录不会受元数据的影响。
+	resp := NewResponse(httpResp, 0).WithTrace(trace).WithMeta(Meta{ID: "1"})
+	if resp.Trace() != trace || resp.Meta().ID != "1" {
+		t.Fatalf("Inconsistent trace of response: expected: %#v, actual: %#v", trace, resp.Trace())
+	}
+}
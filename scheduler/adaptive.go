@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// 自适应限流的默认参数。
+const (
+	// DefaultAdaptiveInitialConcurrency 代表每个主机初始的默认并发数量上限。
+	DefaultAdaptiveInitialConcurrency = 2
+	// DefaultAdaptiveMaxConcurrency 代表每个主机的并发数量上限能达到的默认最大值。
+	DefaultAdaptiveMaxConcurrency = 16
+	// DefaultAdaptiveDelayStep 代表请求间隔时间每次调整的默认步长。
+	DefaultAdaptiveDelayStep = 100 * time.Millisecond
+	// DefaultAdaptiveMaxDelay 代表请求间隔时间能达到的默认最大值。
+	DefaultAdaptiveMaxDelay = 10 * time.Second
+)
+
+// adaptiveLatencyWeight 代表计算延迟的指数加权移动平均值时新样本的权重。
+const adaptiveLatencyWeight = 0.2
+
+// AdaptiveArgs 代表按主机自适应限流的参数。
+// 启用后，控制器会根据各主机的响应按照AIMD（加性增、乘性减）的方式调整并发数量上限和请求间隔时间：
+// 收到429或503的响应、连接失败或超时、平均延迟超出阈值时视为过载，
+// 先把并发数量上限减半，降到1之后再把间隔时间加倍；
+// 其他的响应视为正常，先按步长缩短间隔时间，缩短到0之后再逐步提高并发数量上限。
+// 主机策略中的限制仍然有效，实际的限制取两者中更严格的一个。
+type AdaptiveArgs struct {
+	// Enabled 代表是否启用自适应限流。
+	Enabled bool `json:"enabled"`
+	// InitialConcurrency 代表每个主机初始的并发数量上限，为0时使用默认值。
+	InitialConcurrency uint32 `json:"initial_concurrency"`
+	// MaxConcurrency 代表每个主机的并发数量上限能达到的最大值，为0时使用默认值。
+	MaxConcurrency uint32 `json:"max_concurrency"`
+	// DelayStep 代表请求间隔时间每次调整的步长，也是过载时间隔时间的初始值，为0时使用默认值。
+	DelayStep time.Duration `json:"delay_step"`
+	// MaxDelay 代表请求间隔时间能达到的最大值，为0时使用默认值。
+	MaxDelay time.Duration `json:"max_delay"`
+	// LatencyThreshold 代表平均延迟的阈值，超出时视为过载，为0时不考虑延迟。
+	LatencyThreshold time.Duration `json:"latency_threshold"`
+}
+
+// Check 用于检查自适应限流的参数的有效性。
+func (args *AdaptiveArgs) Check() error {
+	if !args.Enabled {
+		return nil
+	}
+	if args.DelayStep < 0 {
+		return genError(fmt.Sprintf("negative adaptive delay step: %s", args.DelayStep))
+	}
+	if args.MaxDelay < 0 {
+		return genError(fmt.Sprintf("negative adaptive max delay: %s", args.MaxDelay))
+	}
+	if args.LatencyThreshold < 0 {
+		return genError(fmt.Sprintf("negative adaptive latency threshold: %s",
+			args.LatencyThreshold))
+	}
+	if args.initialConcurrency() > args.maxConcurrency() {
+		return genError(fmt.Sprintf("adaptive initial concurrency %d is greater than max concurrency %d",
+			args.initialConcurrency(), args.maxConcurrency()))
+	}
+	if args.delayStep() > args.maxDelay() {
+		return genError(fmt.Sprintf("adaptive delay step %s is greater than max delay %s",
+			args.delayStep(), args.maxDelay()))
+	}
+	return nil
+}
+
+// initialConcurrency 用于获取实际使用的初始并发数量上限。
+func (args *AdaptiveArgs) initialConcurrency() uint32 {
+	if args.InitialConcurrency == 0 {
+		return DefaultAdaptiveInitialConcurrency
+	}
+	return args.InitialConcurrency
+}
+
+// maxConcurrency 用于获取实际使用的并发数量上限的最大值。
+func (args *AdaptiveArgs) maxConcurrency() uint32 {
+	if args.MaxConcurrency == 0 {
+		return DefaultAdaptiveMaxConcurrency
+	}
+	return args.MaxConcurrency
+}
+
+// delayStep 用于获取实际使用的间隔时间的调整步长。
+func (args *AdaptiveArgs) delayStep() time.Duration {
+	if args.DelayStep == 0 {
+		return DefaultAdaptiveDelayStep
+	}
+	return args.DelayStep
+}
+
+// maxDelay 用于获取实际使用的间隔时间的最大值。
+func (args *AdaptiveArgs) maxDelay() time.Duration {
+	if args.MaxDelay == 0 {
+		return DefaultAdaptiveMaxDelay
+	}
+	return args.MaxDelay
+}
+
+// adaptiveSignal 代表一次下载的结果反映出的主机状况。
+type adaptiveSignal int
+
+const (
+	// signalNone 代表下载的结果不反映主机的状况，例如请求被取消。
+	signalNone adaptiveSignal = iota
+	// signalSuccess 代表主机正常响应。
+	signalSuccess
+	// signalOverload 代表主机过载或拒绝服务。
+	signalOverload
+)
+
+// adaptiveSignalOf 用于根据下载的结果判断主机的状况，不考虑延迟。
+func adaptiveSignalOf(resp *module.Response, err error) adaptiveSignal {
+	if err != nil {
+		if retryableError(err) {
+			return signalOverload
+		}
+		return signalNone
+	}
+	if resp == nil || resp.HTTPResp() == nil {
+		return signalNone
+	}
+	switch resp.HTTPResp().StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return signalOverload
+	}
+	return signalSuccess
+}
+
+// adaptiveLimit 代表某个主机的自适应限制，它由礼貌性控制器的锁保护。
+type adaptiveLimit struct {
+	args        *AdaptiveArgs
+	concurrency uint32
+	delay       time.Duration
+	// successes 代表自上次调整以来正常响应的次数。
+	successes uint32
+	// lastDecrease 代表上次降低限制的时间。
+	lastDecrease time.Time
+	latencyEWMA  time.Duration
+	overloads    uint64
+}
+
+// newAdaptiveLimit 用于创建一个主机的自适应限制。
+func newAdaptiveLimit(args *AdaptiveArgs) *adaptiveLimit {
+	return &adaptiveLimit{args: args, concurrency: args.initialConcurrency()}
+}
+
+// observe 会根据一次下载的结果调整限制。
+// 参数started代表该次下载开始的时间。结果值代表限制是否被调整。
+func (al *adaptiveLimit) observe(signal adaptiveSignal, started time.Time, now time.Time) bool {
+	if signal == signalSuccess && al.args.LatencyThreshold > 0 {
+		latency := now.Sub(started)
+		if al.latencyEWMA == 0 {
+			al.latencyEWMA = latency
+		} else {
+			al.latencyEWMA += time.Duration(adaptiveLatencyWeight * float64(latency-al.latencyEWMA))
+		}
+		if al.latencyEWMA > al.args.LatencyThreshold {
+			signal = signalOverload
+		}
+	}
+	switch signal {
+	case signalSuccess:
+		if al.delay > 0 {
+			al.delay -= al.args.delayStep()
+			if al.delay < 0 {
+				al.delay = 0
+			}
+			return true
+		}
+		if al.concurrency >= al.args.maxConcurrency() {
+			return false
+		}
+		// 每个并发数量的请求都正常响应之后才提高一次上限。
+		al.successes++
+		if al.successes < al.concurrency {
+			return false
+		}
+		al.successes = 0
+		al.concurrency++
+		return true
+	case signalOverload:
+		al.overloads++
+		// 在上次降低限制之前开始的下载反映的是降低之前的状况，忽略它们以免连续降低。
+		if started.Before(al.lastDecrease) {
+			return false
+		}
+		al.lastDecrease = now
+		al.successes = 0
+		if al.concurrency > 1 {
+			al.concurrency /= 2
+			return true
+		}
+		delay := al.delay * 2
+		if delay < al.args.delayStep() {
+			delay = al.args.delayStep()
+		}
+		if delay > al.args.maxDelay() {
+			delay = al.args.maxDelay()
+		}
+		changed := delay != al.delay
+		al.delay = delay
+		return changed
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestAdaptiveArgs(t *testing.T) {
+	validArgsList := []AdaptiveArgs{
+		{},
+		{Enabled: true},
+		{Enabled: true, InitialConcurrency: 4, MaxConcurrency: 4},
+		{Enabled: true, DelayStep: time.Second, MaxDelay: time.Second},
+		// 未启用时不检查。
+		{InitialConcurrency: 8, MaxConcurrency: 4},
+	}
+	for _, args := range validArgsList {
+		if err := args.Check(); err != nil {
+			t.Fatalf("An error occurs when checking valid adaptive args %#v: %s", args, err)
+		}
+	}
+	invalidArgsList := []AdaptiveArgs{
+		{Enabled: true, InitialConcurrency: 8, MaxConcurrency: 4},
+		{Enabled: true, InitialConcurrency: DefaultAdaptiveMaxConcurrency + 1},
+		{Enabled: true, DelayStep: -1},
+		{Enabled: true, MaxDelay: -1},
+		{Enabled: true, LatencyThreshold: -1},
+		{Enabled: true, DelayStep: time.Second, MaxDelay: time.Millisecond},
+	}
+	for _, args := range invalidArgsList {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking invalid adaptive args %#v!", args)
+		}
+	}
+}
+
+func TestAdaptiveSignal(t *testing.T) {
+	genResp := func(code int) *module.Response {
+		return module.NewResponse(&http.Response{StatusCode: code}, 0)
+	}
+	cases := []struct {
+		resp     *module.Response
+		err      error
+		expected adaptiveSignal
+	}{
+		{genResp(200), nil, signalSuccess},
+		{genResp(404), nil, signalSuccess},
+		{genResp(500), nil, signalSuccess},
+		{genResp(429), nil, signalOverload},
+		{genResp(503), nil, signalOverload},
+		{nil, &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: timeoutError{}}, signalOverload},
+		{nil, &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: context.Canceled}, signalNone},
+		{nil, errors.New("invalid request"), signalNone},
+		{nil, nil, signalNone},
+	}
+	for i, c := range cases {
+		if signal := adaptiveSignalOf(c.resp, c.err); signal != c.expected {
+			t.Fatalf("Inconsistent signal of case %d: expected: %d, actual: %d",
+				i, c.expected, signal)
+		}
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	args := &AdaptiveArgs{
+		Enabled:            true,
+		InitialConcurrency: 4,
+		MaxConcurrency:     5,
+		DelayStep:          10 * time.Millisecond,
+		MaxDelay:           30 * time.Millisecond,
+	}
+	al := newAdaptiveLimit(args)
+	now := time.Now()
+	check := func(concurrency uint32, delay time.Duration) {
+		if al.concurrency != concurrency || al.delay != delay {
+			t.Fatalf("Inconsistent adaptive limit: expected: (%d, %s), actual: (%d, %s)",
+				concurrency, delay, al.concurrency, al.delay)
+		}
+	}
+	// 乘性减：先把并发数量上限减半。
+	now = now.Add(time.Millisecond)
+	if !al.observe(signalOverload, now, now) {
+		t.Fatal("The limit has not been decreased after overload!")
+	}
+	check(2, 0)
+	// 在降低限制之前开始的下载不会再次降低限制。
+	if al.observe(signalOverload, now.Add(-time.Millisecond), now.Add(time.Millisecond)) {
+		t.Fatal("The limit has been decreased by a stale overload!")
+	}
+	check(2, 0)
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Millisecond)
+		al.observe(signalOverload, now, now)
+	}
+	check(1, 10*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Millisecond)
+		al.observe(signalOverload, now, now)
+	}
+	check(1, 30*time.Millisecond)
+	if al.overloads != 7 {
+		t.Fatalf("Inconsistent overload number: expected: %d, actual: %d", 7, al.overloads)
+	}
+	// 加性增：先按步长缩短间隔时间，再逐步提高并发数量上限。
+	for i := 0; i < 3; i++ {
+		al.observe(signalSuccess, now, now)
+	}
+	check(1, 0)
+	al.observe(signalSuccess, now, now)
+	check(2, 0)
+	al.observe(signalSuccess, now, now)
+	check(2, 0)
+	al.observe(signalSuccess, now, now)
+	check(3, 0)
+	for i := 0; i < 20; i++ {
+		al.observe(signalSuccess, now, now)
+	}
+	check(5, 0)
+	// 平均延迟超出阈值时视为过载。
+	args.LatencyThreshold = 50 * time.Millisecond
+	now = now.Add(time.Second)
+	if !al.observe(signalSuccess, now.Add(-100*time.Millisecond), now) {
+		t.Fatal("The limit has not been decreased after slow response!")
+	}
+	check(2, 0)
+}
+
+func TestPolitenessAdaptive(t *testing.T) {
+	requeued := make(chan *module.Request, 10)
+	p := newPoliteness([]HostPolicy{
+		{Host: "bing.com", MaxConcurrency: 1},
+	}, func(req *module.Request) { requeued <- req })
+	defer p.stop()
+	p.enableAdaptive(AdaptiveArgs{Enabled: true, InitialConcurrency: 2, DelayStep: time.Millisecond})
+	// 没有策略的主机按主机名分别受到自适应限制。
+	req1 := genPoliteReq("http://www.sogou.com/a", t)
+	req2 := genPoliteReq("http://www.sogou.com/b", t)
+	req3 := genPoliteReq("http://www.sogou.com/c", t)
+	for _, req := range []*module.Request{req1, req2} {
+		if key, ok := p.acquire(req); !ok || key != "www.sogou.com" {
+			t.Fatalf("Inconsistent acquire result: key: %q, ok: %v", key, ok)
+		}
+	}
+	if _, ok := p.acquire(req3); ok {
+		t.Fatal("The request over the adaptive concurrency limit has not been held!")
+	}
+	// 主机策略的限制更严格时以主机策略为准。
+	if _, ok := p.acquire(genPoliteReq("http://cn.bing.com/", t)); !ok {
+		t.Fatal("The first request for host with policy has been held!")
+	}
+	if _, ok := p.acquire(genPoliteReq("http://cn.bing.com/", t)); ok {
+		t.Fatal("The request over the policy concurrency limit has not been held!")
+	}
+	started := time.Now()
+	overloaded := module.NewResponse(&http.Response{StatusCode: http.StatusServiceUnavailable}, 0)
+	p.observe(req1, started, overloaded, nil)
+	p.release("www.sogou.com")
+	expectedSummary := []HostQueueSummaryStruct{
+		{Host: "bing.com", Active: 1, Queued: 1, Concurrency: 1},
+		{Host: "www.sogou.com", Active: 1, Queued: 1, Concurrency: 1, Overloads: 1},
+	}
+	summary := p.summary()
+	if len(summary) != len(expectedSummary) ||
+		summary[0] != expectedSummary[0] || summary[1] != expectedSummary[1] {
+		t.Fatalf("Inconsistent host queue summary: expected: %#v, actual: %#v",
+			expectedSummary, summary)
+	}
+	// 正常响应之后提高并发数量上限，被扣留的请求被放行。
+	ok := module.NewResponse(&http.Response{StatusCode: http.StatusOK}, 0)
+	p.observe(req2, time.Now(), ok, nil)
+	select {
+	case req := <-requeued:
+		if req != req3 {
+			t.Fatalf("Inconsistent requeued request: expected: %v, actual: %v",
+				req3.HTTPReq().URL, req.HTTPReq().URL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The held request has not been requeued after the limit is raised!")
+	}
+}
+
+func TestSchedAdaptive(t *testing.T) {
+	linkNumber := 20
+	// capacity 代表模拟的服务器能同时处理的请求的数量，超出时响应503。
+	capacity := int32(2)
+	var current, overloads, served int32
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&current, 1)
+			defer atomic.AddInt32(&current, -1)
+			if n > capacity {
+				atomic.AddInt32(&overloads, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+			lock.Lock()
+			hits[r.URL.Path]++
+			lock.Unlock()
+			atomic.AddInt32(&served, 1)
+			if r.URL.Path == "/" {
+				for i := 0; i < linkNumber; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.Adaptive = AdaptiveArgs{
+		Enabled:            true,
+		InitialConcurrency: 8,
+		MaxConcurrency:     8,
+		DelayStep:          time.Millisecond,
+		MaxDelay:           20 * time.Millisecond,
+	}
+	requestArgs.Retry = RetryArgs{MaxRetries: 20, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	moduleArgs.Workers = WorkerArgs{DownloadWorkers: 8}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 所有的页面最终都被成功下载。
+	if served := atomic.LoadInt32(&served); served != int32(linkNumber+1) {
+		t.Fatalf("Inconsistent served request number: expected: %d, actual: %d",
+			linkNumber+1, served)
+	}
+	summary := sched.Summary().Struct()
+	if summary.Retry.PermanentFailures != 0 {
+		t.Fatalf("Inconsistent retry summary: %#v", summary.Retry)
+	}
+	if len(summary.HostQueues) != 1 {
+		t.Fatalf("Inconsistent host queue summary: %#v", summary.HostQueues)
+	}
+	hq := summary.HostQueues[0]
+	if hq.Host != "127.0.0.1" {
+		t.Fatalf("Inconsistent host of host queue: expected: %s, actual: %s",
+			"127.0.0.1", hq.Host)
+	}
+	// 控制器观察到了过载，并把并发数量上限降到了初始值之下。
+	if overloads := atomic.LoadInt32(&overloads); overloads == 0 ||
+		hq.Overloads != uint64(overloads) {
+		t.Fatalf("Inconsistent overload number: expected: %d, actual: %d",
+			overloads, hq.Overloads)
+	}
+	if hq.Concurrency == 0 || hq.Concurrency >= requestArgs.Adaptive.InitialConcurrency {
+		t.Fatalf("Inconsistent adaptive concurrency: expected: (0, %d), actual: %d",
+			requestArgs.Adaptive.InitialConcurrency, hq.Concurrency)
+	}
+}
@@ -1,6 +1,10 @@
 package scheduler
 
-import "gopcpv2-web-spider/module"
+import (
+	"fmt"
+	"gopcpv2-web-spider/module"
+	"time"
+)
 
 type Args interface {
 	Check()error
@@ -11,13 +15,60 @@ type RequestArgs struct{
 	AcceptedDomains []string `json:"accepted_primary_domains"`
 	//最大深度
 	MaxDepth uint32 `json:"max_depth"`
+	//各主机的礼貌性策略
+	HostPolicies []HostPolicy `json:"host_policies,omitempty"`
+	//是否遵守各主机的robots.txt
+	ObeyRobots bool `json:"obey_robots"`
+	//匹配robots.txt规则时使用的用户代理，为空时只匹配“*”组
+	RobotsUserAgent string `json:"robots_user_agent,omitempty"`
+	//爬取边界的策略，为空时为广度优先
+	FrontierStrategy FrontierStrategy `json:"frontier_strategy,omitempty"`
+	//最佳优先策略所用的计算请求优先级的函数
+	Priority RequestPriority `json:"-"`
+	//去重前URL规范化的参数
+	Canonical CanonicalArgs `json:"canonical"`
+	//爬取范围的规则
+	Scope ScopeArgs `json:"scope"`
+	//接收被丢弃的请求的记录的函数，可以为nil
+	DropSink DropSink `json:"-"`
+	//下载失败的请求的重试策略
+	Retry RetryArgs `json:"retry"`
+	//按主机自适应限流的参数
+	Adaptive AdaptiveArgs `json:"adaptive"`
+	//处理重定向的参数
+	Redirect RedirectArgs `json:"redirect"`
 }
 
 func (args *RequestArgs)Check()error{
 	if  args.AcceptedDomains == nil || len(args.AcceptedDomains) <= 0{
 		return genError("接受的域名列表不能是nil")
 	}
-	return nil
+	hosts := map[string]bool{}
+	for _, policy := range args.HostPolicies{
+		if err := policy.Check(); err != nil{
+			return err
+		}
+		if hosts[policy.Host]{
+			return genError(fmt.Sprintf("duplicate host policy for %q", policy.Host))
+		}
+		hosts[policy.Host] = true
+	}
+	if err := args.Canonical.Check(); err != nil{
+		return err
+	}
+	if err := args.Scope.Check(); err != nil{
+		return err
+	}
+	if err := args.Retry.Check(); err != nil{
+		return err
+	}
+	if err := args.Adaptive.Check(); err != nil{
+		return err
+	}
+	if err := args.Redirect.Check(); err != nil{
+		return err
+	}
+	return checkFrontierStrategy(args.FrontierStrategy, args.Priority)
 }
 
 // Same 用于判断两个请求相关的参数容器是否相同。
@@ -28,6 +79,28 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	if another.MaxDepth != args.MaxDepth {
 		return false
 	}
+	if another.ObeyRobots != args.ObeyRobots ||
+		another.RobotsUserAgent != args.RobotsUserAgent {
+		return false
+	}
+	if another.FrontierStrategy != args.FrontierStrategy {
+		return false
+	}
+	if !another.Canonical.Same(&args.Canonical) {
+		return false
+	}
+	if !another.Scope.Same(&args.Scope) {
+		return false
+	}
+	if another.Retry != args.Retry {
+		return false
+	}
+	if another.Adaptive != args.Adaptive {
+		return false
+	}
+	if another.Redirect != args.Redirect {
+		return false
+	}
 	if len(another.AcceptedDomains) != len(args.AcceptedDomains) {
 		return false
 	}
@@ -38,6 +111,14 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 			}
 		}
 	}
+	if len(another.HostPolicies) != len(args.HostPolicies) {
+		return false
+	}
+	for i, policy := range another.HostPolicies {
+		if policy != args.HostPolicies[i] {
+			return false
+		}
+	}
 	return true
 }
 
@@ -50,6 +131,12 @@ type DataArgs struct{
 	ItemMaxBufferNumber uint32 `json:"item_max_buffer_number"`
 	ErrorBufferCap uint32 `json:"error_buffer_cap"`
 	ErrorMaxBufferNumber uint32 `json:"error_max_buffer_number"`
+	//检查点的存放目录，为空时不写入检查点
+	CheckpointDir string `json:"checkpoint_dir,omitempty"`
+	//写入检查点的间隔时间
+	CheckpointInterval time.Duration `json:"checkpoint_interval,omitempty"`
+	//已见URL集合的参数
+	SeenSet SeenSetArgs `json:"seen_set"`
 }
 
 func (args *DataArgs)Check()error{
@@ -77,13 +164,30 @@ func (args *DataArgs)Check()error{
 	if args.ErrorMaxBufferNumber == 0 {
 		return genError("zero max error buffer number")
 	}
-	return nil
+	if args.CheckpointDir != "" && args.CheckpointInterval <= 0 {
+		return genError("zero checkpoint interval")
+	}
+	return args.SeenSet.Check()
 }
 
 type ModuleArgs struct {
 	Downloaders []module.Downloader
 	Analyzers []module.Analyzer
 	Pipelines []module.Pipeline
+	//各处理流程的工作协程数量
+	Workers WorkerArgs
+	//生命周期事件的观察者，也可以在初始化之后通过RegisterObserver方法注册
+	Observers []Observer
+	//每个观察者的事件队列的容量，为0时使用默认容量
+	ObserverQueueCap uint32
+	//各类型的组件所用的负载均衡策略
+	Balancers BalancerArgs
+	//各组件的权重，未指定的组件使用默认权重
+	Weights map[module.MID]uint32
+	//各组件的熔断器的参数，熔断器断开的组件不会被选择
+	Breaker module.BreakerArgs
+	//请求因没有可用的下载器而被放回请求缓冲池的最大次数，为0时使用默认值
+	MaxRequeues uint32
 }
 
 func (args *ModuleArgs)Check()error{
@@ -96,6 +200,68 @@ func (args *ModuleArgs)Check()error{
 	if len(args.Pipelines) == 0 {
 		return genError("empty pipeline list")
 	}
+	for _, observer := range args.Observers {
+		if observer == nil {
+			return genError("nil observer")
+		}
+	}
+	for mid, weight := range args.Weights {
+		if weight == 0 {
+			return genError(fmt.Sprintf("zero weight of module %q", mid))
+		}
+	}
+	if err := args.Balancers.Check(); err != nil {
+		return err
+	}
+	if err := args.Breaker.Check(); err != nil {
+		return genErrorByError(err)
+	}
+	return args.Workers.Check()
+}
+
+// weight 用于获取给定组件的权重。
+func (args *ModuleArgs) weight(mid module.MID) uint32 {
+	if weight, ok := args.Weights[mid]; ok {
+		return weight
+	}
+	return module.DefaultWeight
+}
+
+// BalancerArgs 代表各类型的组件所用的负载均衡策略的参数。
+// 值为空时使用默认策略，即选择评分最低的组件。
+type BalancerArgs struct {
+	Downloader module.BalanceStrategy `json:"downloader"`
+	Analyzer module.BalanceStrategy `json:"analyzer"`
+	Pipeline module.BalanceStrategy `json:"pipeline"`
+}
+
+func (args *BalancerArgs)Check()error{
+	for _, strategy := range []module.BalanceStrategy{args.Downloader, args.Analyzer, args.Pipeline} {
+		if _, err := module.NewBalancer(strategy); err != nil {
+			return genErrorByError(err)
+		}
+	}
+	return nil
+}
+
+// WorkerArgs 代表各处理流程的工作协程数量的参数。
+// 值为0时代表自动，即按照已注册的相应组件的数量确定。
+type WorkerArgs struct {
+	DownloadWorkers uint32 `json:"download_workers"`
+	AnalyzeWorkers uint32 `json:"analyze_workers"`
+	PipelineWorkers uint32 `json:"pipeline_workers"`
+}
+
+func (args *WorkerArgs)Check()error{
+	if args.DownloadWorkers > maxWorkerNumber {
+		return genError(fmt.Sprintf("too many download workers: %d", args.DownloadWorkers))
+	}
+	if args.AnalyzeWorkers > maxWorkerNumber {
+		return genError(fmt.Sprintf("too many analyze workers: %d", args.AnalyzeWorkers))
+	}
+	if args.PipelineWorkers > maxWorkerNumber {
+		return genError(fmt.Sprintf("too many pipeline workers: %d", args.PipelineWorkers))
+	}
 	return nil
 }
 
@@ -104,6 +270,10 @@ type ModuleArgsSummary struct {
 	DownloaderListSize int `json:"downloader_list_size"`
 	AnalyzerListSize   int `json:"analyzer_list_size"`
 	PipelineListSize   int `json:"pipeline_list_size"`
+	Workers            WorkerArgs `json:"workers"`
+	Balancers          BalancerArgs `json:"balancers"`
+	Breaker            module.BreakerArgs `json:"breaker"`
+	MaxRequeues        uint32 `json:"max_requeues"`
 }
 
 func (args *ModuleArgs) Summary() ModuleArgsSummary {
@@ -111,5 +281,9 @@ func (args *ModuleArgs) Summary() ModuleArgsSummary {
 		DownloaderListSize: len(args.Downloaders),
 		AnalyzerListSize:   len(args.Analyzers),
 		PipelineListSize:   len(args.Pipelines),
+		Workers:            args.Workers,
+		Balancers:          args.Balancers,
+		Breaker:            args.Breaker,
+		MaxRequeues:        args.MaxRequeues,
 	}
 }
\ No newline at end of file
@@ -78,6 +78,63 @@ func TestArgsRequest(t *testing.T) {
 	}
 }
 
+func TestArgsHostPolicy(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"bing.com"}, 0)
+	requestArgs.HostPolicies = []HostPolicy{
+		{Host: "bing.com", MaxConcurrency: 2},
+		{Host: "*", MinDelay: time.Second},
+	}
+	if err := requestArgs.Check(); err != nil {
+		t.Fatalf("Inconsistent check result: expected: %v, actual: %v",
+			nil, err)
+	}
+	another := requestArgs
+	another.HostPolicies = []HostPolicy{
+		{Host: "bing.com", MaxConcurrency: 3},
+		{Host: "*", MinDelay: time.Second},
+	}
+	if requestArgs.Same(&another) {
+		t.Fatal("Same request arguments with different host policies!")
+	}
+	invalidPoliciesList := [][]HostPolicy{
+		{{Host: ""}},
+		{{Host: "bing.com", MinDelay: -time.Second}},
+		{{Host: "bing.com", Rate: -1}},
+		{{Host: "bing.com"}, {Host: "bing.com"}},
+	}
+	for _, policies := range invalidPoliciesList {
+		requestArgs.HostPolicies = policies
+		if err := requestArgs.Check(); err == nil {
+			t.Fatalf("No error when check request arguments! (policies: %#v)",
+				policies)
+		}
+	}
+}
+
+func TestArgsFrontier(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"bing.com"}, 0)
+	requestArgs.FrontierStrategy = FRONTIER_STRATEGY_BEST_FIRST
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when check request arguments without priority function!")
+	}
+	requestArgs.Priority = func(req *module.Request) float64 {
+		return float64(req.Depth())
+	}
+	if err := requestArgs.Check(); err != nil {
+		t.Fatalf("Inconsistent check result: expected: %v, actual: %v",
+			nil, err)
+	}
+	another := requestArgs
+	another.FrontierStrategy = FRONTIER_STRATEGY_DFS
+	if requestArgs.Same(&another) {
+		t.Fatal("Same request arguments with different frontier strategies!")
+	}
+	requestArgs.FrontierStrategy = "random"
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when check request arguments with unknown frontier strategy!")
+	}
+}
+
 func TestArgsData(t *testing.T) {
 	dataArgs := genDataArgs(10, 2, 1)
 	if err := dataArgs.Check(); err != nil {
@@ -167,6 +224,41 @@ func TestArgsModule(t *testing.T) {
 				moduleArgs)
 		}
 	}
+	workerArgsList := []WorkerArgs{
+		WorkerArgs{DownloadWorkers: maxWorkerNumber + 1},
+		WorkerArgs{AnalyzeWorkers: maxWorkerNumber + 1},
+		WorkerArgs{PipelineWorkers: maxWorkerNumber + 1},
+	}
+	for _, workerArgs := range workerArgsList {
+		moduleArgs.Workers = workerArgs
+		if err := moduleArgs.Check(); err == nil {
+			t.Fatalf("No error when check module arguments! (workerArgs: %#v)",
+				workerArgs)
+		}
+	}
+	moduleArgs.Workers = WorkerArgs{}
+	moduleArgs.Balancers = BalancerArgs{Analyzer: "unknown"}
+	if err := moduleArgs.Check(); err == nil {
+		t.Fatalf("No error when check module arguments! (balancers: %#v)",
+			moduleArgs.Balancers)
+	}
+	moduleArgs.Balancers = BalancerArgs{Downloader: module.BALANCE_POWER_OF_TWO}
+	moduleArgs.Weights = map[module.MID]uint32{"D1": 0}
+	if err := moduleArgs.Check(); err == nil {
+		t.Fatalf("No error when check module arguments! (weights: %v)",
+			moduleArgs.Weights)
+	}
+	moduleArgs.Weights = map[module.MID]uint32{"D1": 2}
+	moduleArgs.Breaker = module.BreakerArgs{FailureThreshold: 3, OpenTimeout: -time.Second}
+	if err := moduleArgs.Check(); err == nil {
+		t.Fatalf("No error when check module arguments! (breaker: %#v)",
+			moduleArgs.Breaker)
+	}
+	moduleArgs.Breaker.OpenTimeout = time.Second
+	if err := moduleArgs.Check(); err != nil {
+		t.Fatalf("Inconsistent check result: expected: %v, actual: %v",
+			nil, err)
+	}
 }
 
 // genSimpleModuleArgs 用于生成只包含简易组件实例的参数实例。
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// 可扩展布隆过滤器的扩展参数。
+const (
+	// bloomGrowthFactor 代表每个新的过滤器相对于上一个的容量倍数。
+	bloomGrowthFactor = 2
+	// bloomTighteningRatio 代表每个新的过滤器相对于上一个的误判率倍数。
+	// 各过滤器误判率之和收敛于目标误判率。
+	bloomTighteningRatio = 0.5
+)
+
+// bloomFilter 代表一个容量固定的布隆过滤器。它不是并发安全的。
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+}
+
+// newBloomFilter 用于创建一个能以给定误判率容纳给定数量元素的布隆过滤器。
+func newBloomFilter(capacity uint64, fpRate float64) *bloomFilter {
+	if capacity == 0 {
+		capacity = 1
+	}
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Ceil(math.Log2(1 / fpRate)))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+// bloomHashes 用于计算给定键的两个基础哈希值，
+// 各个位置由它们按双重哈希的方式组合而成。
+func bloomHashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	// 第二个哈希值需要是奇数，以免各位置重合。
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+// test 用于判断过滤器中是否可能含有给定哈希值对应的键。
+func (bf *bloomFilter) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < bf.k; i++ {
+		pos := (h1 + i*h2) % bf.m
+		if bf.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add 用于把给定哈希值对应的键加入过滤器。
+func (bf *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < bf.k; i++ {
+		pos := (h1 + i*h2) % bf.m
+		bf.bits[pos/64] |= 1 << (pos % 64)
+	}
+	bf.count++
+}
+
+// full 用于判断过滤器是否已达到其容量。
+func (bf *bloomFilter) full() bool {
+	return bf.count >= bf.capacity
+}
+
+// falsePositiveRate 用于按照已加入的元素数量估算过滤器的误判率。
+func (bf *bloomFilter) falsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(bf.k)*float64(bf.count)/float64(bf.m)), float64(bf.k))
+}
+
+// NewBloomSeenSet 用于创建一个可扩展的布隆过滤器集合。
+// 每当当前的过滤器达到容量时，就追加一个容量更大、误判率更低的过滤器，
+// 使得整体的误判率不超过给定的目标误判率。
+func NewBloomSeenSet(initialCapacity uint64, fpRate float64) SeenSet {
+	set := &bloomSeenSet{
+		initialCapacity: initialCapacity,
+		fpRate:          fpRate,
+	}
+	set.grow()
+	return set
+}
+
+// bloomSeenSet 代表可扩展的布隆过滤器集合的实现类型。
+type bloomSeenSet struct {
+	lock            sync.RWMutex
+	initialCapacity uint64
+	fpRate          float64
+	filters         []*bloomFilter
+	count           uint64
+}
+
+// grow 用于追加一个新的过滤器。调用方需持有锁。
+func (set *bloomSeenSet) grow() {
+	n := len(set.filters)
+	capacity := set.initialCapacity * uint64(math.Pow(bloomGrowthFactor, float64(n)))
+	fpRate := set.fpRate * (1 - bloomTighteningRatio) * math.Pow(bloomTighteningRatio, float64(n))
+	set.filters = append(set.filters, newBloomFilter(capacity, fpRate))
+}
+
+func (set *bloomSeenSet) Type() SeenSetType {
+	return SEEN_SET_TYPE_BLOOM
+}
+
+func (set *bloomSeenSet) Add(key string) (bool, error) {
+	h1, h2 := bloomHashes(key)
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.contains(h1, h2) {
+		return false, nil
+	}
+	current := set.filters[len(set.filters)-1]
+	if current.full() {
+		set.grow()
+		current = set.filters[len(set.filters)-1]
+	}
+	current.add(h1, h2)
+	set.count++
+	return true, nil
+}
+
+// contains 用于判断任一过滤器是否可能含有给定哈希值对应的键。调用方需持有锁。
+func (set *bloomSeenSet) contains(h1, h2 uint64) bool {
+	for i := len(set.filters) - 1; i >= 0; i-- {
+		if set.filters[i].test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (set *bloomSeenSet) Contains(key string) bool {
+	h1, h2 := bloomHashes(key)
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.contains(h1, h2)
+}
+
+func (set *bloomSeenSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.count
+}
+
+// FalsePositiveRate 用于估算当前的整体误判率，
+// 即任一过滤器发生误判的概率。
+func (set *bloomSeenSet) FalsePositiveRate() float64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	noFalsePositive := 1.0
+	for _, bf := range set.filters {
+		noFalsePositive *= 1 - bf.falsePositiveRate()
+	}
+	return 1 - noFalsePositive
+}
+
+func (set *bloomSeenSet) Close() error {
+	return nil
+}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultIgnoredParams 代表常见的跟踪参数与会话ID参数，可用作CanonicalArgs.IgnoredParams。
+var DefaultIgnoredParams = []string{
+	"utm_*",
+	"jsessionid",
+	"phpsessid",
+	"aspsessionid*",
+	"sessionid",
+	"sid",
+}
+
+// CanonicalArgs 代表URL规范化的参数。
+type CanonicalArgs struct {
+	// IgnoredParams 代表需要移除的查询参数或路径参数的名称，不区分大小写。
+	// 以“*”结尾时代表按前缀匹配，例如“utm_*”。
+	IgnoredParams []string `json:"ignored_params,omitempty"`
+	// KeepFragment 代表是否保留片段。
+	KeepFragment bool `json:"keep_fragment,omitempty"`
+	// KeepQueryOrder 代表是否保留查询参数原有的顺序。
+	KeepQueryOrder bool `json:"keep_query_order,omitempty"`
+}
+
+// Check 用于检查URL规范化的参数。
+func (args *CanonicalArgs) Check() error {
+	for _, param := range args.IgnoredParams {
+		if strings.TrimSuffix(param, "*") == "" {
+			return genError("empty ignored param name")
+		}
+	}
+	return nil
+}
+
+// Same 用于判断两个URL规范化的参数是否相同。
+func (args *CanonicalArgs) Same(another *CanonicalArgs) bool {
+	if another == nil {
+		return false
+	}
+	if another.KeepFragment != args.KeepFragment ||
+		another.KeepQueryOrder != args.KeepQueryOrder {
+		return false
+	}
+	if len(another.IgnoredParams) != len(args.IgnoredParams) {
+		return false
+	}
+	for i, param := range another.IgnoredParams {
+		if param != args.IgnoredParams[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Canonicalizer 代表URL规范化器的接口类型。
+type Canonicalizer interface {
+	// Canonicalize 用于获取给定URL的规范形式，它可以作为去重的依据。
+	Canonicalize(u *url.URL) string
+}
+
+// NewCanonicalizer 用于创建一个URL规范化器。
+func NewCanonicalizer(args CanonicalArgs) Canonicalizer {
+	c := &myCanonicalizer{
+		keepFragment:   args.KeepFragment,
+		keepQueryOrder: args.KeepQueryOrder,
+		ignored:        map[string]bool{},
+	}
+	for _, param := range args.IgnoredParams {
+		param = strings.ToLower(param)
+		if strings.HasSuffix(param, "*") {
+			c.ignoredPrefixes = append(c.ignoredPrefixes, strings.TrimSuffix(param, "*"))
+		} else {
+			c.ignored[param] = true
+		}
+	}
+	return c
+}
+
+// myCanonicalizer 代表URL规范化器的实现类型。
+type myCanonicalizer struct {
+	keepFragment    bool
+	keepQueryOrder  bool
+	ignored         map[string]bool
+	ignoredPrefixes []string
+}
+
+// defaultPorts 代表各协议的默认端口。
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonicalize 会依次：
+// 把协议和主机名转为小写，去掉默认端口，
+// 解析路径中的“.”和“..”，移除被忽略的路径参数和查询参数，
+// 按名称和值排序查询参数，并去掉片段。
+func (c *myCanonicalizer) Canonicalize(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if u.Opaque != "" {
+		return u.String()
+	}
+	var buf strings.Builder
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "" {
+		buf.WriteString(scheme)
+		buf.WriteString(":")
+	}
+	if u.Host != "" || scheme != "" {
+		buf.WriteString("//")
+		if u.User != nil {
+			buf.WriteString(u.User.String())
+			buf.WriteString("@")
+		}
+		buf.WriteString(canonicalHost(scheme, u.Host))
+	}
+	path := removeDotSegments(c.cleanPathParams(u.EscapedPath()))
+	if path == "" && u.Host != "" {
+		path = "/"
+	}
+	buf.WriteString(path)
+	if query := c.canonicalQuery(u.RawQuery); query != "" {
+		buf.WriteString("?")
+		buf.WriteString(query)
+	}
+	if c.keepFragment && u.Fragment != "" {
+		buf.WriteString("#")
+		buf.WriteString(u.EscapedFragment())
+	}
+	return buf.String()
+}
+
+// canonicalHost 用于获取规范的主机部分，即小写的主机名与非默认的端口。
+func canonicalHost(scheme string, host string) string {
+	host = strings.ToLower(host)
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// 没有端口。
+		return strings.TrimSuffix(host, ".")
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	if port == "" || port == defaultPorts[scheme] {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]"
+		}
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
+
+// ignoredParam 用于判断给定名称的参数是否需要被移除。
+func (c *myCanonicalizer) ignoredParam(name string) bool {
+	if decoded, err := url.QueryUnescape(name); err == nil {
+		name = decoded
+	}
+	name = strings.ToLower(name)
+	if c.ignored[name] {
+		return true
+	}
+	for _, prefix := range c.ignoredPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPathParams 用于移除路径片段中被忽略的参数，例如“;jsessionid=...”。
+func (c *myCanonicalizer) cleanPathParams(path string) string {
+	if !strings.Contains(path, ";") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		parts := strings.Split(segment, ";")
+		if len(parts) == 1 {
+			continue
+		}
+		kept := parts[:1]
+		for _, part := range parts[1:] {
+			name := part
+			if j := strings.Index(part, "="); j >= 0 {
+				name = part[:j]
+			}
+			if !c.ignoredParam(name) {
+				kept = append(kept, part)
+			}
+		}
+		segments[i] = strings.Join(kept, ";")
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery 用于获取规范的查询部分。
+// 为了不改变参数的含义，参数的名称和值都保持原有的编码。
+func (c *myCanonicalizer) canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		name := param
+		if i := strings.Index(param, "="); i >= 0 {
+			name = param[:i]
+		}
+		if c.ignoredParam(name) {
+			continue
+		}
+		params = append(params, param)
+	}
+	if !c.keepQueryOrder {
+		sort.SliceStable(params, func(i, j int) bool {
+			return queryParamLess(params[i], params[j])
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+// queryParamLess 用于比较两个查询参数，先比较名称，再比较值。
+func queryParamLess(one, another string) bool {
+	oneName, oneValue := splitQueryParam(one)
+	anotherName, anotherValue := splitQueryParam(another)
+	if oneName != anotherName {
+		return oneName < anotherName
+	}
+	return oneValue < anotherValue
+}
+
+// splitQueryParam 用于把查询参数分为名称和值。
+func splitQueryParam(param string) (name, value string) {
+	if i := strings.Index(param, "="); i >= 0 {
+		return param[:i], param[i+1:]
+	}
+	return param, ""
+}
+
+// removeDotSegments 用于按照RFC 3986第5.2.4节解析路径中的“.”和“..”。
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	var output []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			// 位于末尾的“.”代表目录本身，需要保留结尾的“/”。
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			// 不能越过根目录。
+			if len(output) > 1 || (len(output) == 1 && output[0] != "") {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	result := strings.Join(output, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
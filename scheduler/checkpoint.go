@@ -0,0 +1,394 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// checkpointVersion 代表检查点文件格式的版本。
+const checkpointVersion = 1
+
+const (
+	// checkpointStateFile 代表检查点状态文件的名称。
+	checkpointStateFile = "state.json"
+	// checkpointSeenFile 代表已见URL日志文件的名称。
+	checkpointSeenFile = "seen_urls.log"
+)
+
+// checkpointRequest 代表检查点中的待处理请求。
+type checkpointRequest struct {
+	URL    string      `json:"url"`
+	Method string      `json:"method"`
+	Header http.Header `json:"header,omitempty"`
+	Depth  uint32      `json:"depth"`
+	Meta   module.Meta `json:"meta"`
+}
+
+// newCheckpointRequest 用于根据给定的请求生成检查点中的请求记录。
+func newCheckpointRequest(req *module.Request) checkpointRequest {
+	httpReq := req.HTTPReq()
+	return checkpointRequest{
+		URL:    httpReq.URL.String(),
+		Method: httpReq.Method,
+		Header: httpReq.Header,
+		Depth:  req.Depth(),
+		Meta:   req.Meta(),
+	}
+}
+
+// toRequest 用于把检查点中的请求记录还原为请求。
+func (cr checkpointRequest) toRequest() (*module.Request, error) {
+	method := cr.Method
+	if method == "" {
+		method = "GET"
+	}
+	httpReq, err := http.NewRequest(method, cr.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range cr.Header {
+		httpReq.Header[k] = v
+	}
+	return module.NewRequest(httpReq, cr.Depth).WithMeta(cr.Meta), nil
+}
+
+// checkpointCounters 代表检查点中记录的摘要计数。
+type checkpointCounters struct {
+	Downloaded uint64 `json:"downloaded"`
+	Analyzed   uint64 `json:"analyzed"`
+	Processed  uint64 `json:"processed"`
+}
+
+// checkpointState 代表检查点状态文件的内容。
+type checkpointState struct {
+	Version         int       `json:"version"`
+	SavedAt         time.Time `json:"saved_at"`
+	AcceptedDomains []string  `json:"accepted_domains"`
+	// NumSeen 代表保存时已见URL日志中有效的行数。
+	NumSeen  uint64              `json:"seen_number"`
+	Pending  []checkpointRequest `json:"pending"`
+	Counters checkpointCounters  `json:"counters"`
+}
+
+// CheckpointSummaryStruct 代表检查点的摘要类型。
+type CheckpointSummaryStruct struct {
+	Dir         string `json:"dir,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+	Saves       uint64 `json:"saves"`
+	LastSaved   string `json:"last_saved,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	ResumedFrom string `json:"resumed_from,omitempty"`
+	Pending     uint64 `json:"pending"`
+	Downloaded  uint64 `json:"downloaded"`
+	Analyzed    uint64 `json:"analyzed"`
+	Processed   uint64 `json:"processed"`
+}
+
+// checkpointer 代表检查点的记录器。
+// 已见URL会被逐条追加到日志文件中，
+// 待处理请求和摘要计数会被定期写入状态文件。
+type checkpointer struct {
+	// dir 代表检查点的存放目录，为空时不会写入检查点。
+	dir string
+	// interval 代表写入检查点的间隔时间。
+	interval time.Duration
+	// lock 用于保证已见URL与待处理请求的一致性。
+	lock       sync.Mutex
+	seenFile   *os.File
+	seenWriter *bufio.Writer
+	seenCount  uint64
+	domains    []string
+	// pending 代表待处理请求，其键是规范化之后的URL，与已见URL一致。
+	pending map[string]*module.Request
+	// canonicalizer 代表生成待处理请求的键所用的URL规范化器。
+	canonicalizer Canonicalizer
+	// base 代表从检查点恢复的摘要计数。
+	base        checkpointCounters
+	resumedFrom string
+	saves       uint64
+	lastSaved   time.Time
+	lastErr     string
+}
+
+// newCheckpointer 用于根据数据参数创建检查点记录器。
+// 参数canonicalizer应与调度器去重所用的规范化器相同，为nil时使用原始URL。
+func newCheckpointer(dataArgs DataArgs, canonicalizer Canonicalizer) *checkpointer {
+	return &checkpointer{
+		dir:           dataArgs.CheckpointDir,
+		interval:      dataArgs.CheckpointInterval,
+		pending:       map[string]*module.Request{},
+		canonicalizer: canonicalizer,
+	}
+}
+
+// pendingKey 用于获取待处理请求的键，它与recordSeen所用的已见URL相同。
+func (cp *checkpointer) pendingKey(req *module.Request) string {
+	u := req.HTTPReq().URL
+	if cp.canonicalizer == nil {
+		return u.String()
+	}
+	return cp.canonicalizer.Canonicalize(u)
+}
+
+// enabled 用于判断是否需要写入检查点。
+func (cp *checkpointer) enabled() bool {
+	return cp != nil && cp.dir != ""
+}
+
+// open 会准备好已见URL日志文件，并写入给定的已见URL。
+func (cp *checkpointer) open(seenURLs []string) error {
+	if !cp.enabled() {
+		return nil
+	}
+	if err := os.MkdirAll(cp.dir, 0700); err != nil {
+		return err
+	}
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.closeSeenFile()
+	seenPath := filepath.Join(cp.dir, checkpointSeenFile)
+	tmpPath := seenPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, url := range seenURLs {
+		w.WriteString(url)
+		w.WriteByte('\n')
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, seenPath); err != nil {
+		return err
+	}
+	f, err = os.OpenFile(seenPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	cp.seenFile = f
+	cp.seenWriter = bufio.NewWriter(f)
+	cp.seenCount = uint64(len(seenURLs))
+	return nil
+}
+
+// closeSeenFile 会关闭已见URL日志文件。调用方需持有锁。
+func (cp *checkpointer) closeSeenFile() {
+	if cp.seenFile == nil {
+		return
+	}
+	cp.seenWriter.Flush()
+	cp.seenFile.Close()
+	cp.seenFile = nil
+	cp.seenWriter = nil
+}
+
+// close 会关闭检查点记录器持有的文件。
+func (cp *checkpointer) close() {
+	if cp == nil {
+		return
+	}
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.closeSeenFile()
+}
+
+// addDomain 会记录一个被接受的主域名。
+func (cp *checkpointer) addDomain(domain string) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	for _, d := range cp.domains {
+		if d == domain {
+			return
+		}
+	}
+	cp.domains = append(cp.domains, domain)
+}
+
+// recordSeen 会记录一个新的已见URL及其对应的待处理请求。
+func (cp *checkpointer) recordSeen(url string, req *module.Request) {
+	if !cp.enabled() {
+		return
+	}
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if cp.seenWriter != nil {
+		cp.seenWriter.WriteString(url)
+		cp.seenWriter.WriteByte('\n')
+		cp.seenCount++
+	}
+	cp.pending[url] = req
+}
+
+// addPending 会记录一个待处理请求。
+func (cp *checkpointer) addPending(req *module.Request) {
+	// robots.txt的请求在恢复后会按需重新发起，无需记录。
+	if !cp.enabled() || req == nil || !req.Valid() || robotsKeyOf(req) != "" {
+		return
+	}
+	key := cp.pendingKey(req)
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.pending[key] = req
+}
+
+// donePending 会移除一个已处理完毕的请求。
+func (cp *checkpointer) donePending(req *module.Request) {
+	if !cp.enabled() || req == nil || !req.Valid() {
+		return
+	}
+	key := cp.pendingKey(req)
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	delete(cp.pending, key)
+}
+
+// resume 会根据已加载的检查点设置恢复后的状态。
+func (cp *checkpointer) resume(dir string, state *checkpointState) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.resumedFrom = dir
+	cp.base = state.Counters
+}
+
+// save 会写入一次检查点。
+func (cp *checkpointer) save(counters checkpointCounters) error {
+	if !cp.enabled() {
+		return nil
+	}
+	state := checkpointState{
+		Version: checkpointVersion,
+		SavedAt: time.Now(),
+	}
+	cp.lock.Lock()
+	if cp.seenWriter != nil {
+		if err := cp.seenWriter.Flush(); err != nil {
+			cp.lock.Unlock()
+			return cp.recordErr(err)
+		}
+		if err := cp.seenFile.Sync(); err != nil {
+			cp.lock.Unlock()
+			return cp.recordErr(err)
+		}
+	}
+	state.NumSeen = cp.seenCount
+	state.AcceptedDomains = append([]string{}, cp.domains...)
+	state.Pending = make([]checkpointRequest, 0, len(cp.pending))
+	for _, req := range cp.pending {
+		state.Pending = append(state.Pending, newCheckpointRequest(req))
+	}
+	base := cp.base
+	cp.lock.Unlock()
+	sort.Slice(state.Pending, func(i, j int) bool {
+		return state.Pending[i].URL < state.Pending[j].URL
+	})
+	state.Counters = checkpointCounters{
+		Downloaded: base.Downloaded + counters.Downloaded,
+		Analyzed:   base.Analyzed + counters.Analyzed,
+		Processed:  base.Processed + counters.Processed,
+	}
+	b, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return cp.recordErr(err)
+	}
+	statePath := filepath.Join(cp.dir, checkpointStateFile)
+	tmpPath := statePath + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, b, 0600); err != nil {
+		return cp.recordErr(err)
+	}
+	if err = os.Rename(tmpPath, statePath); err != nil {
+		return cp.recordErr(err)
+	}
+	cp.lock.Lock()
+	cp.saves++
+	cp.lastSaved = state.SavedAt
+	cp.lastErr = ""
+	cp.lock.Unlock()
+	return nil
+}
+
+// recordErr 会记录写入检查点时发生的错误。
+func (cp *checkpointer) recordErr(err error) error {
+	cp.lock.Lock()
+	cp.lastErr = err.Error()
+	cp.lock.Unlock()
+	return genError(fmt.Sprintf("写入检查点失败：%s", err))
+}
+
+// summary 用于获取检查点的摘要。
+func (cp *checkpointer) summary(counters checkpointCounters) CheckpointSummaryStruct {
+	if cp == nil {
+		return CheckpointSummaryStruct{}
+	}
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	summary := CheckpointSummaryStruct{
+		Dir:         cp.dir,
+		Saves:       cp.saves,
+		LastError:   cp.lastErr,
+		ResumedFrom: cp.resumedFrom,
+		Pending:     uint64(len(cp.pending)),
+		Downloaded:  cp.base.Downloaded + counters.Downloaded,
+		Analyzed:    cp.base.Analyzed + counters.Analyzed,
+		Processed:   cp.base.Processed + counters.Processed,
+	}
+	if cp.dir != "" {
+		summary.Interval = cp.interval.String()
+	}
+	if !cp.lastSaved.IsZero() {
+		summary.LastSaved = cp.lastSaved.Format(time.RFC3339)
+	}
+	return summary
+}
+
+// loadCheckpoint 用于从给定目录加载检查点。
+// 第二个结果值代表保存检查点时已见的URL。
+func loadCheckpoint(dir string) (*checkpointState, []string, error) {
+	if dir == "" {
+		return nil, nil, genParameterError("检查点目录为空")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, checkpointStateFile))
+	if err != nil {
+		return nil, nil, genError(fmt.Sprintf("读取检查点失败：%s", err))
+	}
+	var state checkpointState
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, nil, genError(fmt.Sprintf("解析检查点失败：%s", err))
+	}
+	if state.Version != checkpointVersion {
+		return nil, nil, genError(fmt.Sprintf("不支持的检查点版本：%d", state.Version))
+	}
+	f, err := os.Open(filepath.Join(dir, checkpointSeenFile))
+	if err != nil {
+		return nil, nil, genError(fmt.Sprintf("读取已见URL日志失败：%s", err))
+	}
+	defer f.Close()
+	seenURLs := make([]string, 0, state.NumSeen)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for uint64(len(seenURLs)) < state.NumSeen && scanner.Scan() {
+		seenURLs = append(seenURLs, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, genError(fmt.Sprintf("读取已见URL日志失败：%s", err))
+	}
+	if uint64(len(seenURLs)) < state.NumSeen {
+		return nil, nil, genError(fmt.Sprintf("已见URL日志不完整：%d < %d", len(seenURLs), state.NumSeen))
+	}
+	return &state, seenURLs, nil
+}
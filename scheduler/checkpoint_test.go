@@ -0,0 +1,228 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestCheckpointSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.CheckpointDir = dir
+	dataArgs.CheckpointInterval = time.Second
+	cp := newCheckpointer(dataArgs, nil)
+	cp.addDomain("bing.com")
+	if err := cp.open([]string{"http://cn.bing.com/"}); err != nil {
+		t.Fatalf("An error occurs when opening checkpointer: %s", err)
+	}
+	urls := []string{
+		"http://cn.bing.com/search?q=golang",
+		"http://cn.bing.com/images/search?q=golang",
+	}
+	var reqs []*module.Request
+	for i, url := range urls {
+		httpReq, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)",
+				err, url)
+		}
+		httpReq.Header.Set("User-Agent", "finder")
+		req := module.NewRequest(httpReq, uint32(i+1))
+		cp.recordSeen(url, req)
+		reqs = append(reqs, req)
+	}
+	cp.donePending(reqs[0])
+	// 重试的请求会替换同一URL的待处理请求。
+	cp.addPending(reqs[1].WithAttempt(2))
+	counters := checkpointCounters{Downloaded: 3, Analyzed: 2, Processed: 1}
+	if err := cp.save(counters); err != nil {
+		t.Fatalf("An error occurs when saving checkpoint: %s", err)
+	}
+	// 保存之后记录的URL不应出现在恢复的结果中。
+	cp.recordSeen("http://cn.bing.com/unsaved", reqs[1])
+	cp.close()
+
+	state, seenURLs, err := loadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when loading checkpoint: %s", err)
+	}
+	if len(seenURLs) != 3 {
+		t.Fatalf("Inconsistent seen URL number: expected: %d, actual: %d",
+			3, len(seenURLs))
+	}
+	if seenURLs[0] != "http://cn.bing.com/" || seenURLs[2] != urls[1] {
+		t.Fatalf("Inconsistent seen URLs: %v", seenURLs)
+	}
+	if len(state.AcceptedDomains) != 1 || state.AcceptedDomains[0] != "bing.com" {
+		t.Fatalf("Inconsistent accepted domains: %v", state.AcceptedDomains)
+	}
+	if state.Counters != counters {
+		t.Fatalf("Inconsistent counters: expected: %#v, actual: %#v",
+			counters, state.Counters)
+	}
+	if len(state.Pending) != 1 {
+		t.Fatalf("Inconsistent pending request number: expected: %d, actual: %d",
+			1, len(state.Pending))
+	}
+	req, err := state.Pending[0].toRequest()
+	if err != nil {
+		t.Fatalf("An error occurs when restoring request: %s", err)
+	}
+	if req.HTTPReq().URL.String() != urls[1] {
+		t.Fatalf("Inconsistent pending URL: expected: %s, actual: %s",
+			urls[1], req.HTTPReq().URL)
+	}
+	if req.Depth() != 2 {
+		t.Fatalf("Inconsistent pending depth: expected: %d, actual: %d",
+			2, req.Depth())
+	}
+	if req.Attempt() != 2 {
+		t.Fatalf("Inconsistent pending attempt: expected: %d, actual: %d",
+			2, req.Attempt())
+	}
+	if ua := req.HTTPReq().Header.Get("User-Agent"); ua != "finder" {
+		t.Fatalf("Inconsistent pending header: expected: %s, actual: %s",
+			"finder", ua)
+	}
+	// 恢复后的摘要计数应该累加。
+	cp = newCheckpointer(dataArgs, nil)
+	cp.resume(dir, state)
+	summary := cp.summary(checkpointCounters{Downloaded: 1})
+	if summary.Downloaded != 4 || summary.ResumedFrom != dir {
+		t.Fatalf("Inconsistent checkpoint summary: %#v", summary)
+	}
+}
+
+func TestCheckpointLoadInvalid(t *testing.T) {
+	if _, _, err := loadCheckpoint(""); err == nil {
+		t.Fatal("No error when loading checkpoint from empty dir!")
+	}
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, _, err := loadCheckpoint(dir); err == nil {
+		t.Fatal("No error when loading checkpoint without state file!")
+	}
+	statePath := filepath.Join(dir, checkpointStateFile)
+	ioutil.WriteFile(statePath, []byte(`{"version":1,"seen_number":2}`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, checkpointSeenFile), []byte("http://a.com/\n"), 0600)
+	if _, _, err := loadCheckpoint(dir); err == nil {
+		t.Fatal("No error when loading checkpoint with truncated seen log!")
+	}
+	ioutil.WriteFile(statePath, []byte(`{"version":99}`), 0600)
+	if _, _, err := loadCheckpoint(dir); err == nil {
+		t.Fatal("No error when loading checkpoint with unsupported version!")
+	}
+}
+
+func TestSchedResumeFromInvalid(t *testing.T) {
+	sched := NewScheduler()
+	if err := sched.ResumeFrom(""); err == nil {
+		t.Fatal("No error when resume scheduler before initialize!")
+	}
+	requestArgs := genRequestArgs([]string{"bing.com"}, 0)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(3, 2, 1, t)
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.ResumeFrom(""); err == nil {
+		t.Fatal("No error when resume scheduler from empty dir!")
+	}
+	if sched.Status() != SCHED_STATUS_INITIALIZED {
+		t.Fatalf("Inconsistent status after failed resume: expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_INITIALIZED), GetStatusDescription(sched.Status()))
+	}
+}
+
+func TestSchedCheckpointCanonical(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hits[r.URL.Path]++
+			lock.Unlock()
+			if r.URL.Path == "/" {
+				// 这些URL在规范化之后都会改变。
+				fmt.Fprint(w, "/a#top\n/b?y=2&x=1\n")
+			}
+		}))
+	defer server.Close()
+	run := func(resume bool) SummaryStruct {
+		requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+		dataArgs := genDataArgs(10, 2, 1)
+		dataArgs.CheckpointDir = dir
+		dataArgs.CheckpointInterval = time.Hour
+		moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+		a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating an analyzer: %s", err)
+		}
+		moduleArgs.Analyzers = []module.Analyzer{a}
+		sched := NewScheduler()
+		if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+			t.Fatalf("An error occurs when initializing scheduler: %s", err)
+		}
+		if resume {
+			err = sched.ResumeFrom(dir)
+		} else {
+			// 种子的URL没有路径，规范化之后会以“/”结尾。
+			firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+			err = sched.Start(firstHTTPReq)
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when starting scheduler: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := sched.Wait(ctx); err != nil {
+			t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+		}
+		summary := sched.Summary().Struct()
+		sched.Stop()
+		return summary
+	}
+	if summary := run(false); summary.Checkpoint.Pending != 0 {
+		t.Fatalf("Inconsistent pending request number after crawl: expected: %d, actual: %d",
+			0, summary.Checkpoint.Pending)
+	}
+	state, _, err := loadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when loading checkpoint: %s", err)
+	}
+	if len(state.Pending) != 0 {
+		t.Fatalf("Inconsistent pending requests in checkpoint: %#v", state.Pending)
+	}
+	// 恢复之后不会再次下载已处理完毕的请求。
+	run(true)
+	lock.Lock()
+	defer lock.Unlock()
+	for _, path := range []string{"/", "/a", "/b"} {
+		if hits[path] != 1 {
+			t.Fatalf("Inconsistent hits: %v", hits)
+		}
+	}
+}
@@ -1,60 +1,36 @@
 package scheduler
 
 import (
-	"regexp"
-	"strings"
-)
-
-var regexpForIP = regexp.MustCompile(`((?:(?:25[0-5]|2[0-4]\d|[01]?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|[01]?\d?\d))`)
+	"fmt"
 
-var regexpForDomains = []*regexp.Regexp{
-	// *.xx or *.xxx.xx
-	regexp.MustCompile(`\.(com|com\.\w{2})$`),
-	regexp.MustCompile(`\.(gov|gov\.\w{2})$`),
-	regexp.MustCompile(`\.(net|net\.\w{2})$`),
-	regexp.MustCompile(`\.(org|org\.\w{2})$`),
-	// *.xx
-	regexp.MustCompile(`\.me$`),
-	regexp.MustCompile(`\.biz$`),
-	regexp.MustCompile(`\.info$`),
-	regexp.MustCompile(`\.name$`),
-	regexp.MustCompile(`\.mobi$`),
-	regexp.MustCompile(`\.so$`),
-	regexp.MustCompile(`\.asia$`),
-	regexp.MustCompile(`\.tel$`),
-	regexp.MustCompile(`\.tv$`),
-	regexp.MustCompile(`\.cc$`),
-	regexp.MustCompile(`\.co$`),
-	regexp.MustCompile(`\.\w{2}$`),
-}
+	"gopcpv2-web-spider/toolkit/publicsuffix"
+)
 
 // getPrimaryDomain 用于获取给定主机名的主域名。
+// 主机名可以带有端口，IPv6地址可以带有方括号。
+// 对于IP地址，主域名就是去掉端口后的地址本身；
+// 对于域名，主域名是按照公共后缀列表得出的可注册的域名，国际化域名会被转换为Punycode。
+// 顶级域名不在列表中时按照默认规则“*”处理，即以最后一个标签作为公共后缀。
 func getPrimaryDomain(host string) (string, error) {
-	host = strings.TrimSpace(host)
-	if host == "" {
-		return "", genError("empty host")
+	domain, isIP, err := publicsuffix.Normalize(host)
+	if err != nil {
+		return "", genError(fmt.Sprintf("invalid host %q: %s", host, err))
 	}
-	if regexpForIP.MatchString(host) {
-		return host, nil
+	if isIP {
+		return domain, nil
 	}
-	var suffixIndex int
-	for _, re := range regexpForDomains {
-		pos := re.FindStringIndex(host)
-		if pos != nil {
-			suffixIndex = pos[0]
-			break
-		}
+	pd, _, err := publicsuffix.Default().EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", genError(fmt.Sprintf("invalid host %q: %s", host, err))
 	}
-	if suffixIndex > 0 {
-		var pdIndex int
-		firstPart := host[:suffixIndex]
-		index := strings.LastIndex(firstPart, ".")
-		if index < 0 {
-			pdIndex = 0
-		} else {
-			pdIndex = index + 1
-		}
-		return host[pdIndex:], nil
+	return pd, nil
+}
+
+// normalizeDomain 用于把给定的域名转换为与主域名一致的形式，无法转换时原样返回。
+func normalizeDomain(domain string) string {
+	normalized, _, err := publicsuffix.Normalize(domain)
+	if err != nil {
+		return domain
 	}
-	return "", genError("unrecognized host")
-}
\ No newline at end of file
+	return normalized
+}
@@ -28,9 +28,54 @@ func TestGetPrimaryDomain(t *testing.T) {
 	if err == nil {
 		t.Fatal("It still can get primary domain for a empty host!")
 	}
-	host = "123.abc"
+	host = "abc"
 	_, err = getPrimaryDomain(host)
 	if err == nil {
 		t.Fatalf("It still can get primary domain for a unrecognized host %q!", host)
 	}
-}
\ No newline at end of file
+}
+
+func TestGetPrimaryDomainPublicSuffix(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+	}{
+		{"www.foo.co.uk", "foo.co.uk"},
+		{"a.github.io", "a.github.io"},
+		{"x.com.cn", "x.com.cn"},
+		{"www.x.com.cn", "x.com.cn"},
+		{"blog.golang.dev", "golang.dev"},
+		{"my.web.app", "my.web.app"},
+		{"go.example.app", "example.app"},
+		{"www.example.se", "example.se"},
+		{"www.example.cz", "example.cz"},
+		{"www.example.pt", "example.pt"},
+		// 不在公共后缀列表中的顶级域名按照默认规则“*”处理。
+		{"www.123.notatld", "123.notatld"},
+		{"www.例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"cn.bing.com:8080", "bing.com"},
+		{"127.0.0.1:8080", "127.0.0.1"},
+		{"[::1]:8080", "::1"},
+		{"::1", "::1"},
+	}
+	for _, c := range cases {
+		pd, err := getPrimaryDomain(c.host)
+		if err != nil {
+			t.Fatalf("An error occurs when getting primary domain: %s (host: %s)",
+				err, c.host)
+		}
+		if pd != c.expected {
+			t.Fatalf("Inconsistent primary domain of %q: expected: %s, actual: %s",
+				c.host, c.expected, pd)
+		}
+	}
+	for _, host := range []string{"co.uk", "github.io", "localhost"} {
+		if _, err := getPrimaryDomain(host); err == nil {
+			t.Fatalf("It still can get primary domain for host %q!", host)
+		}
+	}
+	if domain := normalizeDomain("Bing.COM"); domain != "bing.com" {
+		t.Fatalf("Inconsistent normalized domain: expected: %s, actual: %s",
+			"bing.com", domain)
+	}
+}
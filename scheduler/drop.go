@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"gopcpv2-web-spider/module"
+)
+
+// DropReason 代表请求被丢弃的原因。
+type DropReason string
+
+const (
+	// DROP_REASON_INVALID 代表请求无效，例如没有HTTP请求或URL。
+	DROP_REASON_INVALID DropReason = "invalid"
+	// DROP_REASON_DUPLICATE 代表请求的URL已经见过。
+	DROP_REASON_DUPLICATE DropReason = "duplicate"
+	// DROP_REASON_SCHEME 代表请求的URL的协议不被支持。
+	DROP_REASON_SCHEME DropReason = "scheme"
+	// DROP_REASON_DOMAIN 代表请求的URL的域名不在爬取范围之内。
+	DROP_REASON_DOMAIN DropReason = "domain"
+	// DROP_REASON_DEPTH 代表请求的深度超出了最大深度。
+	DROP_REASON_DEPTH DropReason = "depth"
+	// DROP_REASON_ROBOTS 代表请求的URL被robots.txt禁止访问。
+	DROP_REASON_ROBOTS DropReason = "robots"
+	// DROP_REASON_FILTER 代表请求的URL被范围规则排除。
+	DROP_REASON_FILTER DropReason = "filter"
+	// DROP_REASON_NO_MODULE 代表请求因一直没有可用的下载器而被放回的次数超出了上限。
+	DROP_REASON_NO_MODULE DropReason = "no_module"
+	// DROP_REASON_REDIRECT 代表请求经历的重定向次数超出了上限。
+	DROP_REASON_REDIRECT DropReason = "redirect"
+)
+
+// dropReasons 代表所有的丢弃原因，其顺序与DropSummaryStruct中的字段一致。
+var dropReasons = []DropReason{
+	DROP_REASON_INVALID,
+	DROP_REASON_DUPLICATE,
+	DROP_REASON_SCHEME,
+	DROP_REASON_DOMAIN,
+	DROP_REASON_DEPTH,
+	DROP_REASON_ROBOTS,
+	DROP_REASON_FILTER,
+	DROP_REASON_NO_MODULE,
+	DROP_REASON_REDIRECT,
+}
+
+// DroppedRequest 代表一个被丢弃的请求的记录。
+type DroppedRequest struct {
+	// URL 代表请求的URL，请求无效时可能为空。
+	URL string `json:"url"`
+	// Depth 代表请求的深度。
+	Depth uint32 `json:"depth"`
+	// Reason 代表丢弃的原因。
+	Reason DropReason `json:"reason"`
+	// Detail 代表丢弃的详细原因，例如匹配的规则。
+	Detail string `json:"detail,omitempty"`
+}
+
+// DropSink 代表接收被丢弃的请求的记录的函数类型。
+// 它会在调度器的处理流程中被同步调用，所以不应阻塞。
+type DropSink func(dropped DroppedRequest)
+
+// dropChanCap 代表被丢弃的请求的通道的容量。
+const dropChanCap = 100
+
+// DropSummaryStruct 代表被丢弃的请求的摘要类型，其中是按原因统计的数量。
+type DropSummaryStruct struct {
+	Invalid   uint64 `json:"invalid"`
+	Duplicate uint64 `json:"duplicate"`
+	Scheme    uint64 `json:"scheme"`
+	Domain    uint64 `json:"domain"`
+	Depth     uint64 `json:"depth"`
+	Robots    uint64 `json:"robots"`
+	Filter    uint64 `json:"filter"`
+	NoModule  uint64 `json:"no_module"`
+	Redirect  uint64 `json:"redirect"`
+	// Total 代表被丢弃的请求的总数。
+	Total uint64 `json:"total"`
+	// Unreported 代表因通道已满而未能送达通道的记录的数量。
+	Unreported uint64 `json:"unreported"`
+}
+
+// dropRecorder 代表被丢弃的请求的记录器。
+type dropRecorder struct {
+	// counts 代表按原因统计的数量，其顺序与dropReasons一致。
+	counts     []uint64
+	unreported uint64
+	sink       DropSink
+	lock       sync.Mutex
+	chans      []chan DroppedRequest
+	closed     bool
+}
+
+// newDropRecorder 用于创建一个被丢弃的请求的记录器。参数sink可以为nil。
+func newDropRecorder(sink DropSink) *dropRecorder {
+	return &dropRecorder{
+		counts: make([]uint64, len(dropReasons)),
+		sink:   sink,
+	}
+}
+
+// record 会记录一个被丢弃的请求并返回它的记录。参数req可以为nil。
+func (dr *dropRecorder) record(req *module.Request, reason DropReason, detail string) DroppedRequest {
+	dropped := DroppedRequest{Reason: reason, Detail: detail}
+	if req != nil {
+		dropped.Depth = req.Depth()
+		if httpReq := req.HTTPReq(); httpReq != nil && httpReq.URL != nil {
+			dropped.URL = httpReq.URL.String()
+		}
+	}
+	if dr == nil {
+		return dropped
+	}
+	for i, r := range dropReasons {
+		if r == reason {
+			// 每种原因只把第一个被丢弃的请求记入日志，以免日志被淹没，其余的只做计数。
+			if atomic.AddUint64(&dr.counts[i], 1) == 1 {
+				log.Printf("忽略请求（%s: %s）：%s（此后因同样原因被忽略的请求只计数，不再记入日志）",
+					reason, detail, dropped.URL)
+			}
+			break
+		}
+	}
+	if dr.sink != nil {
+		dr.sink(dropped)
+	}
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	for _, ch := range dr.chans {
+		select {
+		case ch <- dropped:
+		default:
+			atomic.AddUint64(&dr.unreported, 1)
+		}
+	}
+	return dropped
+}
+
+// subscribe 用于获取一个接收被丢弃的请求的记录的通道。
+// 通道已满时新的记录不会被送达，通道会在记录器关闭时被关闭。
+func (dr *dropRecorder) subscribe() <-chan DroppedRequest {
+	ch := make(chan DroppedRequest, dropChanCap)
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	if dr.closed {
+		close(ch)
+		return ch
+	}
+	dr.chans = append(dr.chans, ch)
+	return ch
+}
+
+// close 会关闭所有的通道。
+func (dr *dropRecorder) close() {
+	if dr == nil {
+		return
+	}
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	if dr.closed {
+		return
+	}
+	dr.closed = true
+	for _, ch := range dr.chans {
+		close(ch)
+	}
+	dr.chans = nil
+}
+
+// summary 用于获取被丢弃的请求的摘要。
+func (dr *dropRecorder) summary() DropSummaryStruct {
+	if dr == nil {
+		return DropSummaryStruct{}
+	}
+	counts := make([]uint64, len(dropReasons))
+	var total uint64
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&dr.counts[i])
+		total += counts[i]
+	}
+	return DropSummaryStruct{
+		Invalid:    counts[0],
+		Duplicate:  counts[1],
+		Scheme:     counts[2],
+		Domain:     counts[3],
+		Depth:      counts[4],
+		Robots:     counts[5],
+		Filter:     counts[6],
+		NoModule:   counts[7],
+		Redirect:   counts[8],
+		Total:      total,
+		Unreported: atomic.LoadUint64(&dr.unreported),
+	}
+}
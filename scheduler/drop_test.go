@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestDropRecorder(t *testing.T) {
+	var lock sync.Mutex
+	var sunk []DroppedRequest
+	dr := newDropRecorder(func(dropped DroppedRequest) {
+		lock.Lock()
+		defer lock.Unlock()
+		sunk = append(sunk, dropped)
+	})
+	ch := dr.subscribe()
+	httpReq, _ := http.NewRequest("GET", "http://a.com/x", nil)
+	req := module.NewRequest(httpReq, 2)
+	dr.record(req, DROP_REASON_DEPTH, "too deep")
+	dr.record(nil, DROP_REASON_INVALID, "nil request")
+	for i := 0; i < dropChanCap+5; i++ {
+		dr.record(req, DROP_REASON_DUPLICATE, "")
+	}
+	expected := DropSummaryStruct{
+		Invalid:    1,
+		Duplicate:  uint64(dropChanCap + 5),
+		Depth:      1,
+		Total:      uint64(dropChanCap + 7),
+		Unreported: 7,
+	}
+	if summary := dr.summary(); summary != expected {
+		t.Fatalf("Inconsistent drop summary: expected: %#v, actual: %#v",
+			expected, summary)
+	}
+	if len(sunk) != dropChanCap+7 {
+		t.Fatalf("Inconsistent sunk record number: expected: %d, actual: %d",
+			dropChanCap+7, len(sunk))
+	}
+	expectedRecord := DroppedRequest{
+		URL: "http://a.com/x", Depth: 2, Reason: DROP_REASON_DEPTH, Detail: "too deep"}
+	if sunk[0] != expectedRecord {
+		t.Fatalf("Inconsistent dropped request: expected: %#v, actual: %#v",
+			expectedRecord, sunk[0])
+	}
+	if dropped := <-ch; dropped != expectedRecord {
+		t.Fatalf("Inconsistent dropped request: expected: %#v, actual: %#v",
+			expectedRecord, dropped)
+	}
+	dr.close()
+	number := 1
+	for range ch {
+		number++
+	}
+	if number != dropChanCap {
+		t.Fatalf("Inconsistent received record number: expected: %d, actual: %d",
+			dropChanCap, number)
+	}
+	if _, ok := <-dr.subscribe(); ok {
+		t.Fatal("The drop channel isn't closed after the recorder is closed!")
+	}
+	// 关闭后仍可以记录。
+	dr.record(req, DROP_REASON_ROBOTS, "")
+	dr.close()
+}
+
+func TestDropRecorderLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	dr := newDropRecorder(nil)
+	defer dr.close()
+	httpReq, _ := http.NewRequest("GET", "http://a.com/x", nil)
+	req := module.NewRequest(httpReq, 0)
+	for i := 0; i < 10; i++ {
+		dr.record(req, DROP_REASON_DOMAIN, "a.com")
+		dr.record(req, DROP_REASON_DUPLICATE, "")
+	}
+	// 每种原因只有第一个被丢弃的请求会被记入日志。
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("Inconsistent log line number: expected: %d, actual: %d\n%s",
+			2, n, buf.String())
+	}
+}
+
+func TestSchedDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/robots.txt":
+				fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			case "/":
+				fmt.Fprint(w, "/a\n/a\n/a?utm_source=x\nftp://127.0.0.1/f\n"+
+					"http://www.bing.net/\n/admin/\n/private/x\n")
+			case "/a":
+				fmt.Fprint(w, "/b\n")
+			}
+		}))
+	defer server.Close()
+	var lock sync.Mutex
+	sunk := map[DropReason]int{}
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.ObeyRobots = true
+	requestArgs.Canonical = CanonicalArgs{IgnoredParams: DefaultIgnoredParams}
+	requestArgs.Scope = ScopeArgs{Rules: []ScopeRule{
+		{Action: SCOPE_ACTION_EXCLUDE, PathPrefix: "/admin/"},
+	}}
+	requestArgs.DropSink = func(dropped DroppedRequest) {
+		lock.Lock()
+		defer lock.Unlock()
+		sunk[dropped.Reason]++
+	}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	dropCh := sched.DropChan()
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// “/a”中的“/b”的深度超出了最大深度。
+	expected := DropSummaryStruct{
+		Duplicate: 2,
+		Scheme:    1,
+		Domain:    1,
+		Depth:     1,
+		Robots:    1,
+		Filter:    1,
+		Total:     7,
+	}
+	summary := sched.Summary().Struct()
+	if summary.Dropped != expected {
+		t.Fatalf("Inconsistent drop summary: expected: %#v, actual: %#v",
+			expected, summary.Dropped)
+	}
+	sched.Stop()
+	received := map[DropReason]int{}
+	for dropped := range dropCh {
+		received[dropped.Reason]++
+		if dropped.Reason == DROP_REASON_FILTER && dropped.Detail == "" {
+			t.Fatalf("No detail in dropped request: %#v", dropped)
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for _, reasons := range []map[DropReason]int{received, sunk} {
+		if reasons[DROP_REASON_DUPLICATE] != 2 || reasons[DROP_REASON_SCHEME] != 1 ||
+			reasons[DROP_REASON_DOMAIN] != 1 || reasons[DROP_REASON_ROBOTS] != 1 ||
+			reasons[DROP_REASON_FILTER] != 1 || reasons[DROP_REASON_DEPTH] != 1 {
+			t.Fatalf("Inconsistent dropped requests: %v", reasons)
+		}
+	}
+}
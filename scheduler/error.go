@@ -21,8 +21,20 @@ func genParameterError(errMsg string) error {
 	return errors.NewCrawlerError(errors.ERROR_TYPE_SCHEDULER, errors.NewIllegalParameterError(errMsg).Error())
 }
 
+// firstError 用于获取给定的错误值列表中的第一个非nil的错误值，不存在时结果值为nil。
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // sendError 用于向错误缓冲池发送错误值。
-func sendError(err error, mid module.MID, errorBufferPool buffer.Pool) bool {
+// 参数tracker不为nil时，错误值在放入错误缓冲池之前会被计入其中，
+// 但不会妨碍其发出完成信号，所以无人读取错误时调度器仍能在爬取完毕后停止。
+func sendError(err error, mid module.MID, errorBufferPool buffer.Pool, tracker *workTracker) bool {
 	if err == nil || errorBufferPool == nil || errorBufferPool.Closed() {
 		return false
 	}
@@ -50,7 +62,9 @@ func sendError(err error, mid module.MID, errorBufferPool buffer.Pool) bool {
 	if errorBufferPool.Closed() {
 		return false
 	}
+	tracker.incr(workKindError)
 	go func(crawlerError errors.CrawlerError) {
+		defer tracker.decr(workKindError)
 		if err := errorBufferPool.Put(crawlerError); err != nil {
 			log.Printf("错误数据发送到缓冲池错误")
 		}
@@ -68,12 +68,12 @@ func TestErrorSend(t *testing.T) {
 		errors.ERROR_TYPE_SCHEDULER, "testing error")
 	mid := module.MID("")
 	buffer, _ := buffer.NewPool(10, 2)
-	if !sendError(cerr, mid, buffer) {
+	if !sendError(cerr, mid, buffer, nil) {
 		t.Fatalf("Couldn't send error! (error: %s, MID: %s, buffer: %#v)",
 			cerr, mid, buffer)
 	}
 	err := errors.NewCrawlerError(errors.ERROR_TYPE_SCHEDULER, "testing error")
-	if !sendError(err, mid, buffer) {
+	if !sendError(err, mid, buffer, nil) {
 		t.Fatalf("Couldn't send error! (error: %s, MID: %s, buffer: %#v)",
 			err, mid, buffer)
 	}
@@ -83,19 +83,41 @@ func TestErrorSend(t *testing.T) {
 		module.MID("P0"),
 	}
 	for _, mid := range mids {
-		if !sendError(err, mid, buffer) {
+		if !sendError(err, mid, buffer, nil) {
 			t.Fatalf("Couldn't send error! (error: %s, MID: %s, buffer: %#v)",
 				err, mid, buffer)
 		}
 	}
-	if sendError(nil, mid, buffer) {
+	if sendError(nil, mid, buffer, nil) {
 		t.Fatalf("It still can send error with nil error!")
 	}
-	if sendError(err, mid, nil) {
+	if sendError(err, mid, nil, nil) {
 		t.Fatalf("It still can send error with nil buffer!")
 	}
 	buffer.Close()
-	if sendError(err, mid, buffer) {
+	if sendError(err, mid, buffer, nil) {
 		t.Fatalf("It still can send error with closed buffer!")
 	}
-}
\ No newline at end of file
+}
+
+func TestErrorSendUndrained(t *testing.T) {
+	// 错误缓冲池已满且无人读取时，错误不应妨碍发出完成信号。
+	buffer, _ := buffer.NewPool(1, 1)
+	defer buffer.Close()
+	wt := newWorkTracker()
+	wt.incr(workKindRequest)
+	number := 3
+	for i := 0; i < number; i++ {
+		err := errors.NewCrawlerError(errors.ERROR_TYPE_SCHEDULER, "testing error")
+		if !sendError(err, module.MID(""), buffer, wt) {
+			t.Fatalf("Couldn't send error! (error: %s)", err)
+		}
+	}
+	wt.decr(workKindRequest)
+	if !isClosed(wt.done()) {
+		t.Fatal("The done channel has not been closed with undrained errors!")
+	}
+	if n := wt.summary().Errors; n == 0 || n > uint64(number) {
+		t.Fatalf("Inconsistent in-flight error number: %d", n)
+	}
+}
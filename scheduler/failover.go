@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// defaultMaxRequeues 代表请求因没有可用的下载器而被放回请求缓冲池的默认最大次数。
+const defaultMaxRequeues = 3
+
+// requeueInterval 代表未使用熔断器时，请求被放回请求缓冲池之前等待的时长。
+const requeueInterval = 100 * time.Millisecond
+
+// requeueCounter 用于记录各请求已被放回请求缓冲池的次数。
+type requeueCounter struct {
+	lock   sync.Mutex
+	counts map[*module.Request]uint32
+}
+
+// newRequeueCounter 用于创建一个请求放回次数的记录器。
+func newRequeueCounter() *requeueCounter {
+	return &requeueCounter{counts: map[*module.Request]uint32{}}
+}
+
+// incr 用于增加给定请求的放回次数，结果值为增加后的次数。
+func (rc *requeueCounter) incr(req *module.Request) uint32 {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.counts[req]++
+	return rc.counts[req]
+}
+
+// remove 用于删除给定请求的记录。
+func (rc *requeueCounter) remove(req *module.Request) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	delete(rc.counts, req)
+}
+
+// breakerChanged 会通过错误缓冲池和观察者报告组件的熔断器的状态变化。
+func (sched *myScheduler) breakerChanged(change module.BreakerStateChange) {
+	log.Printf("组件%s的熔断器由%s变为%s", change.MID, change.From, change.To)
+	sched.sendError(change, change.MID)
+}
+
+// requeueDelay 用于获取没有可用的下载器时，请求被放回之前等待的时长。
+// 下载器都已被熔断时，等待断开的时长之后才会有半开的下载器可用。
+func (sched *myScheduler) requeueDelay() time.Duration {
+	if !sched.breakerArgs.Enabled() {
+		return requeueInterval
+	}
+	if sched.breakerArgs.OpenTimeout == 0 {
+		return module.DefaultBreakerOpenTimeout
+	}
+	return sched.breakerArgs.OpenTimeout
+}
+
+// requeueReq 会在给定的时长之后把未能下载的请求放回请求缓冲池，以便由其他下载器处理。
+// 它不会像sendReq那样检查请求，所以不会因请求已被见过而丢弃它。
+// 放回的次数超出上限后请求会被丢弃。结果值代表请求是否被放回。
+func (sched *myScheduler) requeueReq(req *module.Request, delay time.Duration) bool {
+	if sched.canceled() {
+		return false
+	}
+	if count := sched.requeues.incr(req); count > sched.maxRequeues {
+		sched.requeues.remove(req)
+		sched.checkpointer.donePending(req)
+		sched.dropReq(req, DROP_REASON_NO_MODULE,
+			fmt.Sprintf("requeued %d times", sched.maxRequeues))
+		return false
+	}
+	sched.putReqAfter(req, delay)
+	return true
+}
+
+// putReqAfter 会在给定的时长之后把请求放入请求缓冲池，不做任何检查。
+// 请求在等待期间即被计为尚未处理的请求。
+func (sched *myScheduler) putReqAfter(req *module.Request, delay time.Duration) {
+	sched.tracker.incr(workKindRequest)
+	reqBufferPool := sched.reqBufferPool
+	time.AfterFunc(delay, func() {
+		if err := reqBufferPool.Put(req); err != nil {
+			log.Println("请求发送给请求缓冲器失败")
+			sched.tracker.decr(workKindRequest)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+	"gopcpv2-web-spider/module/local/downloader"
+	"gopcpv2-web-spider/module/local/pipeline"
+)
+
+// failingTransport 代表总是失败的HTTP传输。
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+// breakerObserver 代表记录熔断器状态变化的观察者。
+type breakerObserver struct {
+	NopObserver
+	lock    sync.Mutex
+	changes []module.BreakerStateChange
+}
+
+func (bo *breakerObserver) OnError(err error, mid module.MID) {
+	if change, ok := err.(module.BreakerStateChange); ok {
+		bo.lock.Lock()
+		bo.changes = append(bo.changes, change)
+		bo.lock.Unlock()
+	}
+}
+
+// waitChanges 用于等待观察者收到给定数量的状态变化。
+func (bo *breakerObserver) waitChanges(number int, t *testing.T) []module.BreakerStateChange {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		bo.lock.Lock()
+		changes := append([]module.BreakerStateChange(nil), bo.changes...)
+		bo.lock.Unlock()
+		if len(changes) >= number {
+			return changes
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Inconsistent breaker state changes: expected: %d, actual: %v",
+				number, changes)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// genFailingDownloader 用于生成一个总是下载失败的下载器。
+func genFailingDownloader(mid module.MID, t *testing.T) module.Downloader {
+	d, err := downloader.New(mid, &http.Client{Transport: failingTransport{}}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	return d
+}
+
+func TestSchedBreakerFailover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				for i := 0; i < 6; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	healthyMID := moduleArgs.Downloaders[0].ID()
+	// 失败的下载器的MID较小，所以会被轮询首先选中。
+	moduleArgs.Downloaders = append(moduleArgs.Downloaders, genFailingDownloader("D0", t))
+	moduleArgs.Balancers = BalancerArgs{Downloader: module.BALANCE_ROUND_ROBIN}
+	moduleArgs.Breaker = module.BreakerArgs{FailureThreshold: 1, OpenTimeout: time.Hour}
+	observer := &breakerObserver{}
+	moduleArgs.Observers = []Observer{observer}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	summary := sched.Summary().Struct()
+	// 失败的请求被转给健康的下载器，所以所有的页面都被下载了。
+	for _, ds := range summary.Downloaders {
+		switch module.MID(ds.ID) {
+		case "D0":
+			if ds.Called != 1 || ds.Breaker != module.BREAKER_OPEN {
+				t.Fatalf("Inconsistent summary of failing downloader: %#v", ds)
+			}
+		case healthyMID:
+			if ds.Completed != 7 || ds.Breaker != module.BREAKER_CLOSED {
+				t.Fatalf("Inconsistent summary of healthy downloader: %#v", ds)
+			}
+		}
+	}
+	if summary.Dropped.NoModule != 0 {
+		t.Fatalf("Inconsistent dropped requests: %#v", summary.Dropped)
+	}
+	changes := observer.waitChanges(1, t)
+	if changes[0].MID != "D0" || changes[0].To != module.BREAKER_OPEN {
+		t.Fatalf("Inconsistent breaker state change: %#v", changes[0])
+	}
+}
+
+func TestSchedBreakerRequeueLimit(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(0, 1, 1, t)
+	moduleArgs.Downloaders = []module.Downloader{genFailingDownloader("D1", t)}
+	moduleArgs.Breaker = module.BreakerArgs{
+		FailureThreshold: 1,
+		OpenTimeout:      20 * time.Millisecond,
+	}
+	moduleArgs.MaxRequeues = 2
+	observer := &breakerObserver{}
+	moduleArgs.Observers = []Observer{observer}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 断开后被放回一次；没有可用的下载器，等待后再被放回一次；
+	// 半开时的试探仍然失败，放回的次数超出上限，请求被丢弃。
+	summary := sched.Summary().Struct()
+	if summary.Dropped.NoModule != 1 {
+		t.Fatalf("Inconsistent dropped requests: %#v", summary.Dropped)
+	}
+	if called := summary.Downloaders[0].Called; called != 2 {
+		t.Fatalf("Inconsistent called count: expected: %d, actual: %d", 2, called)
+	}
+	changes := observer.waitChanges(3, t)
+	expected := []module.BreakerState{
+		module.BREAKER_OPEN, module.BREAKER_HALF_OPEN, module.BREAKER_OPEN}
+	for i, state := range expected {
+		if changes[i].To != state {
+			t.Fatalf("Inconsistent breaker state changes: expected: %v, actual: %v",
+				expected, changes)
+		}
+	}
+}
+
+func TestSchedBreakerAnalyzerPipeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 0, t)
+	// 解析器和条目处理器都会失败，但解析器仍会生成一个条目。
+	a, err := analyzer.New("A1", []module.ParseResponse{
+		func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+			httpResp.Body.Close()
+			item := module.Item{"url": httpResp.Request.URL.String()}
+			return []module.Data{item}, []error{errors.New("parse error")}
+		}}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	p, err := pipeline.New("P1", []module.ProcessItem{
+		func(item module.Item) (module.Item, error) {
+			return nil, errors.New("process error")
+		}}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	moduleArgs.Pipelines = []module.Pipeline{p}
+	moduleArgs.Breaker = module.BreakerArgs{FailureThreshold: 1, OpenTimeout: time.Hour}
+	observer := &breakerObserver{}
+	moduleArgs.Observers = []Observer{observer}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 解析器和条目处理器的失败也会使其熔断器断开。
+	summary := sched.Summary().Struct()
+	if as := summary.Analyzers[0]; as.Breaker != module.BREAKER_OPEN {
+		t.Fatalf("Inconsistent summary of failing analyzer: %#v", as)
+	}
+	if ps := summary.Pipelines[0]; ps.Breaker != module.BREAKER_OPEN {
+		t.Fatalf("Inconsistent summary of failing pipeline: %#v", ps)
+	}
+	changes := observer.waitChanges(2, t)
+	for _, change := range changes {
+		if change.To != module.BREAKER_OPEN {
+			t.Fatalf("Inconsistent breaker state change: %#v", change)
+		}
+	}
+}
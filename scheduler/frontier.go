@@ -0,0 +1,438 @@
+package scheduler
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/toolkit/buffer"
+)
+
+// FrontierStrategy 代表爬取边界决定请求下载顺序的策略。
+type FrontierStrategy string
+
+const (
+	// FRONTIER_STRATEGY_BFS 代表广度优先，先放入的请求先被取出。
+	FRONTIER_STRATEGY_BFS FrontierStrategy = "bfs"
+	// FRONTIER_STRATEGY_DFS 代表深度优先，后放入的请求先被取出。
+	FRONTIER_STRATEGY_DFS FrontierStrategy = "dfs"
+	// FRONTIER_STRATEGY_BEST_FIRST 代表最佳优先，优先级最高的请求先被取出。
+	FRONTIER_STRATEGY_BEST_FIRST FrontierStrategy = "best_first"
+	// FRONTIER_STRATEGY_HOST_ROUND_ROBIN 代表按主机轮流取出请求，
+	// 同一主机的请求先放入的先被取出。
+	FRONTIER_STRATEGY_HOST_ROUND_ROBIN FrontierStrategy = "host_round_robin"
+)
+
+// RequestPriority 代表计算请求优先级的函数类型，结果值越大越先被下载。
+type RequestPriority func(req *module.Request) float64
+
+// MetaPriority 代表以请求的元数据中的优先级作为请求优先级的函数。
+func MetaPriority(req *module.Request) float64 {
+	return float64(req.Meta().Priority)
+}
+
+// checkFrontierStrategy 用于检查爬取边界的策略及其所需的参数。
+func checkFrontierStrategy(strategy FrontierStrategy, priority RequestPriority) error {
+	switch strategy {
+	case "", FRONTIER_STRATEGY_BFS, FRONTIER_STRATEGY_DFS,
+		FRONTIER_STRATEGY_HOST_ROUND_ROBIN:
+		return nil
+	case FRONTIER_STRATEGY_BEST_FIRST:
+		if priority == nil {
+			return genError("nil request priority function for best-first frontier")
+		}
+		return nil
+	}
+	return genError(fmt.Sprintf("unknown frontier strategy: %q", strategy))
+}
+
+// Frontier 代表爬取边界的接口类型。
+// 它可以代替请求缓冲池，并按照策略决定请求被取出的顺序。
+type Frontier interface {
+	buffer.Pool
+	// Strategy 用于获取爬取边界的策略。
+	Strategy() FrontierStrategy
+}
+
+// NewFrontier 用于创建一个爬取边界。
+// 参数bufferCap与maxBufferNumber之积为其容量，策略为空时视为广度优先。
+func NewFrontier(
+	strategy FrontierStrategy,
+	priority RequestPriority,
+	bufferCap uint32,
+	maxBufferNumber uint32) (Frontier, error) {
+	if bufferCap == 0 {
+		return nil, genParameterError("zero frontier buffer capacity")
+	}
+	if maxBufferNumber == 0 {
+		return nil, genParameterError("zero max frontier buffer number")
+	}
+	if err := checkFrontierStrategy(strategy, priority); err != nil {
+		return nil, err
+	}
+	if strategy == "" {
+		strategy = FRONTIER_STRATEGY_BFS
+	}
+	var queue frontierQueue
+	switch strategy {
+	case FRONTIER_STRATEGY_BFS:
+		queue = &fifoQueue{}
+	case FRONTIER_STRATEGY_DFS:
+		queue = &lifoQueue{}
+	case FRONTIER_STRATEGY_BEST_FIRST:
+		queue = &priorityQueue{priority: priority}
+	case FRONTIER_STRATEGY_HOST_ROUND_ROBIN:
+		queue = newHostRoundRobinQueue()
+	}
+	f := &myFrontier{
+		strategy:        strategy,
+		bufferCap:       bufferCap,
+		maxBufferNumber: maxBufferNumber,
+		queue:           queue,
+	}
+	f.notEmpty = sync.NewCond(&f.lock)
+	f.notFull = sync.NewCond(&f.lock)
+	return f, nil
+}
+
+// myFrontier 代表爬取边界的实现类型。
+type myFrontier struct {
+	strategy        FrontierStrategy
+	bufferCap       uint32
+	maxBufferNumber uint32
+	queue           frontierQueue
+	total           uint64
+	closed          uint32
+	lock            sync.Mutex
+	notEmpty        *sync.Cond
+	notFull         *sync.Cond
+}
+
+func (f *myFrontier) Strategy() FrontierStrategy {
+	return f.strategy
+}
+
+func (f *myFrontier) BufferCap() uint32 {
+	return f.bufferCap
+}
+
+func (f *myFrontier) MaxBufferNumber() uint32 {
+	return f.maxBufferNumber
+}
+
+// BufferNumber 用于获取按统一容量折算出的缓冲器的数量，至少为1。
+func (f *myFrontier) BufferNumber() uint32 {
+	total := atomic.LoadUint64(&f.total)
+	number := uint32((total + uint64(f.bufferCap) - 1) / uint64(f.bufferCap))
+	if number == 0 {
+		number = 1
+	}
+	return number
+}
+
+func (f *myFrontier) Total() uint64 {
+	return atomic.LoadUint64(&f.total)
+}
+
+// capacity 用于获取爬取边界能容纳的请求的最大数量。
+func (f *myFrontier) capacity() int {
+	return int(f.bufferCap) * int(f.maxBufferNumber)
+}
+
+// Put 会放入一个请求，爬取边界已满时会阻塞。
+func (f *myFrontier) Put(datum interface{}) error {
+	req, ok := datum.(*module.Request)
+	if !ok || req == nil {
+		return genParameterError(fmt.Sprintf("invalid frontier datum: %T", datum))
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for !f.Closed() && f.queue.len() >= f.capacity() {
+		f.notFull.Wait()
+	}
+	if f.Closed() {
+		return buffer.ErrClosedBufferPool
+	}
+	f.queue.push(req)
+	atomic.AddUint64(&f.total, 1)
+	f.notEmpty.Signal()
+	return nil
+}
+
+// tryPut 会在爬取边界未满时放入一个请求，它不会阻塞。
+// 结果值ok代表请求是否已被放入。
+func (f *myFrontier) tryPut(req *module.Request) (ok bool, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.Closed() {
+		return false, buffer.ErrClosedBufferPool
+	}
+	if f.queue.len() >= f.capacity() {
+		return false, nil
+	}
+	f.queue.push(req)
+	atomic.AddUint64(&f.total, 1)
+	f.notEmpty.Signal()
+	return true, nil
+}
+
+// Get 会按照策略取出一个请求，爬取边界为空时会阻塞。
+func (f *myFrontier) Get() (interface{}, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for !f.Closed() && f.queue.len() == 0 {
+		f.notEmpty.Wait()
+	}
+	if f.Closed() {
+		return nil, buffer.ErrClosedBufferPool
+	}
+	req := f.queue.pop()
+	atomic.AddUint64(&f.total, ^uint64(0))
+	f.notFull.Signal()
+	return req, nil
+}
+
+// Close 会关闭爬取边界，并唤醒所有阻塞的调用方。
+func (f *myFrontier) Close() bool {
+	if !atomic.CompareAndSwapUint32(&f.closed, 0, 1) {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.notEmpty.Broadcast()
+	f.notFull.Broadcast()
+	return true
+}
+
+func (f *myFrontier) Closed() bool {
+	return atomic.LoadUint32(&f.closed) == 1
+}
+
+// reqFeeder 代表按顺序把请求放入请求缓冲池的投递器。
+// 缓冲池未满且没有等待放入的请求时，请求会被直接放入；
+// 否则请求会排在等待放入的请求之后，由唯一的协程依次放入。
+// 这样同一个响应中的各个链接会按照被发送的顺序进入爬取边界。
+// 它的零值即可使用。
+type reqFeeder struct {
+	lock    sync.Mutex
+	waiting []feederEntry
+	running bool
+}
+
+// feederEntry 代表等待放入请求缓冲池的请求。
+type feederEntry struct {
+	pool   buffer.Pool
+	req    *module.Request
+	failed func(err error)
+}
+
+// put 会把请求放入给定的缓冲池，它不会阻塞。
+// 放入失败时函数failed会被调用。
+func (rf *reqFeeder) put(pool buffer.Pool, req *module.Request, failed func(err error)) {
+	rf.lock.Lock()
+	if len(rf.waiting) == 0 {
+		if f, ok := pool.(*myFrontier); ok {
+			if ok, err := f.tryPut(req); ok || err != nil {
+				rf.lock.Unlock()
+				if err != nil {
+					failed(err)
+				}
+				return
+			}
+		}
+	}
+	rf.waiting = append(rf.waiting, feederEntry{pool: pool, req: req, failed: failed})
+	if !rf.running {
+		rf.running = true
+		go rf.feed()
+	}
+	rf.lock.Unlock()
+}
+
+// feed 会依次把等待放入的请求放入缓冲池，直至没有等待放入的请求。
+func (rf *reqFeeder) feed() {
+	for {
+		rf.lock.Lock()
+		if len(rf.waiting) == 0 {
+			rf.running = false
+			rf.lock.Unlock()
+			return
+		}
+		entry := rf.waiting[0]
+		rf.lock.Unlock()
+		// 放入时可能阻塞，此时新的请求都会排在它之后。
+		err := entry.pool.Put(entry.req)
+		rf.lock.Lock()
+		rf.waiting[0] = feederEntry{}
+		rf.waiting = rf.waiting[1:]
+		rf.lock.Unlock()
+		if err != nil {
+			entry.failed(err)
+		}
+	}
+}
+
+// frontierQueue 代表爬取边界内部存放请求的队列。
+type frontierQueue interface {
+	push(req *module.Request)
+	pop() *module.Request
+	len() int
+}
+
+// fifoQueue 代表先进先出的队列。
+type fifoQueue struct {
+	reqs []*module.Request
+}
+
+func (q *fifoQueue) push(req *module.Request) {
+	q.reqs = append(q.reqs, req)
+}
+
+func (q *fifoQueue) pop() *module.Request {
+	req := q.reqs[0]
+	q.reqs[0] = nil
+	q.reqs = q.reqs[1:]
+	return req
+}
+
+func (q *fifoQueue) len() int {
+	return len(q.reqs)
+}
+
+// lifoQueue 代表后进先出的队列。
+type lifoQueue struct {
+	reqs []*module.Request
+}
+
+func (q *lifoQueue) push(req *module.Request) {
+	q.reqs = append(q.reqs, req)
+}
+
+func (q *lifoQueue) pop() *module.Request {
+	last := len(q.reqs) - 1
+	req := q.reqs[last]
+	q.reqs[last] = nil
+	q.reqs = q.reqs[:last]
+	return req
+}
+
+func (q *lifoQueue) len() int {
+	return len(q.reqs)
+}
+
+// priorityItem 代表优先级队列中的一个元素。
+type priorityItem struct {
+	req      *module.Request
+	priority float64
+	// seq 代表放入的顺序，用于保证相同优先级的请求先进先出。
+	seq uint64
+}
+
+// priorityQueue 代表按优先级从高到低取出的队列。
+// 请求的优先级在放入时计算。
+type priorityQueue struct {
+	priority RequestPriority
+	items    []priorityItem
+	seq      uint64
+}
+
+func (q *priorityQueue) push(req *module.Request) {
+	q.seq++
+	heap.Push((*priorityHeap)(q), priorityItem{
+		req:      req,
+		priority: q.priority(req),
+		seq:      q.seq,
+	})
+}
+
+func (q *priorityQueue) pop() *module.Request {
+	return heap.Pop((*priorityHeap)(q)).(priorityItem).req
+}
+
+func (q *priorityQueue) len() int {
+	return len(q.items)
+}
+
+// priorityHeap 用于为优先级队列实现heap.Interface。
+type priorityHeap priorityQueue
+
+func (h *priorityHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *priorityHeap) Less(i, j int) bool {
+	if h.items[i].priority != h.items[j].priority {
+		return h.items[i].priority > h.items[j].priority
+	}
+	return h.items[i].seq < h.items[j].seq
+}
+
+func (h *priorityHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *priorityHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(priorityItem))
+}
+
+func (h *priorityHeap) Pop() interface{} {
+	last := len(h.items) - 1
+	item := h.items[last]
+	h.items[last] = priorityItem{}
+	h.items = h.items[:last]
+	return item
+}
+
+// hostRoundRobinQueue 代表按主机轮流取出的队列。
+type hostRoundRobinQueue struct {
+	queues map[string]*fifoQueue
+	// hosts 代表有请求的主机，按轮流的顺序排列。
+	hosts []string
+	// next 代表下一个被取出请求的主机在hosts中的索引。
+	next  int
+	total int
+}
+
+// newHostRoundRobinQueue 用于创建一个按主机轮流取出的队列。
+func newHostRoundRobinQueue() *hostRoundRobinQueue {
+	return &hostRoundRobinQueue{queues: map[string]*fifoQueue{}}
+}
+
+func (q *hostRoundRobinQueue) push(req *module.Request) {
+	var host string
+	if httpReq := req.HTTPReq(); httpReq != nil && httpReq.URL != nil {
+		host = httpReq.URL.Host
+	}
+	hq, ok := q.queues[host]
+	if !ok {
+		hq = &fifoQueue{}
+		q.queues[host] = hq
+		q.hosts = append(q.hosts, host)
+	}
+	hq.push(req)
+	q.total++
+}
+
+func (q *hostRoundRobinQueue) pop() *module.Request {
+	if q.next >= len(q.hosts) {
+		q.next = 0
+	}
+	host := q.hosts[q.next]
+	hq := q.queues[host]
+	req := hq.pop()
+	q.total--
+	if hq.len() == 0 {
+		// 移除没有请求的主机，下一个主机会顺移到当前索引。
+		delete(q.queues, host)
+		q.hosts = append(q.hosts[:q.next], q.hosts[q.next+1:]...)
+	} else {
+		q.next++
+	}
+	return req
+}
+
+func (q *hostRoundRobinQueue) len() int {
+	return q.total
+}
@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/toolkit/buffer"
+)
+
+// genFrontierReq 用于生成针对给定URL和深度的请求。
+func genFrontierReq(url string, depth uint32, t *testing.T) *module.Request {
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)",
+			err, url)
+	}
+	return module.NewRequest(httpReq, depth)
+}
+
+func TestFrontierStrategies(t *testing.T) {
+	urls := []string{
+		"http://a.com/1",
+		"http://a.com/2",
+		"http://a.com/golang",
+		"http://b.com/1",
+		"http://c.com/1",
+		"http://b.com/golang",
+	}
+	// 含有“golang”的URL优先，其次是深度更小的。
+	priority := func(req *module.Request) float64 {
+		p := -float64(req.Depth())
+		if strings.Contains(req.HTTPReq().URL.Path, "golang") {
+			p += 10
+		}
+		return p
+	}
+	cases := []struct {
+		strategy FrontierStrategy
+		expected []int
+	}{
+		{"", []int{0, 1, 2, 3, 4, 5}},
+		{FRONTIER_STRATEGY_BFS, []int{0, 1, 2, 3, 4, 5}},
+		{FRONTIER_STRATEGY_DFS, []int{5, 4, 3, 2, 1, 0}},
+		{FRONTIER_STRATEGY_BEST_FIRST, []int{2, 5, 0, 3, 4, 1}},
+		{FRONTIER_STRATEGY_HOST_ROUND_ROBIN, []int{0, 3, 4, 1, 5, 2}},
+	}
+	for _, c := range cases {
+		f, err := NewFrontier(c.strategy, priority, 2, 3)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a frontier: %s (strategy: %q)",
+				err, c.strategy)
+		}
+		reqs := make([]*module.Request, len(urls))
+		for i, url := range urls {
+			// 第二个请求更深一些。
+			depth := uint32(0)
+			if i == 1 {
+				depth = 1
+			}
+			reqs[i] = genFrontierReq(url, depth, t)
+			if err := f.Put(reqs[i]); err != nil {
+				t.Fatalf("An error occurs when putting request: %s (strategy: %q)",
+					err, c.strategy)
+			}
+		}
+		if f.Total() != uint64(len(urls)) {
+			t.Fatalf("Inconsistent frontier total: expected: %d, actual: %d (strategy: %q)",
+				len(urls), f.Total(), c.strategy)
+		}
+		if f.BufferNumber() != 3 {
+			t.Fatalf("Inconsistent frontier buffer number: expected: %d, actual: %d (strategy: %q)",
+				3, f.BufferNumber(), c.strategy)
+		}
+		for _, index := range c.expected {
+			datum, err := f.Get()
+			if err != nil {
+				t.Fatalf("An error occurs when getting request: %s (strategy: %q)",
+					err, c.strategy)
+			}
+			if datum.(*module.Request) != reqs[index] {
+				t.Fatalf("Inconsistent request order: expected: %s, actual: %s (strategy: %q)",
+					urls[index], datum.(*module.Request).HTTPReq().URL, c.strategy)
+			}
+		}
+		if f.Total() != 0 {
+			t.Fatalf("Inconsistent frontier total: expected: %d, actual: %d (strategy: %q)",
+				0, f.Total(), c.strategy)
+		}
+	}
+}
+
+func TestFrontierNew(t *testing.T) {
+	if _, err := NewFrontier("", nil, 0, 1); err == nil {
+		t.Fatal("No error when creating a frontier with zero buffer capacity!")
+	}
+	if _, err := NewFrontier("", nil, 1, 0); err == nil {
+		t.Fatal("No error when creating a frontier with zero max buffer number!")
+	}
+	if _, err := NewFrontier(FRONTIER_STRATEGY_BEST_FIRST, nil, 1, 1); err == nil {
+		t.Fatal("No error when creating a best-first frontier without priority function!")
+	}
+	if _, err := NewFrontier("random", nil, 1, 1); err == nil {
+		t.Fatal("No error when creating a frontier with unknown strategy!")
+	}
+	f, err := NewFrontier(FRONTIER_STRATEGY_DFS, nil, 1, 1)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a frontier: %s", err)
+	}
+	if f.Strategy() != FRONTIER_STRATEGY_DFS {
+		t.Fatalf("Inconsistent frontier strategy: expected: %s, actual: %s",
+			FRONTIER_STRATEGY_DFS, f.Strategy())
+	}
+	if err := f.Put("http://a.com/"); err == nil {
+		t.Fatal("No error when putting a non-request datum!")
+	}
+}
+
+func TestFrontierBlockAndClose(t *testing.T) {
+	f, err := NewFrontier("", nil, 1, 1)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a frontier: %s", err)
+	}
+	if err := f.Put(genFrontierReq("http://a.com/1", 0, t)); err != nil {
+		t.Fatalf("An error occurs when putting request: %s", err)
+	}
+	// 已满时放入会阻塞直到有请求被取出。
+	putDone := make(chan error, 1)
+	go func() {
+		putDone <- f.Put(genFrontierReq("http://a.com/2", 0, t))
+	}()
+	select {
+	case <-putDone:
+		t.Fatal("Putting into a full frontier has not been blocked!")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := f.Get(); err != nil {
+		t.Fatalf("An error occurs when getting request: %s", err)
+	}
+	if err := <-putDone; err != nil {
+		t.Fatalf("An error occurs when putting request: %s", err)
+	}
+	if _, err := f.Get(); err != nil {
+		t.Fatalf("An error occurs when getting request: %s", err)
+	}
+	// 为空时取出会阻塞直到爬取边界被关闭。
+	getDone := make(chan error, 1)
+	go func() {
+		_, err := f.Get()
+		getDone <- err
+	}()
+	select {
+	case <-getDone:
+		t.Fatal("Getting from an empty frontier has not been blocked!")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !f.Close() {
+		t.Fatal("Couldn't close the frontier!")
+	}
+	if f.Close() {
+		t.Fatal("The frontier has been closed repeatedly!")
+	}
+	if err := <-getDone; err != buffer.ErrClosedBufferPool {
+		t.Fatalf("Inconsistent error after close: expected: %v, actual: %v",
+			buffer.ErrClosedBufferPool, err)
+	}
+	if err := f.Put(genFrontierReq("http://a.com/3", 0, t)); err != buffer.ErrClosedBufferPool {
+		t.Fatalf("Inconsistent error after close: expected: %v, actual: %v",
+			buffer.ErrClosedBufferPool, err)
+	}
+}
+
+func TestFrontierMetaPriority(t *testing.T) {
+	f, err := NewFrontier(FRONTIER_STRATEGY_BEST_FIRST, MetaPriority, 2, 2)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a frontier: %s", err)
+	}
+	priorities := []int{1, 3, -1, 2}
+	reqs := make([]*module.Request, len(priorities))
+	for i, priority := range priorities {
+		reqs[i] = genFrontierReq("http://a.com/"+strings.Repeat("x", i), 0, t).
+			WithMeta(module.Meta{Priority: priority})
+		if err := f.Put(reqs[i]); err != nil {
+			t.Fatalf("An error occurs when putting request: %s", err)
+		}
+	}
+	for _, index := range []int{1, 3, 0, 2} {
+		datum, err := f.Get()
+		if err != nil {
+			t.Fatalf("An error occurs when getting request: %s", err)
+		}
+		if datum.(*module.Request) != reqs[index] {
+			t.Fatalf("Inconsistent request order: expected: %s, actual: %s",
+				reqs[index].HTTPReq().URL, datum.(*module.Request).HTTPReq().URL)
+		}
+	}
+}
+
+func TestSchedPutReqOrder(t *testing.T) {
+	cases := []struct {
+		strategy FrontierStrategy
+		reverse  bool
+	}{
+		{FRONTIER_STRATEGY_BFS, false},
+		{FRONTIER_STRATEGY_DFS, true},
+	}
+	number := 20
+	for _, c := range cases {
+		f, err := NewFrontier(c.strategy, nil, 10, 3)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a frontier: %s (strategy: %q)",
+				err, c.strategy)
+		}
+		sched := &myScheduler{reqBufferPool: f, tracker: newWorkTracker()}
+		// 同一个响应中的各个链接。
+		reqs := make([]*module.Request, number)
+		for i := range reqs {
+			reqs[i] = genFrontierReq(fmt.Sprintf("http://a.com/%d", i), 1, t)
+			sched.putReq(reqs[i])
+		}
+		for i := 0; i < number; i++ {
+			index := i
+			if c.reverse {
+				index = number - 1 - i
+			}
+			datum, err := f.Get()
+			if err != nil {
+				t.Fatalf("An error occurs when getting request: %s (strategy: %q)",
+					err, c.strategy)
+			}
+			if datum.(*module.Request) != reqs[index] {
+				t.Fatalf("Inconsistent request order: expected: %s, actual: %s (strategy: %q)",
+					reqs[index].HTTPReq().URL, datum.(*module.Request).HTTPReq().URL, c.strategy)
+			}
+		}
+		f.Close()
+	}
+	// 爬取边界已满时，后发送的请求仍会排在先发送的请求之后。
+	f, _ := NewFrontier(FRONTIER_STRATEGY_BFS, nil, 1, 2)
+	defer f.Close()
+	sched := &myScheduler{reqBufferPool: f, tracker: newWorkTracker()}
+	for i := 0; i < number; i++ {
+		sched.putReq(genFrontierReq(fmt.Sprintf("http://a.com/%d", i), 1, t))
+	}
+	for i := 0; i < number; i++ {
+		datum, err := f.Get()
+		if err != nil {
+			t.Fatalf("An error occurs when getting request: %s", err)
+		}
+		expected := fmt.Sprintf("http://a.com/%d", i)
+		if url := datum.(*module.Request).HTTPReq().URL.String(); url != expected {
+			t.Fatalf("Inconsistent request order: expected: %s, actual: %s", expected, url)
+		}
+	}
+	if n := sched.tracker.number(workKindRequest); n != uint64(number) {
+		t.Fatalf("Inconsistent request number: expected: %d, actual: %d", number, n)
+	}
+}
@@ -0,0 +1,209 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// moduleDrainInterval 代表等待组件处理完毕时的检查间隔。
+const moduleDrainInterval = 10 * time.Millisecond
+
+// moduleUsage 用于记录各组件正在被处理流程使用的次数。
+// 处理流程从注册器获取组件到开始调用组件之间，组件的处理数量还没有增加，
+// 所以只凭组件的处理数量无法判断它是否已空闲。
+type moduleUsage struct {
+	// selectLock 用于保证获取组件与注销组件互斥。
+	selectLock sync.RWMutex
+	lock       sync.Mutex
+	counts     map[module.MID]*int64
+	// draining 代表已注销但尚未处理完毕的组件。
+	draining map[module.MID]module.Module
+}
+
+// newModuleUsage 用于创建一个组件使用情况的记录器。
+func newModuleUsage() *moduleUsage {
+	return &moduleUsage{
+		counts:   map[module.MID]*int64{},
+		draining: map[module.MID]module.Module{},
+	}
+}
+
+// counter 用于获取给定组件的计数器。
+func (mu *moduleUsage) counter(mid module.MID) *int64 {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	count, ok := mu.counts[mid]
+	if !ok {
+		count = new(int64)
+		mu.counts[mid] = count
+	}
+	return count
+}
+
+// number 用于获取给定组件正在被使用的次数。
+func (mu *moduleUsage) number(mid module.MID) int64 {
+	return atomic.LoadInt64(mu.counter(mid))
+}
+
+// setDraining 用于记录或移除一个正在处理剩余数据的组件。
+func (mu *moduleUsage) setDraining(m module.Module, draining bool) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	if draining {
+		mu.draining[m.ID()] = m
+	} else {
+		delete(mu.draining, m.ID())
+	}
+}
+
+// drainingModules 用于获取正在处理剩余数据的组件的ID，已排序。
+func (mu *moduleUsage) drainingModules() []string {
+	if mu == nil {
+		return nil
+	}
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	mids := make([]string, 0, len(mu.draining))
+	for mid := range mu.draining {
+		mids = append(mids, string(mid))
+	}
+	sort.Strings(mids)
+	return mids
+}
+
+// acquireModule 会基于负载均衡策略获取一个给定类型的组件，并记录它正在被使用。
+// 使用完毕后需要调用releaseModule。
+func (sched *myScheduler) acquireModule(mType module.Type) (module.Module, error) {
+	sched.usage.selectLock.RLock()
+	defer sched.usage.selectLock.RUnlock()
+	m, err := sched.registrar.Get(mType)
+	if err != nil || m == nil {
+		return m, err
+	}
+	atomic.AddInt64(sched.usage.counter(m.ID()), 1)
+	return m, nil
+}
+
+// releaseModule 会记录给定的组件已使用完毕。
+func (sched *myScheduler) releaseModule(m module.Module) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(sched.usage.counter(m.ID()), -1)
+}
+
+// checkStatusForModules 用于检查调度器的状态是否允许增减组件。
+func (sched *myScheduler) checkStatusForModules() error {
+	switch status := sched.Status(); status {
+	case SCHED_STATUS_INITIALIZED, SCHED_STATUS_STARTED,
+		SCHED_STATUS_PAUSED, SCHED_STATUS_STOPPED:
+		return nil
+	default:
+		return genError(fmt.Sprintf("couldn't change modules when the scheduler is %s",
+			GetStatusDescription(status)))
+	}
+}
+
+func (sched *myScheduler) AddModule(m module.Module) error {
+	if m == nil {
+		return genParameterError("nil module")
+	}
+	if err := sched.checkStatusForModules(); err != nil {
+		return err
+	}
+	sched.usage.lock.Lock()
+	_, draining := sched.usage.draining[m.ID()]
+	sched.usage.lock.Unlock()
+	if draining {
+		return genError(fmt.Sprintf("module %q is still draining", m.ID()))
+	}
+	weight := module.DefaultWeight
+	if w, ok := sched.moduleWeights[m.ID()]; ok {
+		weight = w
+	}
+	ok, err := sched.registrar.RegisterWithWeight(m, weight)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	if !ok {
+		return genError(fmt.Sprintf("Couldn't register module instance with MID %q!", m.ID()))
+	}
+	log.Printf("已加入组件：%s", m.ID())
+	sched.growWorkers()
+	return nil
+}
+
+func (sched *myScheduler) RemoveModule(ctx context.Context, mid module.MID) error {
+	if err := sched.checkStatusForModules(); err != nil {
+		return err
+	}
+	ok, mType := module.GetType(mid)
+	if !ok {
+		return genParameterError(fmt.Sprintf("invalid MID %q", mid))
+	}
+	sched.usage.selectLock.Lock()
+	modules, _ := sched.registrar.GetAllByType(mType)
+	m, ok := modules[mid]
+	if !ok {
+		sched.usage.selectLock.Unlock()
+		return genError(fmt.Sprintf("module %q is not registered", mid))
+	}
+	// 否则相应处理流程中的数据将无法被处理。
+	if len(modules) == 1 {
+		sched.usage.selectLock.Unlock()
+		return genError(fmt.Sprintf("couldn't remove the last %s %q", mType, mid))
+	}
+	if _, err := sched.registrar.UnRegister(mid); err != nil {
+		sched.usage.selectLock.Unlock()
+		return genErrorByError(err)
+	}
+	sched.usage.setDraining(m, true)
+	sched.usage.selectLock.Unlock()
+	log.Printf("已移除组件，等待它处理完毕：%s", mid)
+	if err := sched.drainModule(ctx, m); err != nil {
+		return err
+	}
+	sched.usage.setDraining(m, false)
+	log.Printf("组件已处理完毕：%s", mid)
+	return nil
+}
+
+// drainModule 会等待给定的组件处理完毕，直到给定的上下文结束。
+// 上下文结束时组件仍会在后台继续处理剩余的数据。
+func (sched *myScheduler) drainModule(ctx context.Context, m module.Module) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	idle := func() bool {
+		return sched.usage.number(m.ID()) == 0 && m.HandlingNumber() == 0
+	}
+	if idle() {
+		return nil
+	}
+	ticker := time.NewTicker(moduleDrainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			go func() {
+				for !idle() {
+					time.Sleep(moduleDrainInterval)
+				}
+				sched.usage.setDraining(m, false)
+				log.Printf("组件已处理完毕：%s", m.ID())
+			}()
+			return genError(fmt.Sprintf("module %q is still busy: %s", m.ID(), ctx.Err()))
+		case <-ticker.C:
+			if idle() {
+				return nil
+			}
+		}
+	}
+}
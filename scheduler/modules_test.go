@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+	"gopcpv2-web-spider/module/local/downloader"
+)
+
+func TestSchedModules(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				fmt.Fprint(w, "/slow\n")
+			case "/slow":
+				entered <- struct{}{}
+				<-release
+			}
+		}))
+	defer server.Close()
+	newDownloader := func(mid module.MID) module.Downloader {
+		d, err := downloader.New(mid, &http.Client{}, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a downloader: %s", err)
+		}
+		return d
+	}
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	firstMID := moduleArgs.Downloaders[0].ID()
+	sched := NewScheduler()
+	if err := sched.AddModule(newDownloader("D100")); err == nil {
+		t.Fatal("No error when adding module before initializing!")
+	}
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.RemoveModule(nil, firstMID); err == nil {
+		t.Fatal("No error when removing the last downloader!")
+	}
+	if err := sched.AddModule(nil); err == nil {
+		t.Fatal("No error when adding nil module!")
+	}
+	if err := sched.AddModule(newDownloader("D100")); err != nil {
+		t.Fatalf("An error occurs when adding module: %s", err)
+	}
+	if err := sched.AddModule(newDownloader("D100")); err == nil {
+		t.Fatal("No error when adding duplicate module!")
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	workers := sched.Summary().Struct().Workers.Download.Workers
+	if expected := 2 * autoDownloadWorkersPerModule; workers != expected {
+		t.Fatalf("Inconsistent download worker number: expected: %d, actual: %d",
+			expected, workers)
+	}
+	// 运行时加入组件会增加工作协程。
+	if err := sched.AddModule(newDownloader("D101")); err != nil {
+		t.Fatalf("An error occurs when adding module: %s", err)
+	}
+	workers = sched.Summary().Struct().Workers.Download.Workers
+	if expected := 3 * autoDownloadWorkersPerModule; workers != expected {
+		t.Fatalf("Inconsistent download worker number: expected: %d, actual: %d",
+			expected, workers)
+	}
+	select {
+	case <-entered:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout when waiting for the slow download!")
+	}
+	// 找到正在下载的下载器并移除它。
+	var busyMID module.MID
+	deadline := time.Now().Add(5 * time.Second)
+	for busyMID == "" {
+		for _, ds := range sched.Summary().Struct().Downloaders {
+			if ds.Handling > 0 {
+				busyMID = module.MID(ds.ID)
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Couldn't find the busy downloader!")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sched.RemoveModule(ctx, busyMID); err == nil {
+		t.Fatal("No error when removing busy module before it is drained!")
+	}
+	summary := sched.Summary().Struct()
+	if len(summary.DrainingModules) != 1 || summary.DrainingModules[0] != string(busyMID) {
+		t.Fatalf("Inconsistent draining modules: %v", summary.DrainingModules)
+	}
+	if len(summary.Downloaders) != 2 {
+		t.Fatalf("Inconsistent downloader number: expected: %d, actual: %d",
+			2, len(summary.Downloaders))
+	}
+	if err := sched.AddModule(newDownloader(busyMID)); err == nil {
+		t.Fatal("No error when adding draining module!")
+	}
+	if err := sched.RemoveModule(nil, busyMID); err == nil {
+		t.Fatal("No error when removing unregistered module!")
+	}
+	if err := sched.RemoveModule(nil, "X1"); err == nil {
+		t.Fatal("No error when removing module with invalid MID!")
+	}
+	close(release)
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer waitCancel()
+	if err := sched.Wait(waitCtx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for len(sched.Summary().Struct().DrainingModules) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The removed module isn't drained!")
+		}
+		time.Sleep(moduleDrainInterval)
+	}
+	// 空闲的组件可以被立即移除。
+	var idleMID module.MID
+	for _, ds := range sched.Summary().Struct().Downloaders {
+		idleMID = module.MID(ds.ID)
+	}
+	if err := sched.RemoveModule(context.Background(), idleMID); err != nil {
+		t.Fatalf("An error occurs when removing idle module: %s", err)
+	}
+	if number := len(sched.Summary().Struct().Downloaders); number != 1 {
+		t.Fatalf("Inconsistent downloader number: expected: %d, actual: %d", 1, number)
+	}
+}
+
+func TestSchedBalancers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				for i := 0; i < 7; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(2, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	heavyMID := moduleArgs.Downloaders[0].ID()
+	moduleArgs.Balancers = BalancerArgs{Downloader: module.BALANCE_ROUND_ROBIN}
+	moduleArgs.Weights = map[module.MID]uint32{heavyMID: 3}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	summary := sched.Summary().Struct()
+	expectedBalancers := BalancerArgs{
+		Downloader: module.BALANCE_ROUND_ROBIN,
+		Analyzer:   module.BALANCE_LEAST_SCORE,
+		Pipeline:   module.BALANCE_LEAST_SCORE,
+	}
+	if summary.Balancers != expectedBalancers {
+		t.Fatalf("Inconsistent balancers: expected: %#v, actual: %#v",
+			expectedBalancers, summary.Balancers)
+	}
+	// 8次下载按3:1的权重轮流分配。
+	for _, ds := range summary.Downloaders {
+		expected := uint64(2)
+		if ds.ID == heavyMID {
+			expected = 6
+		}
+		if ds.Called != expected {
+			t.Fatalf("Inconsistent called count of downloader %s: expected: %d, actual: %d",
+				ds.ID, expected, ds.Called)
+		}
+	}
+}
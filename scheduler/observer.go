@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// Observer 代表调度器生命周期事件的观察者的接口类型。
+// 各方法会在观察者自己的goroutine中被依次调用，不会阻塞调度器的处理流程。
+// 观察者处理得太慢时，超出队列容量的事件会被丢弃。
+type Observer interface {
+	// OnRequestEnqueued 会在请求通过检查并被放入请求缓冲池后被调用。
+	OnRequestEnqueued(req *module.Request)
+	// OnRequestDropped 会在请求被丢弃后被调用。
+	OnRequestDropped(dropped DroppedRequest)
+	// OnDownloadStarted 会在下载器开始下载请求时被调用。
+	OnDownloadStarted(req *module.Request, mid module.MID)
+	// OnDownloadFinished 会在下载完成（无论成功与否）后被调用。
+	OnDownloadFinished(event DownloadEvent)
+	// OnResponseAnalyzed 会在响应被解析后被调用。
+	OnResponseAnalyzed(event AnalyzeEvent)
+	// OnItemProduced 会在解析器产生的条目被放入条目缓冲池后被调用。
+	OnItemProduced(item module.Item)
+	// OnItemProcessed 会在条目被条目处理管道处理后被调用。
+	OnItemProcessed(item module.Item, mid module.MID, errs []error)
+	// OnError 会在调度器中发生错误时被调用。参数mid在错误与组件无关时为空。
+	OnError(err error, mid module.MID)
+}
+
+// NopObserver 代表什么都不做的观察者。
+// 只关心部分事件的观察者可以嵌入它，然后只实现需要的方法。
+type NopObserver struct{}
+
+func (NopObserver) OnRequestEnqueued(req *module.Request)                          {}
+func (NopObserver) OnRequestDropped(dropped DroppedRequest)                        {}
+func (NopObserver) OnDownloadStarted(req *module.Request, mid module.MID)          {}
+func (NopObserver) OnDownloadFinished(event DownloadEvent)                         {}
+func (NopObserver) OnResponseAnalyzed(event AnalyzeEvent)                          {}
+func (NopObserver) OnItemProduced(item module.Item)                                {}
+func (NopObserver) OnItemProcessed(item module.Item, mid module.MID, errs []error) {}
+func (NopObserver) OnError(err error, mid module.MID)                              {}
+
+// DownloadEvent 代表下载完成的事件。
+type DownloadEvent struct {
+	// Request 代表被下载的请求。
+	Request *module.Request
+	// MID 代表下载器的ID。
+	MID module.MID
+	// Latency 代表下载所用的时间。
+	Latency time.Duration
+	// StatusCode 代表响应的状态码，没有响应时为0。
+	StatusCode int
+	// Err 代表下载时发生的错误。
+	Err error
+}
+
+// AnalyzeEvent 代表响应被解析的事件。
+type AnalyzeEvent struct {
+	// Response 代表被解析的响应。
+	Response *module.Response
+	// MID 代表解析器的ID。
+	MID module.MID
+	// Requests 代表解析出的请求的数量。
+	Requests int
+	// Items 代表解析出的条目的数量。
+	Items int
+	// Errors 代表解析时发生的错误的数量。
+	Errors int
+}
+
+// defaultObserverQueueCap 代表每个观察者的事件队列的默认容量。
+const defaultObserverQueueCap = 1024
+
+// ObserversSummaryStruct 代表观察者的摘要类型。
+type ObserversSummaryStruct struct {
+	// Observers 代表已注册的观察者的数量。
+	Observers int `json:"observers"`
+	// Delivered 代表已送达的事件的数量。
+	Delivered uint64 `json:"delivered"`
+	// Dropped 代表因队列已满而被丢弃的事件的数量。
+	Dropped uint64 `json:"dropped"`
+}
+
+// observerQueue 代表一个观察者及其事件队列。
+type observerQueue struct {
+	observer Observer
+	events   chan func(Observer)
+}
+
+// run 会依次把队列中的事件交给观察者，直到队列被关闭且已取空。
+func (q *observerQueue) run(delivered *uint64) {
+	for event := range q.events {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("观察者处理事件时发生恐慌：%v", p)
+				}
+			}()
+			event(q.observer)
+		}()
+		atomic.AddUint64(delivered, 1)
+	}
+}
+
+// observerHub 代表观察者的集合，它负责把事件异步地分发给各个观察者。
+type observerHub struct {
+	queueCap  int
+	lock      sync.RWMutex
+	queues    []*observerQueue
+	closed    bool
+	delivered uint64
+	dropped   uint64
+}
+
+// newObserverHub 用于创建一个观察者的集合。参数queueCap为0时使用默认容量。
+func newObserverHub(queueCap int, observers []Observer) *observerHub {
+	if queueCap <= 0 {
+		queueCap = defaultObserverQueueCap
+	}
+	hub := &observerHub{queueCap: queueCap}
+	for _, observer := range observers {
+		hub.register(observer)
+	}
+	return hub
+}
+
+// register 用于注册一个观察者。
+func (hub *observerHub) register(observer Observer) error {
+	if observer == nil {
+		return genParameterError("nil observer")
+	}
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if hub.closed {
+		return genError("closed observer hub")
+	}
+	q := &observerQueue{
+		observer: observer,
+		events:   make(chan func(Observer), hub.queueCap),
+	}
+	hub.queues = append(hub.queues, q)
+	go q.run(&hub.delivered)
+	return nil
+}
+
+// publish 会把事件放入各观察者的队列，队列已满时事件会被丢弃。
+func (hub *observerHub) publish(event func(Observer)) {
+	if hub == nil {
+		return
+	}
+	hub.lock.RLock()
+	defer hub.lock.RUnlock()
+	if hub.closed {
+		return
+	}
+	for _, q := range hub.queues {
+		select {
+		case q.events <- event:
+		default:
+			atomic.AddUint64(&hub.dropped, 1)
+		}
+	}
+}
+
+// close 会关闭各观察者的队列，之后发布的事件会被忽略。
+// 已放入队列的事件仍会被交给观察者。为了让观察者可以在回调中停止调度器，
+// 这里不会等待它们处理完毕。
+func (hub *observerHub) close() {
+	if hub == nil {
+		return
+	}
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if hub.closed {
+		return
+	}
+	hub.closed = true
+	for _, q := range hub.queues {
+		close(q.events)
+	}
+}
+
+// summary 用于获取观察者的摘要。
+func (hub *observerHub) summary() ObserversSummaryStruct {
+	if hub == nil {
+		return ObserversSummaryStruct{}
+	}
+	hub.lock.RLock()
+	number := len(hub.queues)
+	hub.lock.RUnlock()
+	return ObserversSummaryStruct{
+		Observers: number,
+		Delivered: atomic.LoadUint64(&hub.delivered),
+		Dropped:   atomic.LoadUint64(&hub.dropped),
+	}
+}
+
+func (hub *observerHub) requestEnqueued(req *module.Request) {
+	hub.publish(func(o Observer) { o.OnRequestEnqueued(req) })
+}
+
+func (hub *observerHub) requestDropped(dropped DroppedRequest) {
+	hub.publish(func(o Observer) { o.OnRequestDropped(dropped) })
+}
+
+func (hub *observerHub) downloadStarted(req *module.Request, mid module.MID) {
+	hub.publish(func(o Observer) { o.OnDownloadStarted(req, mid) })
+}
+
+func (hub *observerHub) downloadFinished(event DownloadEvent) {
+	hub.publish(func(o Observer) { o.OnDownloadFinished(event) })
+}
+
+func (hub *observerHub) responseAnalyzed(event AnalyzeEvent) {
+	hub.publish(func(o Observer) { o.OnResponseAnalyzed(event) })
+}
+
+func (hub *observerHub) itemProduced(item module.Item) {
+	hub.publish(func(o Observer) { o.OnItemProduced(item) })
+}
+
+func (hub *observerHub) itemProcessed(item module.Item, mid module.MID, errs []error) {
+	hub.publish(func(o Observer) { o.OnItemProcessed(item, mid, errs) })
+}
+
+func (hub *observerHub) error(err error, mid module.MID) {
+	hub.publish(func(o Observer) { o.OnError(err, mid) })
+}
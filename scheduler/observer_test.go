@@ -0,0 +1,286 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+// countingObserver 代表会统计各事件数量的观察者。
+type countingObserver struct {
+	NopObserver
+	lock      sync.Mutex
+	enqueued  []string
+	dropped   []DroppedRequest
+	started   int
+	finished  []DownloadEvent
+	analyzed  []AnalyzeEvent
+	produced  int
+	processed int
+	errors    int
+}
+
+func (o *countingObserver) OnRequestEnqueued(req *module.Request) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.enqueued = append(o.enqueued, req.HTTPReq().URL.String())
+}
+
+func (o *countingObserver) OnRequestDropped(dropped DroppedRequest) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.dropped = append(o.dropped, dropped)
+}
+
+func (o *countingObserver) OnDownloadStarted(req *module.Request, mid module.MID) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.started++
+}
+
+func (o *countingObserver) OnDownloadFinished(event DownloadEvent) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.finished = append(o.finished, event)
+}
+
+func (o *countingObserver) OnResponseAnalyzed(event AnalyzeEvent) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.analyzed = append(o.analyzed, event)
+}
+
+func (o *countingObserver) OnItemProduced(item module.Item) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.produced++
+}
+
+func (o *countingObserver) OnItemProcessed(item module.Item, mid module.MID, errs []error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.processed++
+}
+
+func (o *countingObserver) OnError(err error, mid module.MID) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.errors++
+}
+
+// waitDelivered 会等待观察者的集合送达给定数量的事件。
+func waitDelivered(t *testing.T, summary func() ObserversSummaryStruct, expected uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for summary().Delivered < expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout when waiting for observer events: expected: %d, actual: %#v",
+				expected, summary())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestObserverHub(t *testing.T) {
+	var order []string
+	var lock sync.Mutex
+	record := &recordingObserver{record: func(event string) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, event)
+	}}
+	// 发生恐慌的观察者不影响其他事件的送达。
+	hub := newObserverHub(0, []Observer{record, panickingObserver{}})
+	if hub.queueCap != defaultObserverQueueCap {
+		t.Fatalf("Inconsistent queue capacity: expected: %d, actual: %d",
+			defaultObserverQueueCap, hub.queueCap)
+	}
+	if err := hub.register(nil); err == nil {
+		t.Fatal("No error when registering nil observer!")
+	}
+	hub.requestEnqueued(nil)
+	hub.error(fmt.Errorf("boom"), "")
+	hub.itemProduced(nil)
+	waitDelivered(t, hub.summary, 6)
+	lock.Lock()
+	expectedOrder := []string{"enqueued", "error", "produced"}
+	if fmt.Sprint(order) != fmt.Sprint(expectedOrder) {
+		lock.Unlock()
+		t.Fatalf("Inconsistent event order: expected: %v, actual: %v",
+			expectedOrder, order)
+	}
+	lock.Unlock()
+	hub.close()
+	hub.close()
+	if err := hub.register(record); err == nil {
+		t.Fatal("No error when registering observer after closing!")
+	}
+	// 关闭后发布的事件会被忽略。
+	hub.requestEnqueued(nil)
+	if summary := hub.summary(); summary.Delivered != 6 || summary.Dropped != 0 {
+		t.Fatalf("Inconsistent observer summary: %#v", summary)
+	}
+	var nilHub *observerHub
+	nilHub.requestEnqueued(nil)
+	nilHub.close()
+	if summary := nilHub.summary(); summary != (ObserversSummaryStruct{}) {
+		t.Fatalf("Inconsistent summary of nil hub: %#v", summary)
+	}
+}
+
+// recordingObserver 代表会记录事件名称的观察者。
+type recordingObserver struct {
+	NopObserver
+	record func(event string)
+}
+
+func (o *recordingObserver) OnRequestEnqueued(req *module.Request) { o.record("enqueued") }
+func (o *recordingObserver) OnError(err error, mid module.MID)     { o.record("error") }
+func (o *recordingObserver) OnItemProduced(item module.Item)       { o.record("produced") }
+
+// blockingObserver 代表会阻塞在第一个事件上的观察者。
+type blockingObserver struct {
+	NopObserver
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (o *blockingObserver) OnRequestEnqueued(req *module.Request) {
+	select {
+	case o.entered <- struct{}{}:
+	default:
+	}
+	<-o.release
+}
+
+// panickingObserver 代表处理事件时会发生恐慌的观察者。
+type panickingObserver struct {
+	NopObserver
+}
+
+func (panickingObserver) OnRequestEnqueued(req *module.Request) {
+	panic("observer panic")
+}
+
+func TestObserverHubSlow(t *testing.T) {
+	slow := &blockingObserver{
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	hub := newObserverHub(2, []Observer{slow})
+	hub.requestEnqueued(nil)
+	<-slow.entered
+	// 慢观察者的队列容量为2，其余的事件会被丢弃，但发布不会阻塞。
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			hub.requestEnqueued(nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publishing is blocked by a slow observer!")
+	}
+	if dropped := hub.summary().Dropped; dropped != 3 {
+		t.Fatalf("Inconsistent dropped event number: expected: %d, actual: %d",
+			3, dropped)
+	}
+	// 关闭后，已放入队列的事件仍会被送达。
+	hub.close()
+	close(slow.release)
+	waitDelivered(t, hub.summary, 3)
+	if summary := hub.summary(); summary.Observers != 1 || summary.Delivered != 3 {
+		t.Fatalf("Inconsistent observer summary: %#v", summary)
+	}
+}
+
+func TestSchedObservers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				fmt.Fprint(w, "/a\n/a\nftp://127.0.0.1/f\n")
+			case "/a":
+				fmt.Fprint(w, "\n")
+			}
+		}))
+	defer server.Close()
+	first := &countingObserver{}
+	second := &countingObserver{}
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	moduleArgs.Observers = []Observer{first}
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.RegisterObserver(second); err == nil {
+		t.Fatal("No error when registering observer before initializing!")
+	}
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.RegisterObserver(second); err != nil {
+		t.Fatalf("An error occurs when registering observer: %s", err)
+	}
+	if err := sched.RegisterObserver(nil); err == nil {
+		t.Fatal("No error when registering nil observer!")
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	summary := sched.Summary().Struct()
+	if summary.Observers.Observers != 2 {
+		t.Fatalf("Inconsistent observer number: expected: %d, actual: %d",
+			2, summary.Observers.Observers)
+	}
+	// 每个观察者都会收到：2个入队、2个丢弃、2个下载开始、2个下载完成和2个解析完成的事件。
+	waitDelivered(t, func() ObserversSummaryStruct {
+		return sched.Summary().Struct().Observers
+	}, 20)
+	sched.Stop()
+	for _, o := range []*countingObserver{first, second} {
+		o.lock.Lock()
+		if len(o.enqueued) != 2 || len(o.dropped) != 2 || o.started != 2 ||
+			len(o.finished) != 2 || len(o.analyzed) != 2 {
+			o.lock.Unlock()
+			t.Fatalf("Inconsistent observed events: enqueued: %v, dropped: %v, "+
+				"started: %d, finished: %d, analyzed: %d",
+				o.enqueued, o.dropped, o.started, len(o.finished), len(o.analyzed))
+		}
+		for _, event := range o.finished {
+			if event.StatusCode != http.StatusOK || event.Err != nil || event.Latency <= 0 ||
+				event.MID == "" {
+				o.lock.Unlock()
+				t.Fatalf("Inconsistent download event: %#v", event)
+			}
+		}
+		requests := 0
+		for _, event := range o.analyzed {
+			requests += event.Requests
+		}
+		if requests != 3 {
+			o.lock.Unlock()
+			t.Fatalf("Inconsistent analyzed request number: expected: %d, actual: %d",
+				3, requests)
+		}
+		o.lock.Unlock()
+	}
+}
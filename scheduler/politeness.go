@@ -0,0 +1,422 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// defaultHostPolicyKey 代表默认主机策略的主机名。
+// 默认策略会分别作用于每个没有专属策略的主域名。
+const defaultHostPolicyKey = "*"
+
+// HostPolicy 代表针对某个主机的礼貌性策略。
+type HostPolicy struct {
+	// Host 代表策略针对的主机。
+	// 它可以是确切的主机名，也可以是主域名，确切的主机名优先匹配。
+	// 值为"*"时代表默认策略。
+	Host string `json:"host"`
+	// MaxConcurrency 代表针对该主机的最大并发请求数量，为0时不限制。
+	MaxConcurrency uint32 `json:"max_concurrency"`
+	// MinDelay 代表针对该主机的两次请求之间的最小间隔时间。
+	MinDelay time.Duration `json:"min_delay"`
+	// Rate 代表令牌桶每秒产生的令牌数量，为0时不限制。
+	Rate float64 `json:"rate"`
+	// Burst 代表令牌桶的容量，为0时视为1。
+	Burst uint32 `json:"burst"`
+}
+
+// Check 用于检查主机策略的有效性。
+func (policy *HostPolicy) Check() error {
+	if policy.Host == "" {
+		return genError("empty host in host policy")
+	}
+	if policy.MinDelay < 0 {
+		return genError(fmt.Sprintf("negative min delay for host %q", policy.Host))
+	}
+	if policy.Rate < 0 {
+		return genError(fmt.Sprintf("negative rate for host %q", policy.Host))
+	}
+	return nil
+}
+
+// HostQueueSummaryStruct 代表某个主机的请求队列的摘要类型。
+type HostQueueSummaryStruct struct {
+	// Host 代表主机策略的键，即确切的主机名或主域名。
+	Host string `json:"host"`
+	// Active 代表正在下载的请求的数量。
+	Active uint32 `json:"active"`
+	// Queued 代表被暂时扣留的请求的数量。
+	Queued uint64 `json:"queued"`
+	// Concurrency 代表自适应限流当前的并发数量上限，未启用自适应限流时为0。
+	Concurrency uint32 `json:"concurrency,omitempty"`
+	// Delay 代表自适应限流当前的请求间隔时间。
+	Delay time.Duration `json:"delay,omitempty"`
+	// Overloads 代表自适应限流观察到的过载的次数。
+	Overloads uint64 `json:"overloads,omitempty"`
+}
+
+// hostState 代表某个主机的礼貌性状态。
+type hostState struct {
+	key        string
+	policy     HostPolicy
+	active     uint32
+	lastStart  time.Time
+	tokens     float64
+	lastRefill time.Time
+	queue      []*module.Request
+	timer      *time.Timer
+	// adaptive 代表自适应限制，未启用自适应限流时为nil。
+	adaptive *adaptiveLimit
+}
+
+// burst 用于获取令牌桶的容量。
+func (hs *hostState) burst() float64 {
+	if hs.policy.Burst == 0 {
+		return 1
+	}
+	return float64(hs.policy.Burst)
+}
+
+// maxConcurrency 用于获取实际的最大并发请求数量，为0时不限制。
+func (hs *hostState) maxConcurrency() uint32 {
+	max := hs.policy.MaxConcurrency
+	if hs.adaptive != nil && (max == 0 || hs.adaptive.concurrency < max) {
+		max = hs.adaptive.concurrency
+	}
+	return max
+}
+
+// minDelay 用于获取实际的两次请求之间的最小间隔时间。
+func (hs *hostState) minDelay() time.Duration {
+	delay := hs.policy.MinDelay
+	if hs.adaptive != nil && hs.adaptive.delay > delay {
+		delay = hs.adaptive.delay
+	}
+	return delay
+}
+
+// wait 用于计算距离可以开始下一个请求还需等待的时间。
+// 结果值小于0时代表需要等待正在下载的请求完成。
+func (hs *hostState) wait(now time.Time) time.Duration {
+	if max := hs.maxConcurrency(); max > 0 && hs.active >= max {
+		return -1
+	}
+	var wait time.Duration
+	if minDelay := hs.minDelay(); minDelay > 0 && !hs.lastStart.IsZero() {
+		wait = hs.lastStart.Add(minDelay).Sub(now)
+	}
+	if hs.policy.Rate > 0 {
+		elapsed := now.Sub(hs.lastRefill).Seconds()
+		hs.tokens += elapsed * hs.policy.Rate
+		if burst := hs.burst(); hs.tokens > burst {
+			hs.tokens = burst
+		}
+		hs.lastRefill = now
+		if hs.tokens < 1 {
+			tokenWait := time.Duration((1 - hs.tokens) / hs.policy.Rate * float64(time.Second))
+			if tokenWait > wait {
+				wait = tokenWait
+			}
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// take 会占用一个开始请求的机会。
+func (hs *hostState) take(now time.Time) {
+	hs.active++
+	hs.lastStart = now
+	if hs.policy.Rate > 0 {
+		hs.tokens--
+	}
+}
+
+// politeness 代表按主机控制请求节奏的礼貌性控制器。
+// 超出限制的请求会被暂时扣留，待条件满足时再通过requeue放回。
+type politeness struct {
+	lock          sync.Mutex
+	policies      map[string]HostPolicy
+	defaultPolicy *HostPolicy
+	// crawlDelays 代表各主机在robots.txt中声明的抓取间隔。
+	crawlDelays map[string]time.Duration
+	hosts       map[string]*hostState
+	// granted 代表已被放行但尚未开始下载的请求。
+	granted map[*module.Request]string
+	// requeue 用于把被放行的请求放回请求缓冲池。
+	requeue func(req *module.Request)
+	// adaptive 代表自适应限流的参数，未启用时为nil。
+	adaptive *AdaptiveArgs
+}
+
+// newPoliteness 用于创建一个礼貌性控制器。
+func newPoliteness(policies []HostPolicy, requeue func(req *module.Request)) *politeness {
+	p := &politeness{
+		policies:    map[string]HostPolicy{},
+		crawlDelays: map[string]time.Duration{},
+		hosts:       map[string]*hostState{},
+		granted:     map[*module.Request]string{},
+		requeue:     requeue,
+	}
+	for _, policy := range policies {
+		if policy.Host == defaultHostPolicyKey {
+			defaultPolicy := policy
+			p.defaultPolicy = &defaultPolicy
+			continue
+		}
+		p.policies[policy.Host] = policy
+	}
+	return p
+}
+
+// setCrawlDelay 用于设置某个主机在robots.txt中声明的抓取间隔。
+// 它只会在适用于该主机的策略的最小间隔时间更短时生效。
+// 该主机的状态可能已由获取robots.txt的请求建立，所以它也会更新已有的状态。
+func (p *politeness) setCrawlDelay(host string, delay time.Duration) {
+	if p == nil || delay <= 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.crawlDelays[host] = delay
+	key, _ := p.lookup(host)
+	if hs := p.hosts[key]; hs != nil && hs.policy.MinDelay < delay {
+		hs.policy.MinDelay = delay
+	}
+}
+
+// enableAdaptive 用于启用自适应限流。
+// 启用之后，没有适用的策略的主机也会按主机名分别受到自适应限制。
+func (p *politeness) enableAdaptive(args AdaptiveArgs) {
+	if p == nil || !args.Enabled {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.adaptive = &args
+}
+
+// lookup 用于获取适用于给定主机的策略及其状态的键。
+// 结果值policy为nil时代表该主机不受限制。调用方需持有锁。
+func (p *politeness) lookup(host string) (string, *HostPolicy) {
+	key, policy := p.match(host)
+	if policy == nil && p.adaptive != nil {
+		return host, &HostPolicy{Host: host}
+	}
+	return key, policy
+}
+
+// match 用于获取适用于给定主机的策略及其状态的键。调用方需持有锁。
+func (p *politeness) match(host string) (string, *HostPolicy) {
+	key, policy := p.matchPolicy(host)
+	delay, ok := p.crawlDelays[host]
+	if !ok {
+		return key, policy
+	}
+	if policy == nil {
+		return host, &HostPolicy{Host: host, MinDelay: delay}
+	}
+	if policy.MinDelay < delay {
+		adjusted := *policy
+		adjusted.MinDelay = delay
+		policy = &adjusted
+	}
+	return key, policy
+}
+
+// matchPolicy 用于获取适用于给定主机的已设定的策略及其状态的键。
+func (p *politeness) matchPolicy(host string) (string, *HostPolicy) {
+	if policy, ok := p.policies[host]; ok {
+		return host, &policy
+	}
+	pd, err := getPrimaryDomain(host)
+	if err != nil {
+		pd = host
+	}
+	if policy, ok := p.policies[pd]; ok {
+		return pd, &policy
+	}
+	if p.defaultPolicy != nil {
+		return pd, p.defaultPolicy
+	}
+	return "", nil
+}
+
+// acquire 会尝试为给定的请求获取开始下载的许可。
+// 结果值ok为false时代表请求已被扣留，它稍后会被重新放回请求缓冲池。
+// 结果值key需要在下载完成后传给release方法。
+func (p *politeness) acquire(req *module.Request) (key string, ok bool) {
+	if p == nil || req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return "", true
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if key, ok := p.granted[req]; ok {
+		delete(p.granted, req)
+		return key, true
+	}
+	key, policy := p.lookup(req.HTTPReq().URL.Hostname())
+	if policy == nil {
+		return "", true
+	}
+	hs := p.hosts[key]
+	if hs == nil {
+		now := time.Now()
+		hs = &hostState{
+			key:        key,
+			policy:     *policy,
+			lastRefill: now,
+		}
+		hs.tokens = hs.burst()
+		if p.adaptive != nil {
+			hs.adaptive = newAdaptiveLimit(p.adaptive)
+		}
+		p.hosts[key] = hs
+	}
+	// 已有请求被扣留时要排在它们之后。
+	if len(hs.queue) == 0 {
+		now := time.Now()
+		if hs.wait(now) == 0 {
+			hs.take(now)
+			return key, true
+		}
+	}
+	hs.queue = append(hs.queue, req)
+	p.dispatch(hs)
+	return key, false
+}
+
+// release 会在下载完成后归还许可。
+func (p *politeness) release(key string) {
+	if p == nil || key == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	hs := p.hosts[key]
+	if hs == nil {
+		return
+	}
+	if hs.active > 0 {
+		hs.active--
+	}
+	p.dispatch(hs)
+}
+
+// observe 会把一次下载的结果反馈给自适应限流，
+// 并在限制被调整之后尽可能地放行被扣留的请求。参数started代表该次下载开始的时间。
+func (p *politeness) observe(req *module.Request, started time.Time, resp *module.Response, err error) {
+	if p == nil || req == nil || !req.Valid() {
+		return
+	}
+	signal := adaptiveSignalOf(resp, err)
+	if signal == signalNone {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.adaptive == nil {
+		return
+	}
+	key, _ := p.lookup(req.HTTPReq().URL.Hostname())
+	hs := p.hosts[key]
+	if hs == nil || hs.adaptive == nil {
+		return
+	}
+	if hs.adaptive.observe(signal, started, time.Now()) {
+		p.dispatch(hs)
+	}
+}
+
+// abort 会在被放行的请求未能放回请求缓冲池时撤销它的许可。
+func (p *politeness) abort(req *module.Request) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	key, ok := p.granted[req]
+	delete(p.granted, req)
+	p.lock.Unlock()
+	if ok {
+		p.release(key)
+	}
+}
+
+// dispatch 会尽可能地放行被扣留的请求，
+// 并在需要等待一段时间时安排下一次放行。调用方需持有锁。
+func (p *politeness) dispatch(hs *hostState) {
+	for len(hs.queue) > 0 {
+		now := time.Now()
+		wait := hs.wait(now)
+		if wait < 0 {
+			return
+		}
+		if wait > 0 {
+			if hs.timer == nil {
+				hs.timer = time.AfterFunc(wait, func() {
+					p.lock.Lock()
+					defer p.lock.Unlock()
+					hs.timer = nil
+					p.dispatch(hs)
+				})
+			}
+			return
+		}
+		req := hs.queue[0]
+		hs.queue[0] = nil
+		hs.queue = hs.queue[1:]
+		hs.take(now)
+		p.granted[req] = hs.key
+		p.requeue(req)
+	}
+}
+
+// stop 会停止所有的计时器并丢弃被扣留的请求。
+func (p *politeness) stop() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, hs := range p.hosts {
+		if hs.timer != nil {
+			hs.timer.Stop()
+			hs.timer = nil
+		}
+	}
+	p.hosts = map[string]*hostState{}
+	p.granted = map[*module.Request]string{}
+}
+
+// summary 用于获取各主机的请求队列的摘要，按主机排序。
+func (p *politeness) summary() []HostQueueSummaryStruct {
+	summaries := []HostQueueSummaryStruct{}
+	if p == nil {
+		return summaries
+	}
+	p.lock.Lock()
+	for _, hs := range p.hosts {
+		summary := HostQueueSummaryStruct{
+			Host:   hs.key,
+			Active: hs.active,
+			Queued: uint64(len(hs.queue)),
+		}
+		if hs.adaptive != nil {
+			summary.Concurrency = hs.maxConcurrency()
+			summary.Delay = hs.minDelay()
+			summary.Overloads = hs.adaptive.overloads
+		}
+		summaries = append(summaries, summary)
+	}
+	p.lock.Unlock()
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Host < summaries[j].Host
+	})
+	return summaries
+}
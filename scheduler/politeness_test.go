@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+// genPoliteReq 用于生成针对给定URL的请求。
+func genPoliteReq(url string, t *testing.T) *module.Request {
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)",
+			err, url)
+	}
+	return module.NewRequest(httpReq, 0)
+}
+
+func TestPolitenessConcurrency(t *testing.T) {
+	requeued := make(chan *module.Request, 10)
+	p := newPoliteness([]HostPolicy{
+		{Host: "bing.com", MaxConcurrency: 1},
+	}, func(req *module.Request) { requeued <- req })
+	req1 := genPoliteReq("http://cn.bing.com/a", t)
+	req2 := genPoliteReq("http://www.bing.com/b", t)
+	other := genPoliteReq("http://www.sogou.com/", t)
+	key, ok := p.acquire(req1)
+	if !ok || key != "bing.com" {
+		t.Fatalf("Inconsistent acquire result: key: %q, ok: %v", key, ok)
+	}
+	if _, ok := p.acquire(req2); ok {
+		t.Fatal("The request over the host concurrency limit has not been held!")
+	}
+	// 其他主机不受影响。
+	if key, ok := p.acquire(other); !ok || key != "" {
+		t.Fatalf("Inconsistent acquire result for other host: key: %q, ok: %v",
+			key, ok)
+	}
+	expectedSummary := []HostQueueSummaryStruct{
+		{Host: "bing.com", Active: 1, Queued: 1},
+	}
+	summary := p.summary()
+	if len(summary) != 1 || summary[0] != expectedSummary[0] {
+		t.Fatalf("Inconsistent host queue summary: expected: %#v, actual: %#v",
+			expectedSummary, summary)
+	}
+	p.release(key)
+	select {
+	case req := <-requeued:
+		if req != req2 {
+			t.Fatalf("Inconsistent requeued request: expected: %v, actual: %v",
+				req2.HTTPReq().URL, req.HTTPReq().URL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The held request has not been requeued!")
+	}
+	// 被放行的请求可以直接开始下载。
+	if key, ok := p.acquire(req2); !ok || key != "bing.com" {
+		t.Fatalf("Inconsistent acquire result for granted request: key: %q, ok: %v",
+			key, ok)
+	}
+	p.stop()
+	if summary := p.summary(); len(summary) != 0 {
+		t.Fatalf("Inconsistent host queue summary after stop: %#v", summary)
+	}
+}
+
+func TestPolitenessDelay(t *testing.T) {
+	requeued := make(chan *module.Request, 10)
+	delay := 50 * time.Millisecond
+	p := newPoliteness([]HostPolicy{
+		{Host: "cn.bing.com", MinDelay: delay},
+		{Host: defaultHostPolicyKey, Rate: 10, Burst: 2},
+	}, func(req *module.Request) { requeued <- req })
+	defer p.stop()
+	start := time.Now()
+	key, ok := p.acquire(genPoliteReq("http://cn.bing.com/a", t))
+	if !ok || key != "cn.bing.com" {
+		t.Fatalf("Inconsistent acquire result: key: %q, ok: %v", key, ok)
+	}
+	p.release(key)
+	if _, ok := p.acquire(genPoliteReq("http://cn.bing.com/b", t)); ok {
+		t.Fatal("The request within the min delay has not been held!")
+	}
+	select {
+	case <-requeued:
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Fatalf("The held request has been requeued too early: %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The held request has not been requeued!")
+	}
+	// 默认策略按主域名分别生效，令牌桶允许突发两个请求。
+	for i := 0; i < 2; i++ {
+		if key, ok := p.acquire(genPoliteReq("http://www.sogou.com/", t)); !ok || key != "sogou.com" {
+			t.Fatalf("Inconsistent acquire result: key: %q, ok: %v", key, ok)
+		}
+	}
+	if _, ok := p.acquire(genPoliteReq("http://www.sogou.com/", t)); ok {
+		t.Fatal("The request without token has not been held!")
+	}
+	if _, ok := p.acquire(genPoliteReq("http://www.baidu.com/", t)); !ok {
+		t.Fatal("The request for another primary domain has been held!")
+	}
+	select {
+	case <-requeued:
+	case <-time.After(time.Second):
+		t.Fatal("The held request has not been requeued after refill!")
+	}
+}
+
+func TestPolitenessCrawlDelay(t *testing.T) {
+	requeued := make(chan *module.Request, 10)
+	p := newPoliteness([]HostPolicy{
+		{Host: defaultHostPolicyKey, MaxConcurrency: 2},
+	}, func(req *module.Request) { requeued <- req })
+	defer p.stop()
+	// 获取robots.txt的请求会先建立主机的状态，此后才知道抓取间隔。
+	key, ok := p.acquire(genPoliteReq("http://www.sogou.com/robots.txt", t))
+	if !ok || key != "sogou.com" {
+		t.Fatalf("Inconsistent acquire result: key: %q, ok: %v", key, ok)
+	}
+	p.release(key)
+	delay := 100 * time.Millisecond
+	start := time.Now()
+	p.setCrawlDelay("www.sogou.com", delay)
+	if _, ok := p.acquire(genPoliteReq("http://www.sogou.com/a", t)); ok {
+		t.Fatal("The request within the crawl delay has not been held!")
+	}
+	select {
+	case <-requeued:
+		if elapsed := time.Since(start); elapsed < delay/2 {
+			t.Fatalf("The held request has been requeued too early: %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The held request has not been requeued!")
+	}
+	// 更长的已设定的最小间隔时间不会被缩短。
+	p.setCrawlDelay("www.sogou.com", time.Millisecond)
+	p.lock.Lock()
+	minDelay := p.hosts["sogou.com"].minDelay()
+	p.lock.Unlock()
+	if minDelay != delay {
+		t.Fatalf("Inconsistent min delay: expected: %s, actual: %s", delay, minDelay)
+	}
+}
+
+func TestSchedHostPolicy(t *testing.T) {
+	linkNumber := 4
+	var current, maxConcurrent int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&current, 1)
+			defer atomic.AddInt32(&current, -1)
+			for {
+				max := atomic.LoadInt32(&maxConcurrent)
+				if n <= max || atomic.CompareAndSwapInt32(&maxConcurrent, max, n) {
+					break
+				}
+			}
+			if r.URL.Path == "/" {
+				for i := 0; i < linkNumber; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.HostPolicies = []HostPolicy{
+		{Host: "127.0.0.1", MaxConcurrency: 1},
+	}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	moduleArgs.Workers = WorkerArgs{DownloadWorkers: 4}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	if max := atomic.LoadInt32(&maxConcurrent); max != 1 {
+		t.Fatalf("Inconsistent max concurrent request number: expected: %d, actual: %d",
+			1, max)
+	}
+	summary := sched.Summary().Struct()
+	if summary.Downloaders[0].Completed != uint64(linkNumber+1) {
+		t.Fatalf("Inconsistent completed download number: expected: %d, actual: %d",
+			linkNumber+1, summary.Downloaders[0].Completed)
+	}
+	expectedHostQueue := HostQueueSummaryStruct{Host: "127.0.0.1"}
+	if len(summary.HostQueues) != 1 || summary.HostQueues[0] != expectedHostQueue {
+		t.Fatalf("Inconsistent host queue summary: expected: %#v, actual: %#v",
+			expectedHostQueue, summary.HostQueues)
+	}
+}
@@ -0,0 +1,278 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"gopcpv2-web-spider/module"
+)
+
+// DefaultRedirectMaxHops 代表默认的每个请求最多经历的重定向次数。
+const DefaultRedirectMaxHops = 10
+
+// RedirectMode 代表处理重定向的方式。
+type RedirectMode string
+
+const (
+	// REDIRECT_MODE_FOLLOW 代表由下载器直接跟随重定向，
+	// 但每次跳转的目标都要经过爬取范围、robots.txt和已见URL集合的检查。
+	REDIRECT_MODE_FOLLOW RedirectMode = "follow"
+	// REDIRECT_MODE_EMIT 代表下载器不跟随重定向，
+	// 而由调度器把重定向的目标作为新请求放入请求缓冲池，重定向的响应本身不再被分析。
+	REDIRECT_MODE_EMIT RedirectMode = "emit"
+)
+
+// RedirectArgs 代表处理重定向的参数。
+// 重定向的检查只对通过请求上下文接管重定向的下载器（例如本地下载器）有效。
+type RedirectArgs struct {
+	// Mode 代表处理重定向的方式，为空时为REDIRECT_MODE_FOLLOW。
+	Mode RedirectMode `json:"mode,omitempty"`
+	// MaxHops 代表每个请求最多经历的重定向次数，为0时使用默认值。
+	MaxHops uint32 `json:"max_hops"`
+}
+
+// Check 用于检查处理重定向的参数的有效性。
+func (args *RedirectArgs) Check() error {
+	switch args.Mode {
+	case "", REDIRECT_MODE_FOLLOW, REDIRECT_MODE_EMIT:
+		return nil
+	}
+	return genError(fmt.Sprintf("unknown redirect mode: %q", args.Mode))
+}
+
+// maxHops 用于获取实际使用的重定向次数的上限。
+func (args *RedirectArgs) maxHops() uint32 {
+	if args.MaxHops == 0 {
+		return DefaultRedirectMaxHops
+	}
+	return args.MaxHops
+}
+
+// RedirectSummaryStruct 代表重定向处理情况的摘要。
+type RedirectSummaryStruct struct {
+	// Followed 代表下载器跟随的重定向的次数。
+	Followed uint64 `json:"followed"`
+	// Emitted 代表作为新请求放入请求缓冲池的重定向的次数。
+	Emitted uint64 `json:"emitted"`
+	// Stopped 代表因未通过检查而停止跟随的重定向的次数。
+	Stopped uint64 `json:"stopped"`
+}
+
+// redirectPolicy 代表重定向的处理策略。
+type redirectPolicy struct {
+	args     RedirectArgs
+	followed uint64
+	emitted  uint64
+	stopped  uint64
+}
+
+// newRedirectPolicy 用于创建一个重定向的处理策略。
+func newRedirectPolicy(args RedirectArgs) *redirectPolicy {
+	return &redirectPolicy{args: args}
+}
+
+// emit 用于判断是否把重定向的目标作为新请求。
+func (rp *redirectPolicy) emit() bool {
+	return rp != nil && rp.args.Mode == REDIRECT_MODE_EMIT
+}
+
+// summary 用于获取重定向处理情况的摘要。
+func (rp *redirectPolicy) summary() RedirectSummaryStruct {
+	if rp == nil {
+		return RedirectSummaryStruct{}
+	}
+	return RedirectSummaryStruct{
+		Followed: atomic.LoadUint64(&rp.followed),
+		Emitted:  atomic.LoadUint64(&rp.emitted),
+		Stopped:  atomic.LoadUint64(&rp.stopped),
+	}
+}
+
+// isRedirect 用于判断给定的状态码是否代表重定向。
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectReq 用于生成交给下载器的请求，其上下文中带有检查重定向的函数。
+// 调度器的簿记仍然使用原请求。
+func (sched *myScheduler) redirectReq(req *module.Request) *module.Request {
+	// robots.txt的重定向由下载器按照默认的策略跟随。
+	if sched.redirect == nil || req == nil || !req.Valid() || robotsKeyOf(req) != "" {
+		return req
+	}
+	httpReq := req.HTTPReq()
+	ctx := module.ContextWithRedirectChecker(httpReq.Context(), sched.checkRedirect(req))
+	return module.NewRequest(httpReq.WithContext(ctx), req.Depth()).WithMeta(req.Meta())
+}
+
+// checkRedirect 用于生成检查给定请求的重定向的函数。
+// 每次跳转的目标都要像新请求一样经过检查，未通过时下载器会返回重定向的响应本身。
+func (sched *myScheduler) checkRedirect(req *module.Request) module.RedirectChecker {
+	rp := sched.redirect
+	return func(target *http.Request, via []*http.Request) error {
+		// 重定向的目标会在下载之后由emitRedirect方法处理。
+		if rp.emit() {
+			return http.ErrUseLastResponse
+		}
+		targetReq := module.NewRequest(target, req.Depth())
+		hops := uint32(len(via)) + req.Meta().RedirectCount
+		if hops > rp.args.maxHops() {
+			atomic.AddUint64(&rp.stopped, 1)
+			sched.dropReq(targetReq, DROP_REASON_REDIRECT,
+				fmt.Sprintf("too many redirects: %d", hops))
+			return http.ErrUseLastResponse
+		}
+		// 目标主机的robots.txt规则尚未获取时，不在下载器中等待，而把目标作为新请求。
+		if sched.robots != nil {
+			if sched.robotsRules(target.URL, nil, nil) == nil {
+				sched.handOffRedirect(req, target, via, hops)
+				return http.ErrUseLastResponse
+			}
+		}
+		if ok, reason, detail := sched.followable(target, req.Depth(), via); !ok {
+			atomic.AddUint64(&rp.stopped, 1)
+			sched.dropReq(targetReq, reason, detail)
+			return http.ErrUseLastResponse
+		}
+		atomic.AddUint64(&rp.followed, 1)
+		return nil
+	}
+}
+
+// followable 用于检查重定向的目标能否被跟随，能跟随的目标会被加入已见URL集合。
+// 与此前的某个请求规范化之后相同的目标（例如只改变了协议）不算重复。
+func (sched *myScheduler) followable(target *http.Request, depth uint32,
+	via []*http.Request) (ok bool, reason DropReason, detail string) {
+	scheme := strings.ToLower(target.URL.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return false, DROP_REASON_SCHEME, fmt.Sprintf("unsupported scheme %q", target.URL.Scheme)
+	}
+	urlKey := sched.canonicalizer.Canonicalize(target.URL)
+	for _, prev := range via {
+		if sched.canonicalizer.Canonicalize(prev.URL) == urlKey {
+			urlKey = ""
+			break
+		}
+	}
+	if urlKey != "" && sched.seen.Contains(urlKey) {
+		return false, DROP_REASON_DUPLICATE, urlKey
+	}
+	if ok, reason, detail := sched.scope.allowed(target.URL, depth, via[len(via)-1].URL); !ok {
+		return false, reason, detail
+	}
+	if sched.robots != nil {
+		rules := sched.robotsRules(target.URL, nil, nil)
+		if ok, detail := sched.robots.check(rules, target.URL); !ok {
+			return false, DROP_REASON_ROBOTS, detail
+		}
+	}
+	if urlKey == "" {
+		return true, "", ""
+	}
+	added, err := sched.seen.Add(urlKey)
+	if err != nil {
+		return false, DROP_REASON_INVALID, err.Error()
+	}
+	// 其他流程可能已在检查之后加入了同样的URL。
+	if !added {
+		return false, DROP_REASON_DUPLICATE, urlKey
+	}
+	return true, "", ""
+}
+
+// handOffRedirect 用于把下载器正在跟随的重定向的目标作为新请求交给sendReqFrom方法。
+// 参数hops代表目标是原始请求的第几次重定向。
+func (sched *myScheduler) handOffRedirect(req *module.Request, target *http.Request,
+	via []*http.Request, hops uint32) {
+	targetHTTPReq, err := http.NewRequest(target.Method, target.URL.String(), nil)
+	if err != nil {
+		sched.dropReq(module.NewRequest(target, req.Depth()), DROP_REASON_INVALID, err.Error())
+		return
+	}
+	if target.GetBody != nil {
+		if body, err := target.GetBody(); err == nil {
+			targetHTTPReq.Body = body
+			targetHTTPReq.GetBody = target.GetBody
+			targetHTTPReq.ContentLength = target.ContentLength
+		}
+	}
+	// HTTP客户端已按照跳转的规则为目标设置了请求头。
+	for key, values := range target.Header {
+		targetHTTPReq.Header[key] = values
+	}
+	meta := req.Meta()
+	meta.ID = ""
+	meta.RetryCount = 0
+	meta.RedirectCount = hops
+	targetReq := module.NewRequest(targetHTTPReq, req.Depth()).WithMeta(meta)
+	if sched.sendReqFrom(targetReq, via[len(via)-1].URL) {
+		atomic.AddUint64(&sched.redirect.emitted, 1)
+	}
+}
+
+// emitRedirect 用于在REDIRECT_MODE_EMIT方式下把重定向的目标作为新请求放入请求缓冲池。
+// 结果值代表响应是否是已被处理的重定向，此时响应的主体已被关闭，无需再分析。
+func (sched *myScheduler) emitRedirect(req *module.Request, resp *module.Response) bool {
+	rp := sched.redirect
+	if !rp.emit() || resp == nil || resp.HTTPResp() == nil ||
+		!isRedirect(resp.HTTPResp().StatusCode) {
+		return false
+	}
+	httpResp := resp.HTTPResp()
+	location, err := httpResp.Location()
+	if err != nil {
+		return false
+	}
+	if httpResp.Body != nil {
+		httpResp.Body.Close()
+	}
+	httpReq := req.HTTPReq()
+	method := http.MethodGet
+	// 只有307和308要求保持原请求的方法和主体。
+	code := httpResp.StatusCode
+	if code == http.StatusTemporaryRedirect || code == http.StatusPermanentRedirect {
+		method = httpReq.Method
+	}
+	targetHTTPReq, err := http.NewRequest(method, location.String(), nil)
+	if err != nil {
+		sched.dropReq(req, DROP_REASON_INVALID, err.Error())
+		return true
+	}
+	if method == httpReq.Method && httpReq.GetBody != nil {
+		if body, err := httpReq.GetBody(); err == nil {
+			targetHTTPReq.Body = body
+			targetHTTPReq.GetBody = httpReq.GetBody
+			targetHTTPReq.ContentLength = httpReq.ContentLength
+		}
+	}
+	for key, values := range httpReq.Header {
+		targetHTTPReq.Header[key] = values
+	}
+	// 跳转到其他主机时不携带认证信息。
+	if location.Host != httpReq.URL.Host {
+		targetHTTPReq.Header.Del("Authorization")
+		targetHTTPReq.Header.Del("Cookie")
+	}
+	meta := req.Meta()
+	meta.ID = ""
+	meta.RetryCount = 0
+	meta.RedirectCount++
+	targetReq := module.NewRequest(targetHTTPReq, req.Depth()).WithMeta(meta)
+	if meta.RedirectCount > rp.args.maxHops() {
+		atomic.AddUint64(&rp.stopped, 1)
+		sched.dropReq(targetReq, DROP_REASON_REDIRECT,
+			fmt.Sprintf("too many redirects: %d", meta.RedirectCount))
+		return true
+	}
+	if sched.sendReqFrom(targetReq, httpReq.URL) {
+		atomic.AddUint64(&rp.emitted, 1)
+	}
+	return true
+}
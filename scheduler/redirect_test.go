@@ -0,0 +1,224 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestRedirectArgs(t *testing.T) {
+	validArgsList := []RedirectArgs{
+		{},
+		{Mode: REDIRECT_MODE_FOLLOW},
+		{Mode: REDIRECT_MODE_EMIT, MaxHops: 3},
+	}
+	for _, args := range validArgsList {
+		if err := args.Check(); err != nil {
+			t.Fatalf("An error occurs when checking valid redirect args %#v: %s", args, err)
+		}
+	}
+	args := RedirectArgs{Mode: "inline"}
+	if err := args.Check(); err == nil {
+		t.Fatalf("No error when checking invalid redirect args %#v!", args)
+	}
+	if hops := (&RedirectArgs{}).maxHops(); hops != DefaultRedirectMaxHops {
+		t.Fatalf("Inconsistent default max hops: expected: %d, actual: %d",
+			DefaultRedirectMaxHops, hops)
+	}
+}
+
+// runRedirectSched 用于以给定的重定向参数爬取一个包含各种重定向的网站，
+// 并返回调度器摘要和各路径被访问的次数。
+func runRedirectSched(args RedirectArgs, t *testing.T) (SummaryStruct, map[string]int) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hits[r.URL.Path]++
+			lock.Unlock()
+			redirect := func(location string) {
+				w.Header().Set("Location", location)
+				w.WriteHeader(http.StatusFound)
+			}
+			switch {
+			case r.URL.Path == "/":
+				fmt.Fprint(w, "/b\n/a\n/c\n/d\n/r1\n")
+			case r.URL.Path == "/a":
+				// 目标已经见过。
+				redirect("/b")
+			case r.URL.Path == "/c":
+				// 目标不在爬取范围之内。
+				redirect(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/x")
+			case r.URL.Path == "/d":
+				redirect("/e")
+			case strings.HasPrefix(r.URL.Path, "/r"):
+				// 一条没有尽头的重定向链。
+				var n int
+				fmt.Sscanf(r.URL.Path, "/r%d", &n)
+				redirect(fmt.Sprintf("/r%d", n+1))
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.Redirect = args
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	result := map[string]int{}
+	for path, n := range hits {
+		result[path] = n
+	}
+	return sched.Summary().Struct(), result
+}
+
+func TestSchedRedirectFollow(t *testing.T) {
+	summary, hits := runRedirectSched(RedirectArgs{MaxHops: 2}, t)
+	expectedHits := map[string]int{
+		"/": 1, "/a": 1, "/b": 1, "/c": 1, "/d": 1, "/e": 1,
+		"/r1": 1, "/r2": 1, "/r3": 1,
+	}
+	if len(hits) != len(expectedHits) {
+		t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+	}
+	for path, n := range expectedHits {
+		if hits[path] != n {
+			t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+		}
+	}
+	// 跟随了/d→/e、/r1→/r2和/r2→/r3，停止了重复、超出范围和超出次数的跳转。
+	expected := RedirectSummaryStruct{Followed: 3, Stopped: 3}
+	if summary.Redirect != expected {
+		t.Fatalf("Inconsistent redirect summary: expected: %#v, actual: %#v",
+			expected, summary.Redirect)
+	}
+	if summary.Dropped.Redirect != 1 || summary.Dropped.Domain != 1 {
+		t.Fatalf("Inconsistent drop summary: %#v", summary.Dropped)
+	}
+}
+
+func TestSchedRedirectEmit(t *testing.T) {
+	summary, hits := runRedirectSched(RedirectArgs{Mode: REDIRECT_MODE_EMIT, MaxHops: 2}, t)
+	expectedHits := map[string]int{
+		"/": 1, "/a": 1, "/b": 1, "/c": 1, "/d": 1, "/e": 1,
+		"/r1": 1, "/r2": 1, "/r3": 1,
+	}
+	if len(hits) != len(expectedHits) {
+		t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+	}
+	for path, n := range expectedHits {
+		if hits[path] != n {
+			t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+		}
+	}
+	// /d→/e、/r1→/r2和/r2→/r3作为新请求被放入，重复和超出范围的目标由调度器丢弃。
+	expected := RedirectSummaryStruct{Emitted: 3, Stopped: 1}
+	if summary.Redirect != expected {
+		t.Fatalf("Inconsistent redirect summary: expected: %#v, actual: %#v",
+			expected, summary.Redirect)
+	}
+	if summary.Dropped.Redirect != 1 || summary.Dropped.Domain != 1 {
+		t.Fatalf("Inconsistent drop summary: %#v", summary.Dropped)
+	}
+}
+
+func TestSchedRedirectRobots(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hits["target"+r.URL.Path]++
+			lock.Unlock()
+			switch r.URL.Path {
+			case "/robots.txt":
+				fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			case "/x":
+				fmt.Fprint(w, "/private/y\n")
+			}
+		}))
+	defer target.Close()
+	source := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hits["source"+r.URL.Path]++
+			lock.Unlock()
+			if r.URL.Path == "/" {
+				http.Redirect(w, r, target.URL+"/x", http.StatusFound)
+				return
+			}
+			http.NotFound(w, r)
+		}))
+	defer source.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.ObeyRobots = true
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", source.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// 跳转到其他主机时，需要先获取该主机的robots.txt，然后才能下载目标。
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	expectedHits := map[string]int{
+		"source/robots.txt": 1, "source/": 1,
+		"target/robots.txt": 1, "target/x": 1,
+	}
+	if len(hits) != len(expectedHits) {
+		t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+	}
+	for path, n := range expectedHits {
+		if hits[path] != n {
+			t.Fatalf("Inconsistent hits: expected: %v, actual: %v", expectedHits, hits)
+		}
+	}
+	summary := sched.Summary().Struct()
+	if summary.Redirect.Emitted != 1 || summary.Dropped.Robots != 1 {
+		t.Fatalf("Inconsistent summary: %#v, %#v", summary.Redirect, summary.Dropped)
+	}
+}
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// 重试策略的默认参数。
+const (
+	// DefaultRetryBaseDelay 代表第一次重试之前的默认基础等待时长。
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay 代表重试之前等待时长的默认上限。
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+// RetryArgs 代表下载失败的请求的重试策略的参数。
+type RetryArgs struct {
+	// MaxRetries 代表每个请求最多的重试次数，为0时不重试。
+	MaxRetries uint32 `json:"max_retries"`
+	// BaseDelay 代表第一次重试之前的基础等待时长，之后每次重试加倍，为0时使用默认值。
+	BaseDelay time.Duration `json:"base_delay"`
+	// MaxDelay 代表重试之前等待时长的上限，为0时使用默认值。
+	// 服务端通过Retry-After要求的等待时长超出该上限时，请求不再重试。
+	MaxDelay time.Duration `json:"max_delay"`
+}
+
+// Check 用于检查重试策略的参数的有效性。
+func (args *RetryArgs) Check() error {
+	if args.BaseDelay < 0 {
+		return genError(fmt.Sprintf("negative retry base delay: %s", args.BaseDelay))
+	}
+	if args.MaxDelay < 0 {
+		return genError(fmt.Sprintf("negative retry max delay: %s", args.MaxDelay))
+	}
+	if args.baseDelay() > args.maxDelay() {
+		return genError(fmt.Sprintf("retry base delay %s is greater than max delay %s",
+			args.baseDelay(), args.maxDelay()))
+	}
+	return nil
+}
+
+// Enabled 用于判断是否重试下载失败的请求。
+func (args *RetryArgs) Enabled() bool {
+	return args.MaxRetries > 0
+}
+
+// baseDelay 用于获取实际使用的基础等待时长。
+func (args *RetryArgs) baseDelay() time.Duration {
+	if args.BaseDelay == 0 {
+		return DefaultRetryBaseDelay
+	}
+	return args.BaseDelay
+}
+
+// maxDelay 用于获取实际使用的等待时长的上限。
+func (args *RetryArgs) maxDelay() time.Duration {
+	if args.MaxDelay == 0 {
+		return DefaultRetryMaxDelay
+	}
+	return args.MaxDelay
+}
+
+// backoff 用于获取第attempt次尝试失败之后、重试之前等待的时长。
+// 时长按指数增长并受上限限制，然后在其一半到全部之间随机抖动，以免重试扎堆。
+func (args *RetryArgs) backoff(attempt uint32) time.Duration {
+	delay, max := args.baseDelay(), args.maxDelay()
+	for i := uint32(1); i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// RetrySummaryStruct 代表重试情况的摘要类型。
+type RetrySummaryStruct struct {
+	// Retries 代表被安排重试的次数。
+	Retries uint64 `json:"retries"`
+	// PermanentFailures 代表不再重试的下载失败的次数，
+	// 包括不可重试的失败和重试次数已用尽的失败。
+	PermanentFailures uint64 `json:"permanent_failures"`
+}
+
+// retryPolicy 代表下载失败的请求的重试策略。
+type retryPolicy struct {
+	args              RetryArgs
+	retries           uint64
+	permanentFailures uint64
+}
+
+// newRetryPolicy 用于创建一个重试策略。
+func newRetryPolicy(args RetryArgs) *retryPolicy {
+	return &retryPolicy{args: args}
+}
+
+// delay 用于获取给定的请求在下载失败之后、重试之前等待的时长。
+// 响应中有Retry-After时，等待的时长不会短于它。
+// 第二个结果值代表是否应该重试，重试次数已用尽或要求的等待时长超出上限时为false。
+func (rp *retryPolicy) delay(req *module.Request, resp *module.Response) (time.Duration, bool) {
+	if req.Attempt() >= rp.args.MaxRetries {
+		return 0, false
+	}
+	delay := rp.args.backoff(req.Attempt() + 1)
+	if resp != nil && resp.HTTPResp() != nil {
+		if after, ok := parseRetryAfter(resp.HTTPResp().Header, time.Now()); ok {
+			if after > rp.args.maxDelay() {
+				return 0, false
+			}
+			if after > delay {
+				delay = after
+			}
+		}
+	}
+	return delay, true
+}
+
+// summary 用于获取重试情况的摘要。
+func (rp *retryPolicy) summary() RetrySummaryStruct {
+	if rp == nil {
+		return RetrySummaryStruct{}
+	}
+	return RetrySummaryStruct{
+		Retries:           atomic.LoadUint64(&rp.retries),
+		PermanentFailures: atomic.LoadUint64(&rp.permanentFailures),
+	}
+}
+
+// parseRetryAfter 用于解析响应头中的Retry-After，它可以是秒数或HTTP日期。
+// 第二个结果值代表是否存在有效的Retry-After。
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// classifyDownload 用于判断下载的结果是否失败以及失败是否可重试。
+// 超时、连接被拒绝或重置、连接意外关闭以及429和5xx（501除外）的响应都是可重试的。
+func classifyDownload(resp *module.Response, err error) (failed bool, retryable bool) {
+	if err != nil {
+		return true, retryableError(err)
+	}
+	if resp == nil || resp.HTTPResp() == nil {
+		return false, false
+	}
+	code := resp.HTTPResp().StatusCode
+	if code == http.StatusTooManyRequests ||
+		(code >= 500 && code != http.StatusNotImplemented) {
+		return true, true
+	}
+	return false, false
+}
+
+// retryableError 用于判断下载时发生的错误是否是暂时性的。
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// retryDownload 会在下载失败时按照重试策略安排重试，并统计不再重试的失败。
+// 结果值代表请求是否已被安排重试，此时调用方不应再处理该请求及其响应。
+func (sched *myScheduler) retryDownload(req *module.Request, resp *module.Response, err error) bool {
+	failed, retryable := classifyDownload(resp, err)
+	if !failed {
+		return false
+	}
+	if retryable && !sched.canceled() {
+		if delay, ok := sched.retry.delay(req, resp); ok {
+			if resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+				resp.HTTPResp().Body.Close()
+			}
+			atomic.AddUint64(&sched.retry.retries, 1)
+			next := req.WithAttempt(req.Attempt() + 1)
+			log.Printf("请求%s第%d次下载失败，将在%s之后重试", req.HTTPReq().URL,
+				next.Attempt(), delay)
+			sched.requeues.remove(req)
+			sched.checkpointer.addPending(next)
+			sched.putReqAfter(next, delay)
+			return true
+		}
+	}
+	atomic.AddUint64(&sched.retry.permanentFailures, 1)
+	return false
+}
@@ -0,0 +1,289 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestRetryArgs(t *testing.T) {
+	validArgsList := []RetryArgs{
+		{},
+		{MaxRetries: 3},
+		{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: time.Second},
+		{MaxRetries: 3, BaseDelay: time.Millisecond},
+	}
+	for _, args := range validArgsList {
+		if err := args.Check(); err != nil {
+			t.Fatalf("An error occurs when checking valid retry args %#v: %s", args, err)
+		}
+	}
+	invalidArgsList := []RetryArgs{
+		{BaseDelay: -1},
+		{MaxDelay: -1},
+		{BaseDelay: time.Second, MaxDelay: time.Millisecond},
+		{BaseDelay: time.Minute},
+	}
+	for _, args := range invalidArgsList {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking invalid retry args %#v!", args)
+		}
+	}
+	if (&RetryArgs{}).Enabled() {
+		t.Fatal("The retry args without max retries are enabled!")
+	}
+	if !(&RetryArgs{MaxRetries: 1}).Enabled() {
+		t.Fatal("The retry args with max retries are disabled!")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	args := RetryArgs{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	expectedMax := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, max := range expectedMax {
+		for j := 0; j < 100; j++ {
+			delay := args.backoff(uint32(i + 1))
+			if delay < max/2 || delay > max {
+				t.Fatalf("Inconsistent backoff of attempt %d: expected: [%s, %s], actual: %s",
+					i+1, max/2, max, delay)
+			}
+		}
+	}
+	defaultArgs := RetryArgs{MaxRetries: 1}
+	if delay := defaultArgs.backoff(100); delay > DefaultRetryMaxDelay {
+		t.Fatalf("Inconsistent default max backoff: expected: <= %s, actual: %s",
+			DefaultRetryMaxDelay, delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.value != "" {
+			header.Set("Retry-After", c.value)
+		}
+		after, ok := parseRetryAfter(header, now)
+		if after != c.expected || ok != c.ok {
+			t.Fatalf("Inconsistent Retry-After for %q: expected: (%s, %v), actual: (%s, %v)",
+				c.value, c.expected, c.ok, after, ok)
+		}
+	}
+}
+
+// timeoutError 代表超时的网络错误。
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyDownload(t *testing.T) {
+	genResp := func(code int) *module.Response {
+		return module.NewResponse(&http.Response{StatusCode: code}, 0)
+	}
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://127.0.0.1/",
+			Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", err)}}
+	}
+	cases := []struct {
+		resp      *module.Response
+		err       error
+		failed    bool
+		retryable bool
+	}{
+		{genResp(200), nil, false, false},
+		{genResp(404), nil, false, false},
+		{genResp(429), nil, true, true},
+		{genResp(500), nil, true, true},
+		{genResp(501), nil, false, false},
+		{genResp(503), nil, true, true},
+		{nil, &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: timeoutError{}}, true, true},
+		{nil, wrap(syscall.ECONNRESET), true, true},
+		{nil, wrap(syscall.ECONNREFUSED), true, true},
+		{nil, &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: io.ErrUnexpectedEOF}, true, true},
+		{nil, &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: context.Canceled}, true, false},
+		{nil, errors.New("invalid request"), true, false},
+	}
+	for i, c := range cases {
+		failed, retryable := classifyDownload(c.resp, c.err)
+		if failed != c.failed || retryable != c.retryable {
+			t.Fatalf("Inconsistent classification of case %d (%v): expected: (%v, %v), actual: (%v, %v)",
+				i, c.err, c.failed, c.retryable, failed, retryable)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	rp := newRetryPolicy(RetryArgs{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second})
+	httpReq, _ := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	req := module.NewRequest(httpReq, 0)
+	if delay, ok := rp.delay(req, nil); !ok || delay > time.Millisecond {
+		t.Fatalf("Inconsistent delay of first retry: %s, %v", delay, ok)
+	}
+	httpResp := &http.Response{StatusCode: 503, Header: http.Header{}}
+	httpResp.Header.Set("Retry-After", "5")
+	if delay, ok := rp.delay(req.WithAttempt(1), module.NewResponse(httpResp, 0)); !ok || delay != 5*time.Second {
+		t.Fatalf("Inconsistent delay with Retry-After: expected: %s, actual: %s, %v",
+			5*time.Second, delay, ok)
+	}
+	if _, ok := rp.delay(req.WithAttempt(2), nil); ok {
+		t.Fatal("The request is retried after the max retries!")
+	}
+	httpResp.Header.Set("Retry-After", "60")
+	if _, ok := rp.delay(req, module.NewResponse(httpResp, 0)); ok {
+		t.Fatal("The request is retried though Retry-After exceeds the max delay!")
+	}
+}
+
+func TestSchedRetry(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			hits[r.URL.Path]++
+			hit := hits[r.URL.Path]
+			lock.Unlock()
+			switch r.URL.Path {
+			case "/":
+				// 前两次分别要求限流和暂时不可用，然后才正常响应。
+				switch hit {
+				case 1:
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				case 2:
+					w.WriteHeader(http.StatusServiceUnavailable)
+				default:
+					fmt.Fprintln(w, "/ok")
+					fmt.Fprintln(w, "/broken")
+					fmt.Fprintln(w, "/missing")
+				}
+			case "/broken":
+				w.WriteHeader(http.StatusInternalServerError)
+			case "/missing":
+				http.NotFound(w, r)
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.Retry = RetryArgs{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	// 首页重试两次后成功，/broken重试两次后放弃，/missing不可重试。
+	expectedHits := map[string]int{"/": 3, "/ok": 1, "/broken": 3, "/missing": 1}
+	for path, expected := range expectedHits {
+		if hits[path] != expected {
+			t.Fatalf("Inconsistent hits of %q: expected: %d, actual: %d",
+				path, expected, hits[path])
+		}
+	}
+	summary := sched.Summary().Struct()
+	expectedRetry := RetrySummaryStruct{Retries: 4, PermanentFailures: 1}
+	if summary.Retry != expectedRetry {
+		t.Fatalf("Inconsistent retry summary: expected: %#v, actual: %#v",
+			expectedRetry, summary.Retry)
+	}
+	if summary.RequestArgs.Retry != requestArgs.Retry {
+		t.Fatalf("Inconsistent retry args in summary: expected: %#v, actual: %#v",
+			requestArgs.Retry, summary.RequestArgs.Retry)
+	}
+}
+
+// bareRespDownloader 代表总是返回不含HTTP响应的响应值和可重试的错误的下载器。
+type bareRespDownloader struct {
+	module.Downloader
+	calls int32
+}
+
+func (d *bareRespDownloader) Download(req *module.Request) (*module.Response, error) {
+	atomic.AddInt32(&d.calls, 1)
+	return module.NewResponse(nil, req.Depth()), syscall.ECONNRESET
+}
+
+func TestSchedRetryBareResponse(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.Retry = RetryArgs{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	d := &bareRespDownloader{Downloader: moduleArgs.Downloaders[0]}
+	moduleArgs.Downloaders = []module.Downloader{d}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 不含HTTP响应的响应值不应引发恐慌，请求仍会按照策略重试。
+	if calls := atomic.LoadInt32(&d.calls); calls != 3 {
+		t.Fatalf("Inconsistent download calls: expected: %d, actual: %d", 3, calls)
+	}
+	expectedRetry := RetrySummaryStruct{Retries: 2, PermanentFailures: 1}
+	if summary := sched.Summary().Struct(); summary.Retry != expectedRetry {
+		t.Fatalf("Inconsistent retry summary: expected: %#v, actual: %#v",
+			expectedRetry, summary.Retry)
+	}
+}
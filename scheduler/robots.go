@@ -0,0 +1,444 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// defaultRobotsUserAgent 代表未指定用户代理时匹配robots.txt规则所用的用户代理。
+const defaultRobotsUserAgent = "*"
+
+// robotsKeyAttr 代表robots.txt请求的元数据中记录缓存项的键的属性名。
+const robotsKeyAttr = "robots_key"
+
+// robotsMaxSize 代表robots.txt的最大解析长度，超出的部分会被忽略。
+const robotsMaxSize = 500 * 1024
+
+// robotsCacheTTL 代表成功获取的robots.txt的缓存时间。
+const robotsCacheTTL = 24 * time.Hour
+
+// robotsErrorTTL 代表获取robots.txt失败时其结果的缓存时间。
+const robotsErrorTTL = time.Minute
+
+// robotsRule 代表robots.txt中的一条Allow或Disallow规则。
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// String 用于获取规则的字符串形式。
+func (rule robotsRule) String() string {
+	if rule.allow {
+		return "Allow: " + rule.pattern
+	}
+	return "Disallow: " + rule.pattern
+}
+
+// newRobotsRule 用于创建一条规则。路径模式支持通配符“*”和结尾锚点“$”。
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	expr := pattern
+	anchored := strings.HasSuffix(expr, "$")
+	if anchored {
+		expr = expr[:len(expr)-1]
+	}
+	parts := strings.Split(expr, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr = "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{
+		allow:   allow,
+		pattern: pattern,
+		re:      regexp.MustCompile(expr),
+	}
+}
+
+// robotsRules 代表从robots.txt中解析出的适用于某个用户代理的规则。
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string
+	// disallowAll 为true时代表禁止访问所有路径，用于robots.txt暂时不可用的情况。
+	disallowAll bool
+}
+
+// allowed 用于判断给定的路径是否被允许访问。
+// 匹配最长的规则生效，长度相同时Allow优先。
+// 结果值reason代表禁止访问的原因。
+func (rr *robotsRules) allowed(path string) (ok bool, reason string) {
+	if rr == nil {
+		return true, ""
+	}
+	if rr.disallowAll {
+		return false, "robots.txt is unavailable"
+	}
+	var matched *robotsRule
+	for i, rule := range rr.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if matched == nil ||
+			len(rule.pattern) > len(matched.pattern) ||
+			(len(rule.pattern) == len(matched.pattern) && rule.allow) {
+			matched = &rr.rules[i]
+		}
+	}
+	if matched == nil || matched.allow {
+		return true, ""
+	}
+	return false, fmt.Sprintf("disallowed by robots.txt rule %q", matched.String())
+}
+
+// robotsAgentToken 用于从用户代理中提取用于匹配的产品名称。
+func robotsAgentToken(userAgent string) string {
+	token := strings.TrimSpace(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
+
+// parseRobots 用于解析robots.txt，并返回适用于给定用户代理的规则。
+// 有与用户代理匹配的组时只使用这些组，否则使用“*”组。
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := robotsAgentToken(userAgent)
+	var specific, general robotsRules
+	var foundSpecific bool
+	// 当前组所针对的用户代理是否与给定的用户代理匹配或为“*”。
+	var groupSpecific, groupGeneral bool
+	// 上一行是否为User-agent，连续的User-agent行属于同一组。
+	var lastWasAgent bool
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsMaxSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				groupSpecific, groupGeneral = false, false
+			}
+			agent := strings.ToLower(value)
+			if agent == "*" {
+				groupGeneral = true
+			} else if token != "" && token != "*" && agent == token {
+				groupSpecific = true
+				foundSpecific = true
+			}
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// 空的Disallow代表不做任何限制。
+			if value != "" {
+				rule := newRobotsRule(key == "allow", value)
+				if groupSpecific {
+					specific.rules = append(specific.rules, rule)
+				}
+				if groupGeneral {
+					general.rules = append(general.rules, rule)
+				}
+			}
+		case "crawl-delay":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err == nil && seconds > 0 {
+				delay := time.Duration(seconds * float64(time.Second))
+				if groupSpecific {
+					specific.crawlDelay = delay
+				}
+				if groupGeneral {
+					general.crawlDelay = delay
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				specific.sitemaps = append(specific.sitemaps, value)
+				general.sitemaps = append(general.sitemaps, value)
+			}
+		}
+		lastWasAgent = false
+	}
+	if foundSpecific {
+		return &specific
+	}
+	return &general
+}
+
+// RobotsSummaryStruct 代表robots.txt处理情况的摘要类型。
+type RobotsSummaryStruct struct {
+	// Hosts 代表已缓存robots.txt的主机的数量。
+	Hosts uint64 `json:"hosts"`
+	// Disallowed 代表因robots.txt而被忽略的请求的数量。
+	Disallowed uint64 `json:"disallowed"`
+	// FetchErrors 代表获取robots.txt失败的次数。
+	FetchErrors uint64 `json:"fetch_errors"`
+}
+
+// robotsEntry 代表某个主机的robots.txt的缓存项。
+type robotsEntry struct {
+	// rules 代表已获取的规则，尚未获取时为nil。
+	rules   *robotsRules
+	expires time.Time
+	// fetching 代表是否正在获取。
+	fetching bool
+	// waiters 代表等待规则的函数，它们会在获取完成后被调用。
+	waiters []func(rules *robotsRules)
+}
+
+// robotsCache 代表按主机缓存robots.txt规则的缓存。
+// 它本身不会下载robots.txt，而是要求调用方发起获取并在完成后调用done方法，
+// 这样robots.txt的下载可以与其他请求一样受礼貌性控制并使用下载器。
+type robotsCache struct {
+	userAgent   string
+	lock        sync.Mutex
+	entries     map[string]*robotsEntry
+	disallowed  uint64
+	fetchErrors uint64
+	// onRules 会在获取到某个主机的规则后被调用。
+	onRules func(host string, rules *robotsRules)
+}
+
+// newRobotsCache 用于创建一个robots.txt缓存。
+func newRobotsCache(userAgent string, onRules func(host string, rules *robotsRules)) *robotsCache {
+	if userAgent == "" {
+		userAgent = defaultRobotsUserAgent
+	}
+	return &robotsCache{
+		userAgent: userAgent,
+		entries:   map[string]*robotsEntry{},
+		onRules:   onRules,
+	}
+}
+
+// robotsKey 用于获取给定URL所属的缓存项的键，即小写的协议与主机部分。
+func robotsKey(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// rules 用于获取适用于给定URL的规则，它不会阻塞。
+// 规则尚未获取时结果值rules为nil，此时若wait不为nil，
+// park会在持有锁时被调用，wait会在获取完成后被调用。
+// 结果值fetch代表调用方需要获取robots.txt并在完成后调用done方法。
+// 缓存过期时仍会返回过期的规则，同时要求重新获取。
+func (rc *robotsCache) rules(u *url.URL, park func(),
+	wait func(rules *robotsRules)) (rules *robotsRules, fetch bool) {
+	if rc == nil || u == nil {
+		return nil, false
+	}
+	key := robotsKey(u)
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	entry := rc.entries[key]
+	if entry == nil {
+		entry = &robotsEntry{}
+		rc.entries[key] = entry
+	}
+	if !entry.fetching && (entry.rules == nil || time.Now().After(entry.expires)) {
+		entry.fetching = true
+		fetch = true
+	}
+	if entry.rules == nil && wait != nil {
+		if park != nil {
+			park()
+		}
+		entry.waiters = append(entry.waiters, wait)
+	}
+	return entry.rules, fetch
+}
+
+// check 用于按照给定的规则判断URL是否允许访问，并统计被禁止的次数。
+// 结果值reason代表禁止访问的原因。
+func (rc *robotsCache) check(rules *robotsRules, u *url.URL) (ok bool, reason string) {
+	if rc == nil || rules == nil || u == nil {
+		return true, ""
+	}
+	// robots.txt本身总是可以访问的。
+	if u.Path == "/robots.txt" {
+		return true, ""
+	}
+	ok, reason = rules.allowed(u.RequestURI())
+	if !ok {
+		atomic.AddUint64(&rc.disallowed, 1)
+	}
+	return
+}
+
+// done 会根据获取robots.txt的结果更新缓存项，并调用所有等待规则的函数。
+// 参数key是robotsKey的结果值，httpResp的主体会被读取并关闭。
+// 缓存项不在获取中时（例如同一次获取的结果已被处理过）调用它不会有任何效果。
+func (rc *robotsCache) done(key string, httpResp *http.Response, err error) {
+	rc.lock.Lock()
+	entry := rc.entries[key]
+	fetching := entry != nil && entry.fetching
+	rc.lock.Unlock()
+	if !fetching {
+		if httpResp != nil && httpResp.Body != nil {
+			httpResp.Body.Close()
+		}
+		return
+	}
+	rules, ttl := rc.parse(key+"/robots.txt", httpResp, err)
+	rc.lock.Lock()
+	entry.rules = rules
+	entry.expires = time.Now().Add(ttl)
+	entry.fetching = false
+	waiters := entry.waiters
+	entry.waiters = nil
+	rc.lock.Unlock()
+	if rc.onRules != nil {
+		if u, err := url.Parse(key); err == nil {
+			rc.onRules(u.Hostname(), rules)
+		}
+	}
+	for _, wait := range waiters {
+		wait(rules)
+	}
+}
+
+// parse 用于根据获取robots.txt的结果生成规则及其缓存时间。
+// 响应状态码为4xx时视为没有任何限制，
+// 服务器错误或网络错误时暂时禁止访问所有路径。
+func (rc *robotsCache) parse(robotsURL string, httpResp *http.Response, err error) (*robotsRules, time.Duration) {
+	if httpResp != nil && httpResp.Body != nil {
+		defer httpResp.Body.Close()
+	}
+	if err == nil && httpResp == nil {
+		err = errors.New("nil response")
+	}
+	if err != nil {
+		atomic.AddUint64(&rc.fetchErrors, 1)
+		log.Printf("获取robots.txt失败：%s (url: %s)", err, robotsURL)
+		return &robotsRules{disallowAll: true}, robotsErrorTTL
+	}
+	switch {
+	case httpResp.StatusCode >= 200 && httpResp.StatusCode < 300:
+		return parseRobots(httpResp.Body, rc.userAgent), robotsCacheTTL
+	case httpResp.StatusCode >= 400 && httpResp.StatusCode < 500:
+		return &robotsRules{}, robotsCacheTTL
+	default:
+		atomic.AddUint64(&rc.fetchErrors, 1)
+		log.Printf("获取robots.txt失败：%s (url: %s)", httpResp.Status, robotsURL)
+		return &robotsRules{disallowAll: true}, robotsErrorTTL
+	}
+}
+
+// newRequest 用于生成获取给定缓存项的robots.txt的请求。
+// 请求的元数据中带有缓存项的键，见robotsKeyOf函数。
+func (rc *robotsCache) newRequest(ctx context.Context, key string) (*module.Request, error) {
+	httpReq, err := http.NewRequest("GET", key+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	if rc.userAgent != defaultRobotsUserAgent {
+		httpReq.Header.Set("User-Agent", rc.userAgent)
+	}
+	meta := module.Meta{
+		ID:    module.NewRequestID(),
+		Attrs: map[string]string{robotsKeyAttr: key},
+	}
+	return module.NewRequest(httpReq, 0).WithMeta(meta), nil
+}
+
+// robotsKeyOf 用于获取robots.txt请求所属的缓存项的键，其他请求的结果值为空字符串。
+func robotsKeyOf(req *module.Request) string {
+	if req == nil {
+		return ""
+	}
+	return req.Meta().Attr(robotsKeyAttr)
+}
+
+// checkRobots 会按照robots.txt检查给定的请求，通过后把它放入请求缓冲池。
+// 规则尚未获取时请求会被暂存，直至规则获取完成后再检查，调用方不会因此阻塞。
+// 暂存的请求一直被计为尚未处理的请求。结果值代表请求是否被放入请求缓冲池或被暂存。
+func (sched *myScheduler) checkRobots(req *module.Request, urlKey string) bool {
+	if sched.robots == nil {
+		return sched.enqueueReq(req, urlKey)
+	}
+	rules := sched.robotsRules(req.HTTPReq().URL,
+		func() {
+			sched.tracker.incr(workKindRequest)
+		},
+		func(rules *robotsRules) {
+			sched.robotsChecked(req, urlKey, rules)
+			sched.tracker.decr(workKindRequest)
+		})
+	if rules == nil {
+		return true
+	}
+	return sched.robotsChecked(req, urlKey, rules)
+}
+
+// robotsChecked 会按照给定的规则检查请求，通过后把它放入请求缓冲池。
+func (sched *myScheduler) robotsChecked(req *module.Request, urlKey string, rules *robotsRules) bool {
+	if sched.canceled() {
+		return false
+	}
+	if ok, detail := sched.robots.check(rules, req.HTTPReq().URL); !ok {
+		sched.dropReq(req, DROP_REASON_ROBOTS, detail)
+		return false
+	}
+	return sched.enqueueReq(req, urlKey)
+}
+
+// robotsRules 用于获取适用于给定URL的规则，需要时会发起robots.txt的获取。
+// 参数park和wait的含义与robotsCache的rules方法的相同。
+func (sched *myScheduler) robotsRules(u *url.URL, park func(),
+	wait func(rules *robotsRules)) *robotsRules {
+	rules, fetch := sched.robots.rules(u, park, wait)
+	if fetch {
+		sched.fetchRobots(robotsKey(u))
+	}
+	return rules
+}
+
+// fetchRobots 会把获取robots.txt的请求放入请求缓冲池。
+// 它与其他请求一样受礼貌性控制并由下载器下载，其请求使用调度器的上下文，
+// 所以调度器停止时获取也会被中止。获取的结果由downloadOne方法交给缓存。
+func (sched *myScheduler) fetchRobots(key string) {
+	req, err := sched.robots.newRequest(sched.ctx, key)
+	if err != nil {
+		sched.robots.done(key, nil, err)
+		return
+	}
+	sched.putReq(req)
+}
+
+// summary 用于获取robots.txt处理情况的摘要。
+func (rc *robotsCache) summary() RobotsSummaryStruct {
+	if rc == nil {
+		return RobotsSummaryStruct{}
+	}
+	rc.lock.Lock()
+	hosts := len(rc.entries)
+	rc.lock.Unlock()
+	return RobotsSummaryStruct{
+		Hosts:       uint64(hosts),
+		Disallowed:  atomic.LoadUint64(&rc.disallowed),
+		FetchErrors: atomic.LoadUint64(&rc.fetchErrors),
+	}
+}
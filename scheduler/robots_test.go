@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+var robotsFixture = `# 测试用的robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 0.5
+
+User-agent: finder
+User-agent: other
+Disallow: /finder-only
+Crawl-delay: 2
+
+Sitemap: http://www.example.com/sitemap.xml
+`
+
+func TestParseRobots(t *testing.T) {
+	cases := []struct {
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"", "/", true},
+		{"", "/private/secret", false},
+		{"", "/private/public/page", true},
+		{"", "/docs/a.pdf", false},
+		{"", "/docs/a.pdf?download=1", true},
+		{"", "/finder-only", true},
+		{"Finder/1.0", "/finder-only/page", false},
+		// 有匹配的组时不再使用“*”组。
+		{"Finder/1.0", "/private/secret", true},
+		{"Other", "/finder-only", false},
+	}
+	for _, c := range cases {
+		rules := parseRobots(strings.NewReader(robotsFixture), c.userAgent)
+		allowed, reason := rules.allowed(c.path)
+		if allowed != c.allowed {
+			t.Fatalf("Inconsistent robots result: expected: %v, actual: %v (case: %#v, reason: %s)",
+				c.allowed, allowed, c, reason)
+		}
+		if !allowed && reason == "" {
+			t.Fatalf("Empty reason for disallowed path %q!", c.path)
+		}
+	}
+	rules := parseRobots(strings.NewReader(robotsFixture), "")
+	if rules.crawlDelay != 500*time.Millisecond {
+		t.Fatalf("Inconsistent crawl delay: expected: %s, actual: %s",
+			500*time.Millisecond, rules.crawlDelay)
+	}
+	rules = parseRobots(strings.NewReader(robotsFixture), "finder")
+	if rules.crawlDelay != 2*time.Second {
+		t.Fatalf("Inconsistent crawl delay: expected: %s, actual: %s",
+			2*time.Second, rules.crawlDelay)
+	}
+	if len(rules.sitemaps) != 1 || rules.sitemaps[0] != "http://www.example.com/sitemap.xml" {
+		t.Fatalf("Inconsistent sitemaps: %v", rules.sitemaps)
+	}
+}
+
+// newRobotsResp 用于生成一个获取robots.txt的HTTP响应。
+func newRobotsResp(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	var crawlDelay time.Duration
+	var rulesHost string
+	rc := newRobotsCache("finder", func(host string, rules *robotsRules) {
+		rulesHost = host
+		crawlDelay = rules.crawlDelay
+	})
+	var parked, resumed int
+	var fetches int
+	var urls []*url.URL
+	for _, path := range []string{"/a", "/finder-only", "/b"} {
+		u, _ := url.Parse("http://www.Example.com" + path)
+		urls = append(urls, u)
+		rules, fetch := rc.rules(u,
+			func() { parked++ },
+			func(rules *robotsRules) {
+				if rules == nil {
+					t.Fatalf("Nil robots rules for waiter!")
+				}
+				resumed++
+			})
+		if rules != nil {
+			t.Fatalf("Unexpected robots rules before fetching: %#v", rules)
+		}
+		if fetch {
+			fetches++
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("Inconsistent robots fetch count: expected: %d, actual: %d", 1, fetches)
+	}
+	if parked != 3 || resumed != 0 {
+		t.Fatalf("Inconsistent parked requests: expected: %d/%d, actual: %d/%d",
+			3, 0, parked, resumed)
+	}
+	key := robotsKey(urls[0])
+	if key != "http://www.example.com" {
+		t.Fatalf("Inconsistent robots key: expected: %s, actual: %s",
+			"http://www.example.com", key)
+	}
+	req, err := rc.newRequest(context.Background(), key)
+	if err != nil {
+		t.Fatalf("An error occurs when creating robots request: %s", err)
+	}
+	if ua := req.HTTPReq().Header.Get("User-Agent"); ua != "finder" {
+		t.Fatalf("Inconsistent user agent: expected: %s, actual: %s", "finder", ua)
+	}
+	if robotsKeyOf(req) != key {
+		t.Fatalf("Inconsistent robots key of request: expected: %s, actual: %s",
+			key, robotsKeyOf(req))
+	}
+	rc.done(key, newRobotsResp(http.StatusOK, robotsFixture), nil)
+	if resumed != 3 {
+		t.Fatalf("Inconsistent resumed requests: expected: %d, actual: %d", 3, resumed)
+	}
+	if rulesHost != "www.example.com" || crawlDelay != 2*time.Second {
+		t.Fatalf("Inconsistent crawl delay: expected: %s (%s), actual: %s (%s)",
+			2*time.Second, "www.example.com", crawlDelay, rulesHost)
+	}
+	// 重复的结果会被忽略。
+	rc.done(key, newRobotsResp(http.StatusInternalServerError, ""), nil)
+	for i, u := range urls {
+		rules, fetch := rc.rules(u, nil, nil)
+		if rules == nil || fetch {
+			t.Fatalf("Robots rules are not cached: %s", u)
+		}
+		ok, _ := rc.check(rules, u)
+		if expected := i != 1; ok != expected {
+			t.Fatalf("Inconsistent robots result: expected: %v, actual: %v (url: %s)",
+				expected, ok, u)
+		}
+	}
+	expectedSummary := RobotsSummaryStruct{Hosts: 1, Disallowed: 1}
+	if summary := rc.summary(); summary != expectedSummary {
+		t.Fatalf("Inconsistent robots summary: expected: %#v, actual: %#v",
+			expectedSummary, summary)
+	}
+	// 找不到robots.txt时不做任何限制，服务器错误或网络错误时暂时禁止访问。
+	results := []struct {
+		resp     *http.Response
+		err      error
+		expected bool
+	}{
+		{newRobotsResp(http.StatusNotFound, ""), nil, true},
+		{newRobotsResp(http.StatusForbidden, ""), nil, true},
+		{newRobotsResp(http.StatusInternalServerError, ""), nil, false},
+		{newRobotsResp(http.StatusServiceUnavailable, ""), nil, false},
+		{nil, errors.New("connection refused"), false},
+	}
+	for _, result := range results {
+		rc := newRobotsCache("", nil)
+		u, _ := url.Parse("http://www.example.com/page")
+		if _, fetch := rc.rules(u, nil, nil); !fetch {
+			t.Fatalf("Robots rules are not fetched!")
+		}
+		rc.done(robotsKey(u), result.resp, result.err)
+		rules, _ := rc.rules(u, nil, nil)
+		if allowed, _ := rc.check(rules, u); allowed != result.expected {
+			t.Fatalf("Inconsistent robots result: expected: %v, actual: %v (result: %v, %v)",
+				result.expected, allowed, result.resp, result.err)
+		}
+	}
+}
+
+func TestSchedRobots(t *testing.T) {
+	var privateCount int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/robots.txt":
+				fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			case r.URL.Path == "/":
+				fmt.Fprint(w, "/public\n/private/a\n/private/b\n")
+			case strings.HasPrefix(r.URL.Path, "/private/"):
+				atomic.AddInt32(&privateCount, 1)
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.ObeyRobots = true
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	if n := atomic.LoadInt32(&privateCount); n != 0 {
+		t.Fatalf("Disallowed pages have been downloaded: %d", n)
+	}
+	summary := sched.Summary().Struct()
+	// robots.txt也由下载器下载。
+	if summary.Downloaders[0].Completed != 3 {
+		t.Fatalf("Inconsistent completed download number: expected: %d, actual: %d",
+			3, summary.Downloaders[0].Completed)
+	}
+	expectedSummary := RobotsSummaryStruct{Hosts: 1, Disallowed: 2}
+	if summary.Robots != expectedSummary {
+		t.Fatalf("Inconsistent robots summary: expected: %#v, actual: %#v",
+			expectedSummary, summary.Robots)
+	}
+}
+
+func TestSchedRobotsStop(t *testing.T) {
+	fetching := make(chan struct{})
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/robots.txt" {
+				return
+			}
+			close(fetching)
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+			case <-time.After(10 * time.Second):
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.ObeyRobots = true
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	start := time.Now()
+	// 获取robots.txt不应阻塞发送首次请求的调用方。
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	select {
+	case <-fetching:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Robots.txt has not been fetched!")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Starting scheduler is blocked by robots.txt: %s", elapsed)
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	// 停止调度器会中止robots.txt的获取。
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Fetching robots.txt has not been canceled!")
+	}
+}
@@ -11,32 +11,114 @@ import (
 	"fmt"
 	"errors"
 	"strings"
+	"sync/atomic"
+	"time"
+	"net/url"
 )
 
 type Scheduler interface {
 	Init(requestArgs RequestArgs, dataArgs DataArgs, moduleArgs ModuleArgs)error
-	Start(firstHttpReq *http.Request)error
+	//以给定的HTTP请求为种子启动调度器，种子的深度都为0，至少需要一个种子
+	Start(httpReqs ...*http.Request)error
+	//以给定的种子启动调度器，各种子可以有不同的深度，至少需要一个种子
+	StartSeeds(seeds ...Seed)error
+	//在调度器运行（或暂停）时加入新的种子，它们的主域名会被加入接受的域名列表
+	//结果值代表被放入请求缓冲池的种子的数量，已见过的种子会被忽略
+	AddSeeds(seeds ...Seed)(int, error)
+	//从给定目录中的检查点恢复爬取，用于代替Start方法
+	ResumeFrom(dir string)error
 	Stop()error
+	//平稳地停止调度器：不再下载新的请求，等待正在处理的数据处理完毕或给定的上下文结束
+	StopGracefully(ctx context.Context)error
+	//暂停调度器，各处理流程不再获取新的数据，缓冲池和已见URL保持不变
+	Pause()error
+	//恢复已暂停的调度器
+	Resume()error
 	Status()Status
 	ErrorChan()<-chan error
+	//获取被丢弃的请求的通道，每次调用都会得到一个新的通道，调度器停止时它们会被关闭
+	DropChan()<-chan DroppedRequest
 	Idle()bool
+	//获取完成信号通道，调度器中已没有任何需要处理的数据或调度器已停止时它会被关闭
+	Done()<-chan struct{}
+	//等待调度器完成，直到给定的上下文结束
+	Wait(ctx context.Context)error
 	Summary()SchedSummary
+	//注册一个生命周期事件的观察者，需要在初始化之后调用
+	RegisterObserver(observer Observer)error
+	//在初始化之后（包括运行时）加入一个组件，其权重取自ModuleArgs.Weights，自动模式下相应的工作协程会随之增加
+	AddModule(m module.Module)error
+	//移除一个组件并等待它处理完毕，直到给定的上下文结束
+	//被移除的组件不会再被分配新的数据，同一类型的最后一个组件不能被移除
+	RemoveModule(ctx context.Context, mid module.MID)error
 }
 
 type myScheduler struct {
-	maxDepth uint32
 	acceptedDomainMap SafelyMap.ConcurrentMap
 	registrar module.Registrar
+	//各组件的使用情况，用于在运行时安全地移除组件
+	usage *moduleUsage
 	reqBufferPool buffer.Pool
+	//按顺序把请求放入请求缓冲池的投递器
+	reqFeeder reqFeeder
 	respBufferPool buffer.Pool
 	itemBufferPool buffer.Pool
 	errorBufferPool buffer.Pool
-	urlMap SafelyMap.ConcurrentMap
+	//已见URL集合
+	seen SeenSet
+	//爬取范围的判定器
+	scope *scope
+	//被丢弃的请求的记录器
+	drops *dropRecorder
+	//生命周期事件的观察者
+	observers *observerHub
 	ctx context.Context
 	cancelFunc context.CancelFunc
 	status Status
 	statusLock sync.RWMutex
 	summary SchedSummary
+	checkpointer *checkpointer
+	//暂停时不为nil，恢复时会被关闭
+	resumeCh chan struct{}
+	pauseLock sync.RWMutex
+	//各处理流程中正在处理的数据的数量
+	downloadingNumber uint64
+	analyzingNumber uint64
+	pickingNumber uint64
+	//平稳停止时不为0，此时不再下载新的请求
+	draining uint32
+	//平稳停止时被放弃的数据，受statusLock保护
+	abandoned AbandonedSummaryStruct
+	//尚未处理完毕的数据的记录器
+	tracker *workTracker
+	//工作协程数量的参数
+	workerArgs WorkerArgs
+	//各组件的权重
+	moduleWeights map[module.MID]uint32
+	//熔断器的参数
+	breakerArgs module.BreakerArgs
+	//请求被放回请求缓冲池的最大次数及各请求已被放回的次数
+	maxRequeues uint32
+	requeues *requeueCounter
+	//下载失败的请求的重试策略
+	retry *retryPolicy
+	//按主机和下载器累计的下载耗时及传输情况
+	transfers *transferStats
+	//重定向的处理策略
+	redirect *redirectPolicy
+	//各处理流程实际使用的工作协程数量
+	workers workerNumbers
+	//用于保证运行时增加工作协程的操作互斥
+	workersLock sync.Mutex
+	//按主机控制请求节奏的礼貌性控制器
+	politeness *politeness
+	//robots.txt缓存，不遵守robots.txt时为nil
+	robots *robotsCache
+	//爬取边界的策略及其计算请求优先级的函数
+	frontierStrategy FrontierStrategy
+	priority RequestPriority
+	//用于获取作为去重依据的规范URL
+	canonicalizer Canonicalizer
 }
 
 func NewScheduler()Scheduler{
@@ -76,12 +158,53 @@ func(sched *myScheduler)Init(requestArgs RequestArgs, dataArgs DataArgs, moduleA
 	}else{
 		sched.registrar.Clear()
 	}
-	sched.maxDepth = requestArgs.MaxDepth
+	sched.usage = newModuleUsage()
+	sched.workerArgs = moduleArgs.Workers
+	sched.moduleWeights = moduleArgs.Weights
+	sched.breakerArgs = moduleArgs.Breaker
+	sched.maxRequeues = moduleArgs.MaxRequeues
+	if sched.maxRequeues == 0{
+		sched.maxRequeues = defaultMaxRequeues
+	}
+	sched.requeues = newRequeueCounter()
+	sched.retry = newRetryPolicy(requestArgs.Retry)
+	sched.transfers = newTransferStats()
+	sched.redirect = newRedirectPolicy(requestArgs.Redirect)
+	sched.frontierStrategy = requestArgs.FrontierStrategy
+	sched.priority = requestArgs.Priority
+	sched.canonicalizer = NewCanonicalizer(requestArgs.Canonical)
+	sched.politeness.stop()
+	sched.politeness = newPoliteness(requestArgs.HostPolicies, sched.requeueHeld)
+	sched.politeness.enableAdaptive(requestArgs.Adaptive)
+	sched.robots = nil
+	if requestArgs.ObeyRobots{
+		sched.robots = newRobotsCache(requestArgs.RobotsUserAgent,
+			func(host string, rules *robotsRules){
+				sched.politeness.setCrawlDelay(host, rules.crawlDelay)
+			})
+	}
 	sched.acceptedDomainMap, _ = SafelyMap.NewConcurrentMap(1, nil)
 	for _, domain := range requestArgs.AcceptedDomains{
-		sched.acceptedDomainMap.Put(domain, struct {}{})
+		sched.acceptedDomainMap.Put(normalizeDomain(domain), struct {}{})
+	}
+	sched.drops.close()
+	sched.drops = newDropRecorder(requestArgs.DropSink)
+	sched.observers.close()
+	sched.observers = newObserverHub(int(moduleArgs.ObserverQueueCap), moduleArgs.Observers)
+	sched.scope = newScope(requestArgs.Scope, requestArgs.MaxDepth, func(pd string) bool{
+		return sched.acceptedDomainMap.Get(pd) != nil
+	})
+	if sched.seen != nil{
+		sched.seen.Close()
+	}
+	if sched.seen, err = NewSeenSet(dataArgs.SeenSet); err != nil{
+		return err
+	}
+	sched.checkpointer.close()
+	sched.checkpointer = newCheckpointer(dataArgs, sched.canonicalizer)
+	for _, domain := range requestArgs.AcceptedDomains{
+		sched.checkpointer.addDomain(domain)
 	}
-	sched.urlMap, _ = SafelyMap.NewConcurrentMap(16, nil)
 	sched.initBufferPool(dataArgs)
 	sched.resetContext()
 	sched.summary = newSchedSummary(requestArgs, dataArgs, moduleArgs, sched)
@@ -93,7 +216,15 @@ func(sched *myScheduler)Init(requestArgs RequestArgs, dataArgs DataArgs, moduleA
 	return nil
 }
 
-func(sched *myScheduler)Start(firstHTTPReq *http.Request)(err error){
+func(sched *myScheduler)Start(httpReqs ...*http.Request)error{
+	seeds := make([]Seed, 0, len(httpReqs))
+	for _, httpReq := range httpReqs{
+		seeds = append(seeds, Seed{HTTPReq: httpReq})
+	}
+	return sched.StartSeeds(seeds...)
+}
+
+func(sched *myScheduler)StartSeeds(seeds ...Seed)(err error){
 	defer func(){
 		if p := recover(); p!=nil{
 			err = genError(fmt.Sprintf("调度器错误：%s", p))
@@ -112,23 +243,128 @@ func(sched *myScheduler)Start(firstHTTPReq *http.Request)(err error){
 	if err != nil{
 		return
 	}
-	if firstHTTPReq == nil{
-		return genParameterError("第一个HTTP请求参数是nil")
+	if len(seeds) == 0{
+		return genParameterError("empty seed list")
+	}
+	if err = sched.acceptSeeds(seeds); err != nil{
+		return
+	}
+	if err = sched.checkBufferPoolForStart(); err != nil{
+		return
+	}
+	// 全新的爬取不沿用磁盘集合中上次爬取留下的键，只有从检查点恢复时才会沿用。
+	if set, ok := sched.seen.(*diskSeenSet); ok{
+		if err = set.reset(); err != nil{
+			return genErrorByError(err)
+		}
+	}
+	if err = sched.checkpointer.open(nil); err != nil{
+		return genErrorByError(err)
+	}
+	sched.resolveWorkers()
+	sched.download()
+	sched.analyze()
+	sched.pick()
+	sched.checkpoint()
+	log.Printf("调度器已经启动，种子%d个", len(seeds))
+	for _, seed := range seeds{
+		sched.sendReq(seed.request())
+	}
+	sched.tracker.check()
+	return nil
+}
+
+func(sched *myScheduler)AddSeeds(seeds ...Seed)(int, error){
+	status := sched.Status()
+	if status != SCHED_STATUS_STARTED && status != SCHED_STATUS_PAUSED{
+		return 0, genError("the scheduler is not running")
+	}
+	if err := sched.acceptSeeds(seeds); err != nil{
+		return 0, err
+	}
+	number := 0
+	for _, seed := range seeds{
+		if sched.sendReq(seed.request()){
+			number++
+		}
+	}
+	log.Printf("加入种子%d个，其中%d个被放入请求缓冲池", len(seeds), number)
+	return number, nil
+}
+
+// acceptSeeds 会检查所有的种子，并在都通过后把它们的主域名加入接受的域名列表。
+func(sched *myScheduler)acceptSeeds(seeds []Seed)error{
+	primaryDomains := make([]string, 0, len(seeds))
+	for _, seed := range seeds{
+		primaryDomain, err := seed.check()
+		if err != nil{
+			return err
+		}
+		primaryDomains = append(primaryDomains, primaryDomain)
+	}
+	for _, primaryDomain := range primaryDomains{
+		sched.acceptedDomainMap.Put(primaryDomain, struct {}{})
+		sched.checkpointer.addDomain(primaryDomain)
+	}
+	return nil
+}
+
+func(sched *myScheduler)ResumeFrom(dir string)(err error){
+	defer func(){
+		if p := recover(); p!=nil{
+			err = genError(fmt.Sprintf("调度器错误：%s", p))
+		}
+	}()
+	oldStatus, err := sched.checkAndSetStatus(SCHED_STATUS_STARTING)
+	defer func(){
+		sched.statusLock.Lock()
+		if err != nil{
+			sched.status = oldStatus
+		}else{
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil{
+		return
 	}
-	primaryDomain, err := getPrimaryDomain(firstHTTPReq.Host)
+	log.Printf("从检查点恢复：%s", dir)
+	state, seenURLs, err := loadCheckpoint(dir)
 	if err != nil{
 		return
 	}
-	sched.acceptedDomainMap.Put(primaryDomain, struct {}{})
+	for _, domain := range state.AcceptedDomains{
+		sched.acceptedDomainMap.Put(domain, struct {}{})
+		sched.checkpointer.addDomain(domain)
+	}
+	for _, url := range seenURLs{
+		if _, err = sched.seen.Add(url); err != nil{
+			return genErrorByError(err)
+		}
+	}
 	if err = sched.checkBufferPoolForStart(); err != nil{
 		return
 	}
+	sched.checkpointer.resume(dir, state)
+	if err = sched.checkpointer.open(seenURLs); err != nil{
+		return genErrorByError(err)
+	}
+	sched.resolveWorkers()
 	sched.download()
 	sched.analyze()
 	sched.pick()
-	log.Println("调度器已经启动")
-	firstReq := module.NewRequest(firstHTTPReq, 0)
-	sched.sendReq(firstReq)
+	sched.checkpoint()
+	log.Printf("调度器已经启动，已见URL%d个，待处理请求%d个", len(seenURLs), len(state.Pending))
+	for _, cr := range state.Pending{
+		req, err := cr.toRequest()
+		if err != nil{
+			sched.sendError(err, "")
+			continue
+		}
+		sched.checkpointer.addPending(req)
+		sched.putReq(req)
+	}
+	sched.tracker.check()
 	return nil
 }
 
@@ -147,12 +383,150 @@ func(sched *myScheduler)Stop()error{
 	if err != nil{
 		return err
 	}
+	sched.shutdown()
+	log.Println("调度器已关闭")
+	return nil
+}
+
+func(sched *myScheduler)StopGracefully(ctx context.Context)error{
+	log.Println("平稳关闭调度器")
+	oldStatus, err := sched.checkAndSetStatus(SCHED_STATUS_STOPPING)
+	defer func(){
+		sched.statusLock.Lock()
+		if err != nil{
+			sched.status = oldStatus
+		}else{
+			sched.status = SCHED_STATUS_STOPPED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil{
+		return err
+	}
+	if ctx == nil{
+		ctx = context.Background()
+	}
+	atomic.StoreUint32(&sched.draining, 1)
+	// 已暂停的处理流程需要被放行才能处理完毕。
+	sched.unpause()
+	if !sched.drain(ctx){
+		log.Println("等待处理完毕超时")
+	}
+	downloads := atomic.LoadUint64(&sched.downloadingNumber)
+	requests := sched.tracker.number(workKindRequest)
+	if requests >= downloads{
+		requests -= downloads
+	}
+	abandoned := AbandonedSummaryStruct{
+		Requests:  requests,
+		Downloads: downloads,
+		Responses: sched.tracker.number(workKindResponse),
+		Items:     sched.tracker.number(workKindItem),
+	}
+	sched.statusLock.Lock()
+	sched.abandoned = abandoned
+	sched.statusLock.Unlock()
+	sched.shutdown()
+	log.Printf("调度器已关闭，放弃的数据：%+v", abandoned)
+	return nil
+}
+
+// drain 会等待正在下载、解析和处理的数据都处理完毕。
+// 若在此之前给定的上下文已结束，则结果值为false。
+func (sched *myScheduler) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if sched.drained() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// drained 用于判断平稳停止时是否已没有需要处理的数据。
+// 尚未开始下载的请求不会再被处理，所以不在考虑之列。
+func (sched *myScheduler) drained() bool {
+	return atomic.LoadUint64(&sched.downloadingNumber) == 0 &&
+		sched.tracker.number(workKindResponse) == 0 &&
+		sched.tracker.number(workKindItem) == 0
+}
+
+// shutdown 会取消调度器的上下文并关闭所有的缓冲池。
+func (sched *myScheduler) shutdown() {
+	if err := sched.checkpointer.save(sched.checkpointCounters()); err != nil{
+		log.Println(err)
+	}
+	sched.checkpointer.close()
 	sched.cancelFunc()
+	sched.tracker.stop()
+	sched.politeness.stop()
+	sched.unpause()
 	sched.reqBufferPool.Close()
 	sched.respBufferPool.Close()
 	sched.itemBufferPool.Close()
 	sched.errorBufferPool.Close()
-	log.Println("调度器已关闭")
+	sched.drops.close()
+	sched.observers.close()
+}
+
+// abandonedSummary 用于获取平稳停止时被放弃的数据的摘要。
+func (sched *myScheduler) abandonedSummary() AbandonedSummaryStruct {
+	sched.statusLock.RLock()
+	defer sched.statusLock.RUnlock()
+	return sched.abandoned
+}
+
+// isDraining 用于判断调度器是否正在平稳停止。
+func (sched *myScheduler) isDraining() bool {
+	return atomic.LoadUint32(&sched.draining) == 1
+}
+
+func(sched *myScheduler)Pause()error{
+	log.Println("暂停调度器")
+	oldStatus, err := sched.checkAndSetStatus(SCHED_STATUS_PAUSING)
+	defer func(){
+		sched.statusLock.Lock()
+		if err != nil{
+			sched.status = oldStatus
+		}else{
+			sched.status = SCHED_STATUS_PAUSED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil{
+		return err
+	}
+	sched.pauseLock.Lock()
+	if sched.resumeCh == nil{
+		sched.resumeCh = make(chan struct{})
+	}
+	sched.pauseLock.Unlock()
+	log.Println("调度器已暂停")
+	return nil
+}
+
+func(sched *myScheduler)Resume()error{
+	log.Println("恢复调度器")
+	oldStatus, err := sched.checkAndSetStatus(SCHED_STATUS_RESUMING)
+	defer func(){
+		sched.statusLock.Lock()
+		if err != nil{
+			sched.status = oldStatus
+		}else{
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil{
+		return err
+	}
+	sched.unpause()
+	log.Println("调度器已恢复")
 	return nil
 }
 
@@ -180,7 +554,7 @@ func(sched *myScheduler)ErrorChan()<-chan error {
 			}
 			err, ok := datum.(error)
 			if !ok{
-				sendError(errors.New(fmt.Sprintf("无效的错误类型：%T", datum)), "", sched.errorBufferPool)
+				sched.sendError(errors.New(fmt.Sprintf("无效的错误类型：%T", datum)), "")
 				continue
 			}
 			if sched.canceled(){
@@ -193,6 +567,22 @@ func(sched *myScheduler)ErrorChan()<-chan error {
 	return errCh
 }
 
+func(sched *myScheduler)DropChan()<-chan DroppedRequest{
+	if sched.drops == nil{
+		ch := make(chan DroppedRequest)
+		close(ch)
+		return ch
+	}
+	return sched.drops.subscribe()
+}
+
+func(sched *myScheduler)RegisterObserver(observer Observer)error{
+	if sched.observers == nil{
+		return genError("the scheduler has not been initialized")
+	}
+	return sched.observers.register(observer)
+}
+
 func(sched *myScheduler)Idle()bool{
 	moduleList := sched.registrar.GetAll()
 	for _, modulex := range moduleList{
@@ -200,6 +590,9 @@ func(sched *myScheduler)Idle()bool{
 			return false
 		}
 	}
+	if len(sched.usage.drainingModules()) > 0{
+		return false
+	}
 	if sched.reqBufferPool.Total() > 0{
 		return false
 	}
@@ -209,9 +602,28 @@ func(sched *myScheduler)Idle()bool{
 	if sched.itemBufferPool.Total() > 0{
 		return false
 	}
+	if sched.tracker.totalNumber() > 0{
+		return false
+	}
 	return true
 }
 
+func(sched *myScheduler)Done()<-chan struct{}{
+	return sched.tracker.done()
+}
+
+func(sched *myScheduler)Wait(ctx context.Context)error{
+	if ctx == nil{
+		ctx = context.Background()
+	}
+	select{
+	case <-sched.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func(sched *myScheduler)Summary()SchedSummary{
 	return sched.summary
 }
@@ -230,11 +642,11 @@ func(sched *myScheduler)checkAndSetStatus(status Status)(Status, error){
 // initBufferPool 用于按照给定的参数初始化缓冲池。
 // 如果某个缓冲池可用且未关闭，就先关闭该缓冲池。
 func (sched *myScheduler)initBufferPool(dataArgs DataArgs){
-	// 初始化请求缓冲池。
+	// 初始化请求缓冲池，即爬取边界。
 	if sched.reqBufferPool != nil && !sched.reqBufferPool.Closed(){
 		sched.reqBufferPool.Close()
 	}
-	sched.reqBufferPool, _ = buffer.NewPool(dataArgs.ReqBufferCap, dataArgs.ReqMaxBufferNumber)
+	sched.reqBufferPool, _ = NewFrontier(sched.frontierStrategy, sched.priority, dataArgs.ReqBufferCap, dataArgs.ReqMaxBufferNumber)
 	// 初始化响应缓冲池。
 	if sched.respBufferPool != nil && sched.respBufferPool.Closed(){
 		sched.respBufferPool.Close()
@@ -255,16 +667,39 @@ func (sched *myScheduler)initBufferPool(dataArgs DataArgs){
 // resetContext 用于重置调度器的上下文。
 func (sched *myScheduler) resetContext() {
 	sched.ctx, sched.cancelFunc = context.WithCancel(context.Background())
+	sched.tracker = newWorkTracker()
+	atomic.StoreUint32(&sched.draining, 0)
+	sched.statusLock.Lock()
+	sched.abandoned = AbandonedSummaryStruct{}
+	sched.statusLock.Unlock()
 }
 
-// registerModules 会注册所有给定的组件。
+// registerModules 会设置各类型的组件的负载均衡器并注册所有给定的组件。
 func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
+	balancers := map[module.Type]module.BalanceStrategy{
+		module.TYPE_DOWNLOADER: moduleArgs.Balancers.Downloader,
+		module.TYPE_ANALYZER:   moduleArgs.Balancers.Analyzer,
+		module.TYPE_PIPELINE:   moduleArgs.Balancers.Pipeline,
+	}
+	for mType, strategy := range balancers {
+		balancer, err := module.NewBalancer(strategy)
+		if err != nil {
+			return genErrorByError(err)
+		}
+		if err = sched.registrar.SetBalancer(mType, balancer); err != nil {
+			return genErrorByError(err)
+		}
+	}
+	if err := sched.registrar.SetBreakerArgs(moduleArgs.Breaker); err != nil {
+		return genErrorByError(err)
+	}
+	sched.registrar.SetBreakerListener(sched.breakerChanged)
 	log.Println("注册下载器..")
 	for _, d := range moduleArgs.Downloaders {
 		if d == nil {
 			continue
 		}
-		ok, err := sched.registrar.Register(d)
+		ok, err := sched.registrar.RegisterWithWeight(d, moduleArgs.weight(d.ID()))
 		if err != nil {
 			return genErrorByError(err)
 		}
@@ -278,7 +713,7 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 		if a == nil {
 			continue
 		}
-		ok, err := sched.registrar.Register(a)
+		ok, err := sched.registrar.RegisterWithWeight(a, moduleArgs.weight(a.ID()))
 		if err != nil {
 			return genErrorByError(err)
 		}
@@ -292,7 +727,7 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 		if p == nil {
 			continue
 		}
-		ok, err := sched.registrar.Register(p)
+		ok, err := sched.registrar.RegisterWithWeight(p, moduleArgs.weight(p.ID()))
 		if err != nil {
 			return genErrorByError(err)
 		}
@@ -309,12 +744,12 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 // 如果某个缓冲池不可用，就直接返回错误值报告此情况。
 // 如果某个缓冲池已关闭，就按照原先的参数重新初始化它。
 func (sched *myScheduler) checkBufferPoolForStart() error {
-	// 检查请求缓冲池。
+	// 检查请求缓冲池，即爬取边界。
 	if sched.reqBufferPool == nil {
 		return genError("nil request buffer pool")
 	}
 	if sched.reqBufferPool.Closed() {
-		sched.reqBufferPool, _ = buffer.NewPool(sched.reqBufferPool.BufferCap(), sched.reqBufferPool.MaxBufferNumber())
+		sched.reqBufferPool, _ = NewFrontier(sched.frontierStrategy, sched.priority, sched.reqBufferPool.BufferCap(), sched.reqBufferPool.MaxBufferNumber())
 	}
 	// 检查响应缓冲池。
 	if sched.respBufferPool == nil {
@@ -350,24 +785,71 @@ func (sched *myScheduler) canceled() bool {
 	}
 }
 
+// unpause 会放行所有因暂停而等待的处理流程。
+func (sched *myScheduler) unpause() {
+	sched.pauseLock.Lock()
+	defer sched.pauseLock.Unlock()
+	if sched.resumeCh != nil {
+		close(sched.resumeCh)
+		sched.resumeCh = nil
+	}
+}
+
+// waitIfPaused 会在调度器已暂停时阻塞，直到调度器被恢复或上下文被取消。
+// 结果值代表处理流程是否可以继续。
+func (sched *myScheduler) waitIfPaused() bool {
+	sched.pauseLock.RLock()
+	resumeCh := sched.resumeCh
+	sched.pauseLock.RUnlock()
+	if resumeCh != nil {
+		select {
+		case <-resumeCh:
+		case <-sched.ctx.Done():
+			return false
+		}
+	}
+	return !sched.canceled()
+}
+
 //会从请求缓冲池取出请求并下载，然后把得到的响应放入响应缓冲池。
 func(sched *myScheduler)download(){
-	go func(){
-		for{
-			if sched.canceled(){
-				break
-			}
-			datum, err := sched.reqBufferPool.Get()
-			if err != nil{
-				break
-			}
-			req, ok := datum.(*module.Request)
-			if !ok{
-				sendError(errors.New(fmt.Sprintf("无效的请求类型：%T", datum)), "", sched.errorBufferPool)
-			}
-			sched.downloadOne(req)
+	number := atomic.LoadUint32(&sched.workers.download)
+	for i := uint32(0); i < number; i++{
+		go sched.downloadWorker()
+	}
+}
+
+// downloadWorker 代表一个下载工作协程的处理流程。
+func(sched *myScheduler)downloadWorker(){
+	for{
+		if !sched.waitIfPaused() || sched.isDraining(){
+			break
 		}
-	}()
+		datum, err := sched.reqBufferPool.Get()
+		if err != nil{
+			break
+		}
+		if !sched.waitIfPaused(){
+			break
+		}
+		if sched.isDraining(){
+			// 放回请求缓冲池，它仍会被计为尚未处理的请求。
+			sched.reqBufferPool.Put(datum)
+			break
+		}
+		req, ok := datum.(*module.Request)
+		if !ok{
+			sched.sendError(errors.New(fmt.Sprintf("无效的请求类型：%T", datum)), "")
+		}
+		key, ok := sched.politeness.acquire(req)
+		if !ok{
+			// 请求已被扣留，它仍会被计为尚未处理的请求。
+			continue
+		}
+		sched.downloadOne(req)
+		sched.politeness.release(key)
+		sched.tracker.decr(workKindRequest)
+	}
 }
 
 //会根据给定的请求执行下载并把响应放入响应缓冲池。
@@ -375,47 +857,98 @@ func(sched *myScheduler)downloadOne(req *module.Request){
 	if req == nil || sched.canceled(){
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_DOWNLOADER)
+	atomic.AddUint64(&sched.downloadingNumber, 1)
+	defer atomic.AddUint64(&sched.downloadingNumber, ^uint64(0))
+	m, err := sched.acquireModule(module.TYPE_DOWNLOADER)
+	defer sched.releaseModule(m)
 	if err != nil || m == nil{
-		sendError(errors.New(fmt.Sprintf("获取下载器组件失败：%s", err)), "", sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.sendError(errors.New(fmt.Sprintf("获取下载器组件失败：%s", err)), "")
+		sched.requeueReq(req, sched.requeueDelay())
 		return
 	}
 	downloader, ok := m.(module.Downloader)
 	if !ok{
-		sendError(errors.New(fmt.Sprintf("无效的下载器类型：%Y, MID: %s", m, m.ID())), m.ID(), sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.sendError(errors.New(fmt.Sprintf("无效的下载器类型：%T, MID: %s", m, m.ID())), m.ID())
+		sched.requeueReq(req, sched.requeueDelay())
 		return
 	}
-	resp, err := downloader.Download(req)
-	if resp != nil{
-		sendResp(resp, sched.respBufferPool)
+	sched.observers.downloadStarted(req, m.ID())
+	start := time.Now()
+	resp, err := downloader.Download(sched.redirectReq(req))
+	sched.registrar.Report(m.ID(), err)
+	sched.politeness.observe(req, start, resp, err)
+	sched.transfers.record(req, m.ID(), resp)
+	event := DownloadEvent{
+		Request: req,
+		MID: m.ID(),
+		Latency: time.Since(start),
+		Err: err,
+	}
+	if resp != nil && resp.HTTPResp() != nil{
+		event.StatusCode = resp.HTTPResp().StatusCode
+	}
+	sched.observers.downloadFinished(event)
+	if err != nil && resp == nil &&
+		sched.registrar.GetBreakerState(m.ID()) == module.BREAKER_OPEN{
+		// 下载器已被熔断时，把请求转给其他下载器。
+		if sched.requeueReq(req, 0){
+			sched.sendError(err, m.ID())
+			return
+		}
+	}else if sched.retryDownload(req, resp, err){
+		// 可重试的失败会在等待之后重试，不计入错误。
+		return
+	}
+	sched.requeues.remove(req)
+	sched.checkpointer.donePending(req)
+	// robots.txt的响应只用于更新缓存，无需分析。
+	if key := robotsKeyOf(req); key != ""{
+		var httpResp *http.Response
+		if resp != nil{
+			httpResp = resp.HTTPResp()
+		}
+		sched.robots.done(key, httpResp, err)
+		return
+	}
+	// 作为新请求放入请求缓冲池的重定向无需再分析。
+	if resp != nil && !sched.emitRedirect(req, resp){
+		sendResp(resp, sched.respBufferPool, sched.tracker)
 	}
 	if err != nil{
-		sendError(err, m.ID(), sched.errorBufferPool)
+		sched.sendError(err, m.ID())
 	}
 }
 
 // analyze 会从响应缓冲池取出响应并解析，
 // 然后把得到的条目或请求放入相应的缓冲池。
 func (sched *myScheduler) analyze() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
-			}
-			datum, err := sched.respBufferPool.Get()
-			if err != nil {
-				log.Println("从响应缓冲池获取响应失败")
-				break
-			}
-			resp, ok := datum.(*module.Response)
-			if !ok {
-				sendError(errors.New(fmt.Sprintf("无效的响应类型: %T", datum)), "", sched.errorBufferPool)
-			}
-			sched.analyzeOne(resp)
+	number := atomic.LoadUint32(&sched.workers.analyze)
+	for i := uint32(0); i < number; i++ {
+		go sched.analyzeWorker()
+	}
+}
+
+// analyzeWorker 代表一个解析工作协程的处理流程。
+func (sched *myScheduler) analyzeWorker() {
+	for {
+		if !sched.waitIfPaused() {
+			break
 		}
-	}()
+		datum, err := sched.respBufferPool.Get()
+		if err != nil {
+			log.Println("从响应缓冲池获取响应失败")
+			break
+		}
+		if !sched.waitIfPaused() {
+			break
+		}
+		resp, ok := datum.(*module.Response)
+		if !ok {
+			sched.sendError(errors.New(fmt.Sprintf("无效的响应类型: %T", datum)), "")
+		}
+		sched.analyzeOne(resp)
+		sched.tracker.decr(workKindResponse)
+	}
 }
 
 // analyzeOne 会根据给定的响应执行解析并把结果放入相应的缓冲池。
@@ -426,19 +959,28 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 	if sched.canceled() {
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_ANALYZER)
+	atomic.AddUint64(&sched.analyzingNumber, 1)
+	defer atomic.AddUint64(&sched.analyzingNumber, ^uint64(0))
+	m, err := sched.acquireModule(module.TYPE_ANALYZER)
+	defer sched.releaseModule(m)
 	if err != nil || m == nil {
-		sendError(errors.New(fmt.Sprintf("获取解析组件失败: %s", err)), "", sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sched.sendError(errors.New(fmt.Sprintf("获取解析组件失败: %s", err)), "")
+		sendResp(resp, sched.respBufferPool, sched.tracker)
 		return
 	}
 	analyzer, ok := m.(module.Analyzer)
 	if !ok {
-		sendError(errors.New(fmt.Sprintf("无效的解析器组件类型: %T (MID: %s)", m, m.ID())), m.ID(), sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sched.sendError(errors.New(fmt.Sprintf("无效的解析器组件类型: %T (MID: %s)", m, m.ID())), m.ID())
+		sendResp(resp, sched.respBufferPool, sched.tracker)
 		return
 	}
 	dataList, errs := analyzer.Analyze(resp)
+	sched.registrar.Report(m.ID(), firstError(errs))
+	var parent *url.URL
+	if httpResp := resp.HTTPResp(); httpResp != nil && httpResp.Request != nil {
+		parent = httpResp.Request.URL
+	}
+	event := AnalyzeEvent{Response: resp, MID: m.ID(), Errors: len(errs)}
 	if dataList != nil {
 		for _, data := range dataList {
 			if data == nil {
@@ -446,40 +988,55 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 			}
 			switch d := data.(type) {
 			case *module.Request:
-				sched.sendReq(d)
+				event.Requests++
+				sched.sendReqFrom(d, parent)
 			case module.Item:
-				sendItem(d, sched.itemBufferPool)
+				event.Items++
+				if sendItem(d, sched.itemBufferPool, sched.tracker) {
+					sched.observers.itemProduced(d)
+				}
 			default:
-				sendError(errors.New(fmt.Sprintf("不支持的数据类型：%T, data:%#v", d, d)), m.ID(), sched.errorBufferPool)
+				sched.sendError(errors.New(fmt.Sprintf("不支持的数据类型：%T, data:%#v", d, d)), m.ID())
 			}
 		}
 	}
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendError(err, m.ID())
 		}
 	}
+	sched.observers.responseAnalyzed(event)
 }
 
 // pick 会从条目缓冲池取出条目并处理。
 func (sched *myScheduler) pick() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
-			}
-			datum, err := sched.itemBufferPool.Get()
-			if err != nil {
-				log.Println("从条目缓存池获取数据失败")
-				break
-			}
-			item, ok := datum.(module.Item)
-			if !ok {
-				sendError(errors.New(fmt.Sprintf("无效的条目类型: %T", datum)), "", sched.errorBufferPool)
-			}
-			sched.pickOne(item)
+	number := atomic.LoadUint32(&sched.workers.pick)
+	for i := uint32(0); i < number; i++ {
+		go sched.pickWorker()
+	}
+}
+
+// pickWorker 代表一个条目处理工作协程的处理流程。
+func (sched *myScheduler) pickWorker() {
+	for {
+		if !sched.waitIfPaused() {
+			break
 		}
-	}()
+		datum, err := sched.itemBufferPool.Get()
+		if err != nil {
+			log.Println("从条目缓存池获取数据失败")
+			break
+		}
+		if !sched.waitIfPaused() {
+			break
+		}
+		item, ok := datum.(module.Item)
+		if !ok {
+			sched.sendError(errors.New(fmt.Sprintf("无效的条目类型: %T", datum)), "")
+		}
+		sched.pickOne(item)
+		sched.tracker.decr(workKindItem)
+	}
 }
 
 // pickOne 会处理给定的条目。
@@ -487,28 +1044,40 @@ func (sched *myScheduler) pickOne(item module.Item) {
 	if sched.canceled() {
 		return
 	}
-	m, err := sched.registrar.Get(module.TYPE_PIPELINE)
+	atomic.AddUint64(&sched.pickingNumber, 1)
+	defer atomic.AddUint64(&sched.pickingNumber, ^uint64(0))
+	m, err := sched.acquireModule(module.TYPE_PIPELINE)
+	defer sched.releaseModule(m)
 	if err != nil || m == nil {
-		sendError(errors.New(fmt.Sprintf("获取条目处理器失败: %s", err)), "", sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendError(errors.New(fmt.Sprintf("获取条目处理器失败: %s", err)), "")
+		sendItem(item, sched.itemBufferPool, sched.tracker)
 		return
 	}
 	pipeline, ok := m.(module.Pipeline)
 	if !ok {
-		sendError(errors.New(fmt.Sprintf("无效的条目类型: %T (MID: %s)", m, m.ID())), m.ID(), sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendError(errors.New(fmt.Sprintf("无效的条目类型: %T (MID: %s)", m, m.ID())), m.ID())
+		sendItem(item, sched.itemBufferPool, sched.tracker)
 		return
 	}
 	errs := pipeline.Send(item)
+	sched.registrar.Report(m.ID(), firstError(errs))
+	sched.observers.itemProcessed(item, m.ID(), errs)
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendError(err, m.ID())
 		}
 	}
 }
 
 func(sched *myScheduler)sendReq(req *module.Request)bool{
+	return sched.sendReqFrom(req, nil)
+}
+
+// sendReqFrom 会检查给定的请求并在通过后把它放入请求缓冲池。
+// 参数parent代表请求的链接所在网页的URL，可以为nil。
+func(sched *myScheduler)sendReqFrom(req *module.Request, parent *url.URL)bool{
 	if req == nil{
+		sched.dropReq(req, DROP_REASON_INVALID, "nil request")
 		return false
 	}
 	if sched.canceled(){
@@ -516,57 +1085,164 @@ func(sched *myScheduler)sendReq(req *module.Request)bool{
 	}
 	httpReq := req.HTTPReq()
 	if httpReq == nil{
+		sched.dropReq(req, DROP_REASON_INVALID, "nil HTTP request")
 		return false
 	}
 	if httpReq.URL == nil{
+		sched.dropReq(req, DROP_REASON_INVALID, "nil URL")
 		return false
 	}
 	scheme := strings.ToLower(httpReq.URL.Scheme)
 	if scheme != "http" && scheme != "https"{
+		sched.dropReq(req, DROP_REASON_SCHEME,
+			fmt.Sprintf("unsupported scheme %q", httpReq.URL.Scheme))
 		return false
 	}
-	if v := sched.urlMap.Get(httpReq.URL.String()); v != nil{
+	urlKey := sched.canonicalizer.Canonicalize(httpReq.URL)
+	if sched.seen.Contains(urlKey){
+		sched.dropReq(req, DROP_REASON_DUPLICATE, urlKey)
 		return false
 	}
-	pd, _ := getPrimaryDomain(httpReq.URL.Host)
-	if sched.acceptedDomainMap.Get(pd) == nil{
-		if pd == "bing.net"{
-			panic(httpReq.URL)
-		}
+	if ok, reason, detail := sched.scope.allowed(httpReq.URL, req.Depth(), parent); !ok{
+		sched.dropReq(req, reason, detail)
 		return false
 	}
-	if req.Depth() > sched.maxDepth{
+	return sched.checkRobots(req, urlKey)
+}
+
+// enqueueReq 会把已通过检查的请求加入已见URL集合并放入请求缓冲池。
+// 参数urlKey代表请求的URL规范化之后的形式。
+func(sched *myScheduler)enqueueReq(req *module.Request, urlKey string)bool{
+	added, err := sched.seen.Add(urlKey)
+	if err != nil{
+		sched.sendError(err, "")
 		return false
 	}
+	// 其他流程可能已在检查之后加入了同样的URL。
+	if !added{
+		sched.dropReq(req, DROP_REASON_DUPLICATE, urlKey)
+		return false
+	}
+	// 没有ID的请求（例如首次请求）会在这里得到ID。
+	if meta := req.Meta(); meta.ID == ""{
+		meta.ID = module.NewRequestID()
+		req = req.WithMeta(meta)
+	}
+	// 请求要在放入请求缓冲池之前被记为待处理的，否则它可能在记录之前就已下载完毕，
+	// 从而一直留在检查点中。
+	sched.checkpointer.recordSeen(urlKey, req)
+	sched.putReq(req)
+	sched.observers.requestEnqueued(req)
+	return true
+}
+
+// dropReq 会记录被丢弃的请求并通知观察者。
+func(sched *myScheduler)dropReq(req *module.Request, reason DropReason, detail string){
+	// 被丢弃的robots.txt请求视为获取失败，以免等待规则的请求一直被暂存。
+	if key := robotsKeyOf(req); key != ""{
+		sched.robots.done(key, nil,
+			errors.New(fmt.Sprintf("request dropped (%s): %s", reason, detail)))
+	}
+	dropped := sched.drops.record(req, reason, detail)
+	sched.observers.requestDropped(dropped)
+}
+
+// sendError 会把错误放入错误缓冲池并通知观察者。
+func(sched *myScheduler)sendError(err error, mid module.MID)bool{
+	if err == nil{
+		return false
+	}
+	sched.observers.error(err, mid)
+	return sendError(err, mid, sched.errorBufferPool, sched.tracker)
+}
+
+// putReq 会把请求放入请求缓冲池，不做任何检查。
+// 请求会按照调用的顺序进入请求缓冲池，以便爬取边界按照策略排列同一个响应中的各个链接。
+func(sched *myScheduler)putReq(req *module.Request){
+	sched.tracker.incr(workKindRequest)
+	sched.reqFeeder.put(sched.reqBufferPool, req, func(err error){
+		log.Println("请求发送给请求缓冲器失败")
+		sched.tracker.decr(workKindRequest)
+	})
+}
+
+// requeueHeld 会把被礼貌性控制器放行的请求放回请求缓冲池。
+// 被扣留的请求一直被计为尚未处理的请求，所以这里无需再计入。
+func(sched *myScheduler)requeueHeld(req *module.Request){
 	go func(req *module.Request){
 		if err := sched.reqBufferPool.Put(req); err != nil{
-			log.Println("请求发送给请求缓冲器失败")
+			log.Println("被扣留的请求放回请求缓冲池失败")
+			sched.politeness.abort(req)
+			sched.tracker.decr(workKindRequest)
 		}
 	}(req)
-	sched.urlMap.Put(httpReq.URL.String(), struct {}{})
-	return true
 }
 
-func sendResp(resp *module.Response, respBufferPool buffer.Pool) bool {
+// checkpoint 会按照设定的间隔定期写入检查点。
+func(sched *myScheduler)checkpoint(){
+	if !sched.checkpointer.enabled(){
+		return
+	}
+	go func(){
+		ticker := time.NewTicker(sched.checkpointer.interval)
+		defer ticker.Stop()
+		for{
+			select{
+			case <-sched.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := sched.checkpointer.save(sched.checkpointCounters()); err != nil{
+				sched.sendError(err, "")
+			}
+		}
+	}()
+}
+
+// checkpointCounters 用于汇总各类组件成功完成的计数。
+func(sched *myScheduler)checkpointCounters()checkpointCounters{
+	var counters checkpointCounters
+	for mid, m := range sched.registrar.GetAll(){
+		_, mType := module.GetType(mid)
+		switch mType {
+		case module.TYPE_DOWNLOADER:
+			counters.Downloaded += m.CompletedCount()
+		case module.TYPE_ANALYZER:
+			counters.Analyzed += m.CompletedCount()
+		case module.TYPE_PIPELINE:
+			counters.Processed += m.CompletedCount()
+		}
+	}
+	return counters
+}
+
+// sendResp 会向响应缓冲池发送响应。
+// 参数tracker不为nil时，响应会被计入其中直至被解析完毕。
+func sendResp(resp *module.Response, respBufferPool buffer.Pool, tracker *workTracker) bool {
 	if resp == nil || respBufferPool == nil || respBufferPool.Closed() {
 		return false
 	}
+	tracker.incr(workKindResponse)
 	go func(resp *module.Response) {
 		if err := respBufferPool.Put(resp); err != nil {
 			log.Println("响应写入响应缓存池失败")
+			tracker.decr(workKindResponse)
 		}
 	}(resp)
 	return true
 }
 
 // sendItem 会向条目缓冲池发送条目。
-func sendItem(item module.Item, itemBufferPool buffer.Pool) bool {
+// 参数tracker不为nil时，条目会被计入其中直至被处理完毕。
+func sendItem(item module.Item, itemBufferPool buffer.Pool, tracker *workTracker) bool {
 	if item == nil || itemBufferPool == nil || itemBufferPool.Closed() {
 		return false
 	}
+	tracker.incr(workKindItem)
 	go func(item module.Item) {
 		if err := itemBufferPool.Put(item); err != nil {
 			log.Println("条目写入条目缓存池失败")
+			tracker.decr(workKindItem)
 		}
 	}(item)
 	return true
@@ -1,14 +1,19 @@
 package scheduler
 
 import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"runtime"
 	"net/http"
 	"time"
 	"log"
 	"gopcpv2-web-spider/module"
-	"SafelyMap"
 	"gopcpv2-web-spider/toolkit/buffer"
+	"sync"
+	"fmt"
+	"gopcpv2-web-spider/module/local/analyzer"
 )
 
 
@@ -93,7 +98,7 @@ func TestSchedInit(t *testing.T) {
 			dataArgs,
 			invalidModuleArgs)
 		if err == nil {
-			t.Fatalf("No error when initialize scheduler with illegal module arguments %s!",
+			t.Fatalf("No error when initialize scheduler with illegal module arguments %v!",
 				invalidModuleArgs)
 		}
 	}
@@ -239,6 +244,97 @@ func TestSchedStatus(t *testing.T) {
 	}
 }
 
+func TestSchedPauseAndResume(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"bing.com"}, 0)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(3, 2, 1, t)
+	sched := NewScheduler()
+	url := "http://cn.bing.com/search?q=golang"
+	firstHTTPReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)",
+			err, url)
+	}
+	// 测试未启动状态下的暂停和恢复。
+	if err = sched.Pause(); err == nil {
+		t.Fatal("No error when pause scheduler before initialize!")
+	}
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err = sched.Pause(); err == nil {
+		t.Fatal("No error when pause scheduler after initialize!")
+	}
+	if err = sched.Resume(); err == nil {
+		t.Fatal("No error when resume scheduler after initialize!")
+	}
+	if err = sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	if err = sched.Resume(); err == nil {
+		t.Fatal("No error when resume scheduler which is not paused!")
+	}
+	// 测试已启动状态下的暂停。
+	if err = sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if sched.Status() != SCHED_STATUS_PAUSED {
+		t.Fatalf("Inconsistent status: expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(sched.Status()))
+	}
+	// 测试暂停时处理流程会被阻塞。
+	mySched := sched.(*myScheduler)
+	passed := make(chan bool, 1)
+	go func() {
+		passed <- mySched.waitIfPaused()
+	}()
+	select {
+	case <-passed:
+		t.Fatal("The stage was not blocked in paused scheduler!")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// 测试已暂停状态下的重复暂停、启动和初始化。
+	if err = sched.Pause(); err == nil {
+		t.Fatal("No error when repeatedly pause scheduler!")
+	}
+	if err = sched.Start(firstHTTPReq); err == nil {
+		t.Fatal("No error when start scheduler after pause!")
+	}
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err == nil {
+		t.Fatal("No error when initialize scheduler after pause!")
+	}
+	if mySched.seen.Len() != 1 {
+		t.Fatalf("Inconsistent URL map length after pause: expected: %d, actual: %d",
+			1, mySched.seen.Len())
+	}
+	// 测试已暂停状态下的恢复。
+	if err = sched.Resume(); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	select {
+	case ok := <-passed:
+		if !ok {
+			t.Fatal("The stage couldn't go on in resumed scheduler!")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The stage was still blocked in resumed scheduler!")
+	}
+	if sched.Status() != SCHED_STATUS_STARTED {
+		t.Fatalf("Inconsistent status: expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_STARTED), GetStatusDescription(sched.Status()))
+	}
+	// 测试已暂停状态下的停止。
+	if err = sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if err = sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping paused scheduler: %s", err)
+	}
+	if mySched.waitIfPaused() {
+		t.Fatal("The stage still can go on in stopped scheduler!")
+	}
+}
+
 func TestSchedSimple(t *testing.T) {
 	requestArgs := genRequestArgs([]string{}, 0)
 	dataArgs := genDataArgs(10, 2, 1)
@@ -342,7 +438,7 @@ func TestSchedSendReq(t *testing.T) {
 			err)
 	}
 	mySched := sched.(*myScheduler)
-	urlMapLen := mySched.urlMap.Len()
+	urlMapLen := mySched.seen.Len()
 	if urlMapLen != 1 {
 		t.Fatalf("Inconsistent URL map length: expected: %d, actual: %d",
 			1, urlMapLen)
@@ -366,7 +462,7 @@ func TestSchedSendReq(t *testing.T) {
 	if mySched.sendReq(req) {
 		t.Fatalf("It still can send repeated request!")
 	}
-	mySched.urlMap, _ = SafelyMap.NewConcurrentMap(16, nil)
+	mySched.seen = NewMapSeenSet()
 	// 测试scheme不匹配的情况。
 	httpReq.URL.Scheme = "tcp"
 	if mySched.sendReq(req) {
@@ -388,7 +484,7 @@ func TestSchedSendReq(t *testing.T) {
 func TestSendResp(t *testing.T) {
 	// 测试响应无效的情况。
 	buffer, _ := buffer.NewPool(10, 2)
-	if sendResp(nil, buffer) {
+	if sendResp(nil, buffer, nil) {
 		t.Fatalf("It still can send nil response!")
 	}
 	// 测试响应无效的情况。
@@ -400,7 +496,7 @@ func TestSendResp(t *testing.T) {
 	}
 	resp := module.NewResponse(httpResp, 0)
 	buffer.Close()
-	done := sendResp(resp, buffer)
+	done := sendResp(resp, buffer, nil)
 	runtime.Gosched()
 	if done {
 		t.Fatalf("It still can send response with closed buffer!")
@@ -410,15 +506,199 @@ func TestSendResp(t *testing.T) {
 func TestSendItem(t *testing.T) {
 	// 测试响应无效的情况。
 	buffer, _ := buffer.NewPool(10, 2)
-	if sendItem(nil, buffer) {
+	if sendItem(nil, buffer, nil) {
 		t.Fatalf("It still can send nil item!")
 	}
 	// 测试响应无效的情况。
 	item := module.Item(map[string]interface{}{})
 	buffer.Close()
-	done := sendItem(item, buffer)
+	done := sendItem(item, buffer, nil)
 	runtime.Gosched()
 	if done {
 		t.Fatalf("It still can send item with closed buffer!")
 	}
-}
\ No newline at end of file
+}
+
+func TestSchedStopGracefully(t *testing.T) {
+	var delay int64 = int64(200 * time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body></body></html>"))
+		}))
+	defer server.Close()
+	newStartedSched := func() *myScheduler {
+		requestArgs := genRequestArgs([]string{"127.0.0.1"}, 0)
+		dataArgs := genDataArgs(10, 2, 1)
+		moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+		sched := NewScheduler()
+		if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+			t.Fatalf("An error occurs when initializing scheduler: %s", err)
+		}
+		firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+		if err := sched.Start(firstHTTPReq); err != nil {
+			t.Fatalf("An error occurs when starting scheduler: %s", err)
+		}
+		mySched := sched.(*myScheduler)
+		for i := 0; atomic.LoadUint64(&mySched.downloadingNumber) == 0; i++ {
+			if i > 200 {
+				t.Fatal("The first request has not been downloaded!")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return mySched
+	}
+	// 测试正在处理的数据能够处理完毕。
+	sched := newStartedSched()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sched.StopGracefully(ctx); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler gracefully: %s", err)
+	}
+	if sched.Status() != SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status: expected: %s, actual: %s",
+			GetStatusDescription(SCHED_STATUS_STOPPED), GetStatusDescription(sched.Status()))
+	}
+	summary := sched.Summary().Struct()
+	if summary.Downloaders[0].Completed != 1 {
+		t.Fatalf("Inconsistent completed download number: expected: %d, actual: %d",
+			1, summary.Downloaders[0].Completed)
+	}
+	if summary.Analyzers[0].Called != 1 {
+		t.Fatalf("Inconsistent analyzed response number: expected: %d, actual: %d",
+			1, summary.Analyzers[0].Called)
+	}
+	if summary.Abandoned != (AbandonedSummaryStruct{}) {
+		t.Fatalf("Some data was abandoned: %#v", summary.Abandoned)
+	}
+	if err := sched.StopGracefully(ctx); err == nil {
+		t.Fatal("No error when repeatedly stop scheduler gracefully!")
+	}
+	// 测试超时的情况。
+	atomic.StoreInt64(&delay, int64(time.Second))
+	sched = newStartedSched()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := sched.StopGracefully(ctx); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler gracefully: %s", err)
+	}
+	summary = sched.Summary().Struct()
+	if summary.Abandoned.Downloads != 1 {
+		t.Fatalf("Inconsistent abandoned download number: expected: %d, actual: %d",
+			1, summary.Abandoned.Downloads)
+	}
+}
+
+func TestSchedWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body></body></html>"))
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 0)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if isClosed(sched.Done()) {
+		t.Fatal("The done channel has been closed before start!")
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sched.Wait(ctx); err == nil {
+		t.Fatal("No error when waiting for a busy scheduler!")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	if !sched.Idle() {
+		t.Fatal("The scheduler is not idle after done!")
+	}
+	summary := sched.Summary().Struct()
+	if summary.Downloaders[0].Completed != 1 {
+		t.Fatalf("Inconsistent completed download number: expected: %d, actual: %d",
+			1, summary.Downloaders[0].Completed)
+	}
+	if summary.Analyzers[0].Called != 1 {
+		t.Fatalf("Inconsistent analyzed response number: expected: %d, actual: %d",
+			1, summary.Analyzers[0].Called)
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+}
+
+func TestSchedRequestMeta(t *testing.T) {
+	var lock sync.Mutex
+	referers := map[string]string{}
+	metas := map[string]module.Meta{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			referers[r.URL.Path] = r.Header.Get("Referer")
+			lock.Unlock()
+			if r.URL.Path == "/" {
+				fmt.Fprintln(w, "/a")
+			}
+		}))
+	defer server.Close()
+	// 解析函数可以读取响应对应的请求的元数据。
+	parser := func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+		if meta, ok := module.ResponseMeta(httpResp); ok {
+			lock.Lock()
+			metas[httpResp.Request.URL.String()] = meta
+			lock.Unlock()
+		}
+		return parseLines(httpResp, respDepth)
+	}
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parser}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	seedMeta, ok := metas[server.URL]
+	if !ok || seedMeta.ID == "" || seedMeta.ParentURL != "" {
+		t.Fatalf("Inconsistent meta of first request: %#v", seedMeta)
+	}
+	childMeta, ok := metas[server.URL+"/a"]
+	if !ok || childMeta.ID == "" || childMeta.ID == seedMeta.ID {
+		t.Fatalf("Inconsistent ID of child request: %#v", childMeta)
+	}
+	if childMeta.ParentURL != server.URL || childMeta.Referrer != server.URL {
+		t.Fatalf("Inconsistent parent URL or referrer of child request: %#v", childMeta)
+	}
+	if referers["/a"] != server.URL {
+		t.Fatalf("Inconsistent Referer header of child request: expected: %s, actual: %s",
+			server.URL, referers["/a"])
+	}
+}
@@ -0,0 +1,302 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ScopeAction 代表范围规则匹配后的动作。
+type ScopeAction string
+
+const (
+	// SCOPE_ACTION_INCLUDE 代表匹配的URL在爬取范围之内，即使它的主域名未被接受。
+	SCOPE_ACTION_INCLUDE ScopeAction = "include"
+	// SCOPE_ACTION_EXCLUDE 代表匹配的URL在爬取范围之外。
+	SCOPE_ACTION_EXCLUDE ScopeAction = "exclude"
+)
+
+// ScopeRule 代表一条范围规则。
+// 规则中设定的各个条件需要同时满足，规则才算匹配，至少需要设定一个条件。
+type ScopeRule struct {
+	// Name 代表规则的名称，用于说明请求被拒绝的原因。
+	Name string `json:"name,omitempty"`
+	// Action 代表规则匹配后的动作。
+	Action ScopeAction `json:"action"`
+	// Regexp 代表完整的URL需要匹配的正则表达式。
+	Regexp string `json:"regexp,omitempty"`
+	// Glob 代表完整的URL需要匹配的通配模式。
+	// 其中“*”匹配任意个字符（包括“/”），“?”匹配一个字符。
+	Glob string `json:"glob,omitempty"`
+	// Host 代表URL的主机名需要与之完全相同，不区分大小写，不含端口。
+	Host string `json:"host,omitempty"`
+	// Domain 代表URL的主机名需要是该域名本身或它的子域名。
+	Domain string `json:"domain,omitempty"`
+	// PathPrefix 代表URL的路径需要以之开头。
+	PathPrefix string `json:"path_prefix,omitempty"`
+}
+
+// Check 用于检查范围规则。
+func (rule *ScopeRule) Check() error {
+	if rule.Action != SCOPE_ACTION_INCLUDE && rule.Action != SCOPE_ACTION_EXCLUDE {
+		return genError(fmt.Sprintf("invalid scope rule action: %q (rule: %s)",
+			rule.Action, rule.String()))
+	}
+	if rule.Regexp == "" && rule.Glob == "" && rule.Host == "" &&
+		rule.Domain == "" && rule.PathPrefix == "" {
+		return genError(fmt.Sprintf("scope rule without condition: %s", rule.String()))
+	}
+	if rule.Regexp != "" {
+		if _, err := regexp.Compile(rule.Regexp); err != nil {
+			return genError(fmt.Sprintf("invalid scope rule regexp: %s (rule: %s)",
+				err, rule.String()))
+		}
+	}
+	return nil
+}
+
+// String 用于获取规则的名称，没有名称时以动作和条件代替。
+func (rule *ScopeRule) String() string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	var conditions []string
+	add := func(name, value string) {
+		if value != "" {
+			conditions = append(conditions, fmt.Sprintf("%s=%q", name, value))
+		}
+	}
+	add("regexp", rule.Regexp)
+	add("glob", rule.Glob)
+	add("host", rule.Host)
+	add("domain", rule.Domain)
+	add("path_prefix", rule.PathPrefix)
+	return fmt.Sprintf("%s(%s)", rule.Action, strings.Join(conditions, ", "))
+}
+
+// DomainDepth 代表针对某个域名及其子域名的最大深度。
+type DomainDepth struct {
+	// Domain 代表域名。
+	Domain string `json:"domain"`
+	// MaxDepth 代表最大深度。
+	MaxDepth uint32 `json:"max_depth"`
+}
+
+// ScopeArgs 代表爬取范围的参数。
+type ScopeArgs struct {
+	// Rules 代表范围规则，它们会被依次匹配，第一条匹配的规则决定URL是否在范围之内。
+	// 没有规则匹配时，由URL的主域名是否被接受来决定。
+	Rules []ScopeRule `json:"rules,omitempty"`
+	// DomainDepths 代表针对某些域名的最大深度，它们会覆盖全局的最大深度。
+	// 多个域名都适用时，最长的域名生效。
+	DomainDepths []DomainDepth `json:"domain_depths,omitempty"`
+	// FollowOffsite 代表是否跟随范围之外的链接一跳，
+	// 即范围之内的网页所链接的范围之外的网页会被下载，但其中的链接不再被跟随。
+	FollowOffsite bool `json:"follow_offsite,omitempty"`
+}
+
+// Check 用于检查爬取范围的参数。
+func (args *ScopeArgs) Check() error {
+	for _, rule := range args.Rules {
+		if err := rule.Check(); err != nil {
+			return err
+		}
+	}
+	domains := map[string]bool{}
+	for _, dd := range args.DomainDepths {
+		domain := normalizeDomain(dd.Domain)
+		if domain == "" {
+			return genError("empty domain of domain depth")
+		}
+		if domains[domain] {
+			return genError(fmt.Sprintf("duplicate domain depth for %q", dd.Domain))
+		}
+		domains[domain] = true
+	}
+	return nil
+}
+
+// Same 用于判断两个爬取范围的参数是否相同。
+func (args *ScopeArgs) Same(another *ScopeArgs) bool {
+	if another == nil {
+		return false
+	}
+	if another.FollowOffsite != args.FollowOffsite {
+		return false
+	}
+	if len(another.Rules) != len(args.Rules) ||
+		len(another.DomainDepths) != len(args.DomainDepths) {
+		return false
+	}
+	for i, rule := range another.Rules {
+		if rule != args.Rules[i] {
+			return false
+		}
+	}
+	for i, dd := range another.DomainDepths {
+		if dd != args.DomainDepths[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// scopeRule 代表已编译的范围规则。
+type scopeRule struct {
+	ScopeRule
+	re     *regexp.Regexp
+	glob   *regexp.Regexp
+	host   string
+	domain string
+}
+
+// match 用于判断给定的URL是否匹配规则。参数host需要是已规范化的主机名。
+func (rule *scopeRule) match(u *url.URL, host string) bool {
+	if rule.re != nil && !rule.re.MatchString(u.String()) {
+		return false
+	}
+	if rule.glob != nil && !rule.glob.MatchString(u.String()) {
+		return false
+	}
+	if rule.host != "" && host != rule.host {
+		return false
+	}
+	if rule.domain != "" && !matchDomain(host, rule.domain) {
+		return false
+	}
+	if rule.PathPrefix != "" && !strings.HasPrefix(u.EscapedPath(), rule.PathPrefix) {
+		return false
+	}
+	return true
+}
+
+// matchDomain 用于判断给定的主机名是否为给定的域名本身或它的子域名。
+func matchDomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// globToRegexp 用于把通配模式转换为正则表达式。
+func globToRegexp(glob string) *regexp.Regexp {
+	var buf strings.Builder
+	buf.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.MustCompile(buf.String())
+}
+
+// scope 代表爬取范围的判定器。
+type scope struct {
+	rules         []*scopeRule
+	domainDepths  []DomainDepth
+	maxDepth      uint32
+	followOffsite bool
+	// accepted 用于判断给定的主域名是否被接受。
+	accepted func(pd string) bool
+}
+
+// newScope 用于创建一个爬取范围的判定器。给定的参数需要是已检查过的。
+func newScope(args ScopeArgs, maxDepth uint32, accepted func(pd string) bool) *scope {
+	s := &scope{
+		maxDepth:      maxDepth,
+		followOffsite: args.FollowOffsite,
+		accepted:      accepted,
+	}
+	for _, rule := range args.Rules {
+		compiled := &scopeRule{ScopeRule: rule}
+		if rule.Regexp != "" {
+			compiled.re = regexp.MustCompile(rule.Regexp)
+		}
+		if rule.Glob != "" {
+			compiled.glob = globToRegexp(rule.Glob)
+		}
+		if rule.Host != "" {
+			compiled.host = normalizeDomain(rule.Host)
+		}
+		if rule.Domain != "" {
+			compiled.domain = normalizeDomain(rule.Domain)
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	for _, dd := range args.DomainDepths {
+		dd.Domain = normalizeDomain(dd.Domain)
+		s.domainDepths = append(s.domainDepths, dd)
+	}
+	return s
+}
+
+// inScope 用于判断给定的URL是否在爬取范围之内，不考虑深度。
+// 结果值excluded代表URL是否被排除规则明确排除，reason代表判定的依据。
+func (s *scope) inScope(u *url.URL) (ok bool, excluded bool, reason string) {
+	host := normalizeDomain(u.Hostname())
+	for _, rule := range s.rules {
+		if !rule.match(u, host) {
+			continue
+		}
+		if rule.Action == SCOPE_ACTION_EXCLUDE {
+			return false, true, fmt.Sprintf("excluded by scope rule %q", rule.String())
+		}
+		return true, false, fmt.Sprintf("included by scope rule %q", rule.String())
+	}
+	pd, err := getPrimaryDomain(u.Host)
+	if err != nil {
+		return false, false, fmt.Sprintf("unrecognized host %q", u.Host)
+	}
+	if !s.accepted(pd) {
+		return false, false, fmt.Sprintf("primary domain %q is not accepted", pd)
+	}
+	return true, false, fmt.Sprintf("primary domain %q is accepted", pd)
+}
+
+// depthLimit 用于获取适用于给定主机名的最大深度及其来源。
+func (s *scope) depthLimit(host string) (uint32, string) {
+	var matched *DomainDepth
+	for i, dd := range s.domainDepths {
+		if matchDomain(host, dd.Domain) &&
+			(matched == nil || len(dd.Domain) > len(matched.Domain)) {
+			matched = &s.domainDepths[i]
+		}
+	}
+	if matched == nil {
+		return s.maxDepth, "max depth"
+	}
+	return matched.MaxDepth, fmt.Sprintf("max depth of domain %q", matched.Domain)
+}
+
+// allowed 用于判断给定深度的URL是否可以被下载。
+// 参数parent代表链接所在网页的URL，可以为nil。
+// 结果值reason代表拒绝的原因，detail中会说明匹配的规则。
+func (s *scope) allowed(u *url.URL, depth uint32, parent *url.URL) (ok bool, reason DropReason, detail string) {
+	if s == nil || u == nil {
+		return true, "", ""
+	}
+	ok, excluded, detail := s.inScope(u)
+	if !ok {
+		if excluded {
+			return false, DROP_REASON_FILTER, detail
+		}
+		if !s.followOffsite || parent == nil {
+			return false, DROP_REASON_DOMAIN, detail
+		}
+		// 只跟随范围之内的网页中的站外链接。
+		if parentOK, _, parentDetail := s.inScope(parent); !parentOK {
+			return false, DROP_REASON_DOMAIN, fmt.Sprintf("%s; linked from offsite page (%s)",
+				detail, parentDetail)
+		}
+	}
+	limit, source := s.depthLimit(normalizeDomain(u.Hostname()))
+	if depth > limit {
+		return false, DROP_REASON_DEPTH,
+			fmt.Sprintf("depth %d exceeds %s (%d)", depth, source, limit)
+	}
+	return true, "", ""
+}
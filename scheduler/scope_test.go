@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestScopeArgs(t *testing.T) {
+	valid := []ScopeArgs{
+		{},
+		{Rules: []ScopeRule{
+			{Action: SCOPE_ACTION_EXCLUDE, Regexp: `\.pdf$`},
+			{Action: SCOPE_ACTION_INCLUDE, Glob: "https://*.example.com/*"},
+			{Action: SCOPE_ACTION_INCLUDE, Host: "a.com", PathPrefix: "/docs/"},
+		}},
+		{DomainDepths: []DomainDepth{{Domain: "a.com", MaxDepth: 1}}},
+	}
+	for _, args := range valid {
+		if err := args.Check(); err != nil {
+			t.Fatalf("An error occurs when checking scope args %#v: %s", args, err)
+		}
+	}
+	invalid := []ScopeArgs{
+		{Rules: []ScopeRule{{Action: "allow", Host: "a.com"}}},
+		{Rules: []ScopeRule{{Action: SCOPE_ACTION_INCLUDE}}},
+		{Rules: []ScopeRule{{Action: SCOPE_ACTION_EXCLUDE, Regexp: "("}}},
+		{DomainDepths: []DomainDepth{{MaxDepth: 1}}},
+		{DomainDepths: []DomainDepth{{Domain: "a.com"}, {Domain: "A.com"}}},
+	}
+	for _, args := range invalid {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking invalid scope args %#v!", args)
+		}
+	}
+	one := valid[1]
+	another := valid[1]
+	if !one.Same(&another) {
+		t.Fatal("Same scope args are considered different!")
+	}
+	another.FollowOffsite = true
+	if one.Same(&another) {
+		t.Fatal("Different scope args are considered same!")
+	}
+}
+
+func TestScope(t *testing.T) {
+	args := ScopeArgs{
+		Rules: []ScopeRule{
+			{Name: "no-login", Action: SCOPE_ACTION_EXCLUDE, PathPrefix: "/login"},
+			{Action: SCOPE_ACTION_EXCLUDE, Regexp: `\.(pdf|zip)$`},
+			{Action: SCOPE_ACTION_EXCLUDE, Host: "ads.a.com"},
+			{Name: "partner-docs", Action: SCOPE_ACTION_INCLUDE,
+				Domain: "partner.org", PathPrefix: "/docs/"},
+			{Action: SCOPE_ACTION_INCLUDE, Glob: "http://cdn.*.net/img/*"},
+		},
+		DomainDepths: []DomainDepth{
+			{Domain: "a.com", MaxDepth: 1},
+			{Domain: "deep.a.com", MaxDepth: 5},
+		},
+	}
+	accepted := map[string]bool{"a.com": true, "b.com": true}
+	s := newScope(args, 3, func(pd string) bool {
+		return accepted[pd]
+	})
+	cases := []struct {
+		rawURL string
+		depth  uint32
+		reason DropReason
+		detail string
+	}{
+		{"http://www.a.com/", 1, "", ""},
+		{"http://www.a.com/", 2, DROP_REASON_DEPTH,
+			`depth 2 exceeds max depth of domain "a.com" (1)`},
+		{"http://x.deep.a.com/", 5, "", ""},
+		{"http://b.com/x", 3, "", ""},
+		{"http://b.com/x", 4, DROP_REASON_DEPTH, "depth 4 exceeds max depth (3)"},
+		{"http://www.a.com/login/form", 0, DROP_REASON_FILTER,
+			`excluded by scope rule "no-login"`},
+		{"http://b.com/a.PDF", 0, "", ""},
+		{"http://b.com/a.pdf", 0, DROP_REASON_FILTER,
+			`excluded by scope rule "exclude(regexp=\"\\\\.(pdf|zip)$\")"`},
+		{"http://ADS.a.com:8080/x", 0, DROP_REASON_FILTER,
+			`excluded by scope rule "exclude(host=\"ads.a.com\")"`},
+		{"http://x.ads.a.com/x", 0, "", ""},
+		{"https://www.partner.org/docs/a", 0, "", ""},
+		{"https://www.partner.org/blog/a", 0, DROP_REASON_DOMAIN,
+			`primary domain "partner.org" is not accepted`},
+		{"http://cdn.x.net/img/1.png", 0, "", ""},
+		{"http://cdn.x.net/css/1.css", 0, DROP_REASON_DOMAIN,
+			`primary domain "x.net" is not accepted`},
+		{"http://localhost/", 0, DROP_REASON_DOMAIN, `unrecognized host "localhost"`},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.rawURL)
+		ok, reason, detail := s.allowed(u, c.depth, nil)
+		if ok != (c.reason == "") || reason != c.reason || detail != c.detail {
+			t.Fatalf("Inconsistent scope decision for %q (depth: %d): expected: %q (%q), actual: %v, %q (%q)",
+				c.rawURL, c.depth, c.reason, c.detail, ok, reason, detail)
+		}
+	}
+}
+
+func TestScopeFollowOffsite(t *testing.T) {
+	accepted := map[string]bool{"a.com": true}
+	s := newScope(ScopeArgs{FollowOffsite: true}, 3, func(pd string) bool {
+		return accepted[pd]
+	})
+	onsite, _ := url.Parse("http://www.a.com/")
+	offsite, _ := url.Parse("http://www.b.com/")
+	another, _ := url.Parse("http://www.c.com/")
+	if ok, _, detail := s.allowed(offsite, 1, onsite); !ok {
+		t.Fatalf("Offsite link from onsite page is rejected: %s", detail)
+	}
+	ok, reason, detail := s.allowed(another, 2, offsite)
+	if ok {
+		t.Fatal("Offsite link from offsite page is allowed!")
+	}
+	if reason != DROP_REASON_DOMAIN || !strings.Contains(detail, "linked from offsite page") {
+		t.Fatalf("Unexpected rejection reason: %s (%s)", reason, detail)
+	}
+	if ok, _, detail := s.allowed(onsite, 2, offsite); !ok {
+		t.Fatalf("Onsite link from offsite page is rejected: %s", detail)
+	}
+	s.rules = []*scopeRule{{
+		ScopeRule: ScopeRule{Action: SCOPE_ACTION_EXCLUDE, PathPrefix: "/private/"},
+	}}
+	private, _ := url.Parse("http://www.a.com/private/")
+	if ok, reason, _ := s.allowed(private, 1, onsite); ok || reason != DROP_REASON_FILTER {
+		t.Fatal("Excluded link is followed as offsite link!")
+	}
+	if ok, _, _ := s.allowed(offsite, 1, nil); ok {
+		t.Fatal("Offsite link without parent is allowed!")
+	}
+	s.followOffsite = false
+	if ok, _, _ := s.allowed(offsite, 1, onsite); ok {
+		t.Fatal("Offsite link is allowed without following offsite links!")
+	}
+}
+
+func TestSchedScope(t *testing.T) {
+	var lock sync.Mutex
+	requested := map[string]int{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requested[r.Host+r.URL.Path]++
+			lock.Unlock()
+			// 以“localhost”访问的同一服务器代表站外网站。
+			offsite := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+			switch r.URL.Path {
+			case "/":
+				fmt.Fprintf(w, "/a\n/private/x\n%s/off\n", offsite)
+			case "/off":
+				fmt.Fprintf(w, "%s/off2\n", offsite)
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	requestArgs.Scope = ScopeArgs{
+		Rules: []ScopeRule{
+			{Name: "no-private", Action: SCOPE_ACTION_EXCLUDE, PathPrefix: "/private/"},
+		},
+		FollowOffsite: true,
+	}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	offsiteHost := strings.Replace(host, "127.0.0.1", "localhost", 1)
+	lock.Lock()
+	defer lock.Unlock()
+	expected := map[string]int{
+		host + "/":           1,
+		host + "/a":          1,
+		offsiteHost + "/off": 1,
+	}
+	if len(requested) != len(expected) {
+		t.Fatalf("Inconsistent requested pages: expected: %v, actual: %v",
+			expected, requested)
+	}
+	for page, number := range expected {
+		if requested[page] != number {
+			t.Fatalf("Inconsistent requested pages: expected: %v, actual: %v",
+				expected, requested)
+		}
+	}
+}
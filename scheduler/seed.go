@@ -0,0 +1,334 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// Seed 代表一个种子，即爬取的起点。
+type Seed struct {
+	// HTTPReq 代表种子的HTTP请求。
+	HTTPReq *http.Request
+	// Depth 代表种子的深度，通常为0。
+	// 深度不为0的种子会更早地达到最大深度。
+	Depth uint32
+}
+
+// NewSeed 用于根据给定的URL创建一个GET请求的种子。
+func NewSeed(rawURL string, depth uint32) (Seed, error) {
+	httpReq, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return Seed{}, genParameterError(fmt.Sprintf("invalid seed URL %q: %s", rawURL, err))
+	}
+	return Seed{HTTPReq: httpReq, Depth: depth}, nil
+}
+
+// check 用于检查种子，并返回它的主域名。
+func (seed Seed) check() (string, error) {
+	if seed.HTTPReq == nil || seed.HTTPReq.URL == nil {
+		return "", genParameterError("nil seed HTTP request")
+	}
+	host := seed.HTTPReq.Host
+	if host == "" {
+		host = seed.HTTPReq.URL.Host
+	}
+	primaryDomain, err := getPrimaryDomain(host)
+	if err != nil {
+		return "", genParameterError(fmt.Sprintf("invalid seed %q: %s",
+			seed.HTTPReq.URL.String(), err))
+	}
+	return primaryDomain, nil
+}
+
+// request 用于获取种子对应的请求。
+func (seed Seed) request() *module.Request {
+	return module.NewRequest(seed.HTTPReq, seed.Depth)
+}
+
+// SeedProvider 代表种子来源的接口类型。
+type SeedProvider interface {
+	// Seeds 用于获取种子，参数ctx用于取消获取。
+	Seeds(ctx context.Context) ([]Seed, error)
+}
+
+// LoadSeeds 用于从给定的种子来源中依次获取种子。
+// 任何一个来源出错时都会返回错误。
+func LoadSeeds(ctx context.Context, providers ...SeedProvider) ([]Seed, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var seeds []Seed
+	for _, provider := range providers {
+		if provider == nil {
+			return nil, genParameterError("nil seed provider")
+		}
+		more, err := provider.Seeds(ctx)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, more...)
+	}
+	return seeds, nil
+}
+
+// fileSeedProvider 代表从文件中读取种子的种子来源。
+type fileSeedProvider struct {
+	path  string
+	depth uint32
+}
+
+// NewFileSeedProvider 用于创建一个从文件中读取种子的种子来源。
+// 文件中的每一行都是一个URL，空行和以“#”开头的行会被忽略。
+// 参数depth代表各种子的深度。
+func NewFileSeedProvider(path string, depth uint32) SeedProvider {
+	return &fileSeedProvider{path: path, depth: depth}
+}
+
+func (provider *fileSeedProvider) Seeds(ctx context.Context) ([]Seed, error) {
+	file, err := os.Open(provider.path)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	defer file.Close()
+	var seeds []Seed
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if ctx.Err() != nil {
+			return nil, genErrorByError(ctx.Err())
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seed, err := NewSeed(line, provider.depth)
+		if err != nil {
+			return nil, genError(fmt.Sprintf("invalid seed at %s:%d: %s",
+				provider.path, lineNumber, err))
+		}
+		seeds = append(seeds, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, genErrorByError(err)
+	}
+	return seeds, nil
+}
+
+// sitemapFetchTimeout 代表获取站点地图的超时时间。
+const sitemapFetchTimeout = 30 * time.Second
+
+// sitemapMaxSize 代表单个站点地图（解压后）的最大解析长度。
+const sitemapMaxSize = 50 * 1024 * 1024
+
+// sitemapMaxNesting 代表站点地图索引的最大嵌套层数。
+const sitemapMaxNesting = 3
+
+// sitemapDoc 代表站点地图或站点地图索引的文档。
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc 代表站点地图中的一个地址。
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapSeedProvider 代表从站点地图中读取种子的种子来源。
+type sitemapSeedProvider struct {
+	locations []string
+	depth     uint32
+	client    *http.Client
+}
+
+// NewSitemapSeedProvider 用于创建一个从站点地图中读取种子的种子来源。
+// 参数location可以是站点地图或站点地图索引的URL（http或https）或本地文件路径，
+// 支持gzip压缩。站点地图索引中的站点地图会被递归读取。
+// 参数client用于获取远程的站点地图，为nil时使用带有超时的默认客户端。
+func NewSitemapSeedProvider(location string, depth uint32, client *http.Client) SeedProvider {
+	return newSitemapSeedProvider([]string{location}, depth, client)
+}
+
+func newSitemapSeedProvider(locations []string, depth uint32, client *http.Client) *sitemapSeedProvider {
+	if client == nil {
+		client = &http.Client{Timeout: sitemapFetchTimeout}
+	}
+	return &sitemapSeedProvider{locations: locations, depth: depth, client: client}
+}
+
+func (provider *sitemapSeedProvider) Seeds(ctx context.Context) ([]Seed, error) {
+	var seeds []Seed
+	visited := map[string]bool{}
+	seenURLs := map[string]bool{}
+	for _, location := range provider.locations {
+		err := provider.read(ctx, location, 0, visited, func(loc string) error {
+			if seenURLs[loc] {
+				return nil
+			}
+			seenURLs[loc] = true
+			seed, err := NewSeed(loc, provider.depth)
+			if err != nil {
+				return err
+			}
+			seeds = append(seeds, seed)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return seeds, nil
+}
+
+// read 用于读取给定位置的站点地图，并把其中的URL交给add函数。
+// 参数nesting代表当前的嵌套层数，visited用于避免重复读取同一个站点地图。
+func (provider *sitemapSeedProvider) read(ctx context.Context, location string,
+	nesting int, visited map[string]bool, add func(loc string) error) error {
+	if visited[location] {
+		return nil
+	}
+	visited[location] = true
+	if nesting > sitemapMaxNesting {
+		return genError(fmt.Sprintf("sitemap index is nested too deeply: %s", location))
+	}
+	data, err := provider.load(ctx, location)
+	if err != nil {
+		return err
+	}
+	var doc sitemapDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return genError(fmt.Sprintf("invalid sitemap %s: %s", location, err))
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" {
+				continue
+			}
+			if err := add(loc); err != nil {
+				return err
+			}
+		}
+	case "sitemapindex":
+		for _, sm := range doc.Sitemaps {
+			loc := strings.TrimSpace(sm.Loc)
+			if loc == "" {
+				continue
+			}
+			if err := provider.read(ctx, loc, nesting+1, visited, add); err != nil {
+				return err
+			}
+		}
+	default:
+		return genError(fmt.Sprintf("unknown sitemap root element %q: %s",
+			doc.XMLName.Local, location))
+	}
+	return nil
+}
+
+// load 用于获取给定位置的站点地图的内容，必要时会解压。
+func (provider *sitemapSeedProvider) load(ctx context.Context, location string) ([]byte, error) {
+	var body io.ReadCloser
+	if u, err := url.Parse(location); err == nil &&
+		(strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https")) {
+		httpReq, err := http.NewRequest("GET", location, nil)
+		if err != nil {
+			return nil, genErrorByError(err)
+		}
+		httpResp, err := provider.client.Do(httpReq.WithContext(ctx))
+		if err != nil {
+			return nil, genErrorByError(err)
+		}
+		if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+			httpResp.Body.Close()
+			return nil, genError(fmt.Sprintf("failed to fetch sitemap %s: %s",
+				location, httpResp.Status))
+		}
+		body = httpResp.Body
+	} else {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, genErrorByError(err)
+		}
+		body = file
+	}
+	defer body.Close()
+	reader := bufio.NewReader(body)
+	var r io.Reader = reader
+	// 根据gzip的魔数判断是否需要解压，而不依赖文件扩展名或响应头。
+	if magic, err := reader.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, genErrorByError(err)
+		}
+		defer gzReader.Close()
+		r = gzReader
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, sitemapMaxSize+1))
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	if len(data) > sitemapMaxSize {
+		return nil, genError(fmt.Sprintf("sitemap is too large: %s", location))
+	}
+	return data, nil
+}
+
+// robotsSitemapSeedProvider 代表从robots.txt中声明的站点地图中读取种子的种子来源。
+type robotsSitemapSeedProvider struct {
+	siteURL string
+	depth   uint32
+	client  *http.Client
+}
+
+// NewRobotsSitemapSeedProvider 用于创建一个种子来源，
+// 它会读取给定站点的robots.txt中的Sitemap声明的所有站点地图。
+// 参数siteURL代表站点的URL，只有其协议和主机会被使用。
+func NewRobotsSitemapSeedProvider(siteURL string, depth uint32, client *http.Client) SeedProvider {
+	if client == nil {
+		client = &http.Client{Timeout: sitemapFetchTimeout}
+	}
+	return &robotsSitemapSeedProvider{siteURL: siteURL, depth: depth, client: client}
+}
+
+func (provider *robotsSitemapSeedProvider) Seeds(ctx context.Context) ([]Seed, error) {
+	u, err := url.Parse(provider.siteURL)
+	if err != nil || u.Host == "" {
+		return nil, genParameterError(fmt.Sprintf("invalid site URL %q", provider.siteURL))
+	}
+	robotsURL := u.Scheme + "://" + u.Host + "/robots.txt"
+	httpReq, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	httpResp, err := provider.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, genErrorByError(err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, genError(fmt.Sprintf("failed to fetch %s: %s", robotsURL, httpResp.Status))
+	}
+	// Sitemap声明不属于任何组，所以用哪个用户代理解析都可以。
+	rules := parseRobots(httpResp.Body, defaultRobotsUserAgent)
+	if len(rules.sitemaps) == 0 {
+		return nil, nil
+	}
+	return newSitemapSeedProvider(rules.sitemaps, provider.depth, provider.client).Seeds(ctx)
+}
@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+// seedURLs 用于获取种子的URL及深度的列表，已排序。
+func seedURLs(seeds []Seed) []string {
+	var urls []string
+	for _, seed := range seeds {
+		urls = append(urls, fmt.Sprintf("%s@%d", seed.HTTPReq.URL.String(), seed.Depth))
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func TestNewSeed(t *testing.T) {
+	seed, err := NewSeed("http://www.a.com/x", 2)
+	if err != nil {
+		t.Fatalf("An error occurs when creating seed: %s", err)
+	}
+	if pd, err := seed.check(); err != nil || pd != "a.com" {
+		t.Fatalf("Inconsistent primary domain of seed: %q (error: %v)", pd, err)
+	}
+	if seed.request().Depth() != 2 {
+		t.Fatalf("Inconsistent seed depth: expected: %d, actual: %d",
+			2, seed.request().Depth())
+	}
+	if _, err := NewSeed("http://a.com/%zz", 0); err == nil {
+		t.Fatal("No error when creating seed with invalid URL!")
+	}
+	if _, err := (Seed{}).check(); err == nil {
+		t.Fatal("No error when checking empty seed!")
+	}
+	seed, _ = NewSeed("http://localhost/", 0)
+	if _, err := seed.check(); err == nil {
+		t.Fatal("No error when checking seed with unrecognized host!")
+	}
+}
+
+func TestFileSeedProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seeds")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "seeds.txt")
+	content := "# 种子\nhttp://a.com/\n\n  http://b.com/x  \n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("An error occurs when writing seed file: %s", err)
+	}
+	seeds, err := NewFileSeedProvider(path, 1).Seeds(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds: %s", err)
+	}
+	expected := []string{"http://a.com/@1", "http://b.com/x@1"}
+	if actual := seedURLs(seeds); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("Inconsistent seeds: expected: %v, actual: %v", expected, actual)
+	}
+	badPath := filepath.Join(dir, "bad.txt")
+	ioutil.WriteFile(badPath, []byte("http://a.com/\nhttp://a.com/%zz\n"), 0600)
+	if _, err := NewFileSeedProvider(badPath, 0).Seeds(context.Background()); err == nil ||
+		!strings.Contains(err.Error(), "bad.txt:2") {
+		t.Fatalf("Inconsistent error of invalid seed file: %v", err)
+	}
+	if _, err := NewFileSeedProvider(filepath.Join(dir, "none"), 0).Seeds(context.Background()); err == nil {
+		t.Fatal("No error when loading seeds from nonexistent file!")
+	}
+}
+
+func gzipBytes(data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestSitemapSeedProvider(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/robots.txt":
+				fmt.Fprintf(w, "User-agent: *\nDisallow: /private/\nSitemap: %s/index.xml\n", server.URL)
+			case "/index.xml":
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/a.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/b.xml.gz</loc></sitemap>
+  <sitemap><loc>%[1]s/index.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+			case "/a.xml":
+				fmt.Fprint(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://a.com/1</loc><lastmod>2020-01-01</lastmod></url>
+  <url><loc> http://a.com/2 </loc></url>
+</urlset>`)
+			case "/b.xml.gz":
+				w.Write(gzipBytes(`<urlset><url><loc>http://a.com/2</loc></url>` +
+					`<url><loc>http://b.com/3</loc></url></urlset>`))
+			case "/bad.xml":
+				fmt.Fprint(w, `<html></html>`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer server.Close()
+	expected := []string{"http://a.com/1@0", "http://a.com/2@0", "http://b.com/3@0"}
+	seeds, err := NewSitemapSeedProvider(server.URL+"/index.xml", 0, nil).Seeds(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds from sitemap: %s", err)
+	}
+	if actual := seedURLs(seeds); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("Inconsistent seeds: expected: %v, actual: %v", expected, actual)
+	}
+	seeds, err = NewRobotsSitemapSeedProvider(server.URL+"/any/page", 0, nil).Seeds(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds from robots.txt: %s", err)
+	}
+	if actual := seedURLs(seeds); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("Inconsistent seeds: expected: %v, actual: %v", expected, actual)
+	}
+	for _, path := range []string{"/bad.xml", "/none.xml"} {
+		if _, err := NewSitemapSeedProvider(server.URL+path, 0, nil).Seeds(context.Background()); err == nil {
+			t.Fatalf("No error when loading seeds from %s!", path)
+		}
+	}
+	// 本地的站点地图文件。
+	dir, err := ioutil.TempDir("", "sitemap")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sitemap.xml.gz")
+	ioutil.WriteFile(path, gzipBytes(`<urlset><url><loc>http://c.com/</loc></url></urlset>`), 0600)
+	seeds, err = LoadSeeds(nil, NewSitemapSeedProvider(path, 2, nil))
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds from sitemap file: %s", err)
+	}
+	if actual := seedURLs(seeds); len(actual) != 1 || actual[0] != "http://c.com/@2" {
+		t.Fatalf("Inconsistent seeds: %v", actual)
+	}
+	if _, err := LoadSeeds(context.Background(), nil); err == nil {
+		t.Fatal("No error when loading seeds from nil provider!")
+	}
+}
+
+func TestSchedSeeds(t *testing.T) {
+	var lock sync.Mutex
+	visited := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			visited[r.URL.Path]++
+			lock.Unlock()
+			switch r.URL.Path {
+			case "/a":
+				fmt.Fprint(w, "/a1\n")
+			case "/b":
+				fmt.Fprint(w, "/b1\n")
+			}
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if _, err := sched.AddSeeds(); err == nil {
+		t.Fatal("No error when adding seeds before starting!")
+	}
+	if err := sched.StartSeeds(); err == nil {
+		t.Fatal("No error when starting scheduler without seeds!")
+	}
+	badSeed, _ := NewSeed("http://localhost/", 0)
+	if err := sched.StartSeeds(badSeed); err == nil {
+		t.Fatal("No error when starting scheduler with invalid seed!")
+	}
+	seedA, _ := NewSeed(server.URL+"/a", 0)
+	// 深度为1的种子中的链接超出了最大深度。
+	seedB, _ := NewSeed(server.URL+"/b", 1)
+	if err := sched.StartSeeds(seedA, seedB, seedA); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	seedC, _ := NewSeed(server.URL+"/c", 0)
+	if _, err := sched.AddSeeds(seedC, badSeed); err == nil {
+		t.Fatal("No error when adding invalid seed!")
+	}
+	number, err := sched.AddSeeds(seedC, seedA)
+	if err != nil {
+		t.Fatalf("An error occurs when adding seeds: %s", err)
+	}
+	if number != 1 {
+		t.Fatalf("Inconsistent added seed number: expected: %d, actual: %d", 1, number)
+	}
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	sched.Stop()
+	lock.Lock()
+	defer lock.Unlock()
+	expected := map[string]int{"/a": 1, "/a1": 1, "/b": 1, "/c": 1}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Fatalf("Inconsistent visited paths: expected: %v, actual: %v", expected, visited)
+	}
+	if _, err := sched.AddSeeds(seedC); err == nil {
+		t.Fatal("No error when adding seeds after stopping!")
+	}
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SeenSetType 代表已见URL集合的类型。
+type SeenSetType string
+
+const (
+	// SEEN_SET_TYPE_MAP 代表精确的内存集合，它会保存所有的URL。
+	SEEN_SET_TYPE_MAP SeenSetType = "map"
+	// SEEN_SET_TYPE_BLOOM 代表可扩展的布隆过滤器，它占用的内存很少但存在误判。
+	SEEN_SET_TYPE_BLOOM SeenSetType = "bloom"
+	// SEEN_SET_TYPE_DISK 代表基于磁盘日志的精确集合。
+	SEEN_SET_TYPE_DISK SeenSetType = "disk"
+)
+
+// 已见URL集合的默认参数。
+const (
+	defaultBloomFalsePositiveRate        = 0.001
+	defaultBloomInitialCapacity   uint64 = 1 << 16
+)
+
+// SeenSetArgs 代表已见URL集合的参数。
+type SeenSetArgs struct {
+	// Type 代表集合的类型，为空时为精确的内存集合。
+	Type SeenSetType `json:"type,omitempty"`
+	// FalsePositiveRate 代表布隆过滤器的目标误判率，为0时使用默认值。
+	FalsePositiveRate float64 `json:"false_positive_rate,omitempty"`
+	// InitialCapacity 代表布隆过滤器的初始容量，为0时使用默认值。
+	InitialCapacity uint64 `json:"initial_capacity,omitempty"`
+	// Dir 代表磁盘集合的存放目录。
+	Dir string `json:"dir,omitempty"`
+}
+
+// Check 用于检查已见URL集合的参数。
+func (args *SeenSetArgs) Check() error {
+	switch args.Type {
+	case "", SEEN_SET_TYPE_MAP:
+	case SEEN_SET_TYPE_BLOOM:
+		if args.FalsePositiveRate < 0 || args.FalsePositiveRate >= 1 {
+			return genError(fmt.Sprintf("invalid false positive rate: %v",
+				args.FalsePositiveRate))
+		}
+	case SEEN_SET_TYPE_DISK:
+		if args.Dir == "" {
+			return genError("empty seen set dir")
+		}
+	default:
+		return genError(fmt.Sprintf("unknown seen set type: %q", args.Type))
+	}
+	return nil
+}
+
+// SeenSet 代表已见URL集合的接口类型。它的实现类型需要是并发安全的。
+type SeenSet interface {
+	// Type 用于获取集合的类型。
+	Type() SeenSetType
+	// Add 用于添加一个键。结果值added为false时代表该键已存在，
+	// 对于存在误判的实现来说也可能代表误判。
+	Add(key string) (added bool, err error)
+	// Contains 用于判断集合中是否已有给定的键。
+	Contains(key string) bool
+	// Len 用于获取已添加的键的数量。
+	Len() uint64
+	// FalsePositiveRate 用于获取估算的误判率，精确的实现总是返回0。
+	FalsePositiveRate() float64
+	// Close 用于关闭集合并释放它占用的资源。
+	Close() error
+}
+
+// NewSeenSet 用于按照给定的参数创建一个已见URL集合。
+func NewSeenSet(args SeenSetArgs) (SeenSet, error) {
+	if err := args.Check(); err != nil {
+		return nil, err
+	}
+	switch args.Type {
+	case SEEN_SET_TYPE_BLOOM:
+		fpRate := args.FalsePositiveRate
+		if fpRate == 0 {
+			fpRate = defaultBloomFalsePositiveRate
+		}
+		capacity := args.InitialCapacity
+		if capacity == 0 {
+			capacity = defaultBloomInitialCapacity
+		}
+		return NewBloomSeenSet(capacity, fpRate), nil
+	case SEEN_SET_TYPE_DISK:
+		return NewDiskSeenSet(args.Dir)
+	}
+	return NewMapSeenSet(), nil
+}
+
+// SeenSetSummaryStruct 代表已见URL集合的摘要类型。
+type SeenSetSummaryStruct struct {
+	// Type 代表集合的类型。
+	Type SeenSetType `json:"type"`
+	// Number 代表已见URL的数量。
+	Number uint64 `json:"number"`
+	// FalsePositiveRate 代表估算的误判率。
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+}
+
+// getSeenSetSummary 用于获取已见URL集合的摘要。
+func getSeenSetSummary(seen SeenSet) SeenSetSummaryStruct {
+	if seen == nil {
+		return SeenSetSummaryStruct{}
+	}
+	return SeenSetSummaryStruct{
+		Type:              seen.Type(),
+		Number:            seen.Len(),
+		FalsePositiveRate: seen.FalsePositiveRate(),
+	}
+}
+
+// NewMapSeenSet 用于创建一个精确的内存集合。
+func NewMapSeenSet() SeenSet {
+	return &mapSeenSet{m: map[string]struct{}{}}
+}
+
+// mapSeenSet 代表精确的内存集合的实现类型。
+type mapSeenSet struct {
+	lock sync.RWMutex
+	m    map[string]struct{}
+}
+
+func (set *mapSeenSet) Type() SeenSetType {
+	return SEEN_SET_TYPE_MAP
+}
+
+func (set *mapSeenSet) Add(key string) (bool, error) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if _, ok := set.m[key]; ok {
+		return false, nil
+	}
+	set.m[key] = struct{}{}
+	return true, nil
+}
+
+func (set *mapSeenSet) Contains(key string) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	_, ok := set.m[key]
+	return ok
+}
+
+func (set *mapSeenSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return uint64(len(set.m))
+}
+
+func (set *mapSeenSet) FalsePositiveRate() float64 {
+	return 0
+}
+
+func (set *mapSeenSet) Close() error {
+	return nil
+}
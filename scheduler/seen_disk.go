@@ -0,0 +1,465 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 磁盘集合的文件名与参数。
+const (
+	// diskSeenWALFile 代表预写日志的文件名，其中是尚未写入分段的键。
+	diskSeenWALFile = "seen.wal"
+	// diskSeenSegmentPattern 代表分段文件的文件名模式。
+	diskSeenSegmentPattern = "seen-%06d.seg"
+	// diskSeenIndexInterval 代表分段的稀疏索引中相邻两项之间的键的数量。
+	diskSeenIndexInterval = 128
+	// diskSeenFilterFPRate 代表各分段的布隆过滤器的误判率，用于减少磁盘读取。
+	diskSeenFilterFPRate = 0.01
+)
+
+// 磁盘集合的容量参数，测试中会调小它们。
+var (
+	// diskSeenMemLimit 代表内存表中键的最大数量，达到后会被写入一个新的分段。
+	diskSeenMemLimit = 1 << 16
+	// diskSeenMaxSegments 代表分段的最大数量，超出后所有分段会被合并为一个。
+	diskSeenMaxSegments = 8
+)
+
+// diskSeenIndexEntry 代表分段的稀疏索引中的一项。
+type diskSeenIndexEntry struct {
+	key    string
+	offset int64
+}
+
+// diskSeenSegment 代表一个分段，即一个按顺序存放键的只读文件，每行一个键。
+type diskSeenSegment struct {
+	seq    int
+	path   string
+	file   *os.File
+	size   int64
+	count  uint64
+	index  []diskSeenIndexEntry
+	filter *bloomFilter
+}
+
+// openDiskSeenSegment 用于打开一个分段，并建立它的稀疏索引和布隆过滤器。
+// 为了不把分段中的键全部读入内存，它会依次读取两遍：
+// 第一遍统计键的数量并建立索引，第二遍填充布隆过滤器。
+func openDiskSeenSegment(seq int, path string) (*diskSeenSegment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	seg := &diskSeenSegment{seq: seq, path: path, file: file}
+	err = seg.scan(func(key string, offset int64) {
+		if seg.count%diskSeenIndexInterval == 0 {
+			seg.index = append(seg.index, diskSeenIndexEntry{key: key, offset: offset})
+		}
+		seg.count++
+		seg.size = offset + int64(len(key)) + 1
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	seg.filter = newBloomFilter(seg.count, diskSeenFilterFPRate)
+	err = seg.scan(func(key string, offset int64) {
+		seg.filter.add(bloomHashes(key))
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return seg, nil
+}
+
+// scan 用于按顺序遍历分段中的键及其偏移量。
+func (seg *diskSeenSegment) scan(handle func(key string, offset int64)) error {
+	info, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(seg.file, 0, info.Size()))
+	var offset int64
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(line[:len(line)-1], offset)
+		offset += int64(len(line))
+	}
+}
+
+// contains 用于判断分段中是否含有给定的键。
+func (seg *diskSeenSegment) contains(key string) (bool, error) {
+	if seg.count == 0 || !seg.filter.test(bloomHashes(key)) {
+		return false, nil
+	}
+	// 找到最后一个不大于给定键的索引项，所求的键只可能在它所代表的块中。
+	i := sort.Search(len(seg.index), func(i int) bool {
+		return seg.index[i].key > key
+	}) - 1
+	if i < 0 {
+		return false, nil
+	}
+	end := seg.size
+	if i+1 < len(seg.index) {
+		end = seg.index[i+1].offset
+	}
+	block := make([]byte, end-seg.index[i].offset)
+	if _, err := seg.file.ReadAt(block, seg.index[i].offset); err != nil && err != io.EOF {
+		return false, err
+	}
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		if string(line) == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NewDiskSeenSet 用于创建或打开给定目录中的磁盘集合。
+// 目录中已有的键会被保留，调度器只在从检查点恢复时沿用它们，全新的爬取会先清空目录。
+// 新加入的键会先写入预写日志并留在内存表中，
+// 内存表达到上限后会被排序写入一个新的分段，分段过多时会被合并。
+// 每个分段在内存中只保留稀疏索引和布隆过滤器。
+func NewDiskSeenSet(dir string) (SeenSet, error) {
+	if dir == "" {
+		return nil, genParameterError("empty seen set dir")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, genErrorByError(err)
+	}
+	set := &diskSeenSet{
+		dir: dir,
+		mem: map[string]struct{}{},
+	}
+	if err := set.load(); err != nil {
+		set.Close()
+		return nil, genErrorByError(err)
+	}
+	return set, nil
+}
+
+// diskSeenSet 代表磁盘集合的实现类型。
+type diskSeenSet struct {
+	lock     sync.RWMutex
+	dir      string
+	mem      map[string]struct{}
+	wal      *os.File
+	segments []*diskSeenSegment
+	nextSeq  int
+	count    uint64
+}
+
+// load 用于加载目录中已有的分段和预写日志。
+func (set *diskSeenSet) load() error {
+	paths, err := filepath.Glob(filepath.Join(set.dir, "seen-*.seg"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var seq int
+		if _, err := fmt.Sscanf(filepath.Base(path), diskSeenSegmentPattern, &seq); err != nil {
+			continue
+		}
+		seg, err := openDiskSeenSegment(seq, path)
+		if err != nil {
+			return err
+		}
+		set.segments = append(set.segments, seg)
+		set.count += seg.count
+		if seq >= set.nextSeq {
+			set.nextSeq = seq + 1
+		}
+	}
+	walPath := filepath.Join(set.dir, diskSeenWALFile)
+	if data, err := ioutil.ReadFile(walPath); err == nil {
+		for _, key := range strings.Split(string(data), "\n") {
+			if key == "" {
+				continue
+			}
+			if _, ok := set.mem[key]; ok {
+				continue
+			}
+			// 预写日志中的键可能已在写入分段后才被截断。
+			found, err := set.containsOnDisk(key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				set.mem[key] = struct{}{}
+				set.count++
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	set.wal, err = os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (set *diskSeenSet) Type() SeenSetType {
+	return SEEN_SET_TYPE_DISK
+}
+
+// containsOnDisk 用于判断各分段中是否含有给定的键。调用方需持有锁。
+func (set *diskSeenSet) containsOnDisk(key string) (bool, error) {
+	for i := len(set.segments) - 1; i >= 0; i-- {
+		found, err := set.segments[i].contains(key)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+func (set *diskSeenSet) Add(key string) (bool, error) {
+	if strings.Contains(key, "\n") {
+		return false, genParameterError("key with line break")
+	}
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.wal == nil {
+		return false, genError("closed seen set")
+	}
+	if _, ok := set.mem[key]; ok {
+		return false, nil
+	}
+	found, err := set.containsOnDisk(key)
+	if err != nil {
+		return false, genErrorByError(err)
+	}
+	if found {
+		return false, nil
+	}
+	if _, err := set.wal.WriteString(key + "\n"); err != nil {
+		return false, genErrorByError(err)
+	}
+	set.mem[key] = struct{}{}
+	set.count++
+	if len(set.mem) >= diskSeenMemLimit {
+		if err := set.flush(); err != nil {
+			return true, genErrorByError(err)
+		}
+	}
+	return true, nil
+}
+
+// flush 用于把内存表写入一个新的分段并清空预写日志，必要时合并分段。
+// 调用方需持有锁。
+func (set *diskSeenSet) flush() error {
+	if len(set.mem) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set.mem))
+	for key := range set.mem {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seg, err := set.writeSegment(keys)
+	if err != nil {
+		return err
+	}
+	set.segments = append(set.segments, seg)
+	set.mem = map[string]struct{}{}
+	if err := set.wal.Truncate(0); err != nil {
+		return err
+	}
+	if len(set.segments) > diskSeenMaxSegments {
+		return set.compact()
+	}
+	return nil
+}
+
+// writeSegment 用于把已排序的键写入一个新的分段。
+func (set *diskSeenSet) writeSegment(keys []string) (*diskSeenSegment, error) {
+	seq := set.nextSeq
+	set.nextSeq++
+	path := filepath.Join(set.dir, fmt.Sprintf(diskSeenSegmentPattern, seq))
+	err := writeDiskSeenFile(path, func(writer *bufio.Writer) error {
+		for _, key := range keys {
+			writer.WriteString(key)
+			writer.WriteByte('\n')
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return openDiskSeenSegment(seq, path)
+}
+
+// writeDiskSeenFile 用于写入一个分段文件。
+// 它会先写入临时文件再重命名，以免留下不完整的分段。
+func writeDiskSeenFile(path string, write func(writer *bufio.Writer) error) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// compact 用于把所有分段合并为一个。调用方需持有锁。
+// 各分段都是有序的，所以合并时只需逐行读取。
+func (set *diskSeenSet) compact() error {
+	seq := set.nextSeq
+	set.nextSeq++
+	path := filepath.Join(set.dir, fmt.Sprintf(diskSeenSegmentPattern, seq))
+	if err := writeDiskSeenFile(path, func(writer *bufio.Writer) error {
+		return mergeDiskSeenSegments(set.segments, writer)
+	}); err != nil {
+		return err
+	}
+	seg, err := openDiskSeenSegment(seq, path)
+	if err != nil {
+		return err
+	}
+	for _, old := range set.segments {
+		old.file.Close()
+		os.Remove(old.path)
+	}
+	set.segments = []*diskSeenSegment{seg}
+	return nil
+}
+
+// mergeDiskSeenSegments 用于把多个分段中的键按顺序合并写入，相同的键只保留一个。
+func mergeDiskSeenSegments(segments []*diskSeenSegment, writer *bufio.Writer) error {
+	readers := make([]*bufio.Reader, len(segments))
+	heads := make([]*string, len(segments))
+	next := func(i int) error {
+		line, err := readers[i].ReadString('\n')
+		if err == io.EOF {
+			heads[i] = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key := line[:len(line)-1]
+		heads[i] = &key
+		return nil
+	}
+	for i, seg := range segments {
+		readers[i] = bufio.NewReader(io.NewSectionReader(seg.file, 0, seg.size))
+		if err := next(i); err != nil {
+			return err
+		}
+	}
+	var last *string
+	for {
+		min := -1
+		for i, head := range heads {
+			if head != nil && (min < 0 || *head < *heads[min]) {
+				min = i
+			}
+		}
+		if min < 0 {
+			return nil
+		}
+		key := *heads[min]
+		if last == nil || *last != key {
+			writer.WriteString(key)
+			writer.WriteByte('\n')
+			last = &key
+		}
+		if err := next(min); err != nil {
+			return err
+		}
+	}
+}
+
+func (set *diskSeenSet) Contains(key string) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	if _, ok := set.mem[key]; ok {
+		return true
+	}
+	found, _ := set.containsOnDisk(key)
+	return found
+}
+
+func (set *diskSeenSet) Len() uint64 {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.count
+}
+
+func (set *diskSeenSet) FalsePositiveRate() float64 {
+	return 0
+}
+
+// reset 会删除目录中已有的分段和预写日志，使集合成为空集合。
+// 全新的爬取需要调用它，以免把上次爬取留下的键当作已见URL。
+func (set *diskSeenSet) reset() error {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.wal != nil {
+		set.wal.Close()
+		set.wal = nil
+	}
+	for _, seg := range set.segments {
+		seg.file.Close()
+	}
+	set.segments = nil
+	set.mem = map[string]struct{}{}
+	set.nextSeq = 0
+	set.count = 0
+	paths, err := filepath.Glob(filepath.Join(set.dir, "seen-*.seg"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	set.wal, err = os.OpenFile(filepath.Join(set.dir, diskSeenWALFile),
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	return err
+}
+
+// Close 会把内存表写入分段并关闭所有文件。
+func (set *diskSeenSet) Close() error {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	var err error
+	if set.wal != nil {
+		err = set.flush()
+		set.wal.Close()
+		set.wal = nil
+	}
+	for _, seg := range set.segments {
+		seg.file.Close()
+	}
+	set.segments = nil
+	return err
+}
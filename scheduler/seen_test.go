@@ -0,0 +1,313 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeenSetArgs(t *testing.T) {
+	valid := []SeenSetArgs{
+		{},
+		{Type: SEEN_SET_TYPE_MAP},
+		{Type: SEEN_SET_TYPE_BLOOM},
+		{Type: SEEN_SET_TYPE_BLOOM, FalsePositiveRate: 0.01, InitialCapacity: 10},
+		{Type: SEEN_SET_TYPE_DISK, Dir: "seen"},
+	}
+	for _, args := range valid {
+		if err := args.Check(); err != nil {
+			t.Fatalf("An error occurs when checking seen set args %#v: %s", args, err)
+		}
+	}
+	invalid := []SeenSetArgs{
+		{Type: "unknown"},
+		{Type: SEEN_SET_TYPE_BLOOM, FalsePositiveRate: -0.1},
+		{Type: SEEN_SET_TYPE_BLOOM, FalsePositiveRate: 1},
+		{Type: SEEN_SET_TYPE_DISK},
+	}
+	for _, args := range invalid {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking invalid seen set args %#v!", args)
+		}
+		if _, err := NewSeenSet(args); err == nil {
+			t.Fatalf("No error when creating seen set with invalid args %#v!", args)
+		}
+	}
+}
+
+// testSeenSetExact 用于测试精确的集合。
+func testSeenSetExact(seen SeenSet, number int, t *testing.T) {
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if seen.Contains(key) {
+			t.Fatalf("Seen set %q contains key %q before adding it!", seen.Type(), key)
+		}
+		added, err := seen.Add(key)
+		if err != nil {
+			t.Fatalf("An error occurs when adding key %q: %s", key, err)
+		}
+		if !added {
+			t.Fatalf("Couldn't add key %q to seen set %q!", key, seen.Type())
+		}
+		if added, _ = seen.Add(key); added {
+			t.Fatalf("Key %q is added to seen set %q twice!", key, seen.Type())
+		}
+	}
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !seen.Contains(key) {
+			t.Fatalf("Seen set %q doesn't contain key %q!", seen.Type(), key)
+		}
+	}
+	if seen.Len() != uint64(number) {
+		t.Fatalf("Inconsistent seen set length: expected: %d, actual: %d",
+			number, seen.Len())
+	}
+	if fpRate := seen.FalsePositiveRate(); fpRate != 0 {
+		t.Fatalf("Inconsistent false positive rate of exact seen set: expected: 0, actual: %v",
+			fpRate)
+	}
+}
+
+func TestMapSeenSet(t *testing.T) {
+	seen, err := NewSeenSet(SeenSetArgs{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating seen set: %s", err)
+	}
+	if seen.Type() != SEEN_SET_TYPE_MAP {
+		t.Fatalf("Inconsistent seen set type: expected: %q, actual: %q",
+			SEEN_SET_TYPE_MAP, seen.Type())
+	}
+	testSeenSetExact(seen, 1000, t)
+	summary := getSeenSetSummary(seen)
+	expected := SeenSetSummaryStruct{Type: SEEN_SET_TYPE_MAP, Number: 1000}
+	if summary != expected {
+		t.Fatalf("Inconsistent seen set summary: expected: %#v, actual: %#v",
+			expected, summary)
+	}
+}
+
+func TestBloomSeenSet(t *testing.T) {
+	fpRate := 0.01
+	seen, err := NewSeenSet(SeenSetArgs{
+		Type:              SEEN_SET_TYPE_BLOOM,
+		FalsePositiveRate: fpRate,
+		InitialCapacity:   100,
+	})
+	if err != nil {
+		t.Fatalf("An error occurs when creating seen set: %s", err)
+	}
+	// 加入的键远多于初始容量，过滤器需要扩展。
+	number := 10000
+	var added uint64
+	for i := 0; i < number; i++ {
+		ok, err := seen.Add(fmt.Sprintf("http://a.com/%d", i))
+		if err != nil {
+			t.Fatalf("An error occurs when adding key: %s", err)
+		}
+		if ok {
+			added++
+		}
+	}
+	if seen.Len() != added {
+		t.Fatalf("Inconsistent seen set length: expected: %d, actual: %d",
+			added, seen.Len())
+	}
+	if len(seen.(*bloomSeenSet).filters) < 2 {
+		t.Fatal("The bloom filter doesn't grow!")
+	}
+	// 布隆过滤器不会漏判。
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !seen.Contains(key) {
+			t.Fatalf("Seen set doesn't contain key %q!", key)
+		}
+	}
+	estimated := seen.FalsePositiveRate()
+	if estimated <= 0 || estimated > fpRate {
+		t.Fatalf("Invalid estimated false positive rate: %v (target: %v)",
+			estimated, fpRate)
+	}
+	var falsePositives int
+	for i := 0; i < number; i++ {
+		if seen.Contains(fmt.Sprintf("http://b.com/%d", i)) {
+			falsePositives++
+		}
+	}
+	if actual := float64(falsePositives) / float64(number); actual > fpRate*2 {
+		t.Fatalf("Too high false positive rate: %v (target: %v)", actual, fpRate)
+	}
+}
+
+func TestBloomSeenSetInParallel(t *testing.T) {
+	seen := NewBloomSeenSet(64, 0.001)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("http://a.com/%d/%d", i, j)
+				seen.Add(key)
+				if !seen.Contains(key) {
+					t.Errorf("Seen set doesn't contain key %q!", key)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDiskSeenSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seen")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(memLimit, maxSegments int) {
+		diskSeenMemLimit, diskSeenMaxSegments = memLimit, maxSegments
+	}(diskSeenMemLimit, diskSeenMaxSegments)
+	diskSeenMemLimit, diskSeenMaxSegments = 100, 3
+	seen, err := NewSeenSet(SeenSetArgs{Type: SEEN_SET_TYPE_DISK, Dir: dir})
+	if err != nil {
+		t.Fatalf("An error occurs when creating seen set: %s", err)
+	}
+	if seen.Type() != SEEN_SET_TYPE_DISK {
+		t.Fatalf("Inconsistent seen set type: expected: %q, actual: %q",
+			SEEN_SET_TYPE_DISK, seen.Type())
+	}
+	// 加入的键会被写入多个分段并被合并。
+	number := 1050
+	testSeenSetExact(seen, number, t)
+	segments := len(seen.(*diskSeenSet).segments)
+	if segments == 0 || segments > diskSeenMaxSegments {
+		t.Fatalf("Invalid segment number: %d (max: %d)", segments, diskSeenMaxSegments)
+	}
+	if _, err := seen.Add("http://a.com/\n"); err == nil {
+		t.Fatal("No error when adding key with line break!")
+	}
+	// 模拟意外退出：不写入内存表就关闭文件，内存表中的键需要从预写日志中恢复。
+	crashed := seen.(*diskSeenSet)
+	crashed.wal.Close()
+	crashed.wal = nil
+	crashed.Close()
+	reopened, err := NewDiskSeenSet(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening seen set: %s", err)
+	}
+	if reopened.Len() != uint64(number) {
+		t.Fatalf("Inconsistent length of reopened seen set: expected: %d, actual: %d",
+			number, reopened.Len())
+	}
+	for i := 0; i < number; i++ {
+		key := fmt.Sprintf("http://a.com/%d", i)
+		if !reopened.Contains(key) {
+			t.Fatalf("Reopened seen set doesn't contain key %q!", key)
+		}
+	}
+	if reopened.Contains("http://b.com/") {
+		t.Fatal("Reopened seen set contains key never added!")
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("An error occurs when closing seen set: %s", err)
+	}
+	if _, err := reopened.Add("http://b.com/"); err == nil {
+		t.Fatal("No error when adding key to closed seen set!")
+	}
+	// 关闭时内存表会被写入分段。
+	data, err := ioutil.ReadFile(filepath.Join(dir, diskSeenWALFile))
+	if err != nil {
+		t.Fatalf("An error occurs when reading WAL: %s", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("Non-empty WAL after closing: %d bytes", len(data))
+	}
+	reopened, err = NewDiskSeenSet(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening seen set: %s", err)
+	}
+	defer reopened.Close()
+	if reopened.Len() != uint64(number) {
+		t.Fatalf("Inconsistent length of reopened seen set: expected: %d, actual: %d",
+			number, reopened.Len())
+	}
+	if added, _ := reopened.Add("http://a.com/1"); added {
+		t.Fatal("Key is added to reopened seen set twice!")
+	}
+	// 清空之后目录中不再有任何键。
+	if err := reopened.(*diskSeenSet).reset(); err != nil {
+		t.Fatalf("An error occurs when resetting seen set: %s", err)
+	}
+	if reopened.Len() != 0 || reopened.Contains("http://a.com/1") {
+		t.Fatalf("Seen set is not empty after reset: %d", reopened.Len())
+	}
+	if added, _ := reopened.Add("http://a.com/1"); !added {
+		t.Fatal("Key couldn't be added after reset!")
+	}
+	reopened.Close()
+	reopened, err = NewDiskSeenSet(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reopening seen set: %s", err)
+	}
+	defer reopened.Close()
+	if reopened.Len() != 1 {
+		t.Fatalf("Inconsistent length of reopened seen set: expected: %d, actual: %d",
+			1, reopened.Len())
+	}
+}
+
+func TestSchedDiskSeenSetFresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "seen")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	// 模拟上次爬取留下的键。
+	previous, err := NewDiskSeenSet(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when creating seen set: %s", err)
+	}
+	for _, key := range []string{server.URL, server.URL + "/", "http://a.com/"} {
+		previous.Add(key)
+	}
+	previous.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.SeenSet = SeenSetArgs{Type: SEEN_SET_TYPE_DISK, Dir: dir}
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	// 全新的爬取不应把种子当作已见URL。
+	summary := sched.Summary().Struct()
+	if summary.Downloaders[0].Completed != 1 || summary.Dropped.Duplicate != 0 {
+		t.Fatalf("The seed has been treated as seen: %#v, %#v",
+			summary.Downloaders[0], summary.Dropped)
+	}
+	if summary.NumURL.Number != 1 {
+		t.Fatalf("Inconsistent seen URL number: expected: %d, actual: %d",
+			1, summary.NumURL.Number)
+	}
+}
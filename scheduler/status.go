@@ -23,17 +23,25 @@ const (
 	SCHED_STATUS_STOPPING Status = 5
 	// SCHED_STATUS_STOPPED 代表已停止的状态。
 	SCHED_STATUS_STOPPED Status = 6
+	// SCHED_STATUS_PAUSING 代表正在暂停的状态。
+	SCHED_STATUS_PAUSING Status = 7
+	// SCHED_STATUS_PAUSED 代表已暂停的状态。
+	SCHED_STATUS_PAUSED Status = 8
+	// SCHED_STATUS_RESUMING 代表正在恢复的状态。
+	SCHED_STATUS_RESUMING Status = 9
 )
 
 // checkStatus 用于状态的检查。
 // 参数currentStatus代表当前的状态。
 // 参数wantedStatus代表想要的状态。
 // 检查规则：
-//     1. 处于正在初始化、正在启动或正在停止状态时，不能从外部改变状态。
-//     2. 想要的状态只能是正在初始化、正在启动或正在停止状态中的一个。
+//     1. 处于正在初始化、正在启动、正在停止、正在暂停或正在恢复状态时，不能从外部改变状态。
+//     2. 想要的状态只能是正在初始化、正在启动、正在停止、正在暂停或正在恢复状态中的一个。
 //     3. 处于未初始化状态时，不能变为正在启动或正在停止状态。
-//     4. 处于已启动状态时，不能变为正在初始化或正在启动状态。
-//     5. 只要未处于已启动状态就不能变为正在停止状态。
+//     4. 处于已启动或已暂停状态时，不能变为正在初始化或正在启动状态。
+//     5. 只要未处于已启动或已暂停状态就不能变为正在停止状态。
+//     6. 只要未处于已启动状态就不能变为正在暂停状态。
+//     7. 只要未处于已暂停状态就不能变为正在恢复状态。
 func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (err error) {
 	if lock != nil {
 		lock.Lock()
@@ -46,6 +54,10 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 		err = genError("the scheduler is being started!")
 	case SCHED_STATUS_STOPPING:
 		err = genError("the scheduler is being stopped!")
+	case SCHED_STATUS_PAUSING:
+		err = genError("the scheduler is being paused!")
+	case SCHED_STATUS_RESUMING:
+		err = genError("the scheduler is being resumed!")
 	}
 	if err != nil {
 		return
@@ -61,6 +73,8 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 		switch currentStatus {
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started!")
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused!")
 		}
 	case SCHED_STATUS_STARTING:
 		switch currentStatus {
@@ -68,11 +82,22 @@ func checkStatus(currentStatus Status, wantedStatus Status, lock sync.Locker) (e
 			err = genError("the scheduler has not been initialized!")
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started!")
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused!")
 		}
 	case SCHED_STATUS_STOPPING:
+		if currentStatus != SCHED_STATUS_STARTED &&
+			currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("the scheduler has not been started!")
+		}
+	case SCHED_STATUS_PAUSING:
 		if currentStatus != SCHED_STATUS_STARTED {
 			err = genError("the scheduler has not been started!")
 		}
+	case SCHED_STATUS_RESUMING:
+		if currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("the scheduler has not been paused!")
+		}
 	default:
 		errMsg :=
 			fmt.Sprintf("unsupported wanted status for check! (wantedStatus: %d)",
@@ -99,6 +124,12 @@ func GetStatusDescription(status Status) string {
 		return "stopping"
 	case SCHED_STATUS_STOPPED:
 		return "stopped"
+	case SCHED_STATUS_PAUSING:
+		return "pausing"
+	case SCHED_STATUS_PAUSED:
+		return "paused"
+	case SCHED_STATUS_RESUMING:
+		return "resuming"
 	default:
 		return "unkown"
 	}
@@ -13,6 +13,8 @@ func TestCheckStatus(t *testing.T) {
 		SCHED_STATUS_INITIALIZING,
 		SCHED_STATUS_STARTING,
 		SCHED_STATUS_STOPPING,
+		SCHED_STATUS_PAUSING,
+		SCHED_STATUS_RESUMING,
 	}
 	wantedStatus = SCHED_STATUS_INITIALIZING
 	for _, currentStatus := range currentStatusList {
@@ -28,6 +30,7 @@ func TestCheckStatus(t *testing.T) {
 		SCHED_STATUS_INITIALIZED,
 		SCHED_STATUS_STARTED,
 		SCHED_STATUS_STOPPED,
+		SCHED_STATUS_PAUSED,
 	}
 	for _, wantedStatus := range wantedStatusList {
 		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
@@ -85,11 +88,75 @@ func TestCheckStatus(t *testing.T) {
 				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
 		}
 	}
+	currentStatusList = []Status{
+		SCHED_STATUS_STARTED,
+		SCHED_STATUS_PAUSED,
+	}
+	for _, currentStatus := range currentStatusList {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err != nil {
+			t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
+				err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
+	// 6. 只要未处于已启动状态就不能变为正在暂停状态。
+	currentStatusList = []Status{
+		SCHED_STATUS_UNINITIALIZED,
+		SCHED_STATUS_INITIALIZING,
+		SCHED_STATUS_INITIALIZED,
+		SCHED_STATUS_STARTING,
+		SCHED_STATUS_STOPPING,
+		SCHED_STATUS_STOPPED,
+		SCHED_STATUS_PAUSING,
+		SCHED_STATUS_PAUSED,
+		SCHED_STATUS_RESUMING,
+	}
+	wantedStatus = SCHED_STATUS_PAUSING
+	for _, currentStatus := range currentStatusList {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
+			t.Fatalf("It still can check status with current status %q wanted status %q!",
+				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
 	currentStatus = SCHED_STATUS_STARTED
 	if err := checkStatus(currentStatus, wantedStatus, nil); err != nil {
 		t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
 			err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
 	}
+	// 7. 只要未处于已暂停状态就不能变为正在恢复状态。
+	currentStatusList = []Status{
+		SCHED_STATUS_UNINITIALIZED,
+		SCHED_STATUS_INITIALIZING,
+		SCHED_STATUS_INITIALIZED,
+		SCHED_STATUS_STARTING,
+		SCHED_STATUS_STARTED,
+		SCHED_STATUS_STOPPING,
+		SCHED_STATUS_STOPPED,
+		SCHED_STATUS_PAUSING,
+		SCHED_STATUS_RESUMING,
+	}
+	wantedStatus = SCHED_STATUS_RESUMING
+	for _, currentStatus := range currentStatusList {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
+			t.Fatalf("It still can check status with current status %q wanted status %q!",
+				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
+	currentStatus = SCHED_STATUS_PAUSED
+	if err := checkStatus(currentStatus, wantedStatus, nil); err != nil {
+		t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
+			err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+	}
+	// 已暂停状态下不能变为正在初始化或正在启动状态。
+	wantedStatusList = []Status{
+		SCHED_STATUS_INITIALIZING,
+		SCHED_STATUS_STARTING,
+	}
+	for _, wantedStatus := range wantedStatusList {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
+			t.Fatalf("It still can check status with current status %q wanted status %q!",
+				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
 }
 
 func TestCheckStatusInParallel(t *testing.T) {
@@ -156,7 +223,10 @@ func TestGetStatusDescription(t *testing.T) {
 		SCHED_STATUS_STARTED:       "started",
 		SCHED_STATUS_STOPPING:      "stopping",
 		SCHED_STATUS_STOPPED:       "stopped",
-		Status(7):                  "unkown",
+		SCHED_STATUS_PAUSING:       "pausing",
+		SCHED_STATUS_PAUSED:        "paused",
+		SCHED_STATUS_RESUMING:      "resuming",
+		Status(10):                 "unkown",
 	}
 	for status, expectedDesc := range statusMap {
 		desc := GetStatusDescription(status)
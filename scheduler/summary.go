@@ -1,14 +1,13 @@
 package scheduler
 
 import (
-	"gopcpv2-web-spider/module"
 	"encoding/json"
+	"gopcpv2-web-spider/module"
 	"gopcpv2-web-spider/toolkit/buffer"
-	"sort"
 	"log"
+	"sort"
 )
 
-
 // SchedSummary 代表调度器摘要的接口类型。
 type SchedSummary interface {
 	// Struct 用于获得摘要信息的结构化形式。
@@ -50,18 +49,32 @@ type mySchedSummary struct {
 
 // SummaryStruct 代表调度器摘要的结构。
 type SummaryStruct struct {
-	RequestArgs     RequestArgs             `json:"request_args"`
-	DataArgs        DataArgs                `json:"data_args"`
-	ModuleArgs      ModuleArgsSummary       `json:"module_args"`
-	Status          string                  `json:"status"`
-	Downloaders     []module.SummaryStruct  `json:"downloaders"`
-	Analyzers       []module.SummaryStruct  `json:"analyzers"`
-	Pipelines       []module.SummaryStruct  `json:"pipelines"`
-	ReqBufferPool   BufferPoolSummaryStruct `json:"request_buffer_pool"`
-	RespBufferPool  BufferPoolSummaryStruct `json:"response_buffer_pool"`
-	ItemBufferPool  BufferPoolSummaryStruct `json:"item_buffer_pool"`
-	ErrorBufferPool BufferPoolSummaryStruct `json:"error_buffer_pool"`
-	NumURL          uint64                  `json:"url_number"`
+	RequestArgs         RequestArgs              `json:"request_args"`
+	DataArgs            DataArgs                 `json:"data_args"`
+	ModuleArgs          ModuleArgsSummary        `json:"module_args"`
+	Status              string                   `json:"status"`
+	Downloaders         []module.SummaryStruct   `json:"downloaders"`
+	Analyzers           []module.SummaryStruct   `json:"analyzers"`
+	Pipelines           []module.SummaryStruct   `json:"pipelines"`
+	DrainingModules     []string                 `json:"draining_modules"`
+	Balancers           BalancerArgs             `json:"balancers"`
+	ReqBufferPool       BufferPoolSummaryStruct  `json:"request_buffer_pool"`
+	RespBufferPool      BufferPoolSummaryStruct  `json:"response_buffer_pool"`
+	ItemBufferPool      BufferPoolSummaryStruct  `json:"item_buffer_pool"`
+	ErrorBufferPool     BufferPoolSummaryStruct  `json:"error_buffer_pool"`
+	NumURL              SeenSetSummaryStruct     `json:"url_number"`
+	Checkpoint          CheckpointSummaryStruct  `json:"checkpoint"`
+	Abandoned           AbandonedSummaryStruct   `json:"abandoned"`
+	Dropped             DropSummaryStruct        `json:"dropped"`
+	Observers           ObserversSummaryStruct   `json:"observers"`
+	InFlight            InFlightSummaryStruct    `json:"in_flight"`
+	Workers             WorkersSummaryStruct     `json:"workers"`
+	HostQueues          []HostQueueSummaryStruct `json:"host_queues"`
+	Robots              RobotsSummaryStruct      `json:"robots"`
+	Retry               RetrySummaryStruct       `json:"retry"`
+	Redirect            RedirectSummaryStruct    `json:"redirect"`
+	TransferHosts       []TransferSummaryStruct  `json:"transfer_hosts"`
+	TransferDownloaders []TransferSummaryStruct  `json:"transfer_downloaders"`
 }
 
 // Same 用于判断当前的调度器摘要与另一份是否相同。
@@ -102,6 +115,17 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 			return false
 		}
 	}
+	if len(another.DrainingModules) != len(one.DrainingModules) {
+		return false
+	}
+	for i, mid := range another.DrainingModules {
+		if mid != one.DrainingModules[i] {
+			return false
+		}
+	}
+	if another.Balancers != one.Balancers {
+		return false
+	}
 	if another.ReqBufferPool != one.ReqBufferPool {
 		return false
 	}
@@ -117,11 +141,63 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 	if another.NumURL != one.NumURL {
 		return false
 	}
+	if another.Checkpoint != one.Checkpoint {
+		return false
+	}
+	if another.Abandoned != one.Abandoned {
+		return false
+	}
+	if another.Dropped != one.Dropped {
+		return false
+	}
+	if another.Observers != one.Observers {
+		return false
+	}
+	if another.InFlight != one.InFlight {
+		return false
+	}
+	if another.Workers != one.Workers {
+		return false
+	}
+	if len(another.HostQueues) != len(one.HostQueues) {
+		return false
+	}
+	for i, hq := range another.HostQueues {
+		if hq != one.HostQueues[i] {
+			return false
+		}
+	}
+	if another.Robots != one.Robots {
+		return false
+	}
+	if another.Retry != one.Retry {
+		return false
+	}
+	if another.Redirect != one.Redirect {
+		return false
+	}
+	if len(another.TransferHosts) != len(one.TransferHosts) {
+		return false
+	}
+	for i, ts := range another.TransferHosts {
+		if ts != one.TransferHosts[i] {
+			return false
+		}
+	}
+	if len(another.TransferDownloaders) != len(one.TransferDownloaders) {
+		return false
+	}
+	for i, ts := range another.TransferDownloaders {
+		if ts != one.TransferDownloaders[i] {
+			return false
+		}
+	}
 	return true
 }
 
 func (ss *mySchedSummary) Struct() SummaryStruct {
 	registrar := ss.sched.registrar
+	transferHosts, transferDownloaders := ss.sched.transfers.summary()
 	return SummaryStruct{
 		RequestArgs:     ss.requestArgs,
 		DataArgs:        ss.dataArgs,
@@ -130,11 +206,29 @@ func (ss *mySchedSummary) Struct() SummaryStruct {
 		Downloaders:     getModuleSummaries(registrar, module.TYPE_DOWNLOADER),
 		Analyzers:       getModuleSummaries(registrar, module.TYPE_ANALYZER),
 		Pipelines:       getModuleSummaries(registrar, module.TYPE_PIPELINE),
-		ReqBufferPool:   getBufferPoolSummary(ss.sched.reqBufferPool),
-		RespBufferPool:  getBufferPoolSummary(ss.sched.respBufferPool),
-		ItemBufferPool:  getBufferPoolSummary(ss.sched.itemBufferPool),
-		ErrorBufferPool: getBufferPoolSummary(ss.sched.errorBufferPool),
-		NumURL:          ss.sched.urlMap.Len(),
+		DrainingModules: ss.sched.usage.drainingModules(),
+		Balancers: BalancerArgs{
+			Downloader: registrar.GetBalancer(module.TYPE_DOWNLOADER).Strategy(),
+			Analyzer:   registrar.GetBalancer(module.TYPE_ANALYZER).Strategy(),
+			Pipeline:   registrar.GetBalancer(module.TYPE_PIPELINE).Strategy(),
+		},
+		ReqBufferPool:       getBufferPoolSummary(ss.sched.reqBufferPool),
+		RespBufferPool:      getBufferPoolSummary(ss.sched.respBufferPool),
+		ItemBufferPool:      getBufferPoolSummary(ss.sched.itemBufferPool),
+		ErrorBufferPool:     getBufferPoolSummary(ss.sched.errorBufferPool),
+		NumURL:              getSeenSetSummary(ss.sched.seen),
+		Checkpoint:          ss.sched.checkpointer.summary(ss.sched.checkpointCounters()),
+		Abandoned:           ss.sched.abandonedSummary(),
+		Dropped:             ss.sched.drops.summary(),
+		Observers:           ss.sched.observers.summary(),
+		InFlight:            ss.sched.tracker.summary(),
+		Workers:             ss.sched.workersSummary(),
+		HostQueues:          ss.sched.politeness.summary(),
+		Robots:              ss.sched.robots.summary(),
+		Retry:               ss.sched.retry.summary(),
+		Redirect:            ss.sched.redirect.summary(),
+		TransferHosts:       transferHosts,
+		TransferDownloaders: transferDownloaders,
 	}
 }
 
@@ -147,6 +241,18 @@ func (ss *mySchedSummary) String() string {
 	return string(b)
 }
 
+// AbandonedSummaryStruct 代表平稳停止调度器时被放弃的数据的摘要类型。
+type AbandonedSummaryStruct struct {
+	// Requests 代表未被下载的请求的数量。
+	Requests uint64 `json:"requests"`
+	// Downloads 代表未完成的下载的数量。
+	Downloads uint64 `json:"downloads"`
+	// Responses 代表未被解析完毕的响应的数量。
+	Responses uint64 `json:"responses"`
+	// Items 代表未被处理完毕的条目的数量。
+	Items uint64 `json:"items"`
+}
+
 // BufferPoolSummaryStruct 代表缓冲池的摘要类型。
 type BufferPoolSummaryStruct struct {
 	BufferCap       uint32 `json:"buffer_cap"`
@@ -170,8 +276,10 @@ func getModuleSummaries(registrar module.Registrar, mType module.Type) []module.
 	moduleMap, _ := registrar.GetAllByType(mType)
 	summaries := []module.SummaryStruct{}
 	if len(moduleMap) > 0 {
-		for _, module := range moduleMap {
-			summaries = append(summaries, module.Summary())
+		for mid, module := range moduleMap {
+			summary := module.Summary()
+			summary.Breaker = registrar.GetBreakerState(mid)
+			summaries = append(summaries, summary)
 		}
 	}
 	if len(summaries) > 1 {
@@ -181,4 +289,4 @@ func getModuleSummaries(registrar module.Registrar, mType module.Type) []module.
 			})
 	}
 	return summaries
-}
\ No newline at end of file
+}
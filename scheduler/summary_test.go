@@ -157,7 +157,7 @@ func TestSummaryStruct(t *testing.T) {
 	}
 	another.ErrorBufferPool = one.ErrorBufferPool
 	// 不同的URL数量。
-	another.NumURL = 14
+	another.NumURL.Number = 14
 	if one.Same(another) {
 		t.Fatalf("Same scheduler summaries with different URL number!")
 	}
@@ -272,7 +272,11 @@ func TestSummaryString(t *testing.T) {
         "buffer_number": 1,
         "total": 0
     },
-    "url_number": 0
+    "url_number": {
+        "type": "map",
+        "number": 0,
+        "false_positive_rate": 0
+    }
 }`
 	summaryStr := summary.String()
 	if summaryStr != expectedSummaryStr {
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// workKind 代表被记录的数据的种类。
+type workKind int
+
+const (
+	// workKindRequest 代表请求。
+	workKindRequest workKind = iota
+	// workKindResponse 代表响应。
+	workKindResponse
+	// workKindItem 代表条目。
+	workKindItem
+	// workKindError 代表错误。
+	workKindError
+	// workKindNumber 代表数据种类的数量。
+	workKindNumber
+)
+
+// workTracker 用于精确记录调度器中尚未处理完毕的数据的数量。
+// 数据在被发送时计入，在被相应的处理流程处理完毕
+// （对错误来说是放入错误缓冲池）或被丢弃时移除。
+// 处理过程中产生的新数据总会在原数据移除之前计入，
+// 所以数量为0时就代表确实已没有需要处理的数据了。
+// 错误只计入其自身种类的数量而不计入总数量，
+// 因为错误缓冲池可能无人读取，它们不应妨碍发出完成信号。
+type workTracker struct {
+	numbers [workKindNumber]int64
+	// lock 用于保护以下字段以及总数量与完成信号之间的一致性。
+	lock    sync.Mutex
+	total   int64
+	doneCh  chan struct{}
+	closed  bool
+	stopped bool
+}
+
+// newWorkTracker 用于创建一个数据记录器。
+func newWorkTracker() *workTracker {
+	return &workTracker{doneCh: make(chan struct{})}
+}
+
+// incr 会计入一个给定种类的数据。
+func (wt *workTracker) incr(kind workKind) {
+	if wt == nil {
+		return
+	}
+	atomic.AddInt64(&wt.numbers[kind], 1)
+	if kind == workKindError {
+		return
+	}
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	wt.total++
+	// 已完成之后又有了新数据，需要一个新的完成信号。
+	if wt.closed && !wt.stopped {
+		wt.doneCh = make(chan struct{})
+		wt.closed = false
+	}
+}
+
+// decr 会移除一个给定种类的数据。
+func (wt *workTracker) decr(kind workKind) {
+	if wt == nil {
+		return
+	}
+	atomic.AddInt64(&wt.numbers[kind], -1)
+	if kind == workKindError {
+		return
+	}
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	wt.total--
+	if wt.total == 0 {
+		wt.closeDone()
+	}
+}
+
+// check 会在没有任何需要处理的数据时发出完成信号。
+func (wt *workTracker) check() {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	if wt.total == 0 {
+		wt.closeDone()
+	}
+}
+
+// stop 会发出完成信号，并且之后不会再重置它。
+func (wt *workTracker) stop() {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	wt.stopped = true
+	wt.closeDone()
+}
+
+// closeDone 会关闭完成信号通道。调用方需持有锁。
+func (wt *workTracker) closeDone() {
+	if !wt.closed {
+		close(wt.doneCh)
+		wt.closed = true
+	}
+}
+
+// done 用于获取当前的完成信号通道。
+func (wt *workTracker) done() <-chan struct{} {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	return wt.doneCh
+}
+
+// number 用于获取给定种类的数据的数量。
+func (wt *workTracker) number(kind workKind) uint64 {
+	n := atomic.LoadInt64(&wt.numbers[kind])
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
+// totalNumber 用于获取所有数据的数量。
+func (wt *workTracker) totalNumber() uint64 {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	if wt.total < 0 {
+		return 0
+	}
+	return uint64(wt.total)
+}
+
+// InFlightSummaryStruct 代表尚未处理完毕的数据的摘要类型。
+type InFlightSummaryStruct struct {
+	Requests  uint64 `json:"requests"`
+	Responses uint64 `json:"responses"`
+	Items     uint64 `json:"items"`
+	Errors    uint64 `json:"errors"`
+}
+
+// summary 用于获取尚未处理完毕的数据的摘要。
+func (wt *workTracker) summary() InFlightSummaryStruct {
+	if wt == nil {
+		return InFlightSummaryStruct{}
+	}
+	return InFlightSummaryStruct{
+		Requests:  wt.number(workKindRequest),
+		Responses: wt.number(workKindResponse),
+		Items:     wt.number(workKindItem),
+		Errors:    wt.number(workKindError),
+	}
+}
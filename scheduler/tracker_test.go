@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// isClosed 用于判断给定的通道是否已被关闭。
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestWorkTracker(t *testing.T) {
+	wt := newWorkTracker()
+	if isClosed(wt.done()) {
+		t.Fatal("The done channel has been closed before any check!")
+	}
+	wt.incr(workKindRequest)
+	wt.check()
+	if isClosed(wt.done()) {
+		t.Fatal("The done channel has been closed with a pending request!")
+	}
+	// 处理请求时产生的响应会在请求移除之前计入。
+	wt.incr(workKindResponse)
+	wt.decr(workKindRequest)
+	if isClosed(wt.done()) {
+		t.Fatal("The done channel has been closed with a pending response!")
+	}
+	expectedSummary := InFlightSummaryStruct{Responses: 1}
+	if summary := wt.summary(); summary != expectedSummary {
+		t.Fatalf("Inconsistent in-flight summary: expected: %#v, actual: %#v",
+			expectedSummary, summary)
+	}
+	done := wt.done()
+	wt.decr(workKindResponse)
+	if !isClosed(done) {
+		t.Fatal("The done channel has not been closed without pending data!")
+	}
+	// 完成之后又有了新数据。
+	wt.incr(workKindItem)
+	if isClosed(wt.done()) {
+		t.Fatal("The done channel has not been reset with new data!")
+	}
+	if wt.totalNumber() != 1 {
+		t.Fatalf("Inconsistent total number: expected: %d, actual: %d",
+			1, wt.totalNumber())
+	}
+	// 停止之后完成信号不会再被重置。
+	wt.stop()
+	if !isClosed(wt.done()) {
+		t.Fatal("The done channel has not been closed after stop!")
+	}
+	wt.decr(workKindItem)
+	wt.incr(workKindError)
+	if !isClosed(wt.done()) {
+		t.Fatal("The done channel has been reset after stop!")
+	}
+	// nil的记录器不应引发恐慌。
+	var nilTracker *workTracker
+	nilTracker.incr(workKindRequest)
+	nilTracker.decr(workKindRequest)
+	if nilTracker.summary() != (InFlightSummaryStruct{}) {
+		t.Fatal("Inconsistent in-flight summary for nil tracker!")
+	}
+}
+
+func TestWorkTrackerInParallel(t *testing.T) {
+	wt := newWorkTracker()
+	wt.incr(workKindRequest)
+	var wg sync.WaitGroup
+	number := 100
+	wg.Add(number)
+	for i := 0; i < number; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				wt.incr(workKindResponse)
+				wt.incr(workKindItem)
+				wt.decr(workKindResponse)
+				wt.decr(workKindItem)
+			}
+		}()
+	}
+	wg.Wait()
+	if isClosed(wt.done()) {
+		t.Fatal("The done channel has been closed with a pending request!")
+	}
+	wt.decr(workKindRequest)
+	select {
+	case <-wt.done():
+	case <-time.After(time.Second):
+		t.Fatal("The done channel has not been closed without pending data!")
+	}
+}
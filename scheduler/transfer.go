@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gopcpv2-web-spider/module"
+)
+
+// TransferSummaryStruct 代表某个主机或下载器的下载耗时及传输情况的摘要。
+// 其中的各项耗时都是每个响应的平均值。
+type TransferSummaryStruct struct {
+	// Key 代表主机名或下载器的MID。
+	Key string `json:"key"`
+	// Responses 代表记录了下载耗时的响应的数量。
+	Responses uint64 `json:"responses"`
+	// Bytes 代表响应主体的总字节数。
+	Bytes uint64 `json:"bytes"`
+	// DNS 代表DNS查询的平均耗时。
+	DNS time.Duration `json:"dns"`
+	// Connect 代表建立TCP连接的平均耗时。
+	Connect time.Duration `json:"connect"`
+	// TLS 代表TLS握手的平均耗时。
+	TLS time.Duration `json:"tls"`
+	// TTFB 代表收到第一个字节的平均耗时。
+	TTFB time.Duration `json:"ttfb"`
+	// Total 代表下载的平均总耗时。
+	Total time.Duration `json:"total"`
+	// MaxTotal 代表下载的最大总耗时。
+	MaxTotal time.Duration `json:"max_total"`
+	// RemoteIP 代表最近一次下载所连接的远端IP，仅针对主机。
+	RemoteIP string `json:"remote_ip,omitempty"`
+}
+
+// transferTotal 代表下载耗时及传输情况的累计值。
+type transferTotal struct {
+	responses uint64
+	bytes     uint64
+	dns       time.Duration
+	connect   time.Duration
+	tls       time.Duration
+	ttfb      time.Duration
+	total     time.Duration
+	maxTotal  time.Duration
+	remoteIP  string
+}
+
+// add 用于累计一次下载的耗时及传输情况。
+func (tt *transferTotal) add(trace module.Trace) {
+	tt.responses++
+	if trace.Bytes > 0 {
+		tt.bytes += uint64(trace.Bytes)
+	}
+	tt.dns += trace.DNS
+	tt.connect += trace.Connect
+	tt.tls += trace.TLS
+	tt.ttfb += trace.TTFB
+	tt.total += trace.Total
+	if trace.Total > tt.maxTotal {
+		tt.maxTotal = trace.Total
+	}
+	if trace.RemoteIP != "" {
+		tt.remoteIP = trace.RemoteIP
+	}
+}
+
+// summary 用于生成累计值的摘要。
+func (tt *transferTotal) summary(key string) TransferSummaryStruct {
+	n := time.Duration(tt.responses)
+	return TransferSummaryStruct{
+		Key:       key,
+		Responses: tt.responses,
+		Bytes:     tt.bytes,
+		DNS:       tt.dns / n,
+		Connect:   tt.connect / n,
+		TLS:       tt.tls / n,
+		TTFB:      tt.ttfb / n,
+		Total:     tt.total / n,
+		MaxTotal:  tt.maxTotal,
+		RemoteIP:  tt.remoteIP,
+	}
+}
+
+// transferStats 代表按主机和下载器分别累计的下载耗时及传输情况。
+type transferStats struct {
+	lock        sync.Mutex
+	hosts       map[string]*transferTotal
+	downloaders map[module.MID]*transferTotal
+}
+
+// newTransferStats 用于创建一个下载耗时及传输情况的累计器。
+func newTransferStats() *transferStats {
+	return &transferStats{
+		hosts:       map[string]*transferTotal{},
+		downloaders: map[module.MID]*transferTotal{},
+	}
+}
+
+// record 用于记录给定下载器对给定请求的下载耗时及传输情况。
+// 没有记录下载耗时的响应会被忽略。
+func (ts *transferStats) record(req *module.Request, mid module.MID, resp *module.Response) {
+	if ts == nil || req == nil || !req.Valid() || resp == nil {
+		return
+	}
+	trace := resp.Trace()
+	if trace.Total <= 0 {
+		return
+	}
+	host := req.HTTPReq().URL.Hostname()
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ht, ok := ts.hosts[host]
+	if !ok {
+		ht = &transferTotal{}
+		ts.hosts[host] = ht
+	}
+	ht.add(trace)
+	dt, ok := ts.downloaders[mid]
+	if !ok {
+		dt = &transferTotal{}
+		ts.downloaders[mid] = dt
+	}
+	trace.RemoteIP = ""
+	dt.add(trace)
+}
+
+// summary 用于获取按主机和按下载器的摘要，它们都已按键排序。
+func (ts *transferStats) summary() (hosts, downloaders []TransferSummaryStruct) {
+	hosts = []TransferSummaryStruct{}
+	downloaders = []TransferSummaryStruct{}
+	if ts == nil {
+		return
+	}
+	ts.lock.Lock()
+	for host, ht := range ts.hosts {
+		hosts = append(hosts, ht.summary(host))
+	}
+	for mid, dt := range ts.downloaders {
+		downloaders = append(downloaders, dt.summary(string(mid)))
+	}
+	ts.lock.Unlock()
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Key < hosts[j].Key
+	})
+	sort.Slice(downloaders, func(i, j int) bool {
+		return downloaders[i].Key < downloaders[j].Key
+	})
+	return
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestTransferStats(t *testing.T) {
+	ts := newTransferStats()
+	genReq := func(url string) *module.Request {
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		return module.NewRequest(httpReq, 0)
+	}
+	genResp := func(trace module.Trace) *module.Response {
+		return module.NewResponse(&http.Response{StatusCode: http.StatusOK}, 0).WithTrace(trace)
+	}
+	ts.record(genReq("http://www.sogou.com/a"), "D1", genResp(module.Trace{
+		DNS: 2 * time.Millisecond, Connect: 4 * time.Millisecond, TTFB: 10 * time.Millisecond,
+		Total: 20 * time.Millisecond, Bytes: 100, RemoteIP: "10.0.0.1"}))
+	ts.record(genReq("http://www.sogou.com/b"), "D2", genResp(module.Trace{
+		TTFB: 20 * time.Millisecond, Total: 40 * time.Millisecond, Bytes: 300, RemoteIP: "10.0.0.2"}))
+	ts.record(genReq("https://cn.bing.com/"), "D1", genResp(module.Trace{
+		TLS: 6 * time.Millisecond, TTFB: 30 * time.Millisecond, Total: 30 * time.Millisecond}))
+	// 没有记录下载耗时的响应会被忽略。
+	ts.record(genReq("https://cn.bing.com/"), "D1", genResp(module.Trace{}))
+	ts.record(genReq("https://cn.bing.com/"), "D1", nil)
+	hosts, downloaders := ts.summary()
+	expectedHosts := []TransferSummaryStruct{
+		{Key: "cn.bing.com", Responses: 1, TLS: 6 * time.Millisecond,
+			TTFB: 30 * time.Millisecond, Total: 30 * time.Millisecond, MaxTotal: 30 * time.Millisecond},
+		{Key: "www.sogou.com", Responses: 2, Bytes: 400, DNS: time.Millisecond,
+			Connect: 2 * time.Millisecond, TTFB: 15 * time.Millisecond, Total: 30 * time.Millisecond,
+			MaxTotal: 40 * time.Millisecond, RemoteIP: "10.0.0.2"},
+	}
+	expectedDownloaders := []TransferSummaryStruct{
+		{Key: "D1", Responses: 2, Bytes: 100, DNS: time.Millisecond, Connect: 2 * time.Millisecond,
+			TLS: 3 * time.Millisecond, TTFB: 20 * time.Millisecond, Total: 25 * time.Millisecond,
+			MaxTotal: 30 * time.Millisecond},
+		{Key: "D2", Responses: 1, Bytes: 300, TTFB: 20 * time.Millisecond,
+			Total: 40 * time.Millisecond, MaxTotal: 40 * time.Millisecond},
+	}
+	for _, c := range []struct {
+		expected []TransferSummaryStruct
+		actual   []TransferSummaryStruct
+	}{{expectedHosts, hosts}, {expectedDownloaders, downloaders}} {
+		if len(c.actual) != len(c.expected) {
+			t.Fatalf("Inconsistent transfer summary: expected: %#v, actual: %#v", c.expected, c.actual)
+		}
+		for i, s := range c.actual {
+			if s != c.expected[i] {
+				t.Fatalf("Inconsistent transfer summary: expected: %#v, actual: %#v", c.expected[i], s)
+			}
+		}
+	}
+}
+
+func TestSchedTransfer(t *testing.T) {
+	linkNumber := 5
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				for i := 0; i < linkNumber; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+				return
+			}
+			fmt.Fprint(w, "page")
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	summary := sched.Summary().Struct()
+	if len(summary.TransferHosts) != 1 || len(summary.TransferDownloaders) != 1 {
+		t.Fatalf("Inconsistent transfer summary: hosts: %#v, downloaders: %#v",
+			summary.TransferHosts, summary.TransferDownloaders)
+	}
+	hs := summary.TransferHosts[0]
+	expectedBytes := uint64(linkNumber*len("page") + len("/page0\n")*linkNumber)
+	if hs.Key != "127.0.0.1" || hs.Responses != uint64(linkNumber+1) ||
+		hs.Bytes != expectedBytes || hs.Total <= 0 || hs.RemoteIP != "127.0.0.1" {
+		t.Fatalf("Inconsistent transfer summary of host: %#v (expected bytes: %d)", hs, expectedBytes)
+	}
+	ds := summary.TransferDownloaders[0]
+	if ds.Key != string(moduleArgs.Downloaders[0].ID()) || ds.Responses != hs.Responses ||
+		ds.Bytes != hs.Bytes || ds.RemoteIP != "" {
+		t.Fatalf("Inconsistent transfer summary of downloader: %#v", ds)
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"log"
+	"sync/atomic"
+
+	"gopcpv2-web-spider/module"
+)
+
+// maxWorkerNumber 代表每个处理流程最多可以拥有的工作协程的数量。
+const maxWorkerNumber = 1024
+
+// 自动模式下每个已注册的组件对应的工作协程的数量。
+// 下载是IO密集型的，所以每个下载器可以同时承担多个请求。
+const (
+	autoDownloadWorkersPerModule uint32 = 4
+	autoAnalyzeWorkersPerModule  uint32 = 1
+	autoPipelineWorkersPerModule uint32 = 1
+)
+
+// workerNumbers 代表各处理流程实际使用的工作协程的数量。
+type workerNumbers struct {
+	download uint32
+	analyze  uint32
+	pick     uint32
+}
+
+// resolveWorkerNumber 用于确定某个处理流程实际使用的工作协程的数量。
+// 参数specified为0时，按照已注册的组件数量与给定的系数计算。
+// 结果值至少为1且不会超过maxWorkerNumber。
+func resolveWorkerNumber(specified uint32, moduleNumber int, factor uint32) uint32 {
+	number := specified
+	if number == 0 {
+		number = uint32(moduleNumber) * factor
+	}
+	if number == 0 {
+		number = 1
+	}
+	if number > maxWorkerNumber {
+		number = maxWorkerNumber
+	}
+	return number
+}
+
+// resolveWorkers 会根据工作协程参数和已注册的组件确定各处理流程的工作协程数量。
+func (sched *myScheduler) resolveWorkers() {
+	count := func(mType module.Type) int {
+		moduleMap, _ := sched.registrar.GetAllByType(mType)
+		return len(moduleMap)
+	}
+	args := sched.workerArgs
+	atomic.StoreUint32(&sched.workers.download,
+		resolveWorkerNumber(args.DownloadWorkers,
+			count(module.TYPE_DOWNLOADER), autoDownloadWorkersPerModule))
+	atomic.StoreUint32(&sched.workers.analyze,
+		resolveWorkerNumber(args.AnalyzeWorkers,
+			count(module.TYPE_ANALYZER), autoAnalyzeWorkersPerModule))
+	atomic.StoreUint32(&sched.workers.pick,
+		resolveWorkerNumber(args.PipelineWorkers,
+			count(module.TYPE_PIPELINE), autoPipelineWorkersPerModule))
+}
+
+// growWorkers 会在运行时加入组件后，按照新的组件数量增加相应处理流程的工作协程。
+// 只有自动模式的处理流程会增加，工作协程的数量不会因组件被移除而减少。
+func (sched *myScheduler) growWorkers() {
+	sched.workersLock.Lock()
+	defer sched.workersLock.Unlock()
+	if status := sched.Status(); status != SCHED_STATUS_STARTED && status != SCHED_STATUS_PAUSED {
+		return
+	}
+	old := workerNumbers{
+		download: atomic.LoadUint32(&sched.workers.download),
+		analyze:  atomic.LoadUint32(&sched.workers.analyze),
+		pick:     atomic.LoadUint32(&sched.workers.pick),
+	}
+	sched.resolveWorkers()
+	grow := func(name string, number *uint32, oldNumber uint32, worker func()) {
+		newNumber := atomic.LoadUint32(number)
+		if newNumber <= oldNumber {
+			atomic.StoreUint32(number, oldNumber)
+			return
+		}
+		log.Printf("%s工作协程的数量由%d增加到%d", name, oldNumber, newNumber)
+		for i := oldNumber; i < newNumber; i++ {
+			go worker()
+		}
+	}
+	grow("下载", &sched.workers.download, old.download, sched.downloadWorker)
+	grow("解析", &sched.workers.analyze, old.analyze, sched.analyzeWorker)
+	grow("条目处理", &sched.workers.pick, old.pick, sched.pickWorker)
+}
+
+// WorkerSummaryStruct 代表某个处理流程的工作协程的摘要类型。
+type WorkerSummaryStruct struct {
+	// Workers 代表工作协程的数量。
+	Workers uint32 `json:"workers"`
+	// Busy 代表正在处理数据的工作协程的数量。
+	Busy uint64 `json:"busy"`
+}
+
+// WorkersSummaryStruct 代表各处理流程的工作协程的摘要类型。
+type WorkersSummaryStruct struct {
+	Download WorkerSummaryStruct `json:"download"`
+	Analyze  WorkerSummaryStruct `json:"analyze"`
+	Pipeline WorkerSummaryStruct `json:"pipeline"`
+}
+
+// workersSummary 用于获取各处理流程的工作协程的摘要。
+func (sched *myScheduler) workersSummary() WorkersSummaryStruct {
+	return WorkersSummaryStruct{
+		Download: WorkerSummaryStruct{
+			Workers: atomic.LoadUint32(&sched.workers.download),
+			Busy:    atomic.LoadUint64(&sched.downloadingNumber),
+		},
+		Analyze: WorkerSummaryStruct{
+			Workers: atomic.LoadUint32(&sched.workers.analyze),
+			Busy:    atomic.LoadUint64(&sched.analyzingNumber),
+		},
+		Pipeline: WorkerSummaryStruct{
+			Workers: atomic.LoadUint32(&sched.workers.pick),
+			Busy:    atomic.LoadUint64(&sched.pickingNumber),
+		},
+	}
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopcpv2-web-spider/module"
+	"gopcpv2-web-spider/module/local/analyzer"
+)
+
+func TestResolveWorkerNumber(t *testing.T) {
+	cases := []struct {
+		specified    uint32
+		moduleNumber int
+		factor       uint32
+		expected     uint32
+	}{
+		{0, 3, 4, 12},
+		{0, 2, 1, 2},
+		{0, 0, 4, 1},
+		{5, 3, 4, 5},
+		{0, 1000, 4, maxWorkerNumber},
+		{maxWorkerNumber + 1, 1, 1, maxWorkerNumber},
+	}
+	for _, c := range cases {
+		number := resolveWorkerNumber(c.specified, c.moduleNumber, c.factor)
+		if number != c.expected {
+			t.Fatalf("Inconsistent worker number: expected: %d, actual: %d (case: %#v)",
+				c.expected, number, c)
+		}
+	}
+}
+
+// parseLines 代表一个响应解析函数的实现，响应体中的每一行都是一个链接地址。
+func parseLines(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+	defer httpResp.Body.Close()
+	var dataList []module.Data
+	var errs []error
+	scanner := bufio.NewScanner(httpResp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		aURL, err := httpResp.Request.URL.Parse(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		httpReq, err := http.NewRequest("GET", aURL.String(), nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		dataList = append(dataList, module.NewRequest(httpReq, respDepth))
+	}
+	return dataList, errs
+}
+
+func TestSchedWorkers(t *testing.T) {
+	linkNumber := 6
+	var current, maxConcurrent int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				for i := 0; i < linkNumber; i++ {
+					fmt.Fprintf(w, "/page%d\n", i)
+				}
+				return
+			}
+			n := atomic.AddInt32(&current, 1)
+			defer atomic.AddInt32(&current, -1)
+			for {
+				max := atomic.LoadInt32(&maxConcurrent)
+				if n <= max || atomic.CompareAndSwapInt32(&maxConcurrent, max, n) {
+					break
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+		}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{"127.0.0.1"}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 0, 1, t)
+	a, err := analyzer.New("A4", []module.ParseResponse{parseLines}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating an analyzer: %s", err)
+	}
+	moduleArgs.Analyzers = []module.Analyzer{a}
+	moduleArgs.Workers = WorkerArgs{DownloadWorkers: 3, PipelineWorkers: 2}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL, nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	defer sched.Stop()
+	workers := sched.Summary().Struct().Workers
+	expectedWorkers := [3]uint32{3, 1, 2}
+	actualWorkers := [3]uint32{
+		workers.Download.Workers, workers.Analyze.Workers, workers.Pipeline.Workers}
+	if actualWorkers != expectedWorkers {
+		t.Fatalf("Inconsistent worker numbers: expected: %v, actual: %v",
+			expectedWorkers, actualWorkers)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sched.Wait(ctx); err != nil {
+		t.Fatalf("An error occurs when waiting for scheduler: %s", err)
+	}
+	if max := atomic.LoadInt32(&maxConcurrent); max < 2 || max > 3 {
+		t.Fatalf("Inconsistent max concurrent download number: expected: 2~3, actual: %d",
+			max)
+	}
+	workers = sched.Summary().Struct().Workers
+	if workers.Download.Busy != 0 || workers.Analyze.Busy != 0 || workers.Pipeline.Busy != 0 {
+		t.Fatalf("Busy workers after done: %#v", workers)
+	}
+}
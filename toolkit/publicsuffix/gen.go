@@ -0,0 +1,83 @@
+//go:build ignore
+// +build ignore
+
+// gen.go 用于根据完整的公共后缀列表生成rules.go。
+//
+// 用法：
+//
+//	go run gen.go [-src public_suffix_list.dat] [-out rules.go]
+//
+// 参数src为空时会从https://publicsuffix.org/list/public_suffix_list.dat下载。
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// sourceURL 代表公共后缀列表的官方地址。
+const sourceURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+var (
+	src = flag.String("src", "", "公共后缀列表文件的路径，为空时从官方地址下载")
+	out = flag.String("out", "rules.go", "生成的Go源码文件的路径")
+)
+
+func main() {
+	flag.Parse()
+	content, err := readSource(*src)
+	if err != nil {
+		log.Fatalf("读取公共后缀列表失败：%s", err)
+	}
+	text := strings.Replace(string(content), "\r\n", "\n", -1)
+	if strings.Contains(text, "`") {
+		log.Fatal("公共后缀列表中含有反引号，无法生成原始字符串")
+	}
+	for _, marker := range []string{"===BEGIN ICANN DOMAINS===", "===BEGIN PRIVATE DOMAINS==="} {
+		if !strings.Contains(text, marker) {
+			log.Fatalf("公共后缀列表中没有%q，可能不是完整的列表", marker)
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by gen.go; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package publicsuffix")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// embeddedRules 代表内置的公共后缀列表。")
+	fmt.Fprintf(&buf, "// 它是%s的完整副本，使用“go generate”可以重新生成。\n", sourceURL)
+	fmt.Fprintln(&buf, "// 需要更新的列表时也可使用LoadFile函数从本地文件加载。")
+	fmt.Fprintf(&buf, "const embeddedRules = `%s`\n", text)
+	if err := ioutil.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("写入%s失败：%s", *out, err)
+	}
+}
+
+// readSource 用于从本地文件或官方地址读取公共后缀列表。
+func readSource(path string) ([]byte, error) {
+	if path != "" {
+		return ioutil.ReadFile(path)
+	}
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
+
+func init() {
+	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
+}
@@ -0,0 +1,276 @@
+//go:generate go run gen.go
+
+package publicsuffix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Section 代表规则所在的部分。
+type Section uint8
+
+const (
+	// SECTION_NONE 代表没有匹配任何规则，此时按照默认规则“*”以最后一个标签作为公共后缀。
+	SECTION_NONE Section = iota
+	// SECTION_ICANN 代表由ICANN管理的域名部分。
+	SECTION_ICANN
+	// SECTION_PRIVATE 代表由私人组织提交的域名部分，例如“github.io”。
+	SECTION_PRIVATE
+)
+
+func (s Section) String() string {
+	switch s {
+	case SECTION_ICANN:
+		return "ICANN"
+	case SECTION_PRIVATE:
+		return "PRIVATE"
+	}
+	return "NONE"
+}
+
+// 公共后缀列表中标记各部分起止的注释。
+const (
+	beginICANNMarker   = "===BEGIN ICANN DOMAINS==="
+	endICANNMarker     = "===END ICANN DOMAINS==="
+	beginPrivateMarker = "===BEGIN PRIVATE DOMAINS==="
+	endPrivateMarker   = "===END PRIVATE DOMAINS==="
+)
+
+// ErrEmptyDomain 是表示域名为空的错误的变量。
+var ErrEmptyDomain = errors.New("域名为空")
+
+// List 代表公共后缀列表。它是并发安全的。
+type List struct {
+	// rules 代表普通规则，键为规则本身。
+	rules map[string]Section
+	// wildcards 代表通配规则，键为去掉“*.”后的部分。
+	wildcards map[string]Section
+	// exceptions 代表例外规则，键为去掉“!”后的部分。
+	exceptions map[string]Section
+}
+
+// Parse 用于从给定的读取器中解析公共后缀列表，格式见https://publicsuffix.org/list/。
+// 规则中的国际化域名会被转换为Punycode形式。
+func Parse(r io.Reader) (*List, error) {
+	list := &List{
+		rules:      map[string]Section{},
+		wildcards:  map[string]Section{},
+		exceptions: map[string]Section{},
+	}
+	section := SECTION_ICANN
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			switch {
+			case strings.Contains(line, beginICANNMarker):
+				section = SECTION_ICANN
+			case strings.Contains(line, beginPrivateMarker):
+				section = SECTION_PRIVATE
+			case strings.Contains(line, endICANNMarker),
+				strings.Contains(line, endPrivateMarker):
+				section = SECTION_ICANN
+			}
+			continue
+		}
+		// 规则在第一个空白字符处结束。
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		if line == "" {
+			continue
+		}
+		if err := list.addRule(line, section); err != nil {
+			return nil, fmt.Errorf("无效的公共后缀规则（第%d行）：%s", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list.rules)+len(list.wildcards)+len(list.exceptions) == 0 {
+		return nil, errors.New("公共后缀列表中没有规则")
+	}
+	return list, nil
+}
+
+// addRule 用于添加一条规则。
+func (list *List) addRule(rule string, section Section) error {
+	rules := list.rules
+	switch {
+	case strings.HasPrefix(rule, "!"):
+		rules = list.exceptions
+		rule = rule[1:]
+	case strings.HasPrefix(rule, "*."):
+		rules = list.wildcards
+		rule = rule[2:]
+	}
+	rule, err := ToASCII(rule)
+	if err != nil {
+		return err
+	}
+	if rule == "" || strings.Contains(rule, "*") || strings.Contains(rule, "..") {
+		return fmt.Errorf("%q", rule)
+	}
+	rules[rule] = section
+	return nil
+}
+
+// Len 用于获取规则的数量。
+func (list *List) Len() int {
+	return len(list.rules) + len(list.wildcards) + len(list.exceptions)
+}
+
+// PublicSuffix 用于获取给定域名的公共后缀及匹配的规则所在的部分。
+// 给定的域名需要是已规范化的，见Normalize函数。
+func (list *List) PublicSuffix(domain string) (suffix string, section Section) {
+	labels := strings.Split(domain, ".")
+	// 例外规则优先，它代表的公共后缀是规则去掉最左边的标签。
+	for i := 0; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".")
+		if section, ok := list.exceptions[candidate]; ok {
+			return strings.Join(labels[i+1:], "."), section
+		}
+	}
+	// 否则标签最多的规则胜出。
+	for i := 0; i < len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+		if section, ok := list.rules[candidate]; ok {
+			return candidate, section
+		}
+		if i+1 < len(labels) {
+			if section, ok := list.wildcards[strings.Join(labels[i+1:], ".")]; ok {
+				return candidate, section
+			}
+		}
+	}
+	return labels[len(labels)-1], SECTION_NONE
+}
+
+// EffectiveTLDPlusOne 用于获取给定域名的公共后缀再加上一个标签，即可注册的域名。
+// 给定的域名需要是已规范化的，见Normalize函数。
+// 当给定的域名本身就是公共后缀时会返回错误。
+func (list *List) EffectiveTLDPlusOne(domain string) (string, Section, error) {
+	if domain == "" {
+		return "", SECTION_NONE, ErrEmptyDomain
+	}
+	if strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") ||
+		strings.Contains(domain, "..") {
+		return "", SECTION_NONE, fmt.Errorf("域名中有空的标签：%q", domain)
+	}
+	suffix, section := list.PublicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return "", section, fmt.Errorf("域名本身是公共后缀：%q", domain)
+	}
+	i := strings.LastIndex(domain[:len(domain)-len(suffix)-1], ".")
+	return domain[i+1:], section, nil
+}
+
+// Normalize 用于规范化给定的主机名：
+// 去掉空白、端口、IPv6地址的方括号和结尾的“.”，
+// 并把国际化域名转换为小写的Punycode形式。
+// 结果值isIP代表主机名是否为IP地址。
+func Normalize(host string) (domain string, isIP bool, err error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return "", false, ErrEmptyDomain
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return ip.String(), true, nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if ip := net.ParseIP(host); ip != nil {
+			return ip.String(), true, nil
+		}
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", false, ErrEmptyDomain
+	}
+	domain, err = ToASCII(host)
+	if err != nil {
+		return "", false, err
+	}
+	return domain, false, nil
+}
+
+// defaultList 代表默认的公共后缀列表。
+var defaultList *List
+
+// defaultLock 代表保护默认的公共后缀列表的锁。
+var defaultLock sync.RWMutex
+
+func init() {
+	list, err := Parse(strings.NewReader(embeddedRules))
+	if err != nil {
+		panic(fmt.Sprintf("无效的内置公共后缀列表：%s", err))
+	}
+	defaultList = list
+}
+
+// Default 用于获取默认的公共后缀列表。
+func Default() *List {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultList
+}
+
+// SetDefault 用于替换默认的公共后缀列表。
+func SetDefault(list *List) {
+	if list == nil {
+		return
+	}
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultList = list
+}
+
+// LoadFile 用于从给定的本地文件中解析公共后缀列表，并以它替换默认的公共后缀列表。
+// 完整的列表可从https://publicsuffix.org/list/public_suffix_list.dat下载。
+func LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	list, err := Parse(file)
+	if err != nil {
+		return err
+	}
+	SetDefault(list)
+	return nil
+}
+
+// PublicSuffix 用于按照默认的公共后缀列表获取给定主机名的公共后缀。
+func PublicSuffix(host string) (string, Section, error) {
+	domain, isIP, err := Normalize(host)
+	if err != nil {
+		return "", SECTION_NONE, err
+	}
+	if isIP {
+		return "", SECTION_NONE, fmt.Errorf("主机名是IP地址：%q", host)
+	}
+	suffix, section := Default().PublicSuffix(domain)
+	return suffix, section, nil
+}
+
+// EffectiveTLDPlusOne 用于按照默认的公共后缀列表获取给定主机名的可注册的域名。
+func EffectiveTLDPlusOne(host string) (string, Section, error) {
+	domain, isIP, err := Normalize(host)
+	if err != nil {
+		return "", SECTION_NONE, err
+	}
+	if isIP {
+		return "", SECTION_NONE, fmt.Errorf("主机名是IP地址：%q", host)
+	}
+	return Default().EffectiveTLDPlusOne(domain)
+}
@@ -0,0 +1,208 @@
+package publicsuffix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveTLDPlusOne(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+		section  Section
+	}{
+		{"cn.bing.com", "bing.com", SECTION_ICANN},
+		{"bing.com", "bing.com", SECTION_ICANN},
+		{"www.foo.co.uk", "foo.co.uk", SECTION_ICANN},
+		{"foo.co.uk", "foo.co.uk", SECTION_ICANN},
+		{"a.b.x.com.cn", "x.com.cn", SECTION_ICANN},
+		{"www.go.dev", "go.dev", SECTION_ICANN},
+		{"my.site.app", "site.app", SECTION_ICANN},
+		{"a.github.io", "a.github.io", SECTION_PRIVATE},
+		{"x.a.github.io", "a.github.io", SECTION_PRIVATE},
+		{"foo.s3.amazonaws.com", "foo.s3.amazonaws.com", SECTION_PRIVATE},
+		{"www.amazonaws.com", "amazonaws.com", SECTION_ICANN},
+		// 通配规则与例外规则。
+		{"a.b.ck", "a.b.ck", SECTION_ICANN},
+		{"www.ck", "www.ck", SECTION_ICANN},
+		{"a.www.ck", "www.ck", SECTION_ICANN},
+		{"a.b.kawasaki.jp", "a.b.kawasaki.jp", SECTION_ICANN},
+		{"city.kawasaki.jp", "city.kawasaki.jp", SECTION_ICANN},
+		{"www.city.kawasaki.jp", "city.kawasaki.jp", SECTION_ICANN},
+		// 国际化域名、端口、大小写和结尾的“.”。
+		{"www.例子.中国", "xn--fsqu00a.xn--fiqs8s", SECTION_ICANN},
+		{"www.xn--fsqu00a.xn--fiqs8s", "xn--fsqu00a.xn--fiqs8s", SECTION_ICANN},
+		{"WWW.Example.COM:8080", "example.com", SECTION_ICANN},
+		{"www.example.com.", "example.com", SECTION_ICANN},
+		// 内置的是完整的列表，其中包括各个国家和地区顶级域名。
+		{"www.example.se", "example.se", SECTION_ICANN},
+		{"www.example.pl", "example.pl", SECTION_ICANN},
+		{"www.example.com.pl", "example.com.pl", SECTION_ICANN},
+		{"www.example.cz", "example.cz", SECTION_ICANN},
+		{"www.example.dk", "example.dk", SECTION_ICANN},
+		{"www.example.fi", "example.fi", SECTION_ICANN},
+		{"www.example.pt", "example.pt", SECTION_ICANN},
+		{"www.123.abc", "123.abc", SECTION_ICANN},
+		// 未列出的顶级域名按照默认规则“*”处理。
+		{"www.123.notatld", "123.notatld", SECTION_NONE},
+	}
+	for _, c := range cases {
+		actual, section, err := EffectiveTLDPlusOne(c.host)
+		if err != nil {
+			t.Fatalf("An error occurs when getting eTLD+1: %s (host: %s)", err, c.host)
+		}
+		if actual != c.expected {
+			t.Fatalf("Inconsistent eTLD+1 of %q: expected: %s, actual: %s",
+				c.host, c.expected, actual)
+		}
+		if section != c.section {
+			t.Fatalf("Inconsistent section of %q: expected: %s, actual: %s",
+				c.host, c.section, section)
+		}
+	}
+	invalidHosts := []string{
+		"", " ", "com", "co.uk", "github.io", "b.ck", "b.kawasaki.jp",
+		"a..com", ".com", "127.0.0.1", "[::1]:80",
+	}
+	for _, host := range invalidHosts {
+		if pd, _, err := EffectiveTLDPlusOne(host); err == nil {
+			t.Fatalf("No error when getting eTLD+1 of %q: %s", host, pd)
+		}
+	}
+}
+
+func TestPublicSuffix(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+		section  Section
+	}{
+		{"www.foo.co.uk", "co.uk", SECTION_ICANN},
+		{"a.github.io", "github.io", SECTION_PRIVATE},
+		{"a.b.ck", "b.ck", SECTION_ICANN},
+		{"www.ck", "ck", SECTION_ICANN},
+		{"a.example.unknown", "unknown", SECTION_NONE},
+	}
+	for _, c := range cases {
+		actual, section, err := PublicSuffix(c.host)
+		if err != nil {
+			t.Fatalf("An error occurs when getting public suffix: %s (host: %s)", err, c.host)
+		}
+		if actual != c.expected || section != c.section {
+			t.Fatalf("Inconsistent public suffix of %q: expected: %s (%s), actual: %s (%s)",
+				c.host, c.expected, c.section, actual, section)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+		isIP     bool
+	}{
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1:8080", "127.0.0.1", true},
+		{"::1", "::1", true},
+		{"[::1]", "::1", true},
+		{"[2001:DB8::1]:443", "2001:db8::1", true},
+		{" Example.COM. ", "example.com", false},
+		{"example.com:80", "example.com", false},
+		{"Bücher.de", "xn--bcher-kva.de", false},
+	}
+	for _, c := range cases {
+		actual, isIP, err := Normalize(c.host)
+		if err != nil {
+			t.Fatalf("An error occurs when normalizing host: %s (host: %s)", err, c.host)
+		}
+		if actual != c.expected || isIP != c.isIP {
+			t.Fatalf("Inconsistent normalized host of %q: expected: %s (IP: %v), actual: %s (IP: %v)",
+				c.host, c.expected, c.isIP, actual, isIP)
+		}
+	}
+	if _, _, err := Normalize(""); err == nil {
+		t.Fatal("No error when normalizing empty host!")
+	}
+}
+
+func TestParse(t *testing.T) {
+	rules := `// 注释
+example
+*.wild.example
+!safe.wild.example
+例子 多余的内容
+// ===BEGIN PRIVATE DOMAINS===
+hosted.example
+// ===END PRIVATE DOMAINS===
+`
+	list, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing rules: %s", err)
+	}
+	if list.Len() != 5 {
+		t.Fatalf("Inconsistent rule number: expected: %d, actual: %d", 5, list.Len())
+	}
+	cases := []struct {
+		domain  string
+		suffix  string
+		section Section
+	}{
+		{"a.example", "example", SECTION_ICANN},
+		{"a.b.wild.example", "b.wild.example", SECTION_ICANN},
+		{"a.safe.wild.example", "wild.example", SECTION_ICANN},
+		{"a.xn--fsqu00a", "xn--fsqu00a", SECTION_ICANN},
+		{"a.hosted.example", "hosted.example", SECTION_PRIVATE},
+		{"a.com", "com", SECTION_NONE},
+	}
+	for _, c := range cases {
+		suffix, section := list.PublicSuffix(c.domain)
+		if suffix != c.suffix || section != c.section {
+			t.Fatalf("Inconsistent public suffix of %q: expected: %s (%s), actual: %s (%s)",
+				c.domain, c.suffix, c.section, suffix, section)
+		}
+	}
+	invalidRules := []string{"", "// 只有注释\n", "a.*.example\n", "a..example\n"}
+	for _, rules := range invalidRules {
+		if _, err := Parse(strings.NewReader(rules)); err == nil {
+			t.Fatalf("No error when parsing invalid rules %q!", rules)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publicsuffix")
+	if err != nil {
+		t.Fatalf("An error occurs when creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	original := Default()
+	defer SetDefault(original)
+	path := filepath.Join(dir, "public_suffix_list.dat")
+	if err := LoadFile(path); err == nil {
+		t.Fatal("No error when loading nonexistent file!")
+	}
+	if Default() != original {
+		t.Fatal("The default list is replaced after a failed load!")
+	}
+	if err := ioutil.WriteFile(path, []byte("com\nabc\n"), 0644); err != nil {
+		t.Fatalf("An error occurs when writing file: %s", err)
+	}
+	if err := LoadFile(path); err != nil {
+		t.Fatalf("An error occurs when loading file: %s", err)
+	}
+	pd, section, err := EffectiveTLDPlusOne("www.123.abc")
+	if err != nil {
+		t.Fatalf("An error occurs when getting eTLD+1: %s", err)
+	}
+	if pd != "123.abc" || section != SECTION_ICANN {
+		t.Fatalf("Inconsistent eTLD+1: expected: %s (%s), actual: %s (%s)",
+			"123.abc", SECTION_ICANN, pd, section)
+	}
+	// 新的列表中没有“co.uk”。
+	if pd, _, _ := EffectiveTLDPlusOne("www.foo.co.uk"); pd != "co.uk" {
+		t.Fatalf("Inconsistent eTLD+1: expected: %s, actual: %s", "co.uk", pd)
+	}
+}
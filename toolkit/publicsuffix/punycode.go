@@ -0,0 +1,230 @@
+package publicsuffix
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode的参数，见RFC 3492第5节。
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+	// acePrefix 代表经过Punycode编码的标签的前缀。
+	acePrefix = "xn--"
+)
+
+// labelSeparators 代表国际化域名中与“.”等价的分隔符。
+var labelSeparators = strings.NewReplacer(
+	"。", ".", // 表意文字句号
+	"．", ".", // 全角句号
+	"｡", ".", // 半角表意文字句号
+)
+
+// ToASCII 用于把给定的域名转换为小写的ASCII形式，
+// 含有非ASCII字符的标签会被编码为以“xn--”开头的Punycode。
+func ToASCII(domain string) (string, error) {
+	domain = labelSeparators.Replace(domain)
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		label = strings.ToLower(label)
+		if isASCII(label) {
+			labels[i] = label
+			continue
+		}
+		encoded, err := encodePunycode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = acePrefix + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode 用于把给定域名中以“xn--”开头的标签解码为Unicode形式。
+func ToUnicode(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(strings.ToLower(label), acePrefix) {
+			continue
+		}
+		decoded, err := decodePunycode(label[len(acePrefix):])
+		if err != nil {
+			return "", err
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// isASCII 用于判断给定的字符串是否只含有ASCII字符。
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// adaptBias 用于调整偏差值，见RFC 3492第6.1节。
+func adaptBias(delta, numPoints int32, firstTime bool) int32 {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := int32(0)
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+// threshold 用于计算给定位置的阈值。
+func threshold(k, bias int32) int32 {
+	switch {
+	case k <= bias:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	}
+	return k - bias
+}
+
+// encodeDigit 用于获取给定数字对应的字符。
+func encodeDigit(digit int32) byte {
+	if digit < 26 {
+		return byte('a' + digit)
+	}
+	return byte('0' + digit - 26)
+}
+
+// decodeDigit 用于获取给定字符对应的数字。
+func decodeDigit(c byte) (int32, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int32(c-'0') + 26, true
+	case 'a' <= c && c <= 'z':
+		return int32(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int32(c - 'A'), true
+	}
+	return 0, false
+}
+
+// encodePunycode 用于把给定的标签编码为Punycode，见RFC 3492第6.3节。
+func encodePunycode(label string) (string, error) {
+	if !utf8.ValidString(label) {
+		return "", fmt.Errorf("无效的UTF-8标签：%q", label)
+	}
+	runes := []rune(label)
+	var buf strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			buf.WriteByte(byte(r))
+		}
+	}
+	basicNumber := int32(buf.Len())
+	handled := basicNumber
+	if basicNumber > 0 {
+		buf.WriteByte('-')
+	}
+	n, delta, bias := int32(punycodeInitialN), int32(0), int32(punycodeInitialBias)
+	for handled < int32(len(runes)) {
+		// 找出尚未处理的最小的码点。
+		m := int32(utf8.MaxRune + 1)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		if delta < 0 {
+			return "", fmt.Errorf("Punycode编码溢出：%q", label)
+		}
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+				continue
+			}
+			if r > n {
+				continue
+			}
+			q := delta
+			for k := int32(punycodeBase); ; k += punycodeBase {
+				t := threshold(k, bias)
+				if q < t {
+					break
+				}
+				buf.WriteByte(encodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			buf.WriteByte(encodeDigit(q))
+			bias = adaptBias(delta, handled+1, handled == basicNumber)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return buf.String(), nil
+}
+
+// decodePunycode 用于解码给定的Punycode，见RFC 3492第6.2节。
+func decodePunycode(encoded string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndex(encoded, "-"); i >= 0 {
+		for j := 0; j < i; j++ {
+			if encoded[j] >= utf8.RuneSelf {
+				return "", fmt.Errorf("无效的Punycode：%q", encoded)
+			}
+			output = append(output, rune(encoded[j]))
+		}
+		pos = i + 1
+	}
+	n, i, bias := int32(punycodeInitialN), int32(0), int32(punycodeInitialBias)
+	for pos < len(encoded) {
+		oldI, w := i, int32(1)
+		for k := int32(punycodeBase); ; k += punycodeBase {
+			if pos >= len(encoded) {
+				return "", fmt.Errorf("无效的Punycode：%q", encoded)
+			}
+			digit, ok := decodeDigit(encoded[pos])
+			pos++
+			if !ok {
+				return "", fmt.Errorf("无效的Punycode：%q", encoded)
+			}
+			i += digit * w
+			if i < 0 {
+				return "", fmt.Errorf("Punycode解码溢出：%q", encoded)
+			}
+			t := threshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punycodeBase - t
+		}
+		length := int32(len(output) + 1)
+		bias = adaptBias(i-oldI, length, oldI == 0)
+		n += i / length
+		i %= length
+		if n > utf8.MaxRune {
+			return "", fmt.Errorf("无效的Punycode：%q", encoded)
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = n
+		i++
+	}
+	return string(output), nil
+}
@@ -0,0 +1,56 @@
+package publicsuffix
+
+import "testing"
+
+func TestPunycode(t *testing.T) {
+	cases := []struct {
+		unicode string
+		ascii   string
+	}{
+		{"中国", "xn--fiqs8s"},
+		{"公司", "xn--55qx5d"},
+		{"рф", "xn--p1ai"},
+		{"bücher", "xn--bcher-kva"},
+		{"münchen", "xn--mnchen-3ya"},
+		{"例子.中国", "xn--fsqu00a.xn--fiqs8s"},
+		{"www.example.com", "www.example.com"},
+		// RFC 3492第7.1节中的例子。
+		{"他们为什么不说中文", "xn--ihqwcrb4cv8a8dqg056pqjye"},
+		{"3年b組金八先生", "xn--3b-ww4c5e180e575a65lsy2b"},
+	}
+	for _, c := range cases {
+		ascii, err := ToASCII(c.unicode)
+		if err != nil {
+			t.Fatalf("An error occurs when converting %q to ASCII: %s", c.unicode, err)
+		}
+		if ascii != c.ascii {
+			t.Fatalf("Inconsistent ASCII form of %q: expected: %s, actual: %s",
+				c.unicode, c.ascii, ascii)
+		}
+		unicode, err := ToUnicode(ascii)
+		if err != nil {
+			t.Fatalf("An error occurs when converting %q to Unicode: %s", ascii, err)
+		}
+		if unicode != c.unicode {
+			t.Fatalf("Inconsistent Unicode form of %q: expected: %s, actual: %s",
+				ascii, c.unicode, unicode)
+		}
+	}
+	ascii, err := ToASCII("WWW.Bücher.DE")
+	if err != nil {
+		t.Fatalf("An error occurs when converting to ASCII: %s", err)
+	}
+	if expected := "www.xn--bcher-kva.de"; ascii != expected {
+		t.Fatalf("Inconsistent ASCII form: expected: %s, actual: %s", expected, ascii)
+	}
+	ascii, err = ToASCII("例子。中国")
+	if err != nil {
+		t.Fatalf("An error occurs when converting to ASCII: %s", err)
+	}
+	if expected := "xn--fsqu00a.xn--fiqs8s"; ascii != expected {
+		t.Fatalf("Inconsistent ASCII form: expected: %s, actual: %s", expected, ascii)
+	}
+	if _, err := ToUnicode("xn--a!b"); err == nil {
+		t.Fatal("No error when decoding invalid punycode!")
+	}
+}